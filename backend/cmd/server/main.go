@@ -1,8 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	// 核心模块
@@ -11,6 +19,7 @@ import (
 	// 内部包
 	"app-platform-backend/internal/api/v1/admin"
 	"app-platform-backend/internal/api/v1/app"
+	"app-platform-backend/internal/api/v1/health"
 	moduleapi "app-platform-backend/internal/api/v1/module"
 	statsapi "app-platform-backend/internal/api/v1/stats"
 	"app-platform-backend/internal/api/v1/system"
@@ -20,26 +29,60 @@ import (
 	"app-platform-backend/internal/config"
 	"app-platform-backend/internal/middleware"
 	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/secret"
 	"app-platform-backend/internal/scheduler"
 
 	// 导入所有功能模块（通过 import 的副作用触发模块注册）
 	_ "app-platform-backend/modules"
+	"app-platform-backend/modules/remote"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 命令行参数
+	syncDryRun := flag.Bool("sync-dry-run", false, "只预览模块功能同步差异，不写入数据库，输出报告后退出")
+	migrateCmd := flag.String("migrate", "", "执行数据库迁移命令后退出：status | plan | up | down")
+	migrateModule := flag.String("migrate-module", "", "down 时只回滚指定模块的迁移")
+	migrateSteps := flag.Int("migrate-steps", 1, "down 时回滚的迁移数量")
+	reencrypt := flag.Bool("reencrypt", false, "用当前主密钥重新加密所有敏感数据（轮换主密钥后执行），输出统计后退出")
+	flag.Parse()
+
 	// 加载配置
 	cfg, err := config.LoadConfig("./configs/config.yaml")
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// 初始化敏感数据加密的主密钥
+	if err := secret.Init(&cfg.Encryption); err != nil {
+		log.Fatalf("Failed to init encryption keys: %v", err)
+	}
+
 	// 初始化数据库
 	if err := database.InitDB(&cfg.Database); err != nil {
 		log.Fatalf("Failed to init database: %v", err)
 	}
-	defer database.Close()
+
+	// 数据库迁移：命令行模式执行后退出，否则在启动时自动执行
+	migrator := module.NewMigrationRunner(database.GetDB())
+	if *migrateCmd != "" {
+		runMigrateCommand(migrator, *migrateCmd, *migrateModule, *migrateSteps)
+		database.Close()
+		os.Exit(0)
+	}
+	if !cfg.Database.SkipMigrate {
+		if _, err := migrator.Up(); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
+	// 重新加密：命令行模式执行后退出
+	if *reencrypt {
+		runReencryptCommand()
+		database.Close()
+		os.Exit(0)
+	}
 
 	// 初始化JWT
 	middleware.InitJWT(&cfg.JWT)
@@ -48,14 +91,12 @@ func main() {
 	middleware.InitAuditDB(database.GetDB())
 	log.Println("[Main] Audit logging initialized")
 
-	// 初始化并启动审计日志清理调度器
-	auditCleanupScheduler := scheduler.InitAuditCleanupScheduler(database.GetDB(), scheduler.AuditCleanupConfig{
-		RetentionDays: 90,  // 保留最近90天的日志
-		CleanupHour:   3,   // 每天凌晨3点执行清理
-		BatchSize:     1000, // 每批删除1000条
+	// 初始化审计日志清理服务，由审计模块注册为定时任务
+	scheduler.InitAuditCleanup(database.GetDB(), scheduler.AuditCleanupConfig{
+		RetentionDays: 90,          // 保留最近90天的日志
+		Schedule:      "0 3 * * *", // 每天凌晨3点执行清理
+		BatchSize:     1000,        // 每批删除1000条
 	})
-	auditCleanupScheduler.Start()
-	log.Println("[Main] Audit log cleanup scheduler started (retention: 90 days, cleanup at 03:00)")
 
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
@@ -78,7 +119,10 @@ func main() {
 	// ========================================
 	log.Println("[Main] Starting modular architecture initialization...")
 
-	// 1. 初始化所有模块
+	// 1. 注册配置中声明的远程模块，然后初始化所有模块
+	if err := remote.RegisterAll(cfg.RemoteModules); err != nil {
+		log.Fatalf("Failed to register remote modules: %v", err)
+	}
 	if err := module.InitAllModules(); err != nil {
 		log.Fatalf("Failed to init modules: %v", err)
 	}
@@ -91,14 +135,48 @@ func main() {
 
 		// 3. 同步模块功能到数据库
 		syncer := module.NewSyncer(database.GetDB())
+	if *syncDryRun {
+		result, err := syncer.Sync(module.SyncOptions{DryRun: true})
+		if err != nil {
+			log.Fatalf("Failed to preview module sync: %v", err)
+		}
+		report, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(report))
+		database.Close()
+		os.Exit(0)
+	}
 	if err := syncer.SyncModulesToDB(); err != nil {
 		log.Fatalf("Failed to sync modules to database: %v", err)
 	}
 
+	// 4. 加载模块全局启用状态，停用的模块不提供路由、不执行任务、不接收事件
+	if err := module.InitStates(database.GetDB(), module.DefaultStateTTL); err != nil {
+		log.Fatalf("Failed to load module states: %v", err)
+	}
+
+	// 5. 初始化任务调度器并注册模块声明的定时任务
+	jobScheduler, err := scheduler.Init(database.GetDB())
+	if err != nil {
+		log.Fatalf("Failed to init job scheduler: %v", err)
+	}
+	// 多实例部署时通过数据库租约选出唯一执行任务的实例
+	elector, err := scheduler.NewLeaderElector(database.GetDB(), "scheduler", scheduler.DefaultLeaseTTL)
+	if err != nil {
+		log.Fatalf("Failed to init scheduler leader election: %v", err)
+	}
+	jobScheduler.UseLeaderElection(elector)
+	log.Printf("[Main] Scheduler instance id: %s", elector.ID())
+	for _, job := range module.GetAllJobs() {
+		if err := jobScheduler.Register(job); err != nil {
+			log.Fatalf("Failed to register job %s: %v", job.Name, err)
+		}
+	}
+
 	// ========================================
 	// API路由组
 	// ========================================
 	v1 := r.Group("/api/v1")
+	var remoteModules []*remote.RemoteModule
 	{
 // 公开接口（无需认证）
 				// 登录接口使用更严格的限流 (5次/分钟/IP，防止暴力破解)
@@ -110,10 +188,36 @@ func main() {
 			// WebSocket连接端点（无需JWT认证，通过URL参数传递token）
 			v1.GET("/ws", wsapi.HandleWebSocket)
 
+		// 客户端 SDK 接口（APP签名认证，无需管理员登录）
+		sdk := v1.Group("/sdk")
+		sdk.Use(middleware.AppSignatureMiddleware(database.GetDB()))
+		for _, m := range module.GetAllModules() {
+			provider, ok := m.(module.SDKRouteProvider)
+			if !ok {
+				continue
+			}
+			meta := m.Meta()
+			group := sdk.Group("")
+			group.Use(middleware.ModuleStateMiddleware(meta.Code))
+			if !meta.Global {
+				group.Use(middleware.ModuleGateMiddleware(meta.Code))
+			}
+			provider.RegisterSDKRoutes(group)
+		}
+
 		// 需要认证的接口
 		auth := v1.Group("")
 		auth.Use(middleware.AuthMiddleware())
 		auth.Use(middleware.AuditMiddleware()) // 审计日志中间件
+		// 模块被全局停用时路由直接拒绝；非全局模块还需校验APP是否启用了该模块
+		moduleRoutes := func(meta module.Meta) *gin.RouterGroup {
+			group := auth.Group("")
+			group.Use(middleware.ModuleStateMiddleware(meta.Code))
+			if !meta.Global {
+				group.Use(middleware.ModuleGateMiddleware(meta.Code))
+			}
+			return group
+		}
 		{
 			// 管理员相关
 			adminGroup := auth.Group("/admin")
@@ -178,29 +282,53 @@ func main() {
 				appGroup.GET("/:id/modules/:module_code/config/history", moduleapi.GetConfigHistory)
 				appGroup.POST("/:id/modules/:module_code/config/rollback/:history_id", moduleapi.RollbackConfig)
 				appGroup.GET("/:id/modules/:module_code/config/compare", moduleapi.CompareConfig)
+				// 将模块配置从一个环境晋级到另一个环境
+				appGroup.POST("/:id/modules/:module_code/config/promote", moduleapi.PromoteModuleConfig)
+
+				// 配置变更审批
+				appGroup.GET("/:id/config-approval", moduleapi.GetApprovalPolicy)
+				appGroup.PUT("/:id/config-approval", moduleapi.SetApprovalPolicy)
+				appGroup.POST("/:id/modules/:module_code/config/requests", moduleapi.SubmitConfigChange)
+				appGroup.GET("/:id/config-requests", moduleapi.ListConfigChanges)
+				appGroup.GET("/:id/config-requests/:request_id", moduleapi.GetConfigChange)
+				appGroup.POST("/:id/config-requests/:request_id/comments", moduleapi.CommentConfigChange)
+				appGroup.POST("/:id/config-requests/:request_id/approve", moduleapi.ApproveConfigChange)
+				appGroup.POST("/:id/config-requests/:request_id/reject", moduleapi.RejectConfigChange)
+				appGroup.POST("/:id/config-requests/:request_id/cancel", moduleapi.CancelConfigChange)
+				appGroup.POST("/:id/config-requests/:request_id/apply", moduleapi.ApplyConfigChange)
 
 				// 模块依赖管理
 				appGroup.GET("/:id/modules/:module_code/dependencies/check", moduleapi.CheckModuleDependencies)
 				appGroup.GET("/:id/modules/:module_code/dependencies/reverse", moduleapi.CheckModuleReverseDependencies)
 				appGroup.POST("/:id/modules/:module_code/dependencies/auto-enable", moduleapi.AutoEnableModuleDependencies)
 
-				// 批量配置导入导出 (暂时禁用)
-				// appGroup.POST("/:id/config/export", moduleapi.ExportConfig)
-				// appGroup.POST("/:id/config/import", moduleapi.ImportConfig)
-				// appGroup.POST("/:id/config/import/preview", moduleapi.PreviewImportConfig)
+				// 批量配置导入导出
+				appGroup.POST("/:id/config/export", moduleapi.ExportConfig)
+				appGroup.POST("/:id/config/import", moduleapi.ImportConfig)
+				appGroup.POST("/:id/config/import/preview", moduleapi.PreviewImportConfig)
 			}
 
 			// ========================================
 			// 模块化架构：动态注册模块路由
 			// ========================================
 			log.Println("[Main] Registering module routes...")
+			middleware.InitModuleGate(database.GetDB(), middleware.DefaultModuleGateTTL)
+			module.Subscribe("module_gate.invalidate", func(ctx context.Context, e module.AppDeletedEvent) error {
+				middleware.InvalidateModuleGateApp(e.AppID)
+				return nil
+			})
 			modules := module.GetAllModules()
 			for _, m := range modules {
+				// 远程模块的通配路由在其余路由注册完成后再挂载
+				if rm, ok := m.(*remote.RemoteModule); ok {
+					remoteModules = append(remoteModules, rm)
+					continue
+				}
 				meta := m.Meta()
 				log.Printf("[Main] Registering routes for module: %s (%s)", meta.Code, meta.Name)
-				m.RegisterRoutes(auth)
+				m.RegisterRoutes(moduleRoutes(meta))
 			}
-			log.Printf("[Main] %d module routes registered", len(modules))
+			log.Printf("[Main] %d module routes registered", len(modules)-len(remoteModules))
 
 // 系统级API管理
 				apiGroup := auth.Group("/system-apis")
@@ -216,6 +344,34 @@ func main() {
 				moduleGroup.GET("/templates", moduleapi.GetAllTemplates)
 				moduleGroup.GET("/dependencies/detect/:module_code", moduleapi.DetectCircularDependency)
 			}
+
+			// 模块功能同步（支持 dry_run 预览），GET 返回本实例最近一次同步的结果
+			auth.POST("/system/modules/sync", system.SyncModules)
+			auth.GET("/system/modules/sync", system.LastModuleSync)
+
+			// 模块全局启用/停用
+			auth.POST("/system/modules/:code/enable", system.EnableModule)
+			auth.POST("/system/modules/:code/disable", system.DisableModule)
+
+			// 事件总线订阅者统计
+			auth.GET("/system/events/stats", system.EventBusStats)
+
+			// 定时任务管理
+			auth.GET("/system/jobs", system.ListJobs)
+			auth.GET("/system/jobs/:name", system.GetJob)
+			auth.POST("/system/jobs/:name/pause", system.PauseJob)
+			auth.POST("/system/jobs/:name/resume", system.ResumeJob)
+			auth.POST("/system/jobs/:name/trigger", system.TriggerJob)
+		}
+
+		// 远程模块挂载 /<code>/*path，先检查前缀是否已被其他路由占用，避免 gin 注册时 panic
+		for _, m := range remoteModules {
+			if err := m.CheckRoutes(r.Routes(), v1.BasePath()); err != nil {
+				log.Fatalf("Failed to register remote module routes: %v", err)
+			}
+			meta := m.Meta()
+			log.Printf("[Main] Registering routes for remote module: %s (%s)", meta.Code, meta.Name)
+			m.RegisterRoutes(moduleRoutes(meta))
 		}
 	}
 
@@ -223,48 +379,127 @@ func main() {
 	r.Static("/uploads", "./uploads")
 
 	// 健康检查
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":       "ok",
-			"modules":      module.GetModuleCount(),
-			"architecture": "modular",
-		})
-	})
+	r.GET("/health", health.Check)
+	r.GET("/health/live", health.Liveness)
+	r.GET("/health/ready", health.Readiness)
 
 	// 模块信息接口（用于调试）
-	r.GET("/api/v1/system/modules", func(c *gin.Context) {
-		modules := module.GetAllModules()
-		result := make([]gin.H, 0, len(modules))
-		for _, m := range modules {
-			meta := m.Meta()
-			functions := m.GetFunctions()
-			funcList := make([]gin.H, 0, len(functions))
-			for _, fn := range functions {
-				funcList = append(funcList, gin.H{
-					"code":        fn.Code,
-					"name":        fn.Name,
-					"type":        fn.Type,
-					"description": fn.Description,
-				})
-			}
-			result = append(result, gin.H{
-				"code":        meta.Code,
-				"name":        meta.Name,
-				"description": meta.Description,
-				"icon":        meta.Icon,
-				"functions":   funcList,
-			})
-		}
-		c.JSON(200, gin.H{
-			"total":   len(modules),
-			"modules": result,
-		})
-	})
+	r.GET("/api/v1/system/modules", system.ListModules)
+
+	// 监听 SIGINT/SIGTERM，用于触发优雅关闭
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 启动实现了 Starter 接口的模块
+	if err := module.StartAllModules(ctx); err != nil {
+		log.Fatalf("Failed to start modules: %v", err)
+	}
+	jobScheduler.Start()
 
 	// 启动服务器
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
-	log.Printf("Server starting on %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      r,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
+	}
+
+	go func() {
+		log.Printf("Server starting on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("[Main] Shutdown signal received, shutting down gracefully...")
+
+	shutdownTimeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 30 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 1. 停止接收新请求，等待处理中的请求完成
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[Main] HTTP server shutdown error: %v", err)
+	}
+	log.Println("[Main] HTTP server stopped")
+
+	// 2. 停止任务调度器，等待运行中的任务结束
+	if err := jobScheduler.Stop(shutdownCtx); err != nil {
+		log.Printf("[Main] Job scheduler shutdown error: %v", err)
+	}
+
+	// 3. 关闭事件总线，处理完已入队的异步事件
+	if err := module.Bus().Close(shutdownCtx); err != nil {
+		log.Printf("[Main] Event bus shutdown error: %v", err)
+	}
+
+	// 4. 按启动逆序停止模块（WebSocket Hub 等）
+	if err := module.StopAllModules(shutdownCtx); err != nil {
+		log.Printf("[Main] Module shutdown error: %v", err)
+	}
+
+	// 5. 最后关闭数据库连接
+	database.Close()
+	log.Println("[Main] Server exited")
+}
+
+// runMigrateCommand 执行迁移命令并以JSON输出结果
+func runMigrateCommand(migrator *module.MigrationRunner, cmd, moduleCode string, steps int) {
+	var result []module.MigrationStatus
+	var err error
+	switch cmd {
+	case "status":
+		result, err = migrator.Status()
+	case "plan":
+		result, err = migrator.Plan()
+	case "up":
+		result, err = migrator.Up()
+	case "down":
+		result, err = migrator.Down(moduleCode, steps)
+	default:
+		log.Fatalf("Unknown migrate command %q, expected status, plan, up or down", cmd)
+	}
+	if result == nil {
+		result = []module.MigrationStatus{}
+	}
+	report, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(report))
+	if err != nil {
+		log.Fatalf("Migrate %s failed: %v", cmd, err)
+	}
+}
+
+// runReencryptCommand 将APP密钥、API密钥和模块配置中的敏感字段改用当前主密钥加密并以JSON输出更新数量
+// 完成后即可从配置中移除旧主密钥
+func runReencryptCommand() {
+	if !secret.Default().Enabled() {
+		log.Fatalf("Reencrypt requires encryption keys, configure encryption.keys or %s", secret.EnvKeys)
+	}
+	db := database.GetDB()
+	report := map[string]interface{}{"active_key": secret.Default().ActiveKeyID()}
+	steps := []struct {
+		name string
+		run  func() (int, error)
+	}{
+		{"app_secrets", func() (int, error) { return app.ReencryptSecrets(db) }},
+		{"api_key_secrets", func() (int, error) { return apimanager.ReencryptSecrets(db) }},
+		{"module_configs", func() (int, error) { return moduleapi.ReencryptConfigs(db) }},
+	}
+	for _, step := range steps {
+		count, err := step.run()
+		report[step.name] = count
+		if err != nil {
+			output, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(output))
+			log.Fatalf("Reencrypt %s failed: %v", step.name, err)
+		}
 	}
+	output, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(output))
 }
@@ -0,0 +1,115 @@
+// Package module 提供模块配置测试功能
+// 模块可以选择实现 ConfigTester 接口，在保存配置前用候选配置验证外部依赖（通道凭证、存储目录等）
+package module
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultConfigCheckTimeout 配置检查未指定超时时的默认值
+const DefaultConfigCheckTimeout = 10 * time.Second
+
+// ConfigCheck 定义一项配置检查
+type ConfigCheck struct {
+	// Name 检查项名称，在模块内唯一，例如 "provider_credentials"
+	Name string
+	// Description 检查内容说明，随结果返回给前端展示
+	Description string
+	// Timeout 单次检查的超时时间，为 0 时使用 DefaultConfigCheckTimeout
+	Timeout time.Duration
+	// Check 执行检查，返回 nil 表示通过；实现方应尊重 ctx 的超时，且不得持久化配置
+	Check func(ctx context.Context) error
+}
+
+// ConfigTester 是模块可选实现的配置测试接口
+// moduleCode 为被测试的模块或功能Code，config 为尚未保存的候选配置
+type ConfigTester interface {
+	ConfigChecks(appID uint, moduleCode string, config map[string]interface{}) []ConfigCheck
+}
+
+// ConfigCheckResult 单项配置检查结果
+type ConfigCheckResult struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Passed      bool   `json:"passed"`
+	Message     string `json:"message,omitempty"`
+	Latency     int64  `json:"latency_ms"`
+}
+
+// FindOwner 根据模块Code或功能Code查找所属模块
+func FindOwner(code string) (Module, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if m, ok := modules[code]; ok {
+		return m, true
+	}
+	if owner, ok := functionOwners()[code]; ok {
+		return modules[owner], true
+	}
+	return nil, false
+}
+
+// RunConfigChecks 用候选配置执行模块声明的配置检查，结果按声明顺序返回
+// 模块未实现 ConfigTester 时返回 nil，调用方可视为没有需要验证的外部依赖
+func RunConfigChecks(ctx context.Context, appID uint, moduleCode string, config map[string]interface{}) []ConfigCheckResult {
+	m, ok := FindOwner(moduleCode)
+	if !ok {
+		return nil
+	}
+	tester, ok := m.(ConfigTester)
+	if !ok {
+		return nil
+	}
+
+	checks := tester.ConfigChecks(appID, moduleCode, config)
+	results := make([]ConfigCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check ConfigCheck) {
+			defer wg.Done()
+			results[i] = runConfigCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// runConfigCheck 在超时时间内执行单项检查，检查函数 panic 时视为失败
+func runConfigCheck(ctx context.Context, check ConfigCheck) ConfigCheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultConfigCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := ConfigCheckResult{Name: check.Name, Description: check.Description}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			result.Message = err.Error()
+		} else {
+			result.Passed = true
+		}
+	case <-ctx.Done():
+		result.Message = fmt.Sprintf("check timed out after %s", timeout)
+	}
+	result.Latency = time.Since(start).Milliseconds()
+	return result
+}
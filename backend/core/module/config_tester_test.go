@@ -0,0 +1,62 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testerModule struct {
+	*BaseModule
+	received map[string]interface{}
+}
+
+func (m *testerModule) ConfigChecks(appID uint, moduleCode string, config map[string]interface{}) []ConfigCheck {
+	m.received = config
+	return []ConfigCheck{
+		{Name: "ok", Check: func(ctx context.Context) error { return nil }},
+		{Name: "fail", Check: func(ctx context.Context) error { return errors.New("bad credentials") }},
+		{Name: "slow", Timeout: 10 * time.Millisecond, Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond) // 超时后仍不返回的检查不应阻塞结果
+			return nil
+		}},
+		{Name: "panic", Check: func(ctx context.Context) error { panic("boom") }},
+	}
+}
+
+func TestRunConfigChecks(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	m := &testerModule{BaseModule: NewBaseModule(Meta{Code: "tester"}, []Function{{Code: "tester_send"}})}
+	Register(m)
+	Register(NewBaseModule(Meta{Code: "plain"}, nil))
+
+	config := map[string]interface{}{"provider": "fcm"}
+	results := RunConfigChecks(context.Background(), 1, "tester_send", config)
+	if m.received["provider"] != "fcm" {
+		t.Errorf("candidate config not passed to hook: %v", m.received)
+	}
+	if len(results) != 4 {
+		t.Fatalf("results = %+v", results)
+	}
+	if !results[0].Passed || results[0].Name != "ok" {
+		t.Errorf("ok check = %+v", results[0])
+	}
+	if results[1].Passed || results[1].Message != "bad credentials" {
+		t.Errorf("fail check = %+v", results[1])
+	}
+	if results[2].Passed || !strings.Contains(results[2].Message, "timed out") {
+		t.Errorf("slow check = %+v", results[2])
+	}
+	if results[3].Passed || !strings.Contains(results[3].Message, "panic") {
+		t.Errorf("panic check = %+v", results[3])
+	}
+
+	if got := RunConfigChecks(context.Background(), 1, "plain", config); got != nil {
+		t.Errorf("module without ConfigTester returned %+v", got)
+	}
+}
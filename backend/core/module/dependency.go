@@ -0,0 +1,168 @@
+// Package module 提供模块依赖解析功能
+// 根据模块声明的依赖关系进行拓扑排序，保证被依赖的模块先初始化
+package module
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyError 模块依赖解析错误
+// Missing 不为空表示依赖的模块未注册；Cycle 不为空表示存在循环依赖
+type DependencyError struct {
+	Module  string   // 出错的模块Code
+	Missing string   // 缺失的依赖
+	Cycle   []string // 循环依赖路径，首尾相同，例如 [a b c a]
+}
+
+func (e *DependencyError) Error() string {
+	if len(e.Cycle) > 0 {
+		return fmt.Sprintf("circular module dependency: %s", strings.Join(e.Cycle, " -> "))
+	}
+	return fmt.Sprintf("module %s depends on unregistered module or function: %s", e.Module, e.Missing)
+}
+
+// sortedOrder 依赖解析后的模块顺序，为 nil 表示尚未解析
+var sortedOrder []string
+
+// ResolveDependencies 对所有已注册模块进行拓扑排序
+// 解析成功后 GetAllModules / GetAllFunctions / InitAllModules 均按依赖顺序返回和执行
+// 依赖缺失或存在循环时返回 *DependencyError
+func ResolveDependencies() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	order, err := resolveOrder()
+	if err != nil {
+		return err
+	}
+	sortedOrder = order
+	return nil
+}
+
+// GetModuleDependencies 返回模块依赖的其他模块Code列表
+// 包括 Meta.Dependencies 声明的模块依赖，以及功能依赖所属的其他模块
+func GetModuleDependencies(code string) ([]string, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if _, exists := modules[code]; !exists {
+		return nil, fmt.Errorf("module not registered: %s", code)
+	}
+	return moduleDependencies(code, functionOwners())
+}
+
+// orderedCodes 返回当前生效的模块顺序，调用方需持有锁
+func orderedCodes() []string {
+	if sortedOrder != nil {
+		return sortedOrder
+	}
+	return initOrder
+}
+
+// functionOwners 构建 功能Code -> 模块Code 的映射，调用方需持有锁
+func functionOwners() map[string]string {
+	owners := make(map[string]string)
+	for code, m := range modules {
+		for _, fn := range m.GetFunctions() {
+			owners[fn.Code] = code
+		}
+	}
+	return owners
+}
+
+// moduleDependencies 计算单个模块的模块级依赖，调用方需持有锁
+// 依赖项可以是模块Code，也可以是其他模块的功能Code
+func moduleDependencies(code string, owners map[string]string) ([]string, error) {
+	m := modules[code]
+	seen := make(map[string]bool)
+	var deps []string
+
+	add := func(dep string) error {
+		target := dep
+		if _, ok := modules[dep]; !ok {
+			owner, ok := owners[dep]
+			if !ok {
+				return &DependencyError{Module: code, Missing: dep}
+			}
+			target = owner
+		}
+		// 依赖自身模块内的功能不构成模块间依赖
+		if target == code || seen[target] {
+			return nil
+		}
+		seen[target] = true
+		deps = append(deps, target)
+		return nil
+	}
+
+	for _, dep := range m.Meta().Dependencies {
+		if err := add(dep); err != nil {
+			return nil, err
+		}
+	}
+	for _, fn := range m.GetFunctions() {
+		for _, dep := range fn.Dependencies {
+			if err := add(dep); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return deps, nil
+}
+
+// resolveOrder 基于深度优先搜索的拓扑排序，调用方需持有锁
+// 以注册顺序为基础，尽量保持原有顺序稳定
+func resolveOrder() ([]string, error) {
+	owners := functionOwners()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(modules))
+	order := make([]string, 0, len(modules))
+	var path []string
+
+	var visit func(code string) error
+	visit = func(code string) error {
+		switch state[code] {
+		case visited:
+			return nil
+		case visiting:
+			// 从路径中截取出环
+			for i, c := range path {
+				if c == code {
+					cycle := append(append([]string{}, path[i:]...), code)
+					return &DependencyError{Module: code, Cycle: cycle}
+				}
+			}
+		}
+
+		state[code] = visiting
+		path = append(path, code)
+
+		deps, err := moduleDependencies(code, owners)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[code] = visited
+		order = append(order, code)
+		return nil
+	}
+
+	for _, code := range initOrder {
+		if err := visit(code); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,85 @@
+package module
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func registerWithDeps(code string, deps []string, functions ...Function) {
+	Register(NewBaseModule(Meta{Code: code, Dependencies: deps}, functions))
+}
+
+func moduleCodes(mods []Module) []string {
+	codes := make([]string, 0, len(mods))
+	for _, m := range mods {
+		codes = append(codes, m.Meta().Code)
+	}
+	return codes
+}
+
+func TestResolveDependencies_Order(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	// 注册顺序与依赖顺序相反
+	registerWithDeps("push", []string{"user"})
+	registerWithDeps("message", nil, Function{Code: "message_send", Dependencies: []string{"push_send"}})
+	registerWithDeps("user", nil)
+	registerWithDeps("push_fn_owner", nil, Function{Code: "push_send"})
+
+	if err := ResolveDependencies(); err != nil {
+		t.Fatalf("ResolveDependencies() error = %v", err)
+	}
+
+	got := moduleCodes(GetAllModules())
+	want := []string{"user", "push", "push_fn_owner", "message"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+
+	deps, err := GetModuleDependencies("message")
+	if err != nil {
+		t.Fatalf("GetModuleDependencies() error = %v", err)
+	}
+	if !reflect.DeepEqual(deps, []string{"push_fn_owner"}) {
+		t.Errorf("deps = %v, want [push_fn_owner]", deps)
+	}
+}
+
+func TestResolveDependencies_Cycle(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	registerWithDeps("a", []string{"b"})
+	registerWithDeps("b", []string{"c"})
+	registerWithDeps("c", []string{"a"})
+
+	err := ResolveDependencies()
+	var depErr *DependencyError
+	if !errors.As(err, &depErr) {
+		t.Fatalf("ResolveDependencies() error = %v, want *DependencyError", err)
+	}
+	want := []string{"a", "b", "c", "a"}
+	if !reflect.DeepEqual(depErr.Cycle, want) {
+		t.Errorf("cycle = %v, want %v", depErr.Cycle, want)
+	}
+	if err.Error() != "circular module dependency: a -> b -> c -> a" {
+		t.Errorf("error message = %q", err.Error())
+	}
+}
+
+func TestResolveDependencies_Missing(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	registerWithDeps("a", []string{"ghost"})
+
+	var depErr *DependencyError
+	if err := InitAllModules(); !errors.As(err, &depErr) {
+		t.Fatalf("InitAllModules() error = %v, want *DependencyError", err)
+	}
+	if depErr.Module != "a" || depErr.Missing != "ghost" {
+		t.Errorf("got module=%s missing=%s", depErr.Module, depErr.Missing)
+	}
+}
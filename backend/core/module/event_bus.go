@@ -0,0 +1,306 @@
+// Package module 提供模块间的领域事件总线
+// 模块通过发布和订阅事件相互协作，而不需要直接依赖对方的实现
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAsyncQueueSize 异步订阅者的默认队列长度
+const DefaultAsyncQueueSize = 256
+
+// Event 领域事件，EventName 必须使用值接收者实现，以便根据类型推导事件名称
+type Event interface {
+	EventName() string
+}
+
+// SubscribeOption 订阅选项
+type SubscribeOption func(*subscriber)
+
+// Async 异步投递：事件进入订阅者自己的队列，由独立的 goroutine 按顺序处理
+// 队列满时事件会被丢弃并计入 Dropped，发布方不会被阻塞
+func Async() SubscribeOption {
+	return func(s *subscriber) { s.async = true }
+}
+
+// QueueSize 设置异步订阅者的队列长度
+func QueueSize(n int) SubscribeOption {
+	return func(s *subscriber) {
+		if n > 0 {
+			s.queueSize = n
+		}
+	}
+}
+
+// SubscriberStats 订阅者的投递统计
+type SubscriberStats struct {
+	Subscriber  string     `json:"subscriber"`
+	Event       string     `json:"event"`
+	Async       bool       `json:"async"`
+	Delivered   int64      `json:"delivered"`
+	Failed      int64      `json:"failed"`
+	Panics      int64      `json:"panics"`
+	Dropped     int64      `json:"dropped"`
+	Skipped     int64      `json:"skipped"`
+	Pending     int        `json:"pending"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// subscriber 单个订阅
+type subscriber struct {
+	id        uint64
+	name      string
+	event     string
+	async     bool
+	queueSize int
+	handle    func(ctx context.Context, e Event) error
+
+	queue chan Event
+	done  chan struct{}
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+	panics    atomic.Int64
+	dropped   atomic.Int64
+	skipped   atomic.Int64
+
+	mu          sync.Mutex
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// EventBus 进程内事件总线
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+	nextID      uint64
+	closed      bool
+}
+
+// NewEventBus 创建一个新的事件总线
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[string][]*subscriber)}
+}
+
+// 全局事件总线
+var bus = NewEventBus()
+
+// Bus 返回全局事件总线
+func Bus() *EventBus {
+	return bus
+}
+
+// Subscribe 在全局事件总线上订阅类型为 E 的事件，返回取消订阅函数
+// name 用于标识订阅者，出现在统计和日志中，建议使用 "模块code.用途" 的形式
+// 以模块code为前缀的订阅者在该模块被全局停用期间不会收到事件，计入 Skipped
+func Subscribe[E Event](name string, handler func(ctx context.Context, event E) error, opts ...SubscribeOption) func() {
+	return SubscribeTo(bus, name, handler, opts...)
+}
+
+// SubscribeTo 在指定事件总线上订阅类型为 E 的事件
+func SubscribeTo[E Event](b *EventBus, name string, handler func(ctx context.Context, event E) error, opts ...SubscribeOption) func() {
+	var zero E
+	return b.subscribe(zero.EventName(), name, func(ctx context.Context, e Event) error {
+		typed, ok := e.(E)
+		if !ok {
+			return fmt.Errorf("unexpected event type %T for %s", e, zero.EventName())
+		}
+		return handler(ctx, typed)
+	}, opts...)
+}
+
+// Publish 在全局事件总线上发布事件
+func Publish(ctx context.Context, e Event) error {
+	return bus.Publish(ctx, e)
+}
+
+// subscribe 注册订阅者
+func (b *EventBus) subscribe(event, name string, handle func(ctx context.Context, e Event) error, opts ...SubscribeOption) func() {
+	s := &subscriber{name: name, event: event, handle: handle, queueSize: DefaultAsyncQueueSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	s.id = b.nextID
+	if s.async {
+		s.queue = make(chan Event, s.queueSize)
+		s.done = make(chan struct{})
+		go s.run()
+	}
+	b.subscribers[event] = append(b.subscribers[event], s)
+	b.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { b.unsubscribe(s) })
+	}
+}
+
+// unsubscribe 移除订阅者，异步订阅者会处理完队列中剩余的事件
+func (b *EventBus) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	subs := b.subscribers[s.event]
+	for i, sub := range subs {
+		if sub.id == s.id {
+			b.subscribers[s.event] = append(subs[:i:i], subs[i+1:]...)
+			if s.async && !b.closed {
+				close(s.queue)
+			}
+			break
+		}
+	}
+	b.mu.Unlock()
+}
+
+// Publish 发布事件
+// 同步订阅者在当前 goroutine 中依次执行，返回它们的错误（合并）；
+// 异步订阅者只入队，错误只记录在统计中。订阅者 panic 会被恢复并视为失败，不影响发布方和其他订阅者
+func (b *EventBus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return errors.New("event bus is closed")
+	}
+	subs := append([]*subscriber{}, b.subscribers[e.EventName()]...)
+
+	var syncSubs []*subscriber
+	for _, s := range subs {
+		if !s.async {
+			syncSubs = append(syncSubs, s)
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			s.dropped.Add(1)
+			log.Printf("[EventBus] Queue full, dropped %s for subscriber %s", e.EventName(), s.name)
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range syncSubs {
+		if err := s.deliver(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止接收新事件，并等待异步订阅者处理完已入队的事件或 ctx 超时
+func (b *EventBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	var pending []*subscriber
+	for _, subs := range b.subscribers {
+		for _, s := range subs {
+			if s.async {
+				close(s.queue)
+				pending = append(pending, s)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	for _, s := range pending {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return fmt.Errorf("event bus close: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Stats 返回所有订阅者的投递统计，按事件名和订阅者名排序
+func (b *EventBus) Stats() []SubscriberStats {
+	b.mu.RLock()
+	var stats []SubscriberStats
+	for _, subs := range b.subscribers {
+		for _, s := range subs {
+			stats = append(stats, s.stats())
+		}
+	}
+	b.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Event != stats[j].Event {
+			return stats[i].Event < stats[j].Event
+		}
+		return stats[i].Subscriber < stats[j].Subscriber
+	})
+	return stats
+}
+
+// run 异步订阅者的处理循环
+func (s *subscriber) run() {
+	defer close(s.done)
+	for e := range s.queue {
+		s.deliver(context.Background(), e)
+	}
+}
+
+// deliver 调用订阅者处理事件并记录统计，panic 会被恢复为错误
+// 订阅者所属模块已全局停用时跳过
+func (s *subscriber) deliver(ctx context.Context, e Event) (err error) {
+	if owner := ownerOf(s.name); owner != "" && !IsEnabled(owner) {
+		s.skipped.Add(1)
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			s.panics.Add(1)
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil {
+			s.failed.Add(1)
+			s.mu.Lock()
+			s.lastError = err.Error()
+			s.lastErrorAt = time.Now()
+			s.mu.Unlock()
+			log.Printf("[EventBus] Subscriber %s failed to handle %s: %v", s.name, e.EventName(), err)
+			return
+		}
+		s.delivered.Add(1)
+	}()
+	return s.handle(ctx, e)
+}
+
+// stats 返回订阅者的统计快照
+func (s *subscriber) stats() SubscriberStats {
+	st := SubscriberStats{
+		Subscriber: s.name,
+		Event:      s.event,
+		Async:      s.async,
+		Delivered:  s.delivered.Load(),
+		Failed:     s.failed.Load(),
+		Panics:     s.panics.Load(),
+		Dropped:    s.dropped.Load(),
+		Skipped:    s.skipped.Load(),
+	}
+	if s.async {
+		st.Pending = len(s.queue)
+	}
+	s.mu.Lock()
+	if s.lastError != "" {
+		lastErrorAt := s.lastErrorAt
+		st.LastError = s.lastError
+		st.LastErrorAt = &lastErrorAt
+	}
+	s.mu.Unlock()
+	return st
+}
@@ -0,0 +1,110 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testEvent struct{ N int }
+
+func (testEvent) EventName() string { return "test.event" }
+
+func TestEventBus_SyncDelivery(t *testing.T) {
+	b := NewEventBus()
+	var got []int
+	SubscribeTo(b, "first", func(ctx context.Context, e testEvent) error {
+		got = append(got, e.N)
+		return nil
+	})
+	SubscribeTo(b, "failing", func(ctx context.Context, e testEvent) error {
+		return errors.New("boom")
+	})
+	SubscribeTo(b, "panicking", func(ctx context.Context, e testEvent) error {
+		panic("bad subscriber")
+	})
+	SubscribeTo(b, "last", func(ctx context.Context, e testEvent) error {
+		got = append(got, e.N*10)
+		return nil
+	})
+
+	err := b.Publish(context.Background(), testEvent{N: 1})
+	if err == nil {
+		t.Fatal("Publish() should return subscriber errors")
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 10 {
+		t.Errorf("delivered = %v, want [1 10]; failures must not stop other subscribers", got)
+	}
+
+	stats := map[string]SubscriberStats{}
+	for _, s := range b.Stats() {
+		stats[s.Subscriber] = s
+	}
+	if s := stats["failing"]; s.Failed != 1 || s.LastError != "boom" {
+		t.Errorf("failing stats = %+v", s)
+	}
+	if s := stats["panicking"]; s.Failed != 1 || s.Panics != 1 {
+		t.Errorf("panicking stats = %+v", s)
+	}
+	if s := stats["first"]; s.Delivered != 1 {
+		t.Errorf("first stats = %+v", s)
+	}
+}
+
+func TestEventBus_AsyncDelivery(t *testing.T) {
+	b := NewEventBus()
+	received := make(chan int, 10)
+	release := make(chan struct{})
+	SubscribeTo(b, "async", func(ctx context.Context, e testEvent) error {
+		<-release
+		received <- e.N
+		return nil
+	}, Async(), QueueSize(1))
+
+	// 第一个事件被处理器取走并阻塞，第二个进入队列，第三个因队列已满被丢弃
+	if err := b.Publish(context.Background(), testEvent{N: 1}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	b.Publish(context.Background(), testEvent{N: 2})
+	b.Publish(context.Background(), testEvent{N: 3})
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	close(received)
+
+	var got []int
+	for n := range received {
+		got = append(got, n)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("received = %v, want [1 2]", got)
+	}
+	if s := b.Stats()[0]; s.Delivered != 2 || s.Dropped != 1 {
+		t.Errorf("stats = %+v, want delivered=2 dropped=1", s)
+	}
+	if err := b.Publish(context.Background(), testEvent{N: 4}); err == nil {
+		t.Error("Publish() after Close() should fail")
+	}
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	b := NewEventBus()
+	calls := 0
+	unsubscribe := SubscribeTo(b, "once", func(ctx context.Context, e testEvent) error {
+		calls++
+		return nil
+	})
+	b.Publish(context.Background(), testEvent{})
+	unsubscribe()
+	unsubscribe()
+	b.Publish(context.Background(), testEvent{})
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,101 @@
+// Package module 定义模块之间共享的领域事件
+// 事件在业务数据提交之后发布，订阅方不应假设能回滚发布方的操作
+package module
+
+import "time"
+
+// 事件名称
+const (
+	EventPushSent         = "push.sent"
+	EventVersionPublished = "version.published"
+	EventAlertTriggered   = "monitor.alert_triggered"
+	EventAppDeleted       = "app.deleted"
+	EventModuleState      = "module.state_changed"
+	EventConfigReview     = "module.config_review"
+)
+
+// PushSentEvent 推送发送完成
+type PushSentEvent struct {
+	AppID        uint      `json:"app_id"`
+	PushID       uint      `json:"push_id"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content"`
+	TargetType   string    `json:"target_type"`
+	TargetIDs    []string  `json:"target_ids"`
+	SentCount    int       `json:"sent_count"`
+	SuccessCount int       `json:"success_count"`
+	FailedCount  int       `json:"failed_count"`
+	SentAt       time.Time `json:"sent_at"`
+}
+
+// EventName 实现 Event 接口
+func (PushSentEvent) EventName() string { return EventPushSent }
+
+// VersionPublishedEvent 版本发布
+type VersionPublishedEvent struct {
+	AppID         uint      `json:"app_id"`
+	VersionID     uint      `json:"version_id"`
+	VersionName   string    `json:"version_name"`
+	VersionCode   int       `json:"version_code"`
+	Description   string    `json:"description"`
+	DownloadURL   string    `json:"download_url"`
+	IsForceUpdate bool      `json:"is_force_update"`
+	PublishedAt   time.Time `json:"published_at"`
+}
+
+// EventName 实现 Event 接口
+func (VersionPublishedEvent) EventName() string { return EventVersionPublished }
+
+// AlertTriggeredEvent 监控告警触发
+type AlertTriggeredEvent struct {
+	AppID       uint      `json:"app_id"`
+	AlertID     uint      `json:"alert_id"`
+	AlertName   string    `json:"alert_name"`
+	MetricName  string    `json:"metric_name"`
+	Condition   string    `json:"condition"`
+	Threshold   float64   `json:"threshold"`
+	Value       float64   `json:"value"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// EventName 实现 Event 接口
+func (AlertTriggeredEvent) EventName() string { return EventAlertTriggered }
+
+// AppDeletedEvent APP被删除
+type AppDeletedEvent struct {
+	AppID     uint      `json:"app_id"`
+	AppKey    string    `json:"app_key"` // APP的对外标识（apps.app_id）
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// EventName 实现 Event 接口
+func (AppDeletedEvent) EventName() string { return EventAppDeleted }
+
+// ModuleStateChangedEvent 模块被全局启用或停用
+type ModuleStateChangedEvent struct {
+	ModuleCode string    `json:"module_code"`
+	Enabled    bool      `json:"enabled"`
+	Reason     string    `json:"reason"`
+	ChangedBy  string    `json:"changed_by"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// EventName 实现 Event 接口
+func (ModuleStateChangedEvent) EventName() string { return EventModuleState }
+
+// ConfigReviewEvent 模块配置变更申请的状态变化或新评论
+type ConfigReviewEvent struct {
+	AppID       uint      `json:"app_id"`
+	RequestID   uint      `json:"request_id"`
+	ModuleCode  string    `json:"module_code"`
+	Action      string    `json:"action"` // submit, comment, approve, reject, cancel, apply
+	Status      string    `json:"status"`
+	Operator    string    `json:"operator"`
+	SubmittedBy string    `json:"submitted_by"`
+	Comment     string    `json:"comment"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// EventName 实现 Event 接口
+func (ConfigReviewEvent) EventName() string { return EventConfigReview }
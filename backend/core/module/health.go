@@ -0,0 +1,53 @@
+// Package module 提供模块健康检查功能
+// 模块可以选择实现 HealthChecker 接口，向 /health 系列接口汇报自身状态
+package module
+
+import (
+	"context"
+	"time"
+)
+
+// HealthCheck 定义一项健康检查
+type HealthCheck struct {
+	// Name 检查项名称，在模块内唯一，例如 "storage_writable"
+	Name string
+	// Critical 为 true 时检查失败视为 unhealthy（服务不可用），否则视为 degraded（功能降级）
+	Critical bool
+	// Liveness 为 true 时该检查同时参与存活探针，失败意味着进程需要重启
+	Liveness bool
+	// Timeout 单次检查的超时时间，为 0 时使用默认值
+	Timeout time.Duration
+	// Check 执行检查，返回 nil 表示健康；实现方应尊重 ctx 的超时
+	Check func(ctx context.Context) error
+}
+
+// HealthChecker 是模块可选实现的健康检查接口
+type HealthChecker interface {
+	HealthChecks() []HealthCheck
+}
+
+// ModuleHealthCheck 带有所属模块信息的健康检查项
+type ModuleHealthCheck struct {
+	Module string
+	HealthCheck
+}
+
+// GetAllHealthChecks 返回所有模块声明的健康检查项，按模块顺序排列
+// 已全局停用的模块不参与健康检查
+func GetAllHealthChecks() []ModuleHealthCheck {
+	var checks []ModuleHealthCheck
+	for _, m := range GetAllModules() {
+		hc, ok := m.(HealthChecker)
+		if !ok {
+			continue
+		}
+		code := m.Meta().Code
+		if !IsEnabled(code) {
+			continue
+		}
+		for _, check := range hc.HealthChecks() {
+			checks = append(checks, ModuleHealthCheck{Module: code, HealthCheck: check})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,55 @@
+// Package module 提供模块定时任务声明
+// 模块可以选择实现 JobProvider 接口，由调度器统一调度、记录运行历史
+package module
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Job 定义一个定时任务
+type Job struct {
+	// Name 任务名称，全局唯一；模块声明的任务会自动加上 "模块code." 前缀
+	Name string
+	// Description 任务说明
+	Description string
+	// Schedule cron 表达式（分 时 日 月 周），也支持 @daily、@every 1h 等写法
+	Schedule string
+	// Timeout 单次执行的超时时间，为 0 时使用调度器默认值
+	Timeout time.Duration
+	// MaxRetries 执行失败后的最大重试次数
+	MaxRetries int
+	// RetryBackoff 首次重试的等待时间，之后每次翻倍，为 0 时使用调度器默认值
+	RetryBackoff time.Duration
+	// Run 任务逻辑，实现方应尊重 ctx 的取消
+	Run func(ctx context.Context) error
+	// Module 声明任务的模块Code，由 GetAllJobs 填充；模块全局停用期间任务不会执行
+	Module string
+}
+
+// JobProvider 是模块可选实现的定时任务接口
+type JobProvider interface {
+	Jobs() []Job
+}
+
+// GetAllJobs 返回所有模块声明的定时任务，按模块顺序排列
+func GetAllJobs() []Job {
+	var jobs []Job
+	for _, m := range GetAllModules() {
+		provider, ok := m.(JobProvider)
+		if !ok {
+			continue
+		}
+		code := m.Meta().Code
+		prefix := code + "."
+		for _, job := range provider.Jobs() {
+			if !strings.HasPrefix(job.Name, prefix) {
+				job.Name = prefix + job.Name
+			}
+			job.Module = code
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
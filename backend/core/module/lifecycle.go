@@ -0,0 +1,78 @@
+// Package module 提供模块生命周期管理功能
+// 模块可以选择实现 Starter / Stopper 接口，在服务启动和关闭时执行自定义逻辑
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Starter 是模块可选实现的启动接口
+// 在所有模块 Init 完成、路由注册之后，按注册顺序调用
+// 适合启动后台协程、定时任务、连接外部服务等
+type Starter interface {
+	Start(ctx context.Context) error
+}
+
+// Stopper 是模块可选实现的停止接口
+// 在服务关闭时按启动的逆序调用，用于释放资源、等待后台任务退出
+// 实现方应尊重 ctx 的超时，避免阻塞整个关闭流程
+type Stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// started 记录已成功启动的模块，关闭时按逆序停止
+var started []string
+
+// StartAllModules 按顺序启动所有实现了 Starter 接口的模块
+// 任一模块启动失败时，会将已启动的模块按逆序停止后返回错误
+func StartAllModules(ctx context.Context) error {
+	for _, m := range GetAllModules() {
+		code := m.Meta().Code
+
+		if s, ok := m.(Starter); ok {
+			log.Printf("[ModuleLifecycle] Starting module: %s", code)
+			if err := s.Start(ctx); err != nil {
+				if stopErr := StopAllModules(ctx); stopErr != nil {
+					log.Printf("[ModuleLifecycle] Rollback stop failed: %v", stopErr)
+				}
+				return fmt.Errorf("failed to start module %s: %w", code, err)
+			}
+		}
+
+		lock.Lock()
+		started = append(started, code)
+		lock.Unlock()
+	}
+	return nil
+}
+
+// StopAllModules 按启动的逆序停止所有实现了 Stopper 接口的模块
+// 单个模块停止失败不会中断流程，所有错误会合并后返回
+func StopAllModules(ctx context.Context) error {
+	lock.Lock()
+	codes := started
+	started = nil
+	lock.Unlock()
+
+	var errs []error
+	for i := len(codes) - 1; i >= 0; i-- {
+		m, exists := Get(codes[i])
+		if !exists {
+			continue
+		}
+		s, ok := m.(Stopper)
+		if !ok {
+			continue
+		}
+
+		log.Printf("[ModuleLifecycle] Stopping module: %s", codes[i])
+		if err := s.Stop(ctx); err != nil {
+			log.Printf("[ModuleLifecycle] Failed to stop module %s: %v", codes[i], err)
+			errs = append(errs, fmt.Errorf("failed to stop module %s: %w", codes[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
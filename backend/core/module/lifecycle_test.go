@@ -0,0 +1,83 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type lifecycleModule struct {
+	*BaseModule
+	calls    *[]string
+	startErr error
+}
+
+func newLifecycleModule(code string, calls *[]string) *lifecycleModule {
+	return &lifecycleModule{
+		BaseModule: NewBaseModule(Meta{Code: code}, nil),
+		calls:      calls,
+	}
+}
+
+func (m *lifecycleModule) Start(ctx context.Context) error {
+	*m.calls = append(*m.calls, "start:"+m.Meta().Code)
+	return m.startErr
+}
+
+func (m *lifecycleModule) Stop(ctx context.Context) error {
+	*m.calls = append(*m.calls, "stop:"+m.Meta().Code)
+	return nil
+}
+
+func TestStartStopAllModules_Order(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	var calls []string
+	Register(newLifecycleModule("a", &calls))
+	Register(NewBaseModule(Meta{Code: "plain"}, nil)) // 未实现生命周期接口
+	Register(newLifecycleModule("b", &calls))
+
+	if err := StartAllModules(context.Background()); err != nil {
+		t.Fatalf("StartAllModules() error = %v", err)
+	}
+	if err := StopAllModules(context.Background()); err != nil {
+		t.Fatalf("StopAllModules() error = %v", err)
+	}
+
+	want := []string{"start:a", "start:b", "stop:b", "stop:a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestStartAllModules_RollbackOnFailure(t *testing.T) {
+	Clear()
+	defer Clear()
+
+	var calls []string
+	Register(newLifecycleModule("a", &calls))
+	failing := newLifecycleModule("b", &calls)
+	failing.startErr = errors.New("boom")
+	Register(failing)
+	Register(newLifecycleModule("c", &calls))
+
+	if err := StartAllModules(context.Background()); err == nil {
+		t.Fatal("StartAllModules() should fail when a module fails to start")
+	}
+
+	want := []string{"start:a", "start:b", "stop:a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// 回滚后不应再重复停止
+	calls = nil
+	if err := StopAllModules(context.Background()); err != nil {
+		t.Fatalf("StopAllModules() error = %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("modules stopped twice: %v", calls)
+	}
+}
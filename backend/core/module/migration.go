@@ -0,0 +1,436 @@
+// Package module 提供模块数据库迁移功能
+// 模块可以选择实现 Migrator 接口，随模块一起发布自己拥有的表结构变更
+package module
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 迁移状态
+const (
+	MigrationStateApplied = "applied"
+	MigrationStatePending = "pending"
+	// MigrationStateMissing 表示数据库中有执行记录，但代码中已找不到对应迁移
+	MigrationStateMissing = "missing"
+)
+
+// migrationLockName 迁移使用的数据库咨询锁名称
+const migrationLockName = "app_platform_schema_migrations"
+
+// DefaultMigrationLockTimeout 获取迁移锁的默认等待时间
+const DefaultMigrationLockTimeout = 30 * time.Second
+
+// Migration 定义一次表结构变更
+type Migration struct {
+	// Version 版本号，在模块内唯一且递增，建议使用 YYYYMMDDHHMM 格式
+	Version int64
+	// Description 变更说明
+	Description string
+	// Up 执行变更
+	Up func(tx *gorm.DB) error
+	// Down 回滚变更，为 nil 时该迁移不可回滚
+	Down func(tx *gorm.DB) error
+}
+
+// Migrator 是模块可选实现的迁移接口
+type Migrator interface {
+	Migrations() []Migration
+}
+
+// 不属于任何模块的平台表（APP、调度器等）的迁移，按归属登记
+var (
+	coreMigrationsMu sync.Mutex
+	coreMigrations   = make(map[string][]Migration)
+)
+
+// RegisterMigrations 登记不属于任何模块的迁移，通常在拥有这些表的包的 init 中调用
+// owner 与模块Code共用迁移记录中的 module 字段，不能与模块Code重复；这些迁移先于所有模块的迁移执行
+func RegisterMigrations(owner string, migrations ...Migration) {
+	coreMigrationsMu.Lock()
+	defer coreMigrationsMu.Unlock()
+	coreMigrations[owner] = append(coreMigrations[owner], migrations...)
+}
+
+// SchemaMigration 对应数据库中的 schema_migrations 表
+type SchemaMigration struct {
+	ID          uint      `gorm:"primaryKey"`
+	Module      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_schema_migrations_module_version"`
+	Version     int64     `gorm:"not null;uniqueIndex:idx_schema_migrations_module_version"`
+	Description string    `gorm:"type:varchar(255)"`
+	AppliedAt   time.Time `gorm:"not null"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus 单个迁移的状态
+type MigrationStatus struct {
+	Module      string     `json:"module"`
+	Version     int64      `json:"version"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
+
+// moduleMigration 带有所属模块信息的迁移
+type moduleMigration struct {
+	Module string
+	Migration
+}
+
+// migrationKey 迁移的唯一标识
+type migrationKey struct {
+	Module  string
+	Version int64
+}
+
+// MigrationRunner 迁移执行器
+type MigrationRunner struct {
+	db          *gorm.DB
+	LockTimeout time.Duration
+}
+
+// NewMigrationRunner 创建一个新的迁移执行器
+func NewMigrationRunner(db *gorm.DB) *MigrationRunner {
+	return &MigrationRunner{db: db, LockTimeout: DefaultMigrationLockTimeout}
+}
+
+// Status 返回所有迁移的状态：已执行、待执行以及代码中已缺失的迁移
+func (r *MigrationRunner) Status() ([]MigrationStatus, error) {
+	defined, err := collectMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := r.applied(r.db)
+	if err != nil {
+		return nil, err
+	}
+	return migrationStatus(defined, applied), nil
+}
+
+// Plan 返回待执行的迁移，按执行顺序排列
+func (r *MigrationRunner) Plan() ([]MigrationStatus, error) {
+	statuses, err := r.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []MigrationStatus
+	for _, s := range statuses {
+		if s.State == MigrationStatePending {
+			pending = append(pending, s)
+		}
+	}
+	return pending, nil
+}
+
+// Up 在咨询锁保护下执行所有待执行的迁移，返回本次执行的迁移
+// 多个实例同时启动时只有一个实例会执行迁移，其余实例等待锁释放后发现无待执行迁移
+func (r *MigrationRunner) Up() ([]MigrationStatus, error) {
+	defined, err := collectMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []MigrationStatus
+	err = r.withLock(func(conn *gorm.DB) error {
+		applied, err := r.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range pendingMigrations(defined, applied) {
+			log.Printf("[Migration] Applying %s@%d: %s", m.Module, m.Version, m.Description)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{
+					Module:      m.Module,
+					Version:     m.Version,
+					Description: m.Description,
+					AppliedAt:   time.Now(),
+				}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %s@%d failed: %w", m.Module, m.Version, err)
+			}
+			done = append(done, MigrationStatus{
+				Module:      m.Module,
+				Version:     m.Version,
+				Description: m.Description,
+				State:       MigrationStateApplied,
+			})
+		}
+		return nil
+	})
+	if len(done) > 0 {
+		log.Printf("[Migration] %d migrations applied", len(done))
+	}
+	return done, err
+}
+
+// Down 按执行顺序倒序回滚最近的 steps 个迁移，moduleCode 不为空时只回滚该模块的迁移
+func (r *MigrationRunner) Down(moduleCode string, steps int) ([]MigrationStatus, error) {
+	if steps <= 0 {
+		return nil, errors.New("steps must be positive")
+	}
+	defined, err := collectMigrations()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[migrationKey]moduleMigration, len(defined))
+	for _, m := range defined {
+		index[migrationKey{m.Module, m.Version}] = m
+	}
+
+	var done []MigrationStatus
+	err = r.withLock(func(conn *gorm.DB) error {
+		query := conn.Order("applied_at DESC, id DESC").Limit(steps)
+		if moduleCode != "" {
+			query = query.Where("module = ?", moduleCode)
+		}
+		var records []SchemaMigration
+		if err := query.Find(&records).Error; err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			m, ok := index[migrationKey{record.Module, record.Version}]
+			if !ok {
+				return fmt.Errorf("migration %s@%d is not defined in code", record.Module, record.Version)
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %s@%d is irreversible", record.Module, record.Version)
+			}
+			log.Printf("[Migration] Rolling back %s@%d: %s", m.Module, m.Version, m.Description)
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, record.ID).Error
+			}); err != nil {
+				return fmt.Errorf("rollback %s@%d failed: %w", m.Module, m.Version, err)
+			}
+			done = append(done, MigrationStatus{
+				Module:      m.Module,
+				Version:     m.Version,
+				Description: m.Description,
+				State:       MigrationStatePending,
+			})
+		}
+		return nil
+	})
+	return done, err
+}
+
+// applied 读取已执行的迁移记录，表不存在时自动创建
+func (r *MigrationRunner) applied(conn *gorm.DB) (map[migrationKey]SchemaMigration, error) {
+	if err := conn.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var records []SchemaMigration
+	if err := conn.Order("applied_at, id").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[migrationKey]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[migrationKey{record.Module, record.Version}] = record
+	}
+	return applied, nil
+}
+
+// withLock 在同一个数据库连接上持有咨询锁执行 fn
+// MySQL 使用 GET_LOCK，锁与连接绑定，因此 fn 必须使用传入的 conn；其他数据库不加锁
+func (r *MigrationRunner) withLock(fn func(conn *gorm.DB) error) error {
+	if r.db.Dialector.Name() != "mysql" {
+		return fn(r.db)
+	}
+
+	return r.db.Connection(func(conn *gorm.DB) error {
+		timeout := int(r.LockTimeout / time.Second)
+		var acquired *int
+		if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, timeout).Scan(&acquired).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired == nil || *acquired != 1 {
+			return fmt.Errorf("timed out waiting for migration lock after %s", r.LockTimeout)
+		}
+		defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName)
+
+		return fn(conn)
+	})
+}
+
+// collectMigrations 先按归属名称收集平台表的迁移，再按模块依赖顺序收集所有模块的迁移，同一归属内按版本号排序
+// 迁移可能在 InitAllModules 之前执行，这里先解析依赖，保证被依赖模块的表先建立
+func collectMigrations() ([]moduleMigration, error) {
+	if err := ResolveDependencies(); err != nil {
+		return nil, err
+	}
+	var all []moduleMigration
+
+	coreMigrationsMu.Lock()
+	core := make(map[string][]Migration, len(coreMigrations))
+	owners := make([]string, 0, len(coreMigrations))
+	for owner, migrations := range coreMigrations {
+		core[owner] = migrations
+		owners = append(owners, owner)
+	}
+	coreMigrationsMu.Unlock()
+
+	sort.Strings(owners)
+	for _, owner := range owners {
+		if _, ok := Get(owner); ok {
+			return nil, fmt.Errorf("migration owner %s conflicts with a module code", owner)
+		}
+		migrations, err := sortMigrations(owner, core[owner])
+		if err != nil {
+			return nil, err
+		}
+		for _, mig := range migrations {
+			all = append(all, moduleMigration{Module: owner, Migration: mig})
+		}
+	}
+
+	for _, m := range GetAllModules() {
+		migrator, ok := m.(Migrator)
+		if !ok {
+			continue
+		}
+		code := m.Meta().Code
+		migrations, err := sortMigrations(code, migrator.Migrations())
+		if err != nil {
+			return nil, err
+		}
+		for _, mig := range migrations {
+			all = append(all, moduleMigration{Module: code, Migration: mig})
+		}
+	}
+	return all, nil
+}
+
+// sortMigrations 校验并按版本号排序单个模块的迁移
+func sortMigrations(moduleCode string, migrations []Migration) ([]Migration, error) {
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("module %s: migration version must be positive, got %d", moduleCode, m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("module %s: migration %d has no Up function", moduleCode, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("module %s: duplicate migration version %d", moduleCode, m.Version)
+		}
+	}
+	return sorted, nil
+}
+
+// pendingMigrations 返回尚未执行的迁移，保持定义顺序
+func pendingMigrations(defined []moduleMigration, applied map[migrationKey]SchemaMigration) []moduleMigration {
+	var pending []moduleMigration
+	for _, m := range defined {
+		if _, ok := applied[migrationKey{m.Module, m.Version}]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// migrationStatus 合并代码中定义的迁移与数据库中的执行记录
+func migrationStatus(defined []moduleMigration, applied map[migrationKey]SchemaMigration) []MigrationStatus {
+	statuses := make([]MigrationStatus, 0, len(defined))
+	seen := make(map[migrationKey]bool, len(defined))
+	for _, m := range defined {
+		key := migrationKey{m.Module, m.Version}
+		seen[key] = true
+		status := MigrationStatus{
+			Module:      m.Module,
+			Version:     m.Version,
+			Description: m.Description,
+			State:       MigrationStatePending,
+		}
+		if record, ok := applied[key]; ok {
+			appliedAt := record.AppliedAt
+			status.State = MigrationStateApplied
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	var missing []MigrationStatus
+	for key, record := range applied {
+		if seen[key] {
+			continue
+		}
+		appliedAt := record.AppliedAt
+		missing = append(missing, MigrationStatus{
+			Module:      record.Module,
+			Version:     record.Version,
+			Description: record.Description,
+			State:       MigrationStateMissing,
+			AppliedAt:   &appliedAt,
+		})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		if missing[i].Module != missing[j].Module {
+			return missing[i].Module < missing[j].Module
+		}
+		return missing[i].Version < missing[j].Version
+	})
+	return append(statuses, missing...)
+}
+
+// CreateIndexIfNotExists 在索引不存在时创建索引，用于为历史上手工建出的表补齐索引
+func CreateIndexIfNotExists(tx *gorm.DB, table, name, columns string) error {
+	if tx.Migrator().HasIndex(table, name) {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("CREATE INDEX %s ON %s(%s)", name, table, columns)).Error
+}
+
+// CreateUniqueIndexIfNotExists 在索引不存在时创建唯一索引，表中已有重复数据时返回错误
+func CreateUniqueIndexIfNotExists(tx *gorm.DB, table, name, columns string) error {
+	if tx.Migrator().HasIndex(table, name) {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s(%s)", name, table, columns)).Error
+}
+
+// CreateTableIfNotExists 按结构体创建尚不存在的表，已存在的表保持不变
+// 传入的结构体应是建表时的快照，不随之后的模型变更而改变，表结构的后续变更写成新的迁移
+func CreateTableIfNotExists(tx *gorm.DB, models ...interface{}) error {
+	for _, m := range models {
+		if tx.Migrator().HasTable(m) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddColumnsIfNotExists 按结构体字段为表添加尚不存在的列，已存在的列保持不变
+// 与 CreateTableIfNotExists 一样，传入的结构体应是添加这些列时的快照
+func AddColumnsIfNotExists(tx *gorm.DB, model interface{}, fields ...string) error {
+	m := tx.Migrator()
+	for _, field := range fields {
+		if m.HasColumn(model, field) {
+			continue
+		}
+		if err := m.AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func noopMigration(tx *gorm.DB) error { return nil }
+
+func TestSortMigrations(t *testing.T) {
+	sorted, err := sortMigrations("push", []Migration{
+		{Version: 3, Up: noopMigration},
+		{Version: 1, Up: noopMigration},
+		{Version: 2, Up: noopMigration},
+	})
+	if err != nil {
+		t.Fatalf("sortMigrations() error = %v", err)
+	}
+	for i, m := range sorted {
+		if m.Version != int64(i+1) {
+			t.Errorf("sorted[%d].Version = %d, want %d", i, m.Version, i+1)
+		}
+	}
+
+	invalid := map[string][]Migration{
+		"duplicate": {{Version: 1, Up: noopMigration}, {Version: 1, Up: noopMigration}},
+		"zero":      {{Version: 0, Up: noopMigration}},
+		"no up":     {{Version: 1}},
+	}
+	for name, migrations := range invalid {
+		if _, err := sortMigrations("push", migrations); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	defined := []moduleMigration{
+		{Module: "user", Migration: Migration{Version: 1, Up: noopMigration}},
+		{Module: "push", Migration: Migration{Version: 1, Up: noopMigration}},
+		{Module: "push", Migration: Migration{Version: 2, Up: noopMigration}},
+	}
+	applied := map[migrationKey]SchemaMigration{
+		{"user", 1}:   {Module: "user", Version: 1, AppliedAt: time.Now()},
+		{"push", 1}:   {Module: "push", Version: 1, AppliedAt: time.Now()},
+		{"legacy", 5}: {Module: "legacy", Version: 5, AppliedAt: time.Now()},
+	}
+
+	pending := pendingMigrations(defined, applied)
+	if len(pending) != 1 || pending[0].Module != "push" || pending[0].Version != 2 {
+		t.Errorf("pendingMigrations() = %+v, want push@2", pending)
+	}
+
+	statuses := migrationStatus(defined, applied)
+	want := []string{MigrationStateApplied, MigrationStateApplied, MigrationStatePending, MigrationStateMissing}
+	if len(statuses) != len(want) {
+		t.Fatalf("migrationStatus() returned %d entries, want %d", len(statuses), len(want))
+	}
+	for i, s := range statuses {
+		if s.State != want[i] {
+			t.Errorf("statuses[%d] = %s@%d %s, want %s", i, s.Module, s.Version, s.State, want[i])
+		}
+	}
+}
+
+func TestCollectMigrations_CoreFirst(t *testing.T) {
+	Clear()
+	defer Clear()
+	defer func(saved map[string][]Migration) { coreMigrations = saved }(coreMigrations)
+	coreMigrations = map[string][]Migration{}
+
+	RegisterMigrations("platform", Migration{Version: 2, Up: noopMigration}, Migration{Version: 1, Up: noopMigration})
+	// 依赖 push 的模块先注册，迁移仍应排在 push 之后
+	Register(&migratingModule{
+		BaseModule: NewBaseModule(Meta{Code: "message", Dependencies: []string{"push"}}, nil),
+		migrations: []Migration{{Version: 1, Up: noopMigration}},
+	})
+	Register(&migratingModule{
+		BaseModule: NewBaseModule(Meta{Code: "push"}, nil),
+		migrations: []Migration{{Version: 1, Up: noopMigration}},
+	})
+
+	all, err := collectMigrations()
+	if err != nil {
+		t.Fatalf("collectMigrations() error = %v", err)
+	}
+	var got []string
+	for _, m := range all {
+		got = append(got, fmt.Sprintf("%s@%d", m.Module, m.Version))
+	}
+	if strings.Join(got, ",") != "platform@1,platform@2,push@1,message@1" {
+		t.Errorf("collectMigrations() = %v", got)
+	}
+
+	// 平台表的归属名称不能与模块Code重复，否则两者的迁移记录无法区分
+	RegisterMigrations("push", Migration{Version: 9, Up: noopMigration})
+	if _, err := collectMigrations(); err == nil {
+		t.Error("expected error for owner conflicting with a module code")
+	}
+}
+
+type migratingModule struct {
+	*BaseModule
+	migrations []Migration
+}
+
+func (m *migratingModule) Migrations() []Migration { return m.migrations }
@@ -11,6 +11,11 @@ type Meta struct {
 	Description string // 模块功能描述
 	Icon        string // 模块图标
 	SortOrder   int    // 排序顺序
+	// Dependencies 依赖的其他模块Code列表
+	// 被依赖的模块会先初始化、先注册路由、先同步
+	Dependencies []string
+	// Global 全局模块不区分APP，其路由不受APP模块启用状态控制
+	Global bool
 }
 
 // Function 定义了一个具体的功能点，对应数据库中的一条记录
@@ -20,7 +25,7 @@ type Function struct {
 	Description  string                 // 功能描述
 	Type         string                 // 功能类型: "active" (工作台可见) 或 "passive" (后台运行)
 	ConfigSchema map[string]interface{} // 功能的JSON Schema配置
-	Dependencies []string               // 依赖的其他功能Code列表
+	Dependencies []string               // 依赖的其他功能Code列表，跨模块的功能依赖同样参与模块排序
 	SortOrder    int                    // 排序顺序
 }
 
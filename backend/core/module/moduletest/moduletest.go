@@ -0,0 +1,410 @@
+// Package moduletest 提供模块端到端测试工具
+// 使用方式：
+//
+//	h := moduletest.New(t, moduletest.Options{Modules: []string{"push_service"}})
+//	app := h.CreateApp("demo")
+//	h.EnableModule(app, "push_service")
+//	resp := h.Post("/push", gin.H{"app_id": app.ID, "title": "hi"})
+//	resp.AssertOK()
+//
+// New 会创建一个只包含指定模块（及其依赖）的注册中心、执行过迁移的 SQLite 数据库、
+// 与 main.go 一致的路由（JWT 认证 + 模块拦截）以及一个 httptest 服务器，测试结束时自动清理
+package moduletest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/config"
+	"app-platform-backend/internal/middleware"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试用的管理员身份
+const (
+	AdminID       uint = 1
+	AdminUsername      = "moduletest"
+)
+
+// Options 测试环境选项
+type Options struct {
+	// Modules 需要加载的模块Code，依赖的模块会自动加入
+	// 模块包需要被测试文件导入（通常是被测模块自身的包），才会出现在注册中心中
+	Modules []string
+	// Models 迁移之外还需要建表的模型，例如尚未改为迁移管理的历史表
+	Models []interface{}
+	// Start 为 true 时调用 StartAllModules，测试结束时停止
+	Start bool
+}
+
+// Harness 模块测试环境
+type Harness struct {
+	T      testing.TB
+	DB     *gorm.DB
+	Router *gin.Engine
+	Server *httptest.Server
+	// Token 管理员JWT，请求默认携带
+	Token string
+}
+
+// New 创建测试环境，任一步骤失败都会终止测试
+func New(t testing.TB, opts Options) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	h := &Harness{T: t, DB: openDB(t)}
+	database.SetDB(h.DB)
+	t.Cleanup(func() { database.SetDB(nil) })
+
+	useModules(t, opts.Modules)
+
+	models := append([]interface{}{&model.App{}, &model.AppModule{}}, opts.Models...)
+	if err := h.DB.AutoMigrate(models...); err != nil {
+		t.Fatalf("moduletest: auto migrate: %v", err)
+	}
+	if _, err := module.NewMigrationRunner(h.DB).Up(); err != nil {
+		t.Fatalf("moduletest: migrate: %v", err)
+	}
+
+	middleware.InitJWT(&config.JWTConfig{Secret: "moduletest", Expire: 1})
+	token, err := middleware.GenerateToken(AdminID, AdminUsername)
+	if err != nil {
+		t.Fatalf("moduletest: generate token: %v", err)
+	}
+	h.Token = token
+
+	if err := module.InitAllModules(); err != nil {
+		t.Fatalf("moduletest: init modules: %v", err)
+	}
+	if opts.Start {
+		ctx := context.Background()
+		if err := module.StartAllModules(ctx); err != nil {
+			t.Fatalf("moduletest: start modules: %v", err)
+		}
+		t.Cleanup(func() { module.StopAllModules(ctx) })
+	}
+
+	h.Router = newRouter(h.DB)
+	h.Server = httptest.NewServer(h.Router)
+	t.Cleanup(h.Server.Close)
+	return h
+}
+
+// openDB 在临时目录中创建 SQLite 数据库
+func openDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "moduletest.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("moduletest: open sqlite: %v", err)
+	}
+	// SQLite 不支持并发写，串行化连接避免 database is locked
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// useModules 将注册中心替换为指定模块及其依赖，测试结束时恢复
+func useModules(t testing.TB, codes []string) {
+	all := module.GetAllModules()
+
+	selected := make(map[string]bool)
+	var visit func(code string)
+	visit = func(code string) {
+		if selected[code] {
+			return
+		}
+		if _, ok := module.Get(code); !ok {
+			t.Fatalf("moduletest: module %s is not registered, import its package in the test", code)
+		}
+		selected[code] = true
+		deps, err := module.GetModuleDependencies(code)
+		if err != nil {
+			t.Fatalf("moduletest: %v", err)
+		}
+		for _, dep := range deps {
+			visit(dep)
+		}
+	}
+	for _, code := range codes {
+		visit(code)
+	}
+
+	module.Clear()
+	for _, m := range all {
+		if selected[m.Meta().Code] {
+			module.Register(m)
+		}
+	}
+	t.Cleanup(func() {
+		module.Clear()
+		for _, m := range all {
+			module.Register(m)
+		}
+	})
+}
+
+// newRouter 按 main.go 的方式注册模块路由，包括 /sdk 下的 SDK 路由
+func newRouter(db *gorm.DB) *gin.Engine {
+	r := gin.New()
+	auth := r.Group("/api/v1")
+	auth.Use(middleware.AuthMiddleware())
+
+	middleware.InitModuleGate(db, middleware.DefaultModuleGateTTL)
+	for _, m := range module.GetAllModules() {
+		meta := m.Meta()
+		group := auth.Group("")
+		group.Use(middleware.ModuleStateMiddleware(meta.Code))
+		if !meta.Global {
+			group.Use(middleware.ModuleGateMiddleware(meta.Code))
+		}
+		m.RegisterRoutes(group)
+	}
+
+	sdk := r.Group("/api/v1/sdk")
+	sdk.Use(middleware.AppSignatureMiddleware(db))
+	for _, m := range module.GetAllModules() {
+		provider, ok := m.(module.SDKRouteProvider)
+		if !ok {
+			continue
+		}
+		meta := m.Meta()
+		group := sdk.Group("")
+		group.Use(middleware.ModuleStateMiddleware(meta.Code))
+		if !meta.Global {
+			group.Use(middleware.ModuleGateMiddleware(meta.Code))
+		}
+		provider.RegisterSDKRoutes(group)
+	}
+	return r
+}
+
+// Response 接口响应
+type Response struct {
+	t          testing.TB
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// envelope 统一响应结构
+type envelope struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Get 发送 GET 请求，path 相对于 /api/v1
+func (h *Harness) Get(path string) *Response {
+	return h.Do(http.MethodGet, path, nil)
+}
+
+// Post 发送 JSON 请求体的 POST 请求
+func (h *Harness) Post(path string, body interface{}) *Response {
+	return h.Do(http.MethodPost, path, body)
+}
+
+// Put 发送 JSON 请求体的 PUT 请求
+func (h *Harness) Put(path string, body interface{}) *Response {
+	return h.Do(http.MethodPut, path, body)
+}
+
+// Delete 发送 DELETE 请求
+func (h *Harness) Delete(path string) *Response {
+	return h.Do(http.MethodDelete, path, nil)
+}
+
+// Do 以管理员身份发送请求；body 为 nil 时不带请求体，为 []byte 或 string 时原样发送，否则编码为 JSON
+// Token 为空时不带认证头，可用于测试未登录的情况
+func (h *Harness) Do(method, path string, body interface{}) *Response {
+	h.T.Helper()
+	return h.DoWithHeader(method, path, body, nil)
+}
+
+// DoWithHeader 与 Do 相同，额外携带请求头，例如 If-Match
+func (h *Harness) DoWithHeader(method, path string, body interface{}, header http.Header) *Response {
+	h.T.Helper()
+
+	var reader io.Reader
+	if data := h.encodeBody(body); data != nil {
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+"/api/v1"+path, reader)
+	if err != nil {
+		h.T.Fatalf("moduletest: new request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	for key, values := range header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.T.Fatalf("moduletest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.T.Fatalf("moduletest: read response: %v", err)
+	}
+	return &Response{t: h.T, StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// encodeBody 按 Do 的规则编码请求体，body 为 nil 时返回 nil
+func (h *Harness) encodeBody(body interface{}) []byte {
+	h.T.Helper()
+	switch b := body.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return b
+	case string:
+		return []byte(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.T.Fatalf("moduletest: marshal body: %v", err)
+		}
+		return data
+	}
+}
+
+// SDKGet 以APP身份发送 SDK 的 GET 请求，path 相对于 /api/v1/sdk，自动携带签名请求头
+func (h *Harness) SDKGet(app *model.App, path string, header http.Header) *Response {
+	h.T.Helper()
+	return h.SDKDo(app, http.MethodGet, path, nil, header)
+}
+
+// SDKDo 以APP身份发送任意方法的 SDK 请求，body 的处理与 Do 相同
+func (h *Harness) SDKDo(app *model.App, method, path string, body interface{}, header http.Header) *Response {
+	h.T.Helper()
+	if header == nil {
+		header = http.Header{}
+	}
+	data := h.encodeBody(body)
+	if data != nil {
+		// 按原文签名，发送时不再重新编码
+		body = data
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	urlPath, query, _ := strings.Cut("/api/v1/sdk"+path, "?")
+	header.Set(middleware.HeaderAppID, app.AppID)
+	header.Set(middleware.HeaderAppTimestamp, timestamp)
+	header.Set(middleware.HeaderAppNonce, hex.EncodeToString(nonce))
+	header.Set(middleware.HeaderAppSignature, middleware.SignAppRequest(
+		app.AppSecret, method, urlPath, query, timestamp, hex.EncodeToString(nonce), data))
+	return h.DoWithHeader(method, "/sdk"+path, body, header)
+}
+
+// Code 返回响应体中的业务码
+func (r *Response) Code() int {
+	return r.envelope().Code
+}
+
+// Message 返回响应体中的 message
+func (r *Response) Message() string {
+	return r.envelope().Message
+}
+
+// Data 将响应体中的 data 解码到 v
+func (r *Response) Data(v interface{}) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.envelope().Data, v); err != nil {
+		r.t.Fatalf("moduletest: decode data: %v\nbody: %s", err, r.Body)
+	}
+}
+
+// AssertOK 断言HTTP状态码为 200 且业务码为 0
+func (r *Response) AssertOK() *Response {
+	r.t.Helper()
+	if r.StatusCode != http.StatusOK || r.Code() != 0 {
+		r.t.Fatalf("expected success, got status %d\nbody: %s", r.StatusCode, r.Body)
+	}
+	return r
+}
+
+// AssertStatus 断言HTTP状态码
+func (r *Response) AssertStatus(status int) *Response {
+	r.t.Helper()
+	if r.StatusCode != status {
+		r.t.Fatalf("expected status %d, got %d\nbody: %s", status, r.StatusCode, r.Body)
+	}
+	return r
+}
+
+func (r *Response) envelope() envelope {
+	r.t.Helper()
+	var e envelope
+	if err := json.Unmarshal(r.Body, &e); err != nil {
+		r.t.Fatalf("moduletest: decode response: %v\nbody: %s", err, r.Body)
+	}
+	return e
+}
+
+// Create 插入任意测试数据
+func (h *Harness) Create(records ...interface{}) {
+	h.T.Helper()
+	for _, record := range records {
+		if err := h.DB.Create(record).Error; err != nil {
+			h.T.Fatalf("moduletest: create %T: %v", record, err)
+		}
+	}
+}
+
+// CreateApp 创建一个APP
+func (h *Harness) CreateApp(name string) *model.App {
+	h.T.Helper()
+	var count int64
+	h.DB.Model(&model.App{}).Count(&count)
+	app := &model.App{
+		Name:      name,
+		AppID:     fmt.Sprintf("app_test_%d", count+1),
+		AppSecret: "secret",
+		Status:    1,
+	}
+	h.Create(app)
+	return app
+}
+
+// EnableModule 为APP启用模块，使其通过模块拦截器
+func (h *Harness) EnableModule(app *model.App, moduleCode string) {
+	h.T.Helper()
+	h.Create(&model.AppModule{
+		AppID:        app.ID,
+		ModuleCode:   moduleCode,
+		SourceModule: moduleCode,
+		Config:       "{}",
+		Status:       1,
+	})
+	middleware.InvalidateModuleGateApp(app.ID)
+}
@@ -3,8 +3,10 @@
 package module
 
 import (
+	"context"
 	"fmt"
 	"sync"
+	"time"
 )
 
 var (
@@ -12,7 +14,7 @@ var (
 	modules = make(map[string]Module)
 	// lock 保证并发安全
 	lock = sync.RWMutex{}
-	// initOrder 记录模块注册顺序，用于按顺序初始化
+	// initOrder 记录模块注册顺序，依赖解析时以此为基础排序
 	initOrder []string
 )
 
@@ -29,6 +31,8 @@ func Register(m Module) {
 	}
 	modules[meta.Code] = m
 	initOrder = append(initOrder, meta.Code)
+	// 新模块注册后需要重新解析依赖顺序
+	sortedOrder = nil
 }
 
 // Get 根据模块Code获取模块实例
@@ -46,8 +50,8 @@ func GetAllModules() []Module {
 	defer lock.RUnlock()
 
 	all := make([]Module, 0, len(modules))
-	// 按注册顺序返回
-	for _, code := range initOrder {
+	// 依赖解析后按依赖顺序返回，否则按注册顺序返回
+	for _, code := range orderedCodes() {
 		if m, exists := modules[code]; exists {
 			all = append(all, m)
 		}
@@ -68,7 +72,7 @@ func GetAllFunctions() []Function {
 	defer lock.RUnlock()
 
 	var allFunctions []Function
-	for _, code := range initOrder {
+	for _, code := range orderedCodes() {
 		if m, exists := modules[code]; exists {
 			allFunctions = append(allFunctions, m.GetFunctions()...)
 		}
@@ -77,12 +81,18 @@ func GetAllFunctions() []Function {
 }
 
 // InitAllModules 初始化所有已注册的模块
+// 先解析模块依赖，再按依赖顺序初始化，被依赖的模块先初始化
+// 依赖缺失或循环依赖时直接返回错误，不会初始化任何模块
 // 返回第一个遇到的错误
 func InitAllModules() error {
+	if err := ResolveDependencies(); err != nil {
+		return err
+	}
+
 	lock.RLock()
 	defer lock.RUnlock()
 
-	for _, code := range initOrder {
+	for _, code := range orderedCodes() {
 		if m, exists := modules[code]; exists {
 			if err := m.Init(); err != nil {
 				return fmt.Errorf("failed to init module %s: %w", code, err)
@@ -93,9 +103,17 @@ func InitAllModules() error {
 }
 
 // Clear 清空所有已注册的模块（主要用于测试）
+// 模块在 Init 中建立的事件订阅随之失效：关闭并替换全局事件总线
 func Clear() {
 	lock.Lock()
-	defer lock.Unlock()
 	modules = make(map[string]Module)
 	initOrder = nil
+	sortedOrder = nil
+	started = nil
+	lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bus.Close(ctx)
+	bus = NewEventBus()
 }
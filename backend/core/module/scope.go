@@ -0,0 +1,23 @@
+// Package module 提供路由的APP归属声明
+// 模块启用状态拦截器需要知道每个请求属于哪个APP，模块可以选择实现 RouteScoper 接口，
+// 为请求中不带 app_id 的路由声明APP的来源
+package module
+
+// RouteScope 描述一条路由的APP归属
+type RouteScope struct {
+	// Table 按路径参数从该表的记录读取 app_id，用于 /:id 形式的路由
+	Table string
+	// Param 记录ID所在的路径参数，默认为 id
+	Param string
+	// AppFree 路由不属于任何APP（例如模板、健康检查），拦截器直接放行
+	AppFree bool
+	// Optional app_id 为可选的过滤条件，提供时校验，未提供时放行
+	Optional bool
+}
+
+// RouteScoper 是模块可选实现的路由归属接口
+// key 为 "方法 路由模板"，路由模板不含 /api/v1 前缀，例如 "POST /push/:id/send"
+// 未声明的路由必须在路径参数、查询参数或请求体中携带 app_id，否则被拦截器拒绝
+type RouteScoper interface {
+	RouteScopes() map[string]RouteScope
+}
@@ -0,0 +1,13 @@
+// Package module 提供面向客户端 SDK 的路由声明
+// 模块可以选择实现 SDKRouteProvider 接口，向移动端暴露无需管理员登录的接口
+package module
+
+import "github.com/gin-gonic/gin"
+
+// SDKRouteProvider 是模块可选实现的 SDK 路由接口
+// router 带有 /api/v1/sdk 前缀，请求已通过APP签名认证（app_id + app_secret），
+// 并且与管理端路由一样经过模块全局状态和APP启用状态的拦截
+// 处理函数通过 middleware.SDKApp 获取发起请求的APP
+type SDKRouteProvider interface {
+	RegisterSDKRoutes(router *gin.RouterGroup)
+}
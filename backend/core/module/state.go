@@ -0,0 +1,341 @@
+// Package module 提供模块全局启用状态管理
+// 模块在代码中注册后默认启用；运行时可以全局停用某个模块，停用后其路由、定时任务和事件订阅都不再生效，无需重新编译
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultStateTTL 模块状态缓存的默认有效期
+// 本实例内的变更立即生效，TTL 用于兜底其他实例上的变更
+const DefaultStateTTL = 30 * time.Second
+
+// stateRetryBackoff 重新加载失败后再次尝试前的等待时间，避免数据库故障期间每个请求都查询一次
+const stateRetryBackoff = 5 * time.Second
+
+// ErrModuleNotFound 模块未注册
+var ErrModuleNotFound = errors.New("module not registered")
+
+// ModuleState 模块全局启用状态，对应 module_states 表
+// 表中没有记录的模块视为启用
+type ModuleState struct {
+	ModuleCode string    `json:"module_code" gorm:"primaryKey;size:50"`
+	Enabled    bool      `json:"enabled" gorm:"not null"`
+	Reason     string    `json:"reason" gorm:"size:255"`
+	ChangedBy  string    `json:"changed_by" gorm:"size:100"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// TableName 指定表名
+func (ModuleState) TableName() string {
+	return "module_states"
+}
+
+// moduleStateV1 创建 module_states 时的表结构
+type moduleStateV1 struct {
+	ModuleCode string `gorm:"primaryKey;size:50"`
+	Enabled    bool   `gorm:"not null"`
+	Reason     string `gorm:"size:255"`
+	ChangedBy  string `gorm:"size:100"`
+	ChangedAt  time.Time
+}
+
+func (moduleStateV1) TableName() string {
+	return "module_states"
+}
+
+func init() {
+	RegisterMigrations("core", Migration{
+		Version:     202610170001,
+		Description: "create module_states",
+		Up: func(tx *gorm.DB) error {
+			// 之前由启动时的 AutoMigrate 创建，已存在的表保持不变
+			return CreateTableIfNotExists(tx, &moduleStateV1{})
+		},
+	})
+}
+
+// StateDependencyError 启用或停用模块违反依赖关系
+// 启用时 Blocking 为尚未启用的依赖模块；停用时为仍处于启用状态、依赖该模块的模块
+type StateDependencyError struct {
+	Module   string
+	Enabling bool
+	Blocking []string
+}
+
+func (e *StateDependencyError) Error() string {
+	if e.Enabling {
+		return fmt.Sprintf("module %s depends on disabled modules: %s", e.Module, strings.Join(e.Blocking, ", "))
+	}
+	return fmt.Sprintf("module %s is required by enabled modules: %s", e.Module, strings.Join(e.Blocking, ", "))
+}
+
+// StateStore 模块状态存储，带本地缓存
+type StateStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu         sync.RWMutex
+	states     map[string]ModuleState
+	nextReload time.Time
+	// reloading 保证缓存过期时只有一个请求去数据库加载，其他请求继续使用旧缓存
+	reloading sync.Mutex
+}
+
+// states 全局状态存储，未初始化时所有模块视为启用
+var states *StateStore
+
+// InitStates 初始化全局模块状态存储，module_states 表由迁移创建
+func InitStates(db *gorm.DB, ttl time.Duration) error {
+	s, err := NewStateStore(db, ttl)
+	if err != nil {
+		return err
+	}
+	states = s
+	return nil
+}
+
+// NewStateStore 创建模块状态存储并加载当前状态，ttl 为 0 时使用默认值
+func NewStateStore(db *gorm.DB, ttl time.Duration) (*StateStore, error) {
+	if ttl <= 0 {
+		ttl = DefaultStateTTL
+	}
+	s := &StateStore{db: db, ttl: ttl}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsEnabled 返回模块当前是否全局启用
+// 未注册的Code（例如非模块的订阅者前缀）视为启用
+func IsEnabled(code string) bool {
+	if states == nil {
+		return true
+	}
+	return states.Get(code).Enabled
+}
+
+// GetState 返回模块的全局状态
+func GetState(code string) ModuleState {
+	if states == nil {
+		return ModuleState{ModuleCode: code, Enabled: true}
+	}
+	return states.Get(code)
+}
+
+// SetEnabled 修改模块的全局状态，见 StateStore.SetEnabled
+func SetEnabled(ctx context.Context, code string, enabled bool, reason, changedBy string) (ModuleState, error) {
+	if states == nil {
+		return ModuleState{}, errors.New("module state store is not initialized")
+	}
+	return states.SetEnabled(ctx, code, enabled, reason, changedBy)
+}
+
+// Get 返回模块状态，缓存过期时从数据库重新加载
+// 同一时间只有一个请求执行加载；加载失败时继续使用旧缓存，并在 stateRetryBackoff 后再重试
+func (s *StateStore) Get(code string) ModuleState {
+	if s.reloadDue() && s.reloading.TryLock() {
+		// 拿到锁时可能已有其他请求刚完成加载
+		if s.reloadDue() {
+			if err := s.reload(); err != nil {
+				log.Printf("[ModuleState] Failed to reload module states: %v", err)
+				backoff := stateRetryBackoff
+				if s.ttl < backoff {
+					backoff = s.ttl
+				}
+				s.mu.Lock()
+				s.nextReload = time.Now().Add(backoff)
+				s.mu.Unlock()
+			}
+		}
+		s.reloading.Unlock()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if state, ok := s.states[code]; ok {
+		return state
+	}
+	return ModuleState{ModuleCode: code, Enabled: true}
+}
+
+// reloadDue 返回缓存是否需要重新加载
+func (s *StateStore) reloadDue() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !time.Now().Before(s.nextReload)
+}
+
+// SetEnabled 启用或停用模块，并发布 ModuleStateChangedEvent
+// 启用时依赖的模块必须已启用；停用时不能有仍启用的模块依赖它
+// 依赖检查和写入在同一事务中进行，并对涉及的模块状态加行锁，
+// 避免并发停用依赖和启用依赖它的模块同时成功
+func (s *StateStore) SetEnabled(ctx context.Context, code string, enabled bool, reason, changedBy string) (ModuleState, error) {
+	if _, ok := Get(code); !ok {
+		return ModuleState{}, fmt.Errorf("%w: %s", ErrModuleNotFound, code)
+	}
+	var related []string
+	if enabled {
+		deps, err := GetModuleDependencies(code)
+		if err != nil {
+			return ModuleState{}, err
+		}
+		related = deps
+	} else {
+		related = moduleDependents(code)
+	}
+
+	var state ModuleState
+	changed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current, err := lockStates(tx, append([]string{code}, related...))
+		if err != nil {
+			return err
+		}
+
+		var blocking []string
+		for _, other := range related {
+			// 启用时依赖的模块必须已启用；停用时依赖它的模块必须已停用
+			if current[other].Enabled != enabled {
+				blocking = append(blocking, other)
+			}
+		}
+		if len(blocking) > 0 {
+			return &StateDependencyError{Module: code, Enabling: enabled, Blocking: blocking}
+		}
+
+		state = current[code]
+		if state.Enabled == enabled {
+			return nil
+		}
+		state = ModuleState{
+			ModuleCode: code,
+			Enabled:    enabled,
+			Reason:     reason,
+			ChangedBy:  changedBy,
+			ChangedAt:  time.Now(),
+		}
+		changed = true
+		return tx.Save(&state).Error
+	})
+	var depErr *StateDependencyError
+	if errors.As(err, &depErr) {
+		return ModuleState{}, err
+	}
+	if err != nil {
+		return ModuleState{}, fmt.Errorf("failed to save module state: %w", err)
+	}
+	if !changed {
+		return state, nil
+	}
+
+	s.mu.Lock()
+	s.states[code] = state
+	s.mu.Unlock()
+	log.Printf("[ModuleState] Module %s enabled=%v by %s", code, enabled, changedBy)
+
+	if err := Publish(ctx, ModuleStateChangedEvent{
+		ModuleCode: code,
+		Enabled:    enabled,
+		Reason:     reason,
+		ChangedBy:  changedBy,
+		ChangedAt:  state.ChangedAt,
+	}); err != nil {
+		log.Printf("[ModuleState] Failed to publish state change of %s: %v", code, err)
+	}
+	return state, nil
+}
+
+// lockStates 在事务中对模块状态加行锁并返回
+// 没有记录的模块视为启用，先补上启用状态的记录才能加锁；按Code排序加锁，避免并发事务死锁
+func lockStates(tx *gorm.DB, codes []string) (map[string]ModuleState, error) {
+	sort.Strings(codes)
+	now := time.Now()
+	rows := make([]ModuleState, 0, len(codes))
+	for i, code := range codes {
+		if i > 0 && code == codes[i-1] {
+			continue
+		}
+		rows = append(rows, ModuleState{ModuleCode: code, Enabled: true, ChangedAt: now})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	var locked []ModuleState
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("module_code IN ?", codes).Order("module_code").Find(&locked).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[string]ModuleState, len(locked))
+	for _, row := range locked {
+		current[row.ModuleCode] = row
+	}
+	return current, nil
+}
+
+// reload 从数据库加载全部模块状态
+func (s *StateStore) reload() error {
+	var rows []ModuleState
+	if err := s.db.Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to load module states: %w", err)
+	}
+	loaded := make(map[string]ModuleState, len(rows))
+	for _, row := range rows {
+		loaded[row.ModuleCode] = row
+	}
+
+	s.mu.Lock()
+	s.states = loaded
+	s.nextReload = time.Now().Add(s.ttl)
+	s.mu.Unlock()
+	return nil
+}
+
+// moduleDependents 返回直接依赖 code 的已注册模块
+func moduleDependents(code string) []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	owners := functionOwners()
+	var dependents []string
+	for _, other := range orderedCodes() {
+		if other == code {
+			continue
+		}
+		deps, err := moduleDependencies(other, owners)
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if dep == code {
+				dependents = append(dependents, other)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// ownerOf 从 "模块code.名称" 形式的名称中解析所属模块，非模块前缀返回空
+func ownerOf(name string) string {
+	code, _, ok := strings.Cut(name, ".")
+	if !ok {
+		return ""
+	}
+	if _, registered := Get(code); !registered {
+		return ""
+	}
+	return code
+}
@@ -0,0 +1,121 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openStateStore(t *testing.T) *StateStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if _, err := NewMigrationRunner(db).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	s, err := NewStateStore(db, 0)
+	if err != nil {
+		t.Fatalf("NewStateStore() error = %v", err)
+	}
+	return s
+}
+
+func TestStateStore_Dependencies(t *testing.T) {
+	Clear()
+	defer Clear()
+	registerWithDeps("user", nil)
+	registerWithDeps("push", []string{"user"})
+
+	s := openStateStore(t)
+	ctx := context.Background()
+
+	// push 依赖 user，user 不能先于 push 停用
+	var depErr *StateDependencyError
+	if _, err := s.SetEnabled(ctx, "user", false, "", "admin"); !errors.As(err, &depErr) || depErr.Blocking[0] != "push" {
+		t.Fatalf("disable user: err = %v, want dependency error blocked by push", err)
+	}
+
+	if _, err := s.SetEnabled(ctx, "push", false, "maintenance", "admin"); err != nil {
+		t.Fatalf("disable push: %v", err)
+	}
+	if _, err := s.SetEnabled(ctx, "user", false, "", "admin"); err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+
+	// user 停用时 push 不能启用
+	if _, err := s.SetEnabled(ctx, "push", true, "", "admin"); !errors.As(err, &depErr) || !depErr.Enabling {
+		t.Fatalf("enable push: err = %v, want dependency error", err)
+	}
+
+	state := s.Get("push")
+	if state.Enabled || state.Reason != "maintenance" || state.ChangedBy != "admin" || state.ChangedAt.IsZero() {
+		t.Errorf("push state = %+v", state)
+	}
+
+	if _, err := s.SetEnabled(ctx, "missing", false, "", "admin"); !errors.Is(err, ErrModuleNotFound) {
+		t.Errorf("unknown module: err = %v, want ErrModuleNotFound", err)
+	}
+}
+
+func TestStateStore_SkipsDisabledSubscribers(t *testing.T) {
+	Clear()
+	defer Clear()
+	registerWithDeps("push", nil)
+
+	states = openStateStore(t)
+	defer func() { states = nil }()
+
+	b := NewEventBus()
+	var handled int
+	SubscribeTo(b, "push.count", func(ctx context.Context, e testEvent) error {
+		handled++
+		return nil
+	})
+
+	ctx := context.Background()
+	b.Publish(ctx, testEvent{})
+	if _, err := SetEnabled(ctx, "push", false, "", "admin"); err != nil {
+		t.Fatalf("disable push: %v", err)
+	}
+	b.Publish(ctx, testEvent{})
+
+	if handled != 1 {
+		t.Errorf("handled = %d, want 1", handled)
+	}
+	if stats := b.Stats(); stats[0].Skipped != 1 {
+		t.Errorf("skipped = %d, want 1", stats[0].Skipped)
+	}
+	if IsEnabled("push") {
+		t.Error("IsEnabled(push) = true after disable")
+	}
+}
+
+func TestStateStore_ReloadBackoff(t *testing.T) {
+	s := openStateStore(t)
+	s.ttl = time.Millisecond
+	if err := s.db.Migrator().DropTable(&ModuleState{}); err != nil {
+		t.Fatalf("drop module_states: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	// 加载失败后继续使用旧缓存，且在退避时间内不再查询数据库
+	if !s.Get("push").Enabled {
+		t.Error("Get() should fall back to the cached state")
+	}
+	if s.reloadDue() {
+		t.Error("reload should back off after a failure")
+	}
+}
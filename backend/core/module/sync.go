@@ -4,8 +4,10 @@ package module
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -13,17 +15,17 @@ import (
 
 // ModuleTemplateRecord 对应数据库中的 module_templates 表
 type ModuleTemplateRecord struct {
-	ID           uint      `gorm:"primaryKey"`
-	ModuleCode   string    `gorm:"type:varchar(50);uniqueIndex;not null"`
-	ModuleName   string    `gorm:"type:varchar(100);not null"`
-	Description  string    `gorm:"type:text"`
-	Dependencies string    `gorm:"type:json"`
-	Icon         string    `gorm:"type:varchar(100)"`
-	ConfigSchema string    `gorm:"type:text"`
-	SortOrder    int       `gorm:"default:0"`
-	IsActive     bool      `gorm:"default:true"`
-	SourceModule string    `gorm:"type:varchar(50)"` // 新增：来源模块Code
-	FunctionType string    `gorm:"type:varchar(20)"` // 新增：功能类型 active/passive
+	ID           uint   `gorm:"primaryKey"`
+	ModuleCode   string `gorm:"type:varchar(50);uniqueIndex;not null"`
+	ModuleName   string `gorm:"type:varchar(100);not null"`
+	Description  string `gorm:"type:text"`
+	Dependencies string `gorm:"type:json"`
+	Icon         string `gorm:"type:varchar(100)"`
+	ConfigSchema string `gorm:"type:text"`
+	SortOrder    int    `gorm:"default:0"`
+	Status       int    `gorm:"default:1"`        // 1 启用，0 停用；模板的读取方都按 status = 1 过滤
+	SourceModule string `gorm:"type:varchar(50)"` // 新增：来源模块Code
+	FunctionType string `gorm:"type:varchar(20)"` // 新增：功能类型 active/passive
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
@@ -33,42 +35,132 @@ func (ModuleTemplateRecord) TableName() string {
 	return "module_templates"
 }
 
+// 同步动作类型
+const (
+	SyncActionCreate     = "create"
+	SyncActionUpdate     = "update"
+	SyncActionDeactivate = "deactivate"
+)
+
+// SyncOptions 同步选项
+type SyncOptions struct {
+	// DryRun 为 true 时只计算差异，不写入数据库
+	DryRun bool
+}
+
+// FieldChange 单个字段的变更
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// FunctionChange 单个功能的变更
+type FunctionChange struct {
+	Code         string        `json:"code"`
+	SourceModule string        `json:"source_module"`
+	Action       string        `json:"action"`
+	Fields       []FieldChange `json:"fields,omitempty"`
+}
+
+// SyncResult 同步结果
+type SyncResult struct {
+	DryRun      bool             `json:"dry_run"`
+	Total       int              `json:"total"`
+	Created     int              `json:"created"`
+	Updated     int              `json:"updated"`
+	Unchanged   int              `json:"unchanged"`
+	Deactivated int              `json:"deactivated"`
+	Changes     []FunctionChange `json:"changes"`
+	SyncedAt    time.Time        `json:"synced_at"`
+}
+
+// errDryRun 用于在 dry-run 模式下回滚事务
+var errDryRun = errors.New("dry run")
+
 // Syncer 模块同步器
 type Syncer struct {
 	db *gorm.DB
 }
 
+// lastSync 本实例最近一次写入数据库的同步结果，启动时和管理接口触发的同步共用
+var lastSync struct {
+	mu     sync.RWMutex
+	result *SyncResult
+}
+
+// LastSyncResult 返回本实例最近一次同步的结果，尚未同步时返回 nil；预览不计入
+func LastSyncResult() *SyncResult {
+	lastSync.mu.RLock()
+	defer lastSync.mu.RUnlock()
+	return lastSync.result
+}
+
 // NewSyncer 创建一个新的同步器
 func NewSyncer(db *gorm.DB) *Syncer {
 	return &Syncer{db: db}
 }
 
 // SyncModulesToDB 将所有已注册模块的功能同步到数据库
-// 使用 UPSERT 策略：存在则更新，不存在则插入
+// 使用 UPSERT 策略：存在则更新，不存在则插入；代码中已移除的功能标记为停用
 func (s *Syncer) SyncModulesToDB() error {
+	_, err := s.Sync(SyncOptions{})
+	return err
+}
+
+// Sync 在单个事务中同步所有已注册模块的功能，并返回差异报告
+// DryRun 模式下所有写操作在事务中执行后回滚，结果与真实同步完全一致
+func (s *Syncer) Sync(opts SyncOptions) (*SyncResult, error) {
 	modules := GetAllModules()
-	log.Printf("[ModuleSync] Starting sync, found %d registered modules", len(modules))
+	log.Printf("[ModuleSync] Starting sync (dry_run=%v), found %d registered modules", opts.DryRun, len(modules))
+
+	result := &SyncResult{DryRun: opts.DryRun, Changes: []FunctionChange{}, SyncedAt: time.Now()}
 
-	for _, m := range modules {
-		meta := m.Meta()
-		functions := m.GetFunctions()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		synced := make(map[string]bool)
 
-		log.Printf("[ModuleSync] Syncing module: %s (%s) with %d functions",
-			meta.Code, meta.Name, len(functions))
+		for _, m := range modules {
+			meta := m.Meta()
+			functions := m.GetFunctions()
 
-		for _, fn := range functions {
-			if err := s.syncFunction(meta, fn); err != nil {
-				return fmt.Errorf("failed to sync function %s: %w", fn.Code, err)
+			log.Printf("[ModuleSync] Syncing module: %s (%s) with %d functions",
+				meta.Code, meta.Name, len(functions))
+
+			for _, fn := range functions {
+				if err := s.syncFunction(tx, meta, fn, result); err != nil {
+					return fmt.Errorf("failed to sync function %s: %w", fn.Code, err)
+				}
+				synced[fn.Code] = true
+				result.Total++
 			}
 		}
+
+		if err := s.deactivateOrphans(tx, synced, result); err != nil {
+			return fmt.Errorf("failed to deactivate removed functions: %w", err)
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
 	}
 
-	log.Printf("[ModuleSync] Sync completed successfully")
-	return nil
+	// 预览不代表数据库的实际状态，不覆盖最近一次同步的结果
+	if !opts.DryRun {
+		lastSync.mu.Lock()
+		lastSync.result = result
+		lastSync.mu.Unlock()
+	}
+	log.Printf("[ModuleSync] Sync completed (dry_run=%v): created=%d, updated=%d, unchanged=%d, deactivated=%d",
+		opts.DryRun, result.Created, result.Updated, result.Unchanged, result.Deactivated)
+	return result, nil
 }
 
 // syncFunction 同步单个功能到数据库
-func (s *Syncer) syncFunction(meta Meta, fn Function) error {
+func (s *Syncer) syncFunction(tx *gorm.DB, meta Meta, fn Function, result *SyncResult) error {
 	// 序列化配置Schema
 	configSchemaJSON := "{}"
 	if fn.ConfigSchema != nil {
@@ -79,7 +171,8 @@ func (s *Syncer) syncFunction(meta Meta, fn Function) error {
 		configSchemaJSON = string(bytes)
 	}
 
-	// 序列化依赖关系
+	// 序列化依赖关系：只保存功能自身依赖的功能Code
+	// 模块级依赖由 Meta.Dependencies 声明，读取方通过模块注册表获取，不写入功能记录
 	dependenciesJSON := "[]"
 	if len(fn.Dependencies) > 0 {
 		bytes, err := json.Marshal(fn.Dependencies)
@@ -98,7 +191,7 @@ func (s *Syncer) syncFunction(meta Meta, fn Function) error {
 		Icon:         meta.Icon,
 		ConfigSchema: configSchemaJSON,
 		SortOrder:    fn.SortOrder,
-		IsActive:     true,
+		Status:       1,
 		SourceModule: meta.Code,
 		FunctionType: fn.Type,
 		UpdatedAt:    time.Now(),
@@ -106,45 +199,138 @@ func (s *Syncer) syncFunction(meta Meta, fn Function) error {
 
 	// 使用 UPSERT：根据 ModuleCode 判断是更新还是插入
 	var existing ModuleTemplateRecord
-	result := s.db.Where("module_code = ?", fn.Code).First(&existing)
+	err := tx.Where("module_code = ?", fn.Code).First(&existing).Error
 
-	if result.Error == gorm.ErrRecordNotFound {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 不存在，插入新记录
 		record.CreatedAt = time.Now()
-		if err := s.db.Create(&record).Error; err != nil {
+		if err := tx.Create(&record).Error; err != nil {
 			return fmt.Errorf("failed to create record: %w", err)
 		}
+		result.Created++
+		result.Changes = append(result.Changes, FunctionChange{
+			Code:         fn.Code,
+			SourceModule: meta.Code,
+			Action:       SyncActionCreate,
+		})
 		log.Printf("[ModuleSync] Created new function: %s", fn.Code)
-	} else if result.Error != nil {
-		return fmt.Errorf("failed to query existing record: %w", result.Error)
-	} else {
-		// 存在，更新记录
-		updates := map[string]interface{}{
-			"module_name":   record.ModuleName,
-			"description":   record.Description,
-			"dependencies":  record.Dependencies,
-			"icon":          record.Icon,
-			"config_schema": record.ConfigSchema,
-			"sort_order":    record.SortOrder,
-			"source_module": record.SourceModule,
-			"function_type": record.FunctionType,
-			"updated_at":    record.UpdatedAt,
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query existing record: %w", err)
+	}
+
+	// 存在，只在字段有变化时更新
+	fields := diffRecord(existing, record)
+	if len(fields) == 0 {
+		result.Unchanged++
+		return nil
+	}
+
+	updates := map[string]interface{}{
+		"module_name":   record.ModuleName,
+		"description":   record.Description,
+		"dependencies":  record.Dependencies,
+		"icon":          record.Icon,
+		"config_schema": record.ConfigSchema,
+		"sort_order":    record.SortOrder,
+		"status":        record.Status,
+		"source_module": record.SourceModule,
+		"function_type": record.FunctionType,
+		"updated_at":    record.UpdatedAt,
+	}
+	if err := tx.Model(&existing).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update record: %w", err)
+	}
+	result.Updated++
+	result.Changes = append(result.Changes, FunctionChange{
+		Code:         fn.Code,
+		SourceModule: meta.Code,
+		Action:       SyncActionUpdate,
+		Fields:       fields,
+	})
+	log.Printf("[ModuleSync] Updated existing function: %s (%d fields changed)", fn.Code, len(fields))
+	return nil
+}
+
+// deactivateOrphans 将代码中已不存在的功能标记为停用
+// 只处理由同步器写入的记录（source_module 不为空），手工维护的模板不受影响
+func (s *Syncer) deactivateOrphans(tx *gorm.DB, synced map[string]bool, result *SyncResult) error {
+	var active []ModuleTemplateRecord
+	if err := tx.Where("status = ? AND source_module <> ''", 1).Find(&active).Error; err != nil {
+		return err
+	}
+
+	for _, record := range active {
+		if synced[record.ModuleCode] {
+			continue
 		}
-		if err := s.db.Model(&existing).Updates(updates).Error; err != nil {
-			return fmt.Errorf("failed to update record: %w", err)
+		if err := tx.Model(&record).Updates(map[string]interface{}{
+			"status":     0,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
 		}
-		log.Printf("[ModuleSync] Updated existing function: %s", fn.Code)
+		result.Deactivated++
+		result.Changes = append(result.Changes, FunctionChange{
+			Code:         record.ModuleCode,
+			SourceModule: record.SourceModule,
+			Action:       SyncActionDeactivate,
+			Fields:       []FieldChange{{Field: "status", Old: 1, New: 0}},
+		})
+		log.Printf("[ModuleSync] Deactivated removed function: %s", record.ModuleCode)
 	}
-
 	return nil
 }
 
-// GetSyncStats 获取同步统计信息
-func (s *Syncer) GetSyncStats() (created int, updated int, total int) {
-	modules := GetAllModules()
-	for _, m := range modules {
-		total += len(m.GetFunctions())
+// diffRecord 比较数据库中的记录与代码生成的记录，返回有变化的字段
+func diffRecord(old, new ModuleTemplateRecord) []FieldChange {
+	var fields []FieldChange
+	add := func(field string, o, n interface{}) {
+		fields = append(fields, FieldChange{Field: field, Old: o, New: n})
+	}
+
+	if old.ModuleName != new.ModuleName {
+		add("module_name", old.ModuleName, new.ModuleName)
+	}
+	if old.Description != new.Description {
+		add("description", old.Description, new.Description)
+	}
+	if !jsonEqual(old.Dependencies, new.Dependencies) {
+		add("dependencies", old.Dependencies, new.Dependencies)
+	}
+	if old.Icon != new.Icon {
+		add("icon", old.Icon, new.Icon)
+	}
+	if !jsonEqual(old.ConfigSchema, new.ConfigSchema) {
+		add("config_schema", old.ConfigSchema, new.ConfigSchema)
+	}
+	if old.SortOrder != new.SortOrder {
+		add("sort_order", old.SortOrder, new.SortOrder)
+	}
+	if old.Status != new.Status {
+		add("status", old.Status, new.Status)
+	}
+	if old.SourceModule != new.SourceModule {
+		add("source_module", old.SourceModule, new.SourceModule)
+	}
+	if old.FunctionType != new.FunctionType {
+		add("function_type", old.FunctionType, new.FunctionType)
+	}
+	return fields
+}
+
+// jsonEqual 按语义比较两个JSON字符串，忽略键顺序和空白差异
+// 数据库JSON列会对内容重新格式化，直接比较字符串会产生误报
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
 	}
-	// 这里简化处理，实际可以在 syncFunction 中统计
-	return 0, 0, total
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return string(ja) == string(jb)
 }
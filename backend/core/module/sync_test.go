@@ -0,0 +1,101 @@
+package module
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDiffRecord(t *testing.T) {
+	old := ModuleTemplateRecord{
+		ModuleCode:   "push_send",
+		ModuleName:   "发送推送",
+		Dependencies: `["user_management"]`,
+		ConfigSchema: `{"type": "object", "required": ["key"]}`,
+		Status:       0,
+		SourceModule: "push_service",
+	}
+	new := old
+	new.ConfigSchema = `{"required":["key"],"type":"object"}` // 仅格式不同
+	new.Status = 1
+	new.ModuleName = "立即推送"
+
+	fields := diffRecord(old, new)
+	if len(fields) != 2 {
+		t.Fatalf("diffRecord() = %+v, want 2 changes", fields)
+	}
+	if fields[0].Field != "module_name" || fields[0].Old != "发送推送" || fields[0].New != "立即推送" {
+		t.Errorf("unexpected change: %+v", fields[0])
+	}
+	if fields[1].Field != "status" {
+		t.Errorf("unexpected change: %+v", fields[1])
+	}
+
+	if diff := diffRecord(old, old); len(diff) != 0 {
+		t.Errorf("diffRecord() on identical records = %+v", diff)
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	if !jsonEqual(`{"a": 1, "b": [1, 2]}`, `{"b":[1,2],"a":1}`) {
+		t.Error("semantically equal JSON should be equal")
+	}
+	if jsonEqual(`["a"]`, `["b"]`) {
+		t.Error("different JSON should not be equal")
+	}
+	if jsonEqual(`not json`, `{}`) {
+		t.Error("invalid JSON should not be equal to valid JSON")
+	}
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	Clear()
+	defer Clear()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sync.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&ModuleTemplateRecord{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+
+	Register(NewBaseModule(Meta{Code: "push", Dependencies: []string{"user"}},
+		[]Function{{Code: "push_send", Dependencies: []string{"user_list"}}, {Code: "push_stats"}}))
+	s := NewSyncer(db)
+	if _, err := s.Sync(SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	// 功能记录只保存功能依赖，模块级依赖不写入
+	var send ModuleTemplateRecord
+	db.Where("module_code = ?", "push_send").First(&send)
+	if !jsonEqual(send.Dependencies, `["user_list"]`) {
+		t.Errorf("push_send dependencies = %s, want [\"user_list\"]", send.Dependencies)
+	}
+
+	// 代码中移除的功能停用为 status = 0，与模板的读取方一致
+	Clear()
+	Register(NewBaseModule(Meta{Code: "push"}, []Function{{Code: "push_send"}}))
+	preview, err := s.Sync(SyncOptions{DryRun: true})
+	if err != nil || preview.Deactivated != 1 {
+		t.Fatalf("dry run = %+v, %v", preview, err)
+	}
+	if last := LastSyncResult(); last == nil || last.DryRun || last.Created != 2 {
+		t.Errorf("LastSyncResult() after dry run = %+v, want the previous sync", last)
+	}
+
+	if _, err := s.Sync(SyncOptions{}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	var active int64
+	db.Model(&ModuleTemplateRecord{}).Where("status = 1").Count(&active)
+	if active != 1 {
+		t.Errorf("active templates = %d, want 1", active)
+	}
+}
@@ -3,12 +3,14 @@ package apimanager
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/secret"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -317,12 +319,17 @@ func CreateAppAPIKey(c *gin.Context) {
 
 	apiKey := generateAPIKey()
 	apiSecret := generateAPISecret()
+	encryptedSecret, err := secret.Encrypt(apiSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "密钥加密失败"})
+		return
+	}
 
 	key := model.AppAPIKey{
 		AppID:       appID,
 		Name:        req.Name,
 		APIKey:      apiKey,
-		APISecret:   apiSecret,
+		APISecret:   encryptedSecret,
 		Status:      1,
 		IPWhitelist: req.IPWhitelist,
 	}
@@ -511,3 +518,30 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+// ReencryptSecrets 将API密钥的 Secret（含已删除的密钥）改用当前主密钥加密，返回更新的数量
+// 表不存在时（未使用API管理）直接返回
+func ReencryptSecrets(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&model.AppAPIKey{}) {
+		return 0, nil
+	}
+	var keys []model.AppAPIKey
+	if err := db.Unscoped().Select("id", "api_secret").Find(&keys).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, key := range keys {
+		rotated, changed, err := secret.Rotate(key.APISecret)
+		if err != nil {
+			return updated, fmt.Errorf("api key %d: %w", key.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := db.Unscoped().Model(&model.AppAPIKey{}).Where("id = ?", key.ID).UpdateColumn("api_secret", rotated).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
@@ -3,14 +3,22 @@ package app
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"strconv"
+	"time"
 
+	"app-platform-backend/core/module"
 	"app-platform-backend/internal/model"
 	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/pkg/secret"
 	"app-platform-backend/internal/response"
 	"app-platform-backend/internal/validator"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func generateAppID() string {
@@ -25,6 +33,12 @@ func generateAppSecret() string {
 	return hex.EncodeToString(bytes)
 }
 
+// maskSecret 返回遮盖了密钥的APP副本，明文密钥只在创建和重置时返回一次
+func maskSecret(app model.App) model.App {
+	app.AppSecret = secret.Mask(app.AppSecret)
+	return app
+}
+
 // List 获取APP列表
 func List(c *gin.Context) {
 	var apps []model.App
@@ -64,7 +78,7 @@ func List(c *gin.Context) {
 
 	result := make([]AppWithModules, len(apps))
 	for i, app := range apps {
-		result[i].App = app
+		result[i].App = maskSecret(app)
 		database.GetDB().Model(&model.AppModule{}).Where("app_id = ? AND status = 1", app.ID).Count(&result[i].ModuleCount)
 		result[i].UserCount = 0 // 暂时设为0
 	}
@@ -93,10 +107,18 @@ func Create(c *gin.Context) {
 		appName = req.AppName
 	}
 
+	// 密钥加密保存，响应中返回明文
+	plainSecret := generateAppSecret()
+	encryptedSecret, err := secret.Encrypt(plainSecret)
+	if err != nil {
+		response.ServerError(c, "密钥加密失败")
+		return
+	}
+
 	app := model.App{
 		Name:        appName,
 		AppID:       generateAppID(),
-		AppSecret:   generateAppSecret(),
+		AppSecret:   encryptedSecret,
 		PackageName: req.PackageName,
 		Description: req.Description,
 		Icon:        req.Icon,
@@ -104,7 +126,7 @@ func Create(c *gin.Context) {
 	}
 
 	// 使用事务创建APP和关联模块
-	err := database.WithTransaction(func(tx *database.DB) error {
+	err = database.WithTransaction(func(tx *database.DB) error {
 		if err := tx.Create(&app).Error; err != nil {
 			return err
 		}
@@ -130,6 +152,7 @@ func Create(c *gin.Context) {
 		return
 	}
 
+	app.AppSecret = plainSecret
 	response.Success(c, app)
 }
 
@@ -158,7 +181,8 @@ func Detail(c *gin.Context) {
 	}
 
 	c.Writer.Header().Set("X-Debug-Success", "true")
-	response.Success(c, app)
+	etag.Set(c, app)
+	response.Success(c, maskSecret(app))
 }
 
 // Update 更新APP
@@ -206,16 +230,37 @@ func Update(c *gin.Context) {
 		updates["status"] = *req.Status
 	}
 
-	if err := database.GetDB().Model(&app).Updates(updates).Error; err != nil {
+	// 加锁重新读取，携带 If-Match 时确认APP未被其他人修改
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(&app, app.ID).Error; err != nil {
+			return err
+		}
+		if !etag.Match(c, app) {
+			return errAppModified
+		}
+		if err := tx.Model(&app).Updates(updates).Error; err != nil {
+			return err
+		}
+		// 重新查询获取更新后的数据
+		return tx.First(&app, app.ID).Error
+	})
+	if errors.Is(err, errAppModified) {
+		etag.Set(c, app)
+		response.PreconditionFailed(c, "应用已被他人修改，请刷新后重试", maskSecret(app))
+		return
+	}
+	if err != nil {
 		response.DBError(c, err)
 		return
 	}
 
-	// 重新查询获取更新后的数据
-	database.GetDB().First(&app, id)
-	response.Success(c, app)
+	etag.Set(c, app)
+	response.Success(c, maskSecret(app))
 }
 
+// errAppModified 请求携带的 If-Match 与APP当前状态不一致
+var errAppModified = errors.New("app has been modified")
+
 // Delete 删除APP
 func Delete(c *gin.Context) {
 	id := c.Param("id")
@@ -226,6 +271,10 @@ func Delete(c *gin.Context) {
 		return
 	}
 
+	// 删除前读取APP信息，用于发布删除事件
+	var app model.App
+	database.GetDB().First(&app, id)
+
 	// 使用事务删除APP和关联数据
 	err := database.WithTransaction(func(tx *database.DB) error {
 		if err := tx.Delete(&model.App{}, id).Error; err != nil {
@@ -243,6 +292,17 @@ func Delete(c *gin.Context) {
 		return
 	}
 
+	if app.ID != 0 {
+		if err := module.Publish(c.Request.Context(), module.AppDeletedEvent{
+			AppID:     app.ID,
+			AppKey:    app.AppID,
+			Name:      app.Name,
+			DeletedAt: time.Now(),
+		}); err != nil {
+			log.Printf("[App] Failed to publish app.deleted for app %d: %v", app.ID, err)
+		}
+	}
+
 	response.SuccessWithMessage(c, nil, "应用删除成功")
 }
 
@@ -263,7 +323,12 @@ func ResetSecret(c *gin.Context) {
 	}
 
 	newSecret := generateAppSecret()
-	if err := database.GetDB().Model(&app).Update("app_secret", newSecret).Error; err != nil {
+	encryptedSecret, err := secret.Encrypt(newSecret)
+	if err != nil {
+		response.ServerError(c, "密钥加密失败")
+		return
+	}
+	if err := database.GetDB().Model(&app).Update("app_secret", encryptedSecret).Error; err != nil {
 		response.DBError(c, err)
 		return
 	}
@@ -272,3 +337,26 @@ func ResetSecret(c *gin.Context) {
 		"app_secret": newSecret,
 	})
 }
+
+// ReencryptSecrets 将APP密钥（含已删除的APP）改用当前主密钥加密，明文密钥会被加密，返回更新的数量
+func ReencryptSecrets(db *gorm.DB) (int, error) {
+	var apps []model.App
+	if err := db.Unscoped().Select("id", "app_secret").Find(&apps).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, app := range apps {
+		rotated, changed, err := secret.Rotate(app.AppSecret)
+		if err != nil {
+			return updated, fmt.Errorf("app %d: %w", app.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := db.Unscoped().Model(&model.App{}).Where("id = ?", app.ID).UpdateColumn("app_secret", rotated).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
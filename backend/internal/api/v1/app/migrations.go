@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"app-platform-backend/core/module"
+
+	"gorm.io/gorm"
+)
+
+// migrationOwner APP相关表在迁移记录中的归属
+const migrationOwner = "app"
+
+func init() {
+	module.RegisterMigrations(migrationOwner, module.Migration{
+		Version:     202610170001,
+		Description: "widen encrypted secret columns of apps and app_api_keys",
+		Up: func(tx *gorm.DB) error {
+			// 历史部署中 app_api_keys 可能从未建表
+			if err := module.CreateTableIfNotExists(tx, &apiKeyV1{}); err != nil {
+				return err
+			}
+			// 加密后的密钥比明文长，旧表的列宽不足以保存
+			if err := widenColumn(tx, "app_api_keys", "api_secret", 255); err != nil {
+				return err
+			}
+			return widenColumn(tx, "apps", "app_secret", 255)
+		},
+		// 加宽列不回滚：已加密的数据无法放回更窄的列
+	})
+}
+
+// apiKeyV1 创建 app_api_keys 时的表结构，与之后的模型变更无关
+type apiKeyV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	AppID       uint   `gorm:"not null;index"`
+	Name        string `gorm:"size:100;not null"`
+	APIKey      string `gorm:"size:64;not null;uniqueIndex"`
+	APISecret   string `gorm:"size:255;not null"`
+	Status      int8   `gorm:"default:1"`
+	Permissions string `gorm:"type:json"`
+	IPWhitelist string `gorm:"type:text"`
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (apiKeyV1) TableName() string {
+	return "app_api_keys"
+}
+
+// widenColumn 将 VARCHAR 列加宽到 size，列已足够宽或不存在时不做修改
+// 只处理 MySQL；SQLite 不限制 VARCHAR 的长度
+func widenColumn(tx *gorm.DB, table, column string, size int64) error {
+	if tx.Dialector.Name() != "mysql" || !tx.Migrator().HasTable(table) {
+		return nil
+	}
+	columns, err := tx.Migrator().ColumnTypes(table)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		if c.Name() != column {
+			continue
+		}
+		if length, ok := c.Length(); !ok || length >= size {
+			// 非定长类型（如 TEXT）或已足够宽
+			return nil
+		}
+		return tx.Exec(fmt.Sprintf("ALTER TABLE `%s` MODIFY `%s` VARCHAR(%d) NOT NULL", table, column, size)).Error
+	}
+	return nil
+}
@@ -35,9 +35,9 @@ type AuditLog struct {
 }
 
 // InitDB 初始化数据库
+// audit_logs 表由审计模块的迁移创建
 func InitDB(database *gorm.DB) {
 	db = database
-	db.AutoMigrate(&AuditLog{})
 }
 
 // RecordAudit 记录审计日志
@@ -317,11 +317,11 @@ func Cleanup(c *gin.Context) {
 		return
 	}
 
-	s := scheduler.GetScheduler()
+	s := scheduler.GetAuditCleanup()
 	if s == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "清理调度器未初始化",
+			"message": "清理服务未初始化",
 		})
 		return
 	}
@@ -349,11 +349,11 @@ func Cleanup(c *gin.Context) {
 func CleanupHistory(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	s := scheduler.GetScheduler()
+	s := scheduler.GetAuditCleanup()
 	if s == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "清理调度器未初始化",
+			"message": "清理服务未初始化",
 		})
 		return
 	}
@@ -375,11 +375,11 @@ func CleanupHistory(c *gin.Context) {
 
 // CleanupConfig 获取清理配置
 func CleanupConfig(c *gin.Context) {
-	s := scheduler.GetScheduler()
+	s := scheduler.GetAuditCleanup()
 	if s == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "清理调度器未初始化",
+			"message": "清理服务未初始化",
 		})
 		return
 	}
@@ -389,7 +389,7 @@ func CleanupConfig(c *gin.Context) {
 		"code": 0,
 		"data": gin.H{
 			"retention_days": config.RetentionDays,
-			"cleanup_hour":   config.CleanupHour,
+			"schedule":       config.Schedule,
 			"batch_size":     config.BatchSize,
 		},
 	})
@@ -1,26 +1,425 @@
+// Package config 远程配置中心：按APP管理配置项草稿，发布时生成不可变的配置快照供客户端拉取
 package config
 
 import (
-	"net/http"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/response"
+	"app-platform-backend/internal/validator"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+var db *gorm.DB
+
+// InitDB 初始化数据库连接
+func InitDB(database *gorm.DB) {
+	db = database
+}
+
+// configKeyPattern 配置键只允许字母、数字和 . _ -，便于客户端按键读取
+var configKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]{0,99}$`)
+
+// errConfigModified 请求携带的 If-Match 与配置当前状态不一致
+var errConfigModified = errors.New("config has been modified")
+
+// NormalizeValue 校验配置值是否符合类型，返回规范化后的字符串
+// number 统一为十进制表示，bool 统一为 true/false，json 去除多余空白
+func NormalizeValue(valueType, value string) (string, error) {
+	switch valueType {
+	case model.ConfigTypeString:
+		return value, nil
+	case model.ConfigTypeNumber:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("值 %q 不是有效的数字", value)
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case model.ConfigTypeBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return "", fmt.Errorf("值 %q 不是有效的布尔值", value)
+		}
+		return strconv.FormatBool(b), nil
+	case model.ConfigTypeJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return "", fmt.Errorf("值不是有效的 JSON: %v", err)
+		}
+		data, _ := json.Marshal(v)
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("无效的类型 %q，请使用: string, number, bool, json", valueType)
+	}
+}
+
+// TypedValue 将已规范化的配置值按类型解码，供客户端直接使用
+func TypedValue(valueType, value string) interface{} {
+	switch valueType {
+	case model.ConfigTypeNumber:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case model.ConfigTypeBool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case model.ConfigTypeJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return v
+		}
+	}
+	return value
+}
+
+// List 环境、命名空间中自身的配置（不含继承的值），支持按键名模糊搜索
+// 未指定时为 default 环境的 application 命名空间，生效值见 Effective
 func List(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "data": []interface{}{}})
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	s, ok := queryScope(c)
+	if !ok {
+		return
+	}
+	page, size := validator.ParsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("size", "20"))
+	page, size = validator.ValidatePagination(page, size)
+
+	query := db.Model(&model.Config{}).Where("app_id = ? AND environment = ? AND namespace = ?", appID, s.Environment, s.Namespace)
+	if keyword := c.Query("keyword"); keyword != "" {
+		query = query.Where("config_key LIKE ?", "%"+keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+
+	var configs []model.Config
+	if err := query.Offset((page - 1) * size).Limit(size).Order("config_key ASC").Find(&configs).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.PageSuccess(c, configs, total, page, size)
 }
 
+// Detail 配置详情，响应头 ETag 用于更新时的 If-Match
+func Detail(c *gin.Context) {
+	cfg, ok := loadConfig(c)
+	if !ok {
+		return
+	}
+	etag.Set(c, cfg)
+	response.Success(c, cfg)
+}
+
+// Create 创建配置项，新配置在发布后才会下发
+// 在非 default 环境中创建与基础环境同名的键即为覆盖该键
 func Create(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "Config created"})
+	var req struct {
+		AppID       uint   `json:"app_id" binding:"required"`
+		Environment string `json:"environment"`
+		Namespace   string `json:"namespace"`
+		ConfigKey   string `json:"config_key" binding:"required"`
+		ConfigValue string `json:"config_value"`
+		ValueType   string `json:"value_type"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+	if !configKeyPattern.MatchString(req.ConfigKey) {
+		response.ParamError(c, "配置键需以字母开头，只能包含字母、数字和 . _ -，长度不超过100")
+		return
+	}
+	s, err := NormalizeScope(req.Environment, req.Namespace)
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+	if req.ValueType == "" {
+		req.ValueType = model.ConfigTypeString
+	}
+	value, err := NormalizeValue(req.ValueType, req.ConfigValue)
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	cfg := model.Config{
+		AppID:       req.AppID,
+		Environment: s.Environment,
+		Namespace:   s.Namespace,
+		ConfigKey:   req.ConfigKey,
+		ConfigValue: value,
+		ValueType:   req.ValueType,
+		Description: req.Description,
+		UpdatedBy:   c.GetString("username"),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if _, err := EnvironmentChain(tx, req.AppID, s.Environment); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.Config{}).Where("app_id = ? AND environment = ? AND namespace = ? AND config_key = ?",
+			req.AppID, s.Environment, s.Namespace, req.ConfigKey).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errConfigKeyExists
+		}
+		if err := tx.Create(&cfg).Error; err != nil {
+			return err
+		}
+		return recordHistory(tx, c, &cfg, "create", "", 0)
+	})
+	// 并发创建同一个键时由唯一索引拦截
+	if errors.Is(err, errConfigKeyExists) || database.IsDuplicateKey(err) {
+		response.Conflict(c, "配置键已存在")
+		return
+	}
+	if err != nil {
+		respondScopeError(c, err)
+		return
+	}
+
+	etag.Set(c, cfg)
+	response.SuccessWithMessage(c, cfg, "配置创建成功")
 }
 
+// errConfigKeyExists 同一APP下配置键重复
+var errConfigKeyExists = errors.New("config key already exists")
+
+// Update 更新配置项，值或类型变化后需要重新发布
 func Update(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "Config updated"})
+	cfg, ok := loadConfig(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ConfigValue *string `json:"config_value"`
+		ValueType   string  `json:"value_type"`
+		Description *string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+
+	// 加锁重新读取，携带 If-Match 时确认配置未被其他人修改
+	var validationErr error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(cfg, cfg.ID).Error; err != nil {
+			return err
+		}
+		if !etag.Match(c, cfg) {
+			return errConfigModified
+		}
+
+		valueType, value := cfg.ValueType, cfg.ConfigValue
+		if req.ValueType != "" {
+			valueType = req.ValueType
+		}
+		if req.ConfigValue != nil {
+			value = *req.ConfigValue
+		}
+		if value, validationErr = NormalizeValue(valueType, value); validationErr != nil {
+			return validationErr
+		}
+
+		updates := map[string]interface{}{"updated_by": c.GetString("username")}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		oldValue := cfg.ConfigValue
+		changed := value != cfg.ConfigValue || valueType != cfg.ValueType
+		if changed {
+			updates["config_value"] = value
+			updates["value_type"] = valueType
+			updates["is_published"] = 0
+		}
+		if err := tx.Model(cfg).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(cfg, cfg.ID).Error; err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		return recordHistory(tx, c, cfg, "update", oldValue, 0)
+	})
+	switch {
+	case errors.Is(err, errConfigModified):
+		etag.Set(c, cfg)
+		response.PreconditionFailed(c, "配置已被他人修改，请刷新后重试", cfg)
+		return
+	case validationErr != nil:
+		response.ParamError(c, validationErr.Error())
+		return
+	case err != nil:
+		response.DBError(c, err)
+		return
+	}
+
+	etag.Set(c, cfg)
+	response.SuccessWithMessage(c, cfg, "配置更新成功")
 }
 
-func Publish(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "message": "Config published"})
+// Delete 删除配置项，下次发布后客户端不再收到该配置
+func Delete(c *gin.Context) {
+	cfg, ok := loadConfig(c)
+	if !ok {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 物理删除，之后可以重新创建同名配置键，删除前的内容保留在历史中
+		if err := tx.Unscoped().Delete(cfg).Error; err != nil {
+			return err
+		}
+		return recordDeletion(tx, c, cfg, "delete", 0)
+	})
+	if err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, nil, "配置删除成功")
 }
 
+// History 配置变更历史
+// 路径带配置ID时返回该配置的历史，否则返回APP全部配置的历史，可按 environment、namespace、config_key 过滤
 func History(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"code": 0, "data": []interface{}{}})
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	page, size := validator.ParsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("size", "20"))
+	page, size = validator.ValidatePagination(page, size)
+
+	query := db.Model(&model.ConfigHistory{}).Where("app_id = ?", appID)
+	if idStr := c.Param("id"); idStr != "" {
+		id, err := validator.ValidateID(idStr)
+		if err != nil {
+			response.ParamError(c, "无效的配置ID")
+			return
+		}
+		query = query.Where("config_id = ?", id)
+	}
+	if env := c.Query("environment"); env != "" {
+		query = query.Where("environment = ?", env)
+	}
+	if ns := c.Query("namespace"); ns != "" {
+		query = query.Where("namespace = ?", ns)
+	}
+	if key := c.Query("config_key"); key != "" {
+		query = query.Where("config_key = ?", key)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	var history []model.ConfigHistory
+	if err := query.Offset((page - 1) * size).Limit(size).Order("id DESC").Find(&history).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.PageSuccess(c, history, total, page, size)
+}
+
+// queryAppID 解析查询参数中的 app_id
+func queryAppID(c *gin.Context) (uint, bool) {
+	appIDStr := c.Query("app_id")
+	if appIDStr == "" {
+		response.ParamError(c, "app_id 不能为空")
+		return 0, false
+	}
+	appID, err := strconv.ParseUint(appIDStr, 10, 32)
+	if err != nil {
+		response.ParamError(c, "无效的 app_id")
+		return 0, false
+	}
+	return uint(appID), true
+}
+
+// loadConfig 加载路径中的配置，同时校验其属于 app_id，防止越权操作
+func loadConfig(c *gin.Context) (*model.Config, bool) {
+	id, err := validator.ValidateID(c.Param("id"))
+	if err != nil {
+		response.ParamError(c, "无效的配置ID")
+		return nil, false
+	}
+	appID, ok := queryAppID(c)
+	if !ok {
+		return nil, false
+	}
+
+	var cfg model.Config
+	if err := db.Where("id = ? AND app_id = ?", id, appID).First(&cfg).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "配置不存在或无权限操作")
+			return nil, false
+		}
+		response.DBError(c, err)
+		return nil, false
+	}
+	return &cfg, true
+}
+
+// recordHistory 记录配置修改，cfg 为修改后的状态
+func recordHistory(tx *gorm.DB, c *gin.Context, cfg *model.Config, operation, oldValue string, releaseID uint) error {
+	return tx.Create(&model.ConfigHistory{
+		ConfigID:    cfg.ID,
+		AppID:       cfg.AppID,
+		Environment: cfg.Environment,
+		Namespace:   cfg.Namespace,
+		ConfigKey:   cfg.ConfigKey,
+		OldValue:    oldValue,
+		ConfigValue: cfg.ConfigValue,
+		ValueType:   cfg.ValueType,
+		OperatorID:  operatorID(c),
+		Operator:    c.GetString("username"),
+		Operation:   operation,
+		ReleaseID:   releaseID,
+	}).Error
+}
+
+// recordDeletion 记录配置删除，删除后的值为空
+func recordDeletion(tx *gorm.DB, c *gin.Context, cfg *model.Config, operation string, releaseID uint) error {
+	return tx.Create(&model.ConfigHistory{
+		ConfigID:    cfg.ID,
+		AppID:       cfg.AppID,
+		Environment: cfg.Environment,
+		Namespace:   cfg.Namespace,
+		ConfigKey:   cfg.ConfigKey,
+		OldValue:    cfg.ConfigValue,
+		ValueType:   cfg.ValueType,
+		OperatorID:  operatorID(c),
+		Operator:    c.GetString("username"),
+		Operation:   operation,
+		ReleaseID:   releaseID,
+	}).Error
+}
+
+func operatorID(c *gin.Context) *uint {
+	if id := c.GetUint("user_id"); id != 0 {
+		return &id
+	}
+	return nil
 }
@@ -0,0 +1,455 @@
+package config
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/response"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// scopeNamePattern 环境名和命名空间只允许小写字母、数字和 _ -
+var scopeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+// ErrEnvironmentNotFound 环境不存在
+var ErrEnvironmentNotFound = errors.New("config environment not found")
+
+// errEnvironmentExists 同一APP下环境名重复
+var errEnvironmentExists = errors.New("config environment already exists")
+
+// Scope 配置所属的环境和命名空间，模块配置也按同样的环境和命名空间区分
+type Scope struct {
+	Environment string
+	Namespace   string
+}
+
+// NormalizeScope 填充默认环境和命名空间并校验格式
+func NormalizeScope(environment, namespace string) (Scope, error) {
+	if environment == "" {
+		environment = model.DefaultConfigEnvironment
+	}
+	if namespace == "" {
+		namespace = model.DefaultConfigNamespace
+	}
+	if !scopeNamePattern.MatchString(environment) || !scopeNamePattern.MatchString(namespace) {
+		return Scope{}, errors.New("环境和命名空间需以小写字母开头，只能包含小写字母、数字和 _ -，长度不超过50")
+	}
+	return Scope{Environment: environment, Namespace: namespace}, nil
+}
+
+// queryScope 解析查询参数中的 environment 和 namespace
+func queryScope(c *gin.Context) (Scope, bool) {
+	s, err := NormalizeScope(c.Query("environment"), c.Query("namespace"))
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return Scope{}, false
+	}
+	return s, true
+}
+
+// EnvironmentChain 返回环境的继承链，从自身开始，以 default 结束
+func EnvironmentChain(tx *gorm.DB, appID uint, environment string) ([]string, error) {
+	// default 环境始终存在；未启用配置中心模块时没有 config_environments 表，也就没有其他环境
+	if environment == model.DefaultConfigEnvironment {
+		return []string{environment}, nil
+	}
+	if !tx.Migrator().HasTable(&model.ConfigEnvironment{}) {
+		return nil, ErrEnvironmentNotFound
+	}
+	var envs []model.ConfigEnvironment
+	if err := tx.Where("app_id = ?", appID).Find(&envs).Error; err != nil {
+		return nil, err
+	}
+	bases := make(map[string]string, len(envs))
+	for _, env := range envs {
+		bases[env.Name] = env.BaseEnvironment
+	}
+
+	chain := []string{environment}
+	for name := environment; name != model.DefaultConfigEnvironment; {
+		base, ok := bases[name]
+		if !ok {
+			return nil, ErrEnvironmentNotFound
+		}
+		// 基础环境在创建后不可修改，正常不会成环；数据被手工改坏时按不存在处理
+		if len(chain) > len(envs)+1 {
+			return nil, ErrEnvironmentNotFound
+		}
+		chain = append(chain, base)
+		name = base
+	}
+	return chain, nil
+}
+
+// effectiveItems 计算环境在命名空间下的生效配置，按键排序
+// 每个键取继承链上最近的环境中的值
+func effectiveItems(tx *gorm.DB, appID uint, s Scope) ([]model.ConfigReleaseItem, error) {
+	chain, err := EnvironmentChain(tx, appID, s.Environment)
+	if err != nil {
+		return nil, err
+	}
+	var configs []model.Config
+	if err := tx.Where("app_id = ? AND namespace = ? AND environment IN ?", appID, s.Namespace, chain).
+		Find(&configs).Error; err != nil {
+		return nil, err
+	}
+
+	depth := make(map[string]int, len(chain))
+	for i, env := range chain {
+		depth[env] = i
+	}
+	nearest := make(map[string]model.Config)
+	for _, cfg := range configs {
+		if current, ok := nearest[cfg.ConfigKey]; !ok || depth[cfg.Environment] < depth[current.Environment] {
+			nearest[cfg.ConfigKey] = cfg
+		}
+	}
+
+	items := make([]model.ConfigReleaseItem, 0, len(nearest))
+	for _, cfg := range nearest {
+		items = append(items, model.ConfigReleaseItem{
+			Key:         cfg.ConfigKey,
+			Value:       cfg.ConfigValue,
+			Type:        cfg.ValueType,
+			Description: cfg.Description,
+			Source:      cfg.Environment,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return items, nil
+}
+
+// respondScopeError 输出环境不存在或数据库错误
+func respondScopeError(c *gin.Context, err error) {
+	if errors.Is(err, ErrEnvironmentNotFound) {
+		response.NotFound(c, "环境不存在")
+		return
+	}
+	// 并发发布或写入同一配置键时由唯一索引拦截，客户端可重试
+	if database.IsDuplicateKey(err) {
+		response.Conflict(c, "配置已被并发修改，请重试")
+		return
+	}
+	response.DBError(c, err)
+}
+
+// Environments 环境列表，内置的 default 环境排在最前
+func Environments(c *gin.Context) {
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	var envs []model.ConfigEnvironment
+	if err := db.Where("app_id = ?", appID).Order("name ASC").Find(&envs).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	list := append([]model.ConfigEnvironment{{
+		AppID:       appID,
+		Name:        model.DefaultConfigEnvironment,
+		Description: "基础环境",
+	}}, envs...)
+	response.Success(c, list)
+}
+
+// CreateEnvironment 创建环境，基础环境默认为 default，创建后不可修改
+func CreateEnvironment(c *gin.Context) {
+	var req struct {
+		AppID           uint   `json:"app_id" binding:"required"`
+		Name            string `json:"name" binding:"required"`
+		BaseEnvironment string `json:"base_environment"`
+		Description     string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.BaseEnvironment == "" {
+		req.BaseEnvironment = model.DefaultConfigEnvironment
+	}
+	if !scopeNamePattern.MatchString(req.Name) {
+		response.ParamError(c, "环境名需以小写字母开头，只能包含小写字母、数字和 _ -，长度不超过50")
+		return
+	}
+	if req.Name == model.DefaultConfigEnvironment {
+		response.Conflict(c, "default 为内置环境")
+		return
+	}
+
+	env := model.ConfigEnvironment{
+		AppID:           req.AppID,
+		Name:            req.Name,
+		BaseEnvironment: req.BaseEnvironment,
+		Description:     req.Description,
+		CreatedBy:       c.GetString("username"),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := EnvironmentChain(tx, req.AppID, req.BaseEnvironment); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.ConfigEnvironment{}).Where("app_id = ? AND name = ?", req.AppID, req.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEnvironmentExists
+		}
+		return tx.Create(&env).Error
+	})
+	switch {
+	case errors.Is(err, ErrEnvironmentNotFound):
+		response.ParamError(c, "基础环境不存在")
+		return
+	case errors.Is(err, errEnvironmentExists):
+		response.Conflict(c, "环境已存在")
+		return
+	case err != nil:
+		response.DBError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, env, "环境创建成功")
+}
+
+// DeleteEnvironment 删除环境，环境中仍有配置（包括模块配置的覆盖值）或被其他环境继承时拒绝删除
+func DeleteEnvironment(c *gin.Context) {
+	id, err := validator.ValidateID(c.Param("env_id"))
+	if err != nil {
+		response.ParamError(c, "无效的环境ID")
+		return
+	}
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+
+	var env model.ConfigEnvironment
+	if err := db.Where("id = ? AND app_id = ?", id, appID).First(&env).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "环境不存在或无权限操作")
+			return
+		}
+		response.DBError(c, err)
+		return
+	}
+
+	var configs, moduleConfigs, children int64
+	if err := db.Model(&model.Config{}).Where("app_id = ? AND environment = ?", appID, env.Name).Count(&configs).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	// 模块配置的覆盖值表由模块管理的迁移创建，只部署配置中心时不存在
+	if db.Migrator().HasTable(&model.AppModuleConfig{}) {
+		if err := db.Model(&model.AppModuleConfig{}).Where("app_id = ? AND environment = ?", appID, env.Name).Count(&moduleConfigs).Error; err != nil {
+			response.DBError(c, err)
+			return
+		}
+	}
+	if err := db.Model(&model.ConfigEnvironment{}).Where("app_id = ? AND base_environment = ?", appID, env.Name).Count(&children).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	if configs > 0 || moduleConfigs > 0 || children > 0 {
+		response.Conflict(c, "环境中仍有配置或被其他环境继承，无法删除")
+		return
+	}
+
+	if err := db.Delete(&env).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, nil, "环境删除成功")
+}
+
+// Namespaces APP下已使用的命名空间，始终包含 application
+func Namespaces(c *gin.Context) {
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	var namespaces []string
+	if err := db.Model(&model.Config{}).Where("app_id = ?", appID).Distinct().Pluck("namespace", &namespaces).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	found := false
+	for _, ns := range namespaces {
+		found = found || ns == model.DefaultConfigNamespace
+	}
+	if !found {
+		namespaces = append(namespaces, model.DefaultConfigNamespace)
+	}
+	sort.Strings(namespaces)
+	response.Success(c, namespaces)
+}
+
+// EffectiveItem 环境中生效的配置项
+type EffectiveItem struct {
+	model.ConfigReleaseItem
+	TypedValue interface{} `json:"typed_value"`
+	Inherited  bool        `json:"inherited"` // 值继承自基础环境，当前环境没有覆盖
+}
+
+// Effective 计算环境在命名空间下的生效配置（草稿，不是已发布的内容）
+func Effective(c *gin.Context) {
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	s, ok := queryScope(c)
+	if !ok {
+		return
+	}
+	items, err := effectiveItems(db, appID, s)
+	if err != nil {
+		respondScopeError(c, err)
+		return
+	}
+	list := make([]EffectiveItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, EffectiveItem{
+			ConfigReleaseItem: item,
+			TypedValue:        TypedValue(item.Type, item.Value),
+			Inherited:         item.Source != s.Environment,
+		})
+	}
+	response.Success(c, gin.H{
+		"environment": s.Environment,
+		"namespace":   s.Namespace,
+		"items":       list,
+	})
+}
+
+// PromoteChange 晋级时对目标环境的一项修改
+type PromoteChange struct {
+	Key      string `json:"key"`
+	Action   string `json:"action"` // create: 新增覆盖值; update: 修改已有覆盖值
+	OldValue string `json:"old_value,omitempty"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+}
+
+// Promote 将源环境某个命名空间的生效配置复制到目标环境
+// 目标环境中生效值已经相同的键跳过，其余写入目标环境作为覆盖值；目标环境独有的键保持不变
+// 只修改草稿，需要在目标环境发布后才会下发；dry_run 为 true 时只返回将要执行的修改
+func Promote(c *gin.Context) {
+	var req struct {
+		AppID     uint   `json:"app_id" binding:"required"`
+		Namespace string `json:"namespace"`
+		From      string `json:"from" binding:"required"`
+		To        string `json:"to" binding:"required"`
+		DryRun    bool   `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+	from, err := NormalizeScope(req.From, req.Namespace)
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+	to, err := NormalizeScope(req.To, req.Namespace)
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+	if from.Environment == to.Environment {
+		response.ParamError(c, "源环境和目标环境不能相同")
+		return
+	}
+
+	var changes []PromoteChange
+	err = db.Transaction(func(tx *gorm.DB) error {
+		source, err := effectiveItems(tx, req.AppID, from)
+		if err != nil {
+			return err
+		}
+		target, err := effectiveItems(tx, req.AppID, to)
+		if err != nil {
+			return err
+		}
+		current := make(map[string]model.ConfigReleaseItem, len(target))
+		for _, item := range target {
+			current[item.Key] = item
+		}
+
+		var own []model.Config
+		if err := tx.Where("app_id = ? AND environment = ? AND namespace = ?", req.AppID, to.Environment, to.Namespace).
+			Find(&own).Error; err != nil {
+			return err
+		}
+		overrides := make(map[string]*model.Config, len(own))
+		for i := range own {
+			overrides[own[i].ConfigKey] = &own[i]
+		}
+
+		changes = []PromoteChange{}
+		for _, item := range source {
+			if existing, ok := current[item.Key]; ok && existing.Value == item.Value && existing.Type == item.Type {
+				continue
+			}
+			change := PromoteChange{Key: item.Key, Action: "create", Value: item.Value, Type: item.Type}
+			cfg, exists := overrides[item.Key]
+			if exists {
+				change.Action = "update"
+				change.OldValue = cfg.ConfigValue
+			}
+			changes = append(changes, change)
+			if req.DryRun {
+				continue
+			}
+
+			if !exists {
+				cfg = &model.Config{
+					AppID:       req.AppID,
+					Environment: to.Environment,
+					Namespace:   to.Namespace,
+					ConfigKey:   item.Key,
+					ConfigValue: item.Value,
+					ValueType:   item.Type,
+					Description: item.Description,
+					UpdatedBy:   c.GetString("username"),
+				}
+				if err := tx.Create(cfg).Error; err != nil {
+					return err
+				}
+			} else {
+				if err := tx.Model(cfg).Updates(map[string]interface{}{
+					"config_value": item.Value,
+					"value_type":   item.Type,
+					"is_published": 0,
+					"updated_by":   c.GetString("username"),
+				}).Error; err != nil {
+					return err
+				}
+				cfg.ConfigValue, cfg.ValueType = item.Value, item.Type
+			}
+			if err := recordHistory(tx, c, cfg, "promote", change.OldValue, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondScopeError(c, err)
+		return
+	}
+
+	message := "配置晋级成功，发布目标环境后生效"
+	if req.DryRun {
+		message = "晋级预览"
+	}
+	response.SuccessWithMessage(c, gin.H{
+		"from":      from.Environment,
+		"to":        to.Environment,
+		"namespace": to.Namespace,
+		"dry_run":   req.DryRun,
+		"changes":   changes,
+	}, message)
+}
@@ -0,0 +1,337 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/response"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errNothingToPublish 配置与最新发布相同
+var errNothingToPublish = errors.New("no config changes since the latest release")
+
+// ReleaseDetail 发布详情：快照中的配置项及按类型解码后的值
+type ReleaseDetail struct {
+	model.ConfigRelease
+	Items  []model.ConfigReleaseItem `json:"items"`
+	Values map[string]interface{}    `json:"values"`
+}
+
+// Publish 将环境在命名空间下的生效配置（含继承的值）快照为一次新的发布
+// 与最新发布相比没有变化时返回 409，避免产生内容相同的发布
+// 基础环境的修改不会自动进入继承它的环境，需要分别发布
+func Publish(c *gin.Context) {
+	var req struct {
+		AppID       uint   `json:"app_id" binding:"required"`
+		Environment string `json:"environment"`
+		Namespace   string `json:"namespace"`
+		Remark      string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+	s, err := NormalizeScope(req.Environment, req.Namespace)
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+
+	var release *model.ConfigRelease
+	err = db.Transaction(func(tx *gorm.DB) error {
+		items, err := effectiveItems(tx, req.AppID, s)
+		if err != nil {
+			return err
+		}
+		latest, err := latestRelease(tx, req.AppID, s)
+		if err != nil {
+			return err
+		}
+		if latest != nil && reflect.DeepEqual(releaseItems(latest), items) {
+			return errNothingToPublish
+		}
+		release, err = createRelease(tx, c, req.AppID, s, items, req.Remark, 0)
+		return err
+	})
+	if errors.Is(err, errNothingToPublish) {
+		response.Conflict(c, "配置没有变化，无需发布")
+		return
+	}
+	if err != nil {
+		respondScopeError(c, err)
+		return
+	}
+	notifyRelease(release)
+	response.SuccessWithMessage(c, release, "配置发布成功")
+}
+
+// Releases 环境、命名空间的发布列表，不包含快照内容
+func Releases(c *gin.Context) {
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	s, ok := queryScope(c)
+	if !ok {
+		return
+	}
+	page, size := validator.ParsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("size", "20"))
+	page, size = validator.ValidatePagination(page, size)
+
+	query := db.Model(&model.ConfigRelease{}).
+		Where("app_id = ? AND environment = ? AND namespace = ?", appID, s.Environment, s.Namespace)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	var releases []model.ConfigRelease
+	if err := query.Offset((page - 1) * size).Limit(size).Order("version DESC").Find(&releases).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.PageSuccess(c, releases, total, page, size)
+}
+
+// Release 发布详情
+func Release(c *gin.Context) {
+	release, ok := loadRelease(c)
+	if !ok {
+		return
+	}
+	response.Success(c, newReleaseDetail(release))
+}
+
+// LatestRelease 环境、命名空间当前生效的发布，尚未发布过时返回 404
+func LatestRelease(c *gin.Context) {
+	appID, ok := queryAppID(c)
+	if !ok {
+		return
+	}
+	s, ok := queryScope(c)
+	if !ok {
+		return
+	}
+	release, err := latestRelease(db, appID, s)
+	if err != nil {
+		response.DBError(c, err)
+		return
+	}
+	if release == nil {
+		response.NotFound(c, "尚未发布配置")
+		return
+	}
+	response.Success(c, newReleaseDetail(release))
+}
+
+// Rollback 回滚到指定发布
+// 发布所在环境的配置草稿恢复为目标快照的内容（新增、修改、删除的配置都会还原），并立即生成一次新的发布
+// 快照中继承自基础环境的值不会写入当前环境，基础环境的草稿保持不变
+// 历史发布保持不变，回滚本身也会出现在发布列表和配置历史中
+func Rollback(c *gin.Context) {
+	target, ok := loadRelease(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Remark string `json:"remark"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.ParamError(c, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	var release *model.ConfigRelease
+	err := db.Transaction(func(tx *gorm.DB) error {
+		items := releaseItems(target)
+		if err := restoreConfigs(tx, c, target, items); err != nil {
+			return err
+		}
+		remark := req.Remark
+		if remark == "" {
+			remark = "rollback to version " + strconv.Itoa(target.Version)
+		}
+		var err error
+		release, err = createRelease(tx, c, target.AppID, releaseScope(target), items, remark, target.Version)
+		return err
+	})
+	if err != nil {
+		// 与并发的发布争用同一版本号时返回 409
+		respondScopeError(c, err)
+		return
+	}
+	notifyRelease(release)
+	response.SuccessWithMessage(c, release, "配置已回滚")
+}
+
+// createRelease 生成新的发布，版本号在环境、命名空间内递增，并将该环境自身的配置标记为已发布
+func createRelease(tx *gorm.DB, c *gin.Context, appID uint, s Scope, items []model.ConfigReleaseItem, remark string, rollbackFrom int) (*model.ConfigRelease, error) {
+	var maxVersion int
+	if err := tx.Model(&model.ConfigRelease{}).
+		Where("app_id = ? AND environment = ? AND namespace = ?", appID, s.Environment, s.Namespace).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	release := &model.ConfigRelease{
+		AppID:        appID,
+		Environment:  s.Environment,
+		Namespace:    s.Namespace,
+		Version:      maxVersion + 1,
+		Snapshot:     string(snapshot),
+		KeyCount:     len(items),
+		Remark:       remark,
+		RollbackFrom: rollbackFrom,
+		PublishedBy:  c.GetString("username"),
+	}
+	if err := tx.Create(release).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.Config{}).
+		Where("app_id = ? AND environment = ? AND namespace = ?", appID, s.Environment, s.Namespace).Updates(map[string]interface{}{
+		"is_published": 1,
+		"published_at": release.CreatedAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// restoreConfigs 将发布所在环境的配置草稿恢复为快照内容，每项变化都记录为 rollback 历史
+func restoreConfigs(tx *gorm.DB, c *gin.Context, target *model.ConfigRelease, items []model.ConfigReleaseItem) error {
+	var current []model.Config
+	if err := tx.Where("app_id = ? AND environment = ? AND namespace = ?", target.AppID, target.Environment, target.Namespace).
+		Find(&current).Error; err != nil {
+		return err
+	}
+	byKey := make(map[string]*model.Config, len(current))
+	for i := range current {
+		byKey[current[i].ConfigKey] = &current[i]
+	}
+
+	for _, item := range items {
+		// 继承的值属于基础环境；引入环境之前的快照没有 Source，均属于自身
+		if item.Source != "" && item.Source != target.Environment {
+			continue
+		}
+		cfg, exists := byKey[item.Key]
+		delete(byKey, item.Key)
+		if !exists {
+			cfg = &model.Config{
+				AppID:       target.AppID,
+				Environment: target.Environment,
+				Namespace:   target.Namespace,
+				ConfigKey:   item.Key,
+				ConfigValue: item.Value,
+				ValueType:   item.Type,
+				Description: item.Description,
+				UpdatedBy:   c.GetString("username"),
+			}
+			if err := tx.Create(cfg).Error; err != nil {
+				return err
+			}
+			if err := recordHistory(tx, c, cfg, "rollback", "", target.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if cfg.ConfigValue == item.Value && cfg.ValueType == item.Type && cfg.Description == item.Description {
+			continue
+		}
+		oldValue := cfg.ConfigValue
+		if err := tx.Model(cfg).Updates(map[string]interface{}{
+			"config_value": item.Value,
+			"value_type":   item.Type,
+			"description":  item.Description,
+			"updated_by":   c.GetString("username"),
+		}).Error; err != nil {
+			return err
+		}
+		cfg.ConfigValue, cfg.ValueType = item.Value, item.Type
+		if err := recordHistory(tx, c, cfg, "rollback", oldValue, target.ID); err != nil {
+			return err
+		}
+	}
+
+	// 目标快照中不属于当前环境的配置需要删除
+	for _, cfg := range byKey {
+		if err := tx.Unscoped().Delete(cfg).Error; err != nil {
+			return err
+		}
+		if err := recordDeletion(tx, c, cfg, "rollback", target.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latestRelease 环境、命名空间最新的发布，没有发布时返回 nil
+func latestRelease(tx *gorm.DB, appID uint, s Scope) (*model.ConfigRelease, error) {
+	var releases []model.ConfigRelease
+	if err := tx.Where("app_id = ? AND environment = ? AND namespace = ?", appID, s.Environment, s.Namespace).
+		Order("version DESC").Limit(1).Find(&releases).Error; err != nil {
+		return nil, err
+	}
+	if len(releases) == 0 {
+		return nil, nil
+	}
+	return &releases[0], nil
+}
+
+// loadRelease 加载路径中的发布，同时校验其属于 app_id
+func loadRelease(c *gin.Context) (*model.ConfigRelease, bool) {
+	id, err := validator.ValidateID(c.Param("release_id"))
+	if err != nil {
+		response.ParamError(c, "无效的发布ID")
+		return nil, false
+	}
+	appID, ok := queryAppID(c)
+	if !ok {
+		return nil, false
+	}
+
+	var release model.ConfigRelease
+	if err := db.Where("id = ? AND app_id = ?", id, appID).First(&release).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "发布不存在或无权限操作")
+			return nil, false
+		}
+		response.DBError(c, err)
+		return nil, false
+	}
+	return &release, true
+}
+
+// releaseScope 发布所属的环境和命名空间
+func releaseScope(release *model.ConfigRelease) Scope {
+	return Scope{Environment: release.Environment, Namespace: release.Namespace}
+}
+
+// releaseItems 解析发布快照
+func releaseItems(release *model.ConfigRelease) []model.ConfigReleaseItem {
+	items := []model.ConfigReleaseItem{}
+	json.Unmarshal([]byte(release.Snapshot), &items)
+	return items
+}
+
+func newReleaseDetail(release *model.ConfigRelease) ReleaseDetail {
+	items := releaseItems(release)
+	values := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		values[item.Key] = TypedValue(item.Type, item.Value)
+	}
+	return ReleaseDetail{ConfigRelease: *release, Items: items, Values: values}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"app-platform-backend/internal/middleware"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSDKWait 长轮询最长等待时间，需小于服务器的 write_timeout
+const MaxSDKWait = 30 * time.Second
+
+// SDKConfig 下发给客户端的配置
+// 尚未发布过配置时 version 为 0，values 为空
+type SDKConfig struct {
+	Environment string                 `json:"environment"`
+	Namespace   string                 `json:"namespace"`
+	Version     int                    `json:"version"`
+	Values      map[string]interface{} `json:"values"`
+	PublishedAt *time.Time             `json:"published_at"`
+}
+
+// SDKFetch 客户端获取环境、命名空间最新发布的配置，默认为 default 环境的 application 命名空间
+// 响应携带 ETag；请求的 If-None-Match 与之相同时返回 304
+// 携带 wait（秒，最长 30）时为长轮询：配置未变化则挂起请求，直到有新发布或超时，超时返回 304
+func SDKFetch(c *gin.Context) {
+	app := middleware.SDKApp(c)
+	if app == nil {
+		response.Unauthorized(c, "")
+		return
+	}
+	s, ok := queryScope(c)
+	if !ok {
+		return
+	}
+	if _, err := EnvironmentChain(db, app.ID, s.Environment); err != nil {
+		respondScopeError(c, err)
+		return
+	}
+
+	wait := time.Duration(0)
+	if v := c.Query("wait"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			response.ParamError(c, "无效的等待时间")
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+		if wait > MaxSDKWait {
+			wait = MaxSDKWait
+		}
+	}
+
+	current, tag, err := waitSDKConfig(c, app.ID, s, wait)
+	if err != nil {
+		response.DBError(c, err)
+		return
+	}
+	if current == nil {
+		// 客户端已断开
+		return
+	}
+
+	c.Header("ETag", tag)
+	if c.GetHeader("If-None-Match") == tag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	response.Success(c, current)
+}
+
+// waitSDKConfig 读取配置；与 If-None-Match 相同时等待新的发布，直到配置变化或超时
+// 同一APP其他环境、命名空间的发布也会唤醒等待，此时重新读取比较后继续等待
+func waitSDKConfig(c *gin.Context, appID uint, s Scope, wait time.Duration) (*SDKConfig, string, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		// 先登记再读取，读取之后的发布不会被漏掉
+		changed, done := releaseWaiters.watch(appID)
+		current, releaseID, err := loadSDKConfig(appID, s)
+		if err != nil {
+			done()
+			return nil, "", err
+		}
+		tag := etag.Of(current)
+		remaining := time.Until(deadline)
+		if tag != c.GetHeader("If-None-Match") || remaining <= 0 {
+			done()
+			return current, tag, nil
+		}
+
+		releaseWaiters.observe(appID, releaseID)
+		timer := time.NewTimer(remaining)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			timer.Stop()
+			done()
+			return nil, "", nil
+		}
+		timer.Stop()
+		done()
+	}
+}
+
+// loadSDKConfig 读取最新发布的配置，同时返回发布ID（没有发布时为 0）
+func loadSDKConfig(appID uint, s Scope) (*SDKConfig, uint, error) {
+	cfg := &SDKConfig{Environment: s.Environment, Namespace: s.Namespace, Values: map[string]interface{}{}}
+	release, err := latestRelease(db, appID, s)
+	if err != nil || release == nil {
+		return cfg, 0, err
+	}
+	cfg.Version = release.Version
+	cfg.Values = newReleaseDetail(release).Values
+	cfg.PublishedAt = &release.CreatedAt
+	return cfg, release.ID, nil
+}
+
+// notifyRelease 唤醒等待该APP配置的长轮询请求，在发布事务提交后调用
+func notifyRelease(release *model.ConfigRelease) {
+	releaseWaiters.notify(release.AppID, release.ID)
+}
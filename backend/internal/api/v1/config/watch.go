@@ -0,0 +1,121 @@
+package config
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"app-platform-backend/internal/model"
+)
+
+// DefaultReleasePollInterval 长轮询期间检查其他实例发布的间隔
+// 无论有多少客户端在等待，每个实例每个间隔只执行一次查询；本实例的发布会立即唤醒等待方
+const DefaultReleasePollInterval = 3 * time.Second
+
+// releaseWatch 某个APP的等待状态
+// 以发布ID而不是版本号判断新旧：版本号只在环境、命名空间内递增，发布ID在APP内单调递增
+// changed 在发现更新的发布时关闭并替换，等待方持有旧的通道即可收到通知
+type releaseWatch struct {
+	releaseID uint
+	changed   chan struct{}
+	waiters   int
+}
+
+// releaseHub 管理等待配置发布的长轮询请求
+type releaseHub struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	watches map[uint]*releaseWatch
+	polling bool
+}
+
+var releaseWaiters = newReleaseHub(DefaultReleasePollInterval)
+
+func newReleaseHub(interval time.Duration) *releaseHub {
+	return &releaseHub{interval: interval, watches: make(map[uint]*releaseWatch)}
+}
+
+// watch 登记一个等待方，返回发布变化时关闭的通道及取消登记的函数
+// 调用方应先登记再读取最新发布，这样读取之后的发布不会被漏掉
+func (h *releaseHub) watch(appID uint) (<-chan struct{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.watches[appID]
+	if !ok {
+		w = &releaseWatch{changed: make(chan struct{})}
+		h.watches[appID] = w
+	}
+	w.waiters++
+	if !h.polling {
+		h.polling = true
+		go h.poll()
+	}
+
+	var once sync.Once
+	return w.changed, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if w.waiters--; w.waiters == 0 {
+				delete(h.watches, appID)
+			}
+		})
+	}
+}
+
+// observe 记录等待方已读取到的发布，作为轮询比较的基线
+func (h *releaseHub) observe(appID, releaseID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if w, ok := h.watches[appID]; ok && releaseID > w.releaseID {
+		w.releaseID = releaseID
+	}
+}
+
+// notify 通知APP产生了新的发布，不比已知发布更新时忽略
+func (h *releaseHub) notify(appID, releaseID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, ok := h.watches[appID]
+	if !ok || releaseID <= w.releaseID {
+		return
+	}
+	w.releaseID = releaseID
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// poll 在有等待方期间定期批量查询最新发布，发现其他实例的发布；没有等待方时退出
+func (h *releaseHub) poll() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.Lock()
+		if len(h.watches) == 0 {
+			h.polling = false
+			h.mu.Unlock()
+			return
+		}
+		appIDs := make([]uint, 0, len(h.watches))
+		for appID := range h.watches {
+			appIDs = append(appIDs, appID)
+		}
+		h.mu.Unlock()
+
+		var latest []struct {
+			AppID     uint
+			ReleaseID uint
+		}
+		if err := db.Model(&model.ConfigRelease{}).Select("app_id, MAX(id) AS release_id").
+			Where("app_id IN ?", appIDs).Group("app_id").Scan(&latest).Error; err != nil {
+			log.Printf("[Config] Poll latest releases failed: %v", err)
+			continue
+		}
+		for _, row := range latest {
+			h.notify(row.AppID, row.ReleaseID)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"app-platform-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestReleaseHub_PollsOtherInstances(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "watch.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&model.ConfigRelease{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	InitDB(database)
+
+	database.Create(&model.ConfigRelease{AppID: 1, Version: 1, Snapshot: "[]"})
+	hub := newReleaseHub(10 * time.Millisecond)
+	changed, done := hub.watch(1)
+	defer done()
+	hub.observe(1, 1)
+
+	// 不比已知发布更新时不唤醒
+	hub.notify(1, 1)
+	select {
+	case <-changed:
+		t.Fatal("woken without a newer release")
+	default:
+	}
+
+	// 其他实例写入的发布由轮询发现
+	database.Create(&model.ConfigRelease{AppID: 1, Environment: "prod", Version: 1, Snapshot: "[]"})
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("release from another instance was not observed")
+	}
+
+	done()
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if len(hub.watches) != 0 {
+		t.Errorf("watches not released: %v", hub.watches)
+	}
+}
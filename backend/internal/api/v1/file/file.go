@@ -20,6 +20,28 @@ import (
 var db *gorm.DB
 var uploadDir = "/tmp/uploads"
 
+// CheckStorageWritable 检查上传目录是否可写，用于健康检查
+func CheckStorageWritable() error {
+	return CheckDirWritable(uploadDir)
+}
+
+// CheckDirWritable 检查目录是否可写：不存在时尝试创建，并写入、删除一个临时文件
+func CheckDirWritable(dir string) error {
+	if dir == "" {
+		dir = uploadDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("upload dir unavailable: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return fmt.Errorf("upload dir not writable: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
 // 允许的文件类型
 var allowedMimeTypes = map[string]bool{
 	"image/jpeg":      true,
@@ -1,32 +1,52 @@
 package health
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
+	"app-platform-backend/core/module"
 	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
 )
 
 var startTime = time.Now()
 
+// 健康状态
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"  // 非关键检查失败，服务可用但部分功能受影响
+	StatusUnhealthy = "unhealthy" // 关键检查失败，服务不可用
+)
+
+// DefaultCheckTimeout 单项检查的默认超时时间
+const DefaultCheckTimeout = 2 * time.Second
+
 // HealthStatus 健康状态
 type HealthStatus struct {
 	Status    string                 `json:"status"`
 	Timestamp int64                  `json:"timestamp"`
 	Uptime    float64                `json:"uptime"`
 	Version   string                 `json:"version"`
+	Modules   int                    `json:"modules"`
+	Leader    *scheduler.LeaderInfo  `json:"leader,omitempty"`
 	Checks    map[string]CheckResult `json:"checks"`
 	System    SystemInfo             `json:"system"`
 }
 
 // CheckResult 检查结果
 type CheckResult struct {
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
-	Latency int64  `json:"latency_ms,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Latency  int64  `json:"latency_ms"`
+	Module   string `json:"module,omitempty"`
+	Critical bool   `json:"critical"`
 }
 
 // SystemInfo 系统信息
@@ -38,21 +58,9 @@ type SystemInfo struct {
 	MemSys       uint64 `json:"mem_sys_mb"`
 }
 
-// Check 健康检查
+// Check 健康检查，汇总数据库及所有模块的检查结果
 func Check(c *gin.Context) {
-	checks := make(map[string]CheckResult)
-
-	// 数据库检查
-	checks["database"] = checkDatabase()
-
-	// 计算整体状态
-	overallStatus := "healthy"
-	for _, check := range checks {
-		if check.Status != "healthy" {
-			overallStatus = "unhealthy"
-			break
-		}
-	}
+	checks, overallStatus := runChecks(c.Request.Context(), collectChecks(false))
 
 	// 获取系统信息
 	var memStats runtime.MemStats
@@ -63,6 +71,8 @@ func Check(c *gin.Context) {
 		Timestamp: time.Now().Unix(),
 		Uptime:    time.Since(startTime).Seconds(),
 		Version:   "1.0.0",
+		Modules:   module.GetModuleCount(),
+		Leader:    currentLeader(c.Request.Context()),
 		Checks:    checks,
 		System: SystemInfo{
 			GoVersion:    runtime.Version(),
@@ -74,7 +84,7 @@ func Check(c *gin.Context) {
 	}
 
 	httpStatus := http.StatusOK
-	if overallStatus != "healthy" {
+	if overallStatus == StatusUnhealthy {
 		httpStatus = http.StatusServiceUnavailable
 	}
 
@@ -84,64 +94,175 @@ func Check(c *gin.Context) {
 	})
 }
 
-// checkDatabase 检查数据库连接
-func checkDatabase() CheckResult {
-	start := time.Now()
-
-	db := database.GetDB()
-	if db == nil {
-		return CheckResult{
-			Status:  "unhealthy",
-			Message: "Database not initialized",
-		}
+// currentLeader 返回任务调度的当前 leader，未启用选主或查询失败时返回 nil
+func currentLeader(ctx context.Context) *scheduler.LeaderInfo {
+	s := scheduler.Default()
+	if s == nil {
+		return nil
 	}
-
-	sqlDB, err := db.DB()
+	leader, err := s.Leader(ctx)
 	if err != nil {
-		return CheckResult{
-			Status:  "unhealthy",
-			Message: "Failed to get database connection: " + err.Error(),
-		}
-	}
-
-	if err := sqlDB.Ping(); err != nil {
-		return CheckResult{
-			Status:  "unhealthy",
-			Message: "Database ping failed: " + err.Error(),
-			Latency: time.Since(start).Milliseconds(),
-		}
-	}
-
-	return CheckResult{
-		Status:  "healthy",
-		Latency: time.Since(start).Milliseconds(),
+		return nil
 	}
+	return leader
 }
 
 // Liveness 存活探针
+// 只执行模块声明为 Liveness 的检查，任一失败即返回 503，提示编排系统重启进程
 func Liveness(c *gin.Context) {
+	checks, _ := runChecks(c.Request.Context(), collectChecks(true))
+	for _, check := range checks {
+		if check.Status != StatusHealthy {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status": "not alive",
+				"checks": checks,
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "alive",
+		"checks": checks,
 	})
 }
 
 // Readiness 就绪探针
+// 关键检查失败时返回 503，降级时仍视为就绪
 func Readiness(c *gin.Context) {
-	// 检查数据库连接
-	dbCheck := checkDatabase()
-	if dbCheck.Status != "healthy" {
+	checks, overallStatus := runChecks(c.Request.Context(), collectChecks(false))
+	if overallStatus == StatusUnhealthy {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status":  "not ready",
-			"message": dbCheck.Message,
+			"status": "not ready",
+			"health": overallStatus,
+			"checks": checks,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status": "ready",
+		"health": overallStatus,
+		"checks": checks,
 	})
 }
 
+// collectChecks 收集需要执行的检查项，livenessOnly 为 true 时只返回存活检查
+func collectChecks(livenessOnly bool) []module.ModuleHealthCheck {
+	var checks []module.ModuleHealthCheck
+	if !livenessOnly {
+		checks = append(checks, module.ModuleHealthCheck{
+			HealthCheck: module.HealthCheck{Name: "database", Critical: true, Check: checkDatabase},
+		})
+	}
+	for _, check := range module.GetAllHealthChecks() {
+		if livenessOnly && !check.Liveness {
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// runChecks 并发执行所有检查并计算整体状态
+// 关键检查失败为 unhealthy，仅非关键检查失败为 degraded
+func runChecks(ctx context.Context, checks []module.ModuleHealthCheck) (map[string]CheckResult, string) {
+	results := make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check module.ModuleHealthCheck) {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+
+			key := check.Name
+			if check.Module != "" {
+				key = check.Module + "." + check.Name
+			}
+			mu.Lock()
+			results[key] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	overallStatus := StatusHealthy
+	for _, result := range results {
+		if result.Status == StatusHealthy {
+			continue
+		}
+		if result.Critical {
+			overallStatus = StatusUnhealthy
+			break
+		}
+		overallStatus = StatusDegraded
+	}
+	return results, overallStatus
+}
+
+// runCheck 在超时时间内执行单项检查，检查函数 panic 时视为失败
+func runCheck(ctx context.Context, check module.ModuleHealthCheck) CheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := CheckResult{Module: check.Module, Critical: check.Critical}
+	failedStatus := StatusDegraded
+	if check.Critical {
+		failedStatus = StatusUnhealthy
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		result.Latency = time.Since(start).Milliseconds()
+		if err != nil {
+			result.Status = failedStatus
+			result.Message = err.Error()
+			return result
+		}
+		result.Status = StatusHealthy
+	case <-ctx.Done():
+		result.Latency = time.Since(start).Milliseconds()
+		result.Status = failedStatus
+		result.Message = fmt.Sprintf("check timed out after %s", timeout)
+	}
+	return result
+}
+
+// checkDatabase 检查数据库连接
+func checkDatabase(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("Database not initialized")
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("Failed to get database connection: %w", err)
+	}
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("Database ping failed: %w", err)
+	}
+	return nil
+}
+
 // Metrics 简单的指标端点
 func Metrics(c *gin.Context) {
 	var memStats runtime.MemStats
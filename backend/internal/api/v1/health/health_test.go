@@ -0,0 +1,48 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+)
+
+func check(name string, critical bool, fn func(ctx context.Context) error) module.ModuleHealthCheck {
+	return module.ModuleHealthCheck{
+		Module:      "test",
+		HealthCheck: module.HealthCheck{Name: name, Critical: critical, Timeout: 50 * time.Millisecond, Check: fn},
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("boom") }
+	hang := func(ctx context.Context) error { <-ctx.Done(); time.Sleep(10 * time.Millisecond); return nil }
+	panics := func(ctx context.Context) error { panic("bad check") }
+
+	tests := []struct {
+		name   string
+		checks []module.ModuleHealthCheck
+		want   string
+	}{
+		{name: "all healthy", checks: []module.ModuleHealthCheck{check("a", true, ok), check("b", false, ok)}, want: StatusHealthy},
+		{name: "non-critical failure", checks: []module.ModuleHealthCheck{check("a", true, ok), check("b", false, fail)}, want: StatusDegraded},
+		{name: "critical failure", checks: []module.ModuleHealthCheck{check("a", true, fail), check("b", false, fail)}, want: StatusUnhealthy},
+		{name: "critical timeout", checks: []module.ModuleHealthCheck{check("a", true, hang)}, want: StatusUnhealthy},
+		{name: "panic", checks: []module.ModuleHealthCheck{check("a", false, panics)}, want: StatusDegraded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, status := runChecks(context.Background(), tt.checks)
+			if status != tt.want {
+				t.Errorf("status = %s, want %s (%v)", status, tt.want, results)
+			}
+			if len(results) != len(tt.checks) {
+				t.Errorf("got %d results, want %d", len(results), len(tt.checks))
+			}
+		})
+	}
+}
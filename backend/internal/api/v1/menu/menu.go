@@ -1,11 +1,13 @@
 package menu
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/etag"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -151,17 +153,40 @@ func UpdateMenu(c *gin.Context) {
 	delete(updateData, "app_id")
 	delete(updateData, "id")
 
-	if err := db.Model(&menu).Updates(updateData).Error; err != nil {
+	// 加锁重新读取，携带 If-Match 时确认菜单未被其他人修改
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(&menu, menu.ID).Error; err != nil {
+			return err
+		}
+		if !etag.Match(c, menu) {
+			return errMenuModified
+		}
+		if err := tx.Model(&menu).Updates(updateData).Error; err != nil {
+			return err
+		}
+		return tx.First(&menu, menu.ID).Error
+	})
+	if errors.Is(err, errMenuModified) {
+		etag.Set(c, menu)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"code": 412, "message": "菜单已被他人修改，请刷新后重试", "data": menu})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "更新菜单失败"})
 		return
 	}
 
+	etag.Set(c, menu)
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "更新成功",
+		"data":    menu,
 	})
 }
 
+// errMenuModified 请求携带的 If-Match 与菜单当前状态不一致
+var errMenuModified = errors.New("menu has been modified")
+
 // DeleteMenu 删除菜单
 func DeleteMenu(c *gin.Context) {
 	appIDParam := c.Param("id")
@@ -221,6 +246,7 @@ func GetMenuDetail(c *gin.Context) {
 		return
 	}
 
+	etag.Set(c, menu)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": menu,
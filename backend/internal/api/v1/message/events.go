@@ -0,0 +1,60 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+)
+
+// OnPushSent 推送发送后写入站内信：指定用户的推送为每个用户写一条，其余写一条APP级消息
+func OnPushSent(ctx context.Context, e module.PushSentEvent) error {
+	if db == nil {
+		return errors.New("message module not initialized")
+	}
+
+	var messages []model.Message
+	if e.TargetType == "user" {
+		for _, target := range e.TargetIDs {
+			userID, err := strconv.ParseUint(target, 10, 32)
+			if err != nil {
+				continue
+			}
+			uid := uint(userID)
+			messages = append(messages, model.Message{AppID: e.AppID, UserID: &uid, Title: e.Title, Content: e.Content, Type: "push"})
+		}
+	}
+	if len(messages) == 0 {
+		messages = append(messages, model.Message{AppID: e.AppID, Title: e.Title, Content: e.Content, Type: "push"})
+	}
+
+	if err := db.WithContext(ctx).Create(&messages).Error; err != nil {
+		return fmt.Errorf("create inbox messages for push %d: %w", e.PushID, err)
+	}
+	return nil
+}
+
+// OnVersionPublished 版本发布后写入一条APP级更新通知
+func OnVersionPublished(ctx context.Context, e module.VersionPublishedEvent) error {
+	if db == nil {
+		return errors.New("message module not initialized")
+	}
+
+	content := e.Description
+	if content == "" {
+		content = fmt.Sprintf("新版本 %s 已发布", e.VersionName)
+	}
+	message := model.Message{
+		AppID:   e.AppID,
+		Title:   fmt.Sprintf("版本更新：%s", e.VersionName),
+		Content: content,
+		Type:    "version",
+	}
+	if err := db.WithContext(ctx).Create(&message).Error; err != nil {
+		return fmt.Errorf("create version notice for version %d: %w", e.VersionID, err)
+	}
+	return nil
+}
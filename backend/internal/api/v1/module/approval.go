@@ -0,0 +1,527 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	coremodule "app-platform-backend/core/module"
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 配置变更申请的操作
+const (
+	activitySubmit  = "submit"
+	activityComment = "comment"
+	activityApprove = "approve"
+	activityReject  = "reject"
+	activityCancel  = "cancel"
+	activityApply   = "apply"
+)
+
+var (
+	// errChangeStatus 申请状态已被并发操作修改
+	errChangeStatus = errors.New("change request status has changed")
+	// errChangeStale 申请提交后配置已被其他变更修改
+	errChangeStale = errors.New("module config has changed since the request was submitted")
+)
+
+// requiresApproval APP是否要求配置变更经过审批
+func requiresApproval(db *gorm.DB, appID uint) bool {
+	var policy model.ConfigApprovalPolicy
+	if err := db.Where("app_id = ?", appID).Limit(1).Find(&policy).Error; err != nil {
+		return false
+	}
+	return policy.RequireApproval
+}
+
+// GetApprovalPolicy 获取APP的配置审批设置
+func GetApprovalPolicy(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	policy := model.ConfigApprovalPolicy{AppID: appID}
+	database.GetDB().Where("app_id = ?", appID).Limit(1).Find(&policy)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": policy,
+	})
+}
+
+// SetApprovalPolicy 设置APP的配置变更是否需要审批
+// 关闭审批不影响已提交的申请，它们仍可继续审批和应用
+func SetApprovalPolicy(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	var req struct {
+		RequireApproval *bool `json:"require_approval" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := model.ConfigApprovalPolicy{
+		AppID:           appID,
+		RequireApproval: *req.RequireApproval,
+		UpdatedBy:       c.GetString("username"),
+	}
+	if err := database.GetDB().Save(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save approval policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Approval policy updated",
+		"data":    policy,
+	})
+}
+
+// SubmitConfigChange 提交配置变更申请，配置在审批通过并应用后才生效
+// 与保存配置一样通过查询参数 environment、namespace 指定修改的范围
+func SubmitConfigChange(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+	moduleCode := c.Param("module_code")
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Config map[string]interface{} `json:"config" binding:"required"`
+		Remark string                 `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	var module model.AppModule
+	if err := db.Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+
+	schema, err := loadConfigSchema(db, moduleCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config schema"})
+		return
+	}
+	target, plain, sealed, ok := resolveScopedConfig(c, &module, s, schema, req.Config)
+	if !ok {
+		return
+	}
+	if err := validator.ValidateModuleConfig(moduleCode, schema, plain); err != nil {
+		respondConfigInvalid(c, err)
+		return
+	}
+	createConfigChange(c, target, sealed, req.Remark)
+}
+
+// createConfigChange 为已校验、敏感字段已加密的配置创建待审批的申请，SaveModuleConfig 在需要审批时也走这里
+// 申请以范围内当前保存的配置作为基线
+func createConfigChange(c *gin.Context, target *scopedConfig, config map[string]interface{}, remark string) {
+	configJSON, _ := json.Marshal(config)
+	request := model.ConfigChangeRequest{
+		AppID:         target.Module.AppID,
+		ModuleCode:    target.Module.ModuleCode,
+		Environment:   target.Scope.Environment,
+		Namespace:     target.Scope.Namespace,
+		Config:        string(configJSON),
+		BaseConfig:    target.raw(),
+		Status:        model.ConfigChangePending,
+		Remark:        remark,
+		SubmittedBy:   c.GetString("username"),
+		SubmittedByID: c.GetUint("user_id"),
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, c, request.ID, activitySubmit, remark)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit config change"})
+		return
+	}
+	publishConfigReview(c, &request, activitySubmit, remark)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    0,
+		"message": "Config change submitted for approval",
+		"data":    maskedChangeRequest(newSchemaCache(database.GetDB()), &request),
+	})
+}
+
+// ListConfigChanges 配置变更申请列表，可按 status、module_code、environment、namespace 过滤
+func ListConfigChanges(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	query := database.GetDB().Where("app_id = ?", appID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if moduleCode := c.Query("module_code"); moduleCode != "" {
+		query = query.Where("module_code = ?", moduleCode)
+	}
+	if environment := c.Query("environment"); environment != "" {
+		query = query.Where("environment = ?", environment)
+	}
+	if namespace := c.Query("namespace"); namespace != "" {
+		query = query.Where("namespace = ?", namespace)
+	}
+
+	var requests []model.ConfigChangeRequest
+	query.Order("id DESC").Limit(100).Find(&requests)
+
+	schemas := newSchemaCache(database.GetDB())
+	for i := range requests {
+		requests[i] = maskedChangeRequest(schemas, &requests[i])
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": requests,
+	})
+}
+
+// GetConfigChange 申请详情，包含操作记录和相对所在范围当前配置的差异
+func GetConfigChange(c *gin.Context) {
+	request, ok := loadConfigChange(c)
+	if !ok {
+		return
+	}
+
+	var activities []model.ConfigChangeActivity
+	database.GetDB().Where("request_id = ?", request.ID).Order("id ASC").Find(&activities)
+
+	module := model.AppModule{AppID: request.AppID, ModuleCode: request.ModuleCode}
+	database.GetDB().Where("app_id = ? AND module_code = ?", request.AppID, request.ModuleCode).First(&module)
+	current := map[string]interface{}{}
+	if target, err := loadScopedConfig(database.GetDB(), &module, requestScope(request)); err == nil {
+		current = openStoredConfig(target.raw())
+	}
+	proposed := openStoredConfig(request.Config)
+
+	// 按明文比较，返回前遮盖敏感字段
+	schemas := newSchemaCache(database.GetDB())
+	schema := schemas.get(request.ModuleCode)
+	maskedCurrent, maskedProposed := maskConfigPair(schema, current, proposed)
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"request":    maskedChangeRequest(schemas, request),
+			"activities": activities,
+			"diff":       maskDiff(schema, current, proposed, diffConfig(current, proposed)),
+			"unified":    unifiedDiff("current", fmt.Sprintf("request #%d", request.ID), maskedCurrent, maskedProposed),
+			// 提交后配置被其他变更修改过，此时申请无法应用，需要重新提交
+			"stale": len(diffConfig(openStoredConfig(request.BaseConfig), current)) > 0,
+		},
+	})
+}
+
+// CommentConfigChange 在申请下发表评论
+func CommentConfigChange(c *gin.Context) {
+	request, ok := loadConfigChange(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	activity := model.ConfigChangeActivity{
+		RequestID:  request.ID,
+		Action:     activityComment,
+		Operator:   c.GetString("username"),
+		OperatorID: c.GetUint("user_id"),
+		Content:    req.Content,
+	}
+	if err := database.GetDB().Create(&activity).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
+		return
+	}
+	publishConfigReview(c, request, activityComment, req.Content)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": activity,
+	})
+}
+
+// ApproveConfigChange 审批通过，审批人不能是提交人
+func ApproveConfigChange(c *gin.Context) {
+	reviewConfigChange(c, activityApprove, model.ConfigChangeApproved)
+}
+
+// RejectConfigChange 驳回申请，需填写驳回原因
+func RejectConfigChange(c *gin.Context) {
+	reviewConfigChange(c, activityReject, model.ConfigChangeRejected)
+}
+
+func reviewConfigChange(c *gin.Context, action, status string) {
+	request, ok := loadConfigChange(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if action == activityReject && req.Comment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comment is required when rejecting"})
+		return
+	}
+	if request.SubmittedByID == c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Config change must be reviewed by another admin"})
+		return
+	}
+
+	now := time.Now()
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := transitionConfigChange(tx, request, model.ConfigChangePending, map[string]interface{}{
+			"status":         status,
+			"reviewed_by":    c.GetString("username"),
+			"reviewed_by_id": c.GetUint("user_id"),
+			"reviewed_at":    now,
+			"review_comment": req.Comment,
+		}); err != nil {
+			return err
+		}
+		return recordActivity(tx, c, request.ID, action, req.Comment)
+	})
+	if !respondTransitionError(c, err) {
+		return
+	}
+	publishConfigReview(c, request, action, req.Comment)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": maskedChangeRequest(newSchemaCache(database.GetDB()), request),
+	})
+}
+
+// CancelConfigChange 提交人撤回尚未应用的申请
+func CancelConfigChange(c *gin.Context) {
+	request, ok := loadConfigChange(c)
+	if !ok {
+		return
+	}
+	if request.SubmittedByID != c.GetUint("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the submitter can cancel the request"})
+		return
+	}
+	if request.Status != model.ConfigChangePending && request.Status != model.ConfigChangeApproved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request is already " + request.Status})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := transitionConfigChange(tx, request, request.Status, map[string]interface{}{
+			"status": model.ConfigChangeCancelled,
+		}); err != nil {
+			return err
+		}
+		return recordActivity(tx, c, request.ID, activityCancel, "")
+	})
+	if !respondTransitionError(c, err) {
+		return
+	}
+	publishConfigReview(c, request, activityCancel, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": maskedChangeRequest(newSchemaCache(database.GetDB()), request),
+	})
+}
+
+// ApplyConfigChange 应用已审批的申请
+// 与保存配置一致，应用前的配置记录为配置历史，历史记录与申请互相关联
+// 提交后配置已被其他变更修改时返回 409，需要基于最新配置重新提交
+func ApplyConfigChange(c *gin.Context) {
+	request, ok := loadConfigChange(c)
+	if !ok {
+		return
+	}
+	if request.Status != model.ConfigChangeApproved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only approved requests can be applied, current status: " + request.Status})
+		return
+	}
+
+	now := time.Now()
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// 加锁读取，基线检查到写入之间配置不会被其他请求修改
+		var module model.AppModule
+		if err := etag.ForUpdate(tx).Where("app_id = ? AND module_code = ?", request.AppID, request.ModuleCode).First(&module).Error; err != nil {
+			return err
+		}
+		// 提交后环境可能已被删除
+		if _, err := configapi.EnvironmentChain(tx, request.AppID, request.Environment); err != nil {
+			return err
+		}
+		target, err := loadScopedConfig(tx, &module, requestScope(request))
+		if err != nil {
+			return err
+		}
+		// 按明文比较，密钥轮换导致的密文变化不算作修改
+		if len(diffConfig(openStoredConfig(request.BaseConfig), openStoredConfig(target.raw()))) > 0 {
+			return errChangeStale
+		}
+
+		history, err := target.recordHistory(tx, c.GetString("username"),
+			fmt.Sprintf("apply change request #%d approved by %s", request.ID, request.ReviewedBy))
+		if err != nil {
+			return err
+		}
+		if err := target.save(tx, request.Config, c.GetString("username")); err != nil {
+			return err
+		}
+
+		if err := transitionConfigChange(tx, request, model.ConfigChangeApproved, map[string]interface{}{
+			"status":     model.ConfigChangeApplied,
+			"applied_by": c.GetString("username"),
+			"applied_at": now,
+			"history_id": history.ID,
+		}); err != nil {
+			return err
+		}
+		return recordActivity(tx, c, request.ID, activityApply, fmt.Sprintf("config history version %d", history.Version))
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+	if errors.Is(err, configapi.ErrEnvironmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Environment not found"})
+		return
+	}
+	if !respondTransitionError(c, err) {
+		return
+	}
+	publishConfigReview(c, request, activityApply, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Config change applied",
+		"data":    maskedChangeRequest(newSchemaCache(database.GetDB()), request),
+	})
+}
+
+// loadConfigChange 加载路径中的申请，并校验其属于路径中的APP
+func loadConfigChange(c *gin.Context) (*model.ConfigChangeRequest, bool) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("request_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request id"})
+		return nil, false
+	}
+
+	var request model.ConfigChangeRequest
+	if err := database.GetDB().Where("id = ? AND app_id = ?", id, appID).First(&request).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Config change request not found"})
+		return nil, false
+	}
+	return &request, true
+}
+
+// requestScope 申请修改的环境和命名空间
+func requestScope(request *model.ConfigChangeRequest) configapi.Scope {
+	return configapi.Scope{Environment: request.Environment, Namespace: request.Namespace}
+}
+
+// transitionConfigChange 仅当申请仍处于 from 状态时更新，避免并发审批或重复应用
+// 更新成功后同步修改 request
+func transitionConfigChange(tx *gorm.DB, request *model.ConfigChangeRequest, from string, updates map[string]interface{}) error {
+	result := tx.Model(&model.ConfigChangeRequest{}).
+		Where("id = ? AND status = ?", request.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errChangeStatus
+	}
+	return tx.First(request, request.ID).Error
+}
+
+// respondTransitionError 处理状态流转的错误，返回 true 表示成功
+func respondTransitionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errChangeStatus), errors.Is(err, errChangeStale):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update config change: " + err.Error()})
+	}
+	return false
+}
+
+// recordActivity 记录申请的操作
+func recordActivity(tx *gorm.DB, c *gin.Context, requestID uint, action, content string) error {
+	return tx.Create(&model.ConfigChangeActivity{
+		RequestID:  requestID,
+		Action:     action,
+		Operator:   c.GetString("username"),
+		OperatorID: c.GetUint("user_id"),
+		Content:    content,
+	}).Error
+}
+
+// publishConfigReview 通知订阅方（如 WebSocket 推送给管理后台），发布失败只记录日志
+func publishConfigReview(c *gin.Context, request *model.ConfigChangeRequest, action, comment string) {
+	if err := coremodule.Publish(c.Request.Context(), coremodule.ConfigReviewEvent{
+		AppID:       request.AppID,
+		RequestID:   request.ID,
+		ModuleCode:  request.ModuleCode,
+		Action:      action,
+		Status:      request.Status,
+		Operator:    c.GetString("username"),
+		SubmittedBy: request.SubmittedBy,
+		Comment:     comment,
+		OccurredAt:  time.Now(),
+	}); err != nil {
+		log.Printf("[ConfigApproval] Failed to publish %s for request %d: %v", coremodule.EventConfigReview, request.ID, err)
+	}
+}
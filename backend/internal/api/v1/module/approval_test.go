@@ -0,0 +1,248 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	coremodule "app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// approvalRouter 注册审批相关路由，请求头 X-User 模拟登录的管理员（"id:name"）
+func approvalRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	db := openBundleDB(t)
+	if err := db.AutoMigrate(&model.App{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	if _, err := coremodule.NewMigrationRunner(db).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	database.SetDB(db)
+	t.Cleanup(func() { database.SetDB(nil) })
+
+	db.Create(&model.App{ID: 1, Name: "prod", AppID: "app_prod"})
+	db.Create(&model.AppModule{AppID: 1, ModuleCode: "push_send", Config: `{"batch_size":100}`, Status: 1})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		var id uint
+		var name string
+		fmt.Sscanf(c.GetHeader("X-User"), "%d:%s", &id, &name)
+		c.Set("user_id", id)
+		c.Set("username", name)
+	})
+	r.PUT("/apps/:id/config-approval", SetApprovalPolicy)
+	r.PUT("/apps/:id/modules/:module_code/config", SaveModuleConfig)
+	r.POST("/apps/:id/modules/:module_code/config/rollback/:history_id", RollbackConfig)
+	r.POST("/apps/:id/modules/:module_code/config/reset", ResetModuleConfig)
+	r.POST("/apps/:id/config/import", ImportConfig)
+	r.POST("/apps/:id/config-requests/:request_id/:action", func(c *gin.Context) {
+		switch c.Param("action") {
+		case "approve":
+			ApproveConfigChange(c)
+		case "reject":
+			RejectConfigChange(c)
+		case "apply":
+			ApplyConfigChange(c)
+		}
+	})
+	return r
+}
+
+func doApproval(r *gin.Engine, method, path, user string, body interface{}) (int, map[string]interface{}) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User", user)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestConfigApprovalWorkflow(t *testing.T) {
+	r := approvalRouter(t)
+	db := database.GetDB()
+	const alice, bob = "1:alice", "2:bob"
+
+	if code, _ := doApproval(r, http.MethodPut, "/apps/1/config-approval", alice, gin.H{"require_approval": true}); code != http.StatusOK {
+		t.Fatalf("set policy status = %d", code)
+	}
+
+	// 需要审批时保存配置只生成申请，配置不变
+	code, resp := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config", alice, gin.H{"config": gin.H{"batch_size": 200}})
+	if code != http.StatusAccepted {
+		t.Fatalf("save status = %d, body %v", code, resp)
+	}
+	requestID := uint(resp["data"].(map[string]interface{})["id"].(float64))
+	var module model.AppModule
+	db.Where("app_id = 1 AND module_code = ?", "push_send").First(&module)
+	if module.Config != `{"batch_size":100}` {
+		t.Fatalf("config changed before approval: %s", module.Config)
+	}
+
+	base := fmt.Sprintf("/apps/1/config-requests/%d", requestID)
+	if code, _ := doApproval(r, http.MethodPost, base+"/apply", alice, nil); code != http.StatusConflict {
+		t.Errorf("apply before approval status = %d, want 409", code)
+	}
+	if code, _ := doApproval(r, http.MethodPost, base+"/approve", alice, nil); code != http.StatusForbidden {
+		t.Errorf("self approval status = %d, want 403", code)
+	}
+	if code, resp := doApproval(r, http.MethodPost, base+"/approve", bob, gin.H{"comment": "lgtm"}); code != http.StatusOK {
+		t.Fatalf("approve status = %d, body %v", code, resp)
+	}
+	if code, _ := doApproval(r, http.MethodPost, base+"/reject", bob, gin.H{"comment": "no"}); code != http.StatusConflict {
+		t.Errorf("reject after approval status = %d, want 409", code)
+	}
+	if code, resp := doApproval(r, http.MethodPost, base+"/apply", alice, nil); code != http.StatusOK {
+		t.Fatalf("apply status = %d, body %v", code, resp)
+	}
+
+	db.First(&module, module.ID)
+	if module.Config != `{"batch_size":200}` {
+		t.Errorf("config after apply = %s", module.Config)
+	}
+	var request model.ConfigChangeRequest
+	db.First(&request, requestID)
+	var history model.ModuleConfigHistory
+	db.First(&history, request.HistoryID)
+	if request.Status != model.ConfigChangeApplied || history.Config != `{"batch_size":100}` {
+		t.Errorf("request = %+v, history = %+v", request, history)
+	}
+	var actions []string
+	db.Model(&model.ConfigChangeActivity{}).Where("request_id = ?", requestID).Order("id").Pluck("action", &actions)
+	if fmt.Sprint(actions) != "[submit approve apply]" {
+		t.Errorf("activities = %v", actions)
+	}
+	if code, _ := doApproval(r, http.MethodPost, base+"/apply", alice, nil); code != http.StatusConflict {
+		t.Errorf("second apply status = %d, want 409", code)
+	}
+}
+
+func TestApplyConfigChange_Stale(t *testing.T) {
+	r := approvalRouter(t)
+	db := database.GetDB()
+	db.Create(&model.ConfigApprovalPolicy{AppID: 1, RequireApproval: true})
+
+	_, resp := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config", "1:alice", gin.H{"config": gin.H{"batch_size": 300}})
+	base := fmt.Sprintf("/apps/1/config-requests/%d", uint(resp["data"].(map[string]interface{})["id"].(float64)))
+	doApproval(r, http.MethodPost, base+"/approve", "2:bob", nil)
+
+	// 申请提交后配置被其他途径修改
+	db.Model(&model.AppModule{}).Where("app_id = 1").Update("config", `{"batch_size":150}`)
+	if code, _ := doApproval(r, http.MethodPost, base+"/apply", "1:alice", nil); code != http.StatusConflict {
+		t.Errorf("stale apply status = %d, want 409", code)
+	}
+}
+
+func TestConfigApproval_RollbackAndImport(t *testing.T) {
+	r := approvalRouter(t)
+	db := database.GetDB()
+	db.Create(&model.ConfigApprovalPolicy{AppID: 1, RequireApproval: true})
+	db.Create(&model.ModuleTemplate{ModuleCode: "push_send", Status: 1})
+	history := model.ModuleConfigHistory{AppID: 1, ModuleCode: "push_send", Config: `{"batch_size":50}`, Version: 1}
+	db.Create(&history)
+
+	// 回滚生成变更申请，配置不变
+	code, resp := doApproval(r, http.MethodPost, fmt.Sprintf("/apps/1/modules/push_send/config/rollback/%d", history.ID), "1:alice", nil)
+	if code != http.StatusAccepted {
+		t.Fatalf("rollback status = %d, body %v", code, resp)
+	}
+	var request model.ConfigChangeRequest
+	db.First(&request, uint(resp["data"].(map[string]interface{})["id"].(float64)))
+	if request.Config != `{"batch_size":50}` || request.Remark != "rollback to version 1" {
+		t.Errorf("rollback request = %+v", request)
+	}
+
+	// 导入修改配置时视为冲突，不做任何修改
+	bundle := gin.H{
+		"format_version": BundleFormatVersion,
+		"modules":        []gin.H{{"module_code": "push_send", "config": gin.H{"batch_size": 500}}},
+	}
+	if code, resp := doApproval(r, http.MethodPost, "/apps/1/config/import", "1:alice", bundle); code != http.StatusConflict {
+		t.Errorf("import status = %d, body %v", code, resp)
+	}
+	var module model.AppModule
+	db.Where("app_id = 1 AND module_code = ?", "push_send").First(&module)
+	if module.Config != `{"batch_size":100}` {
+		t.Errorf("config changed without approval: %s", module.Config)
+	}
+}
+
+func TestResetModuleConfig(t *testing.T) {
+	r := approvalRouter(t)
+	db := database.GetDB()
+
+	// 过期的 If-Match 返回 412，配置不变
+	req := httptest.NewRequest(http.MethodPost, "/apps/1/modules/push_send/config/reset", nil)
+	req.Header.Set("If-Match", `"stale"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("reset with stale If-Match status = %d, want 412", w.Code)
+	}
+
+	// 直接重置时记录历史
+	if code, resp := doApproval(r, http.MethodPost, "/apps/1/modules/push_send/config/reset", "1:alice", nil); code != http.StatusOK {
+		t.Fatalf("reset status = %d, body %v", code, resp)
+	}
+	var module model.AppModule
+	db.Where("app_id = 1 AND module_code = ?", "push_send").First(&module)
+	var history model.ModuleConfigHistory
+	db.Where("app_id = 1 AND module_code = ?", "push_send").Last(&history)
+	if module.Config != "{}" || history.Config != `{"batch_size":100}` {
+		t.Errorf("config = %s, history = %+v", module.Config, history)
+	}
+
+	// 要求审批时重置只生成变更申请
+	db.Model(&module).Update("config", `{"batch_size":100}`)
+	db.Create(&model.ConfigApprovalPolicy{AppID: 1, RequireApproval: true})
+	if code, _ := doApproval(r, http.MethodPost, "/apps/1/modules/push_send/config/reset", "1:alice", nil); code != http.StatusAccepted {
+		t.Errorf("reset with approval status = %d, want 202", code)
+	}
+	db.First(&module, module.ID)
+	if module.Config != `{"batch_size":100}` {
+		t.Errorf("config changed before approval: %s", module.Config)
+	}
+}
+
+func TestRollbackConfig_IfMatch(t *testing.T) {
+	r := approvalRouter(t)
+	db := database.GetDB()
+	history := model.ModuleConfigHistory{AppID: 1, ModuleCode: "push_send", Config: `{"batch_size":50}`, Version: 1}
+	db.Create(&history)
+
+	path := fmt.Sprintf("/apps/1/modules/push_send/config/rollback/%d", history.ID)
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("If-Match", `"stale"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") == "" {
+		t.Errorf("rollback with stale If-Match status = %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// 使用当前 ETag 回滚成功，历史版本号递增
+	req = httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("If-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("rollback status = %d, body %s", w.Code, w.Body.String())
+	}
+	var latest model.ModuleConfigHistory
+	db.Where("app_id = 1 AND module_code = ?", "push_send").Order("version DESC").First(&latest)
+	if latest.Version != 2 {
+		t.Errorf("latest history version = %d, want 2", latest.Version)
+	}
+}
@@ -0,0 +1,910 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/middleware"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// BundleFormatVersion 配置包格式版本，格式不兼容地变更时递增
+const BundleFormatVersion = 1
+
+// 导入计划中的动作
+const (
+	ImportAdd       = "add"
+	ImportChange    = "change"
+	ImportUnchanged = "unchanged"
+	ImportConflict  = "conflict"
+)
+
+// ConfigBundle APP模块配置包，用于在APP之间迁移模块、配置和菜单
+type ConfigBundle struct {
+	FormatVersion int            `json:"format_version" yaml:"format_version"`
+	ExportedAt    time.Time      `json:"exported_at" yaml:"exported_at"`
+	Source        BundleSource   `json:"source" yaml:"source"`
+	Modules       []BundleModule `json:"modules" yaml:"modules"`
+	Menus         []BundleMenu   `json:"menus" yaml:"menus"`
+}
+
+// BundleSource 导出来源
+type BundleSource struct {
+	AppID string `json:"app_id" yaml:"app_id"`
+	Name  string `json:"name" yaml:"name"`
+}
+
+// BundleModule 已启用的模块及其配置，Config 为基础范围的配置，Overrides 为其他环境、命名空间的覆盖值
+type BundleModule struct {
+	ModuleCode   string                 `json:"module_code" yaml:"module_code"`
+	SourceModule string                 `json:"source_module,omitempty" yaml:"source_module,omitempty"`
+	Config       map[string]interface{} `json:"config" yaml:"config"`
+	Overrides    []BundleOverride       `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+}
+
+// BundleOverride 模块配置在某个环境、命名空间下的覆盖值，导入时环境需已存在于目标APP
+type BundleOverride struct {
+	Environment string                 `json:"environment" yaml:"environment"`
+	Namespace   string                 `json:"namespace" yaml:"namespace"`
+	Config      map[string]interface{} `json:"config" yaml:"config"`
+}
+
+// scope 覆盖值所属的环境和命名空间
+func (o BundleOverride) scope() configapi.Scope {
+	return configapi.Scope{Environment: o.Environment, Namespace: o.Namespace}
+}
+
+// overrideKey 导入计划中覆盖值的标识，例如 push_send@staging/application
+func overrideKey(moduleCode string, o BundleOverride) string {
+	return fmt.Sprintf("%s@%s/%s", moduleCode, o.Environment, o.Namespace)
+}
+
+// BundleMenu 菜单，父子关系以菜单Code表示，与数据库ID无关
+type BundleMenu struct {
+	Code       string `json:"code" yaml:"code"`
+	ParentCode string `json:"parent_code,omitempty" yaml:"parent_code,omitempty"`
+	Name       string `json:"name" yaml:"name"`
+	Icon       string `json:"icon,omitempty" yaml:"icon,omitempty"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+	Component  string `json:"component,omitempty" yaml:"component,omitempty"`
+	MenuType   int8   `json:"menu_type" yaml:"menu_type"`
+	Visible    int8   `json:"visible" yaml:"visible"`
+	Status     int8   `json:"status" yaml:"status"`
+	SortOrder  int    `json:"sort_order" yaml:"sort_order"`
+	Permission string `json:"permission,omitempty" yaml:"permission,omitempty"`
+	Remark     string `json:"remark,omitempty" yaml:"remark,omitempty"`
+}
+
+// ImportItem 导入计划中的单项
+type ImportItem struct {
+	Kind   string         `json:"kind"` // module / module_config / menu
+	Key    string         `json:"key"`
+	Action string         `json:"action"`
+	Reason string         `json:"reason,omitempty"`
+	Fields []string       `json:"fields,omitempty"` // 菜单变更的字段
+	Diff   []ConfigChange `json:"diff,omitempty"`   // 模块配置的差异
+}
+
+// ImportSummary 导入计划统计
+type ImportSummary struct {
+	Add       int `json:"add"`
+	Change    int `json:"change"`
+	Unchanged int `json:"unchanged"`
+	Conflict  int `json:"conflict"`
+}
+
+// ImportPlan 导入计划，预览和实际导入使用同一份计划
+type ImportPlan struct {
+	Source  BundleSource  `json:"source"`
+	Summary ImportSummary `json:"summary"`
+	Items   []ImportItem  `json:"items"`
+}
+
+// ExportConfig 导出APP已启用的模块、配置和菜单
+// 请求体可选：format 为 json（默认）或 yaml，modules 限定导出的模块，include_menus 为 false 时不导出菜单
+// yaml 格式以附件形式下载，json 格式按统一响应结构返回
+func ExportConfig(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	var req struct {
+		Format       string   `json:"format"`
+		Modules      []string `json:"modules"`
+		IncludeMenus *bool    `json:"include_menus"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Format == "" {
+		req.Format = c.DefaultQuery("format", "json")
+	}
+	if req.Format != "json" && req.Format != "yaml" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or yaml"})
+		return
+	}
+
+	bundle, err := exportBundle(database.GetDB(), appID, req.Modules, req.IncludeMenus == nil || *req.IncludeMenus)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export config: " + err.Error()})
+		return
+	}
+
+	if req.Format == "yaml" {
+		data, err := yaml.Marshal(bundle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode bundle: " + err.Error()})
+			return
+		}
+		filename := fmt.Sprintf("%s-config-%s.yaml", bundle.Source.AppID, bundle.ExportedAt.Format("20060102150405"))
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", data)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": bundle,
+	})
+}
+
+// PreviewImportConfig 预览导入：返回每个模块和菜单将被新增、修改、保持不变还是存在冲突，不写入数据库
+func PreviewImportConfig(c *gin.Context) {
+	appID, bundle, ok := bindImport(c)
+	if !ok {
+		return
+	}
+
+	plan, err := planImport(database.GetDB(), appID, bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to plan import: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": plan,
+	})
+}
+
+// ImportConfig 在单个事务中导入配置包
+// 存在冲突时不做任何修改并返回 409；配置发生变化的模块会记录配置历史，便于回滚
+// 要求审批的APP中配置有变化的模块视为冲突，需通过变更申请修改
+func ImportConfig(c *gin.Context) {
+	appID, bundle, ok := bindImport(c)
+	if !ok {
+		return
+	}
+
+	var plan *ImportPlan
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plan, err = planImport(tx, appID, bundle); err != nil {
+			return err
+		}
+		if plan.Summary.Conflict > 0 {
+			return errImportConflict
+		}
+		return applyImport(tx, appID, bundle, c.GetString("username"))
+	})
+	if errors.Is(err, errImportConflict) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Import has conflicts, nothing was changed",
+			"data":  plan,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import config: " + err.Error()})
+		return
+	}
+	middleware.InvalidateModuleGateApp(appID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "Config imported successfully",
+		"data":    plan,
+	})
+}
+
+// errImportConflict 导入计划存在冲突，用于回滚事务
+var errImportConflict = errors.New("import has conflicts")
+
+// bindImport 解析目标APP和请求体中的配置包，请求体可以是 JSON 或 YAML
+func bindImport(c *gin.Context) (uint, *ConfigBundle, bool) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return 0, nil, false
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return 0, nil, false
+	}
+	bundle, err := parseBundle(data, strings.Contains(c.ContentType(), "yaml"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, nil, false
+	}
+	return appID, bundle, true
+}
+
+// parseBundle 解析并校验配置包
+// 模块配置统一经过一次 JSON 编解码，使 YAML 中的整数与数据库中的配置（float64）可以直接比较
+func parseBundle(data []byte, isYAML bool) (*ConfigBundle, error) {
+	var bundle ConfigBundle
+	var err error
+	if isYAML {
+		err = yaml.Unmarshal(data, &bundle)
+	} else {
+		err = json.Unmarshal(data, &bundle)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if bundle.FormatVersion != BundleFormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format_version %d, expected %d", bundle.FormatVersion, BundleFormatVersion)
+	}
+
+	for i, m := range bundle.Modules {
+		if m.ModuleCode == "" {
+			return nil, fmt.Errorf("modules[%d]: module_code is required", i)
+		}
+		raw, err := json.Marshal(m.Config)
+		if err != nil {
+			return nil, fmt.Errorf("module %s: invalid config: %w", m.ModuleCode, err)
+		}
+		bundle.Modules[i].Config = parseConfig(string(raw))
+
+		seen := make(map[configapi.Scope]bool, len(m.Overrides))
+		for j, o := range m.Overrides {
+			s, err := configapi.NormalizeScope(o.Environment, o.Namespace)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: overrides[%d]: %w", m.ModuleCode, j, err)
+			}
+			if isBaseScope(s) {
+				return nil, fmt.Errorf("module %s: overrides[%d]: %s/%s is the module config itself", m.ModuleCode, j, s.Environment, s.Namespace)
+			}
+			if seen[s] {
+				return nil, fmt.Errorf("module %s: duplicate override %s/%s", m.ModuleCode, s.Environment, s.Namespace)
+			}
+			seen[s] = true
+			raw, err := json.Marshal(o.Config)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: overrides[%d]: invalid config: %w", m.ModuleCode, j, err)
+			}
+			bundle.Modules[i].Overrides[j] = BundleOverride{Environment: s.Environment, Namespace: s.Namespace, Config: parseConfig(string(raw))}
+		}
+	}
+	for i, m := range bundle.Menus {
+		if m.Code == "" || m.Name == "" {
+			return nil, fmt.Errorf("menus[%d]: code and name are required", i)
+		}
+	}
+	return &bundle, nil
+}
+
+// exportBundle 构建APP的配置包，moduleCodes 为空时导出全部已启用模块
+func exportBundle(db *gorm.DB, appID uint, moduleCodes []string, includeMenus bool) (*ConfigBundle, error) {
+	var app model.App
+	if err := db.First(&app, appID).Error; err != nil {
+		return nil, err
+	}
+
+	query := db.Where("app_id = ? AND status = 1", appID).Order("module_code ASC")
+	if len(moduleCodes) > 0 {
+		query = query.Where("module_code IN ?", moduleCodes)
+	}
+	var modules []model.AppModule
+	if err := query.Find(&modules).Error; err != nil {
+		return nil, err
+	}
+
+	bundle := &ConfigBundle{
+		FormatVersion: BundleFormatVersion,
+		ExportedAt:    time.Now(),
+		Source:        BundleSource{AppID: app.AppID, Name: app.Name},
+		Modules:       make([]BundleModule, 0, len(modules)),
+		Menus:         []BundleMenu{},
+	}
+	codes := make([]string, 0, len(modules))
+	for _, m := range modules {
+		codes = append(codes, m.ModuleCode)
+	}
+	var overrides []model.AppModuleConfig
+	if err := db.Where("app_id = ? AND module_code IN ?", appID, codes).
+		Order("environment ASC, namespace ASC").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	byModule := make(map[string][]model.AppModuleConfig, len(modules))
+	for _, o := range overrides {
+		byModule[o.ModuleCode] = append(byModule[o.ModuleCode], o)
+	}
+
+	// 敏感字段导出为 ******，导入时保持目标APP中的原值
+	schemas := newSchemaCache(db)
+	for _, m := range modules {
+		schema := schemas.get(m.ModuleCode)
+		module := BundleModule{
+			ModuleCode:   m.ModuleCode,
+			SourceModule: m.SourceModule,
+			Config:       maskConfig(schema, parseConfig(m.Config)),
+		}
+		for _, o := range byModule[m.ModuleCode] {
+			module.Overrides = append(module.Overrides, BundleOverride{
+				Environment: o.Environment,
+				Namespace:   o.Namespace,
+				Config:      maskConfig(schema, parseConfig(o.Config)),
+			})
+		}
+		bundle.Modules = append(bundle.Modules, module)
+	}
+
+	if includeMenus {
+		var menus []model.AppMenu
+		if err := db.Where("app_id = ?", appID).Order("sort_order ASC, id ASC").Find(&menus).Error; err != nil {
+			return nil, err
+		}
+		codes := make(map[uint]string, len(menus))
+		for _, m := range menus {
+			codes[m.ID] = m.Code
+		}
+		for _, m := range menus {
+			menu := toBundleMenu(m)
+			menu.ParentCode = codes[m.ParentID]
+			bundle.Menus = append(bundle.Menus, menu)
+		}
+	}
+	return bundle, nil
+}
+
+// planImport 计算将配置包导入APP的计划
+func planImport(db *gorm.DB, appID uint, bundle *ConfigBundle) (*ImportPlan, error) {
+	plan := &ImportPlan{Source: bundle.Source, Items: []ImportItem{}}
+
+	moduleItems, err := planModules(db, appID, bundle.Modules)
+	if err != nil {
+		return nil, err
+	}
+	menuItems, err := planMenus(db, appID, bundle.Menus)
+	if err != nil {
+		return nil, err
+	}
+	plan.Items = append(moduleItems, menuItems...)
+
+	for _, item := range plan.Items {
+		switch item.Action {
+		case ImportAdd:
+			plan.Summary.Add++
+		case ImportChange:
+			plan.Summary.Change++
+		case ImportUnchanged:
+			plan.Summary.Unchanged++
+		case ImportConflict:
+			plan.Summary.Conflict++
+		}
+	}
+	return plan, nil
+}
+
+// planModules 模块：未启用过的新增，已存在的比较启用状态和配置
+// 未知模块、依赖不满足、配置不符合目标环境 schema 视为冲突；模块的覆盖值随后逐个规划，见 planOverride
+func planModules(db *gorm.DB, appID uint, modules []BundleModule) ([]ImportItem, error) {
+	graph, err := loadDependencyGraph(db)
+	if err != nil {
+		return nil, err
+	}
+	var templateCodes []string
+	if err := db.Model(&model.ModuleTemplate{}).Where("status = 1").Pluck("module_code", &templateCodes).Error; err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(templateCodes))
+	for _, code := range templateCodes {
+		known[code] = true
+	}
+
+	// 导入后的启用集合 = 当前已启用 + 配置包中的模块
+	enabled, err := loadEnabledModules(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range modules {
+		enabled[m.ModuleCode] = true
+	}
+
+	var existing []model.AppModule
+	if err := db.Where("app_id = ?", appID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[string]model.AppModule, len(existing))
+	for _, m := range existing {
+		current[m.ModuleCode] = m
+	}
+
+	// 要求审批的APP不能通过导入绕过审批，配置有变化的模块需逐个提交变更申请
+	approval := requiresApproval(db, appID)
+
+	items := make([]ImportItem, 0, len(modules))
+	seen := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		item := ImportItem{Kind: "module", Key: m.ModuleCode}
+		items = append(items, item)
+		last := &items[len(items)-1]
+
+		if seen[m.ModuleCode] {
+			last.Action, last.Reason = ImportConflict, "duplicate module in bundle"
+			continue
+		}
+		seen[m.ModuleCode] = true
+
+		if _, ok := graph[m.ModuleCode]; !ok && !known[m.ModuleCode] {
+			last.Action, last.Reason = ImportConflict, "module is not registered on this platform"
+			continue
+		}
+		if missing := graph.missing(m.ModuleCode, enabled); len(missing) > 0 {
+			last.Action, last.Reason = ImportConflict, "missing dependencies: "+strings.Join(missing, ", ")
+			continue
+		}
+		schema, err := loadConfigSchema(db, m.ModuleCode)
+		if err != nil {
+			return nil, err
+		}
+		existing, ok := current[m.ModuleCode]
+		stored := openStoredConfig(existing.Config)
+		config, err := openConfig(unmaskConfig(m.Config, parseConfig(existing.Config)))
+		if err != nil {
+			last.Action, last.Reason = ImportConflict, err.Error()
+			continue
+		}
+		if err := validator.ValidateModuleConfig(m.ModuleCode, schema, config); err != nil {
+			last.Action, last.Reason = ImportConflict, err.Error()
+			continue
+		}
+
+		if !ok {
+			last.Action = ImportAdd
+			last.Diff = maskDiff(schema, map[string]interface{}{}, config, diffConfig(map[string]interface{}{}, config))
+			if approval && len(last.Diff) > 0 {
+				last.Action, last.Reason = ImportConflict, "config changes require approval"
+			}
+			continue
+		}
+		last.Diff = maskDiff(schema, stored, config, diffConfig(stored, config))
+		switch {
+		case approval && len(last.Diff) > 0:
+			last.Action, last.Reason = ImportConflict, "config changes require approval"
+		case existing.Status != 1:
+			last.Action, last.Reason = ImportChange, "module will be re-enabled"
+		case len(last.Diff) > 0:
+			last.Action = ImportChange
+		default:
+			last.Action = ImportUnchanged
+		}
+	}
+
+	actions := make(map[string]string, len(items))
+	for _, item := range items {
+		actions[item.Key] = item.Action
+	}
+	for _, m := range modules {
+		for _, o := range m.Overrides {
+			item, err := planOverride(db, appID, m, o, actions[m.ModuleCode], approval)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// planOverride 覆盖值：环境不存在于目标APP、所属模块存在冲突、导入后的生效配置不符合 schema 时视为冲突
+// 生效配置按导入后的状态计算，继承链上的基础范围和其他覆盖值优先使用配置包中的值
+func planOverride(db *gorm.DB, appID uint, m BundleModule, o BundleOverride, moduleAction string, approval bool) (ImportItem, error) {
+	item := ImportItem{Kind: "module_config", Key: overrideKey(m.ModuleCode, o)}
+	if moduleAction == ImportConflict {
+		item.Action, item.Reason = ImportConflict, "module has conflicts"
+		return item, nil
+	}
+
+	module := model.AppModule{AppID: appID, ModuleCode: m.ModuleCode, Config: "{}"}
+	if err := db.Where("app_id = ? AND module_code = ?", appID, m.ModuleCode).Limit(1).Find(&module).Error; err != nil {
+		return item, err
+	}
+	layers, err := scopeLayers(db, &module, o.scope())
+	if errors.Is(err, configapi.ErrEnvironmentNotFound) {
+		item.Action, item.Reason = ImportConflict, fmt.Sprintf("environment %s not found", o.Environment)
+		return item, nil
+	}
+	if err != nil {
+		return item, err
+	}
+	target, err := loadScopedConfig(db, &module, o.scope())
+	if err != nil {
+		return item, err
+	}
+
+	bundled := make(map[string]map[string]interface{}, len(m.Overrides))
+	for _, other := range m.Overrides {
+		if other.Namespace == o.Namespace {
+			bundled[other.Environment] = other.Config
+		}
+	}
+	for i, layer := range layers {
+		if isBaseScope(configapi.Scope{Environment: layer.Environment, Namespace: o.Namespace}) {
+			layers[i].Config = unmaskConfig(m.Config, layer.Config)
+		} else if config, ok := bundled[layer.Environment]; ok {
+			layers[i].Config = unmaskConfig(config, layer.Config)
+		}
+	}
+
+	schema, err := loadConfigSchema(db, m.ModuleCode)
+	if err != nil {
+		return item, err
+	}
+	config, err := openConfig(layers[len(layers)-1].Config)
+	var effective map[string]interface{}
+	if err == nil {
+		effective, err = withInherited(layers, config)
+	}
+	if err == nil {
+		err = validator.ValidateModuleConfig(m.ModuleCode, schema, effective)
+	}
+	if err != nil {
+		item.Action, item.Reason = ImportConflict, err.Error()
+		return item, nil
+	}
+
+	stored := openStoredConfig(target.raw())
+	item.Diff = maskDiff(schema, stored, config, diffConfig(stored, config))
+	switch {
+	case len(item.Diff) == 0:
+		item.Action = ImportUnchanged
+	case approval:
+		item.Action, item.Reason = ImportConflict, "config changes require approval"
+	case target.Override == nil:
+		item.Action = ImportAdd
+	default:
+		item.Action = ImportChange
+	}
+	return item, nil
+}
+
+// planMenus 菜单按 Code 匹配：不存在的新增，存在的比较字段
+// 父菜单既不在配置包中也不在目标APP中、Code 重复或存在循环引用时视为冲突
+func planMenus(db *gorm.DB, appID uint, menus []BundleMenu) ([]ImportItem, error) {
+	current, err := loadMenusByCode(db, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	inBundle := make(map[string]int, len(menus))
+	for _, m := range menus {
+		inBundle[m.Code]++
+	}
+	_, cyclic := orderMenus(menus)
+
+	items := make([]ImportItem, 0, len(menus))
+	for _, m := range menus {
+		item := ImportItem{Kind: "menu", Key: m.Code}
+		existing, exists := current[m.Code]
+		switch {
+		case inBundle[m.Code] > 1:
+			item.Action, item.Reason = ImportConflict, "duplicate menu code in bundle"
+		case m.ParentCode != "" && inBundle[m.ParentCode] == 0 && !hasMenu(current, m.ParentCode):
+			item.Action, item.Reason = ImportConflict, fmt.Sprintf("parent menu %s not found", m.ParentCode)
+		case cyclic[m.Code]:
+			item.Action, item.Reason = ImportConflict, "circular parent reference"
+		case !exists:
+			item.Action = ImportAdd
+		default:
+			if item.Fields = menuChanges(existing, m); len(item.Fields) > 0 {
+				item.Action = ImportChange
+			} else {
+				item.Action = ImportUnchanged
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// applyImport 写入配置包，调用方需在事务中调用并确保计划无冲突
+func applyImport(tx *gorm.DB, appID uint, bundle *ConfigBundle, operator string) error {
+	remark := "import"
+	if bundle.Source.AppID != "" {
+		remark = "import from " + bundle.Source.AppID
+	}
+
+	for _, m := range bundle.Modules {
+		var existing model.AppModule
+		err := etag.ForUpdate(tx).Where("app_id = ? AND module_code = ?", appID, m.ModuleCode).First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		exists := err == nil
+
+		previous := "{}"
+		if exists {
+			previous = existing.Config
+		}
+		schema, err := loadConfigSchema(tx, m.ModuleCode)
+		if err != nil {
+			return err
+		}
+		config, sealed, err := resolveConfig(schema, m.Config, previous)
+		if err != nil {
+			return err
+		}
+		if exists && existing.Status == 1 && len(diffConfig(openStoredConfig(previous), config)) == 0 {
+			continue
+		}
+		configJSON, err := json.Marshal(sealed)
+		if err != nil {
+			return err
+		}
+
+		history := model.ModuleConfigHistory{
+			AppID:       appID,
+			ModuleCode:  m.ModuleCode,
+			Environment: baseScope.Environment,
+			Namespace:   baseScope.Namespace,
+			Config:      previous,
+			Version:     nextHistoryVersion(tx, appID, m.ModuleCode, baseScope),
+			Operator:    operator,
+			Remark:      remark,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+
+		if exists {
+			if err := tx.Model(&existing).Updates(map[string]interface{}{
+				"config": string(configJSON),
+				"status": 1,
+			}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Create(&model.AppModule{
+			AppID:        appID,
+			ModuleCode:   m.ModuleCode,
+			SourceModule: m.SourceModule,
+			Config:       string(configJSON),
+			Status:       1,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	// 覆盖值在模块写入之后导入，此时模块一定已存在
+	for _, m := range bundle.Modules {
+		if len(m.Overrides) == 0 {
+			continue
+		}
+		var module model.AppModule
+		if err := etag.ForUpdate(tx).Where("app_id = ? AND module_code = ?", appID, m.ModuleCode).First(&module).Error; err != nil {
+			return err
+		}
+		schema, err := loadConfigSchema(tx, m.ModuleCode)
+		if err != nil {
+			return err
+		}
+		for _, o := range m.Overrides {
+			target, err := loadScopedConfig(tx, &module, o.scope())
+			if err != nil {
+				return err
+			}
+			config, sealed, err := resolveConfig(schema, o.Config, target.raw())
+			if err != nil {
+				return err
+			}
+			if len(diffConfig(openStoredConfig(target.raw()), config)) == 0 {
+				continue
+			}
+			configJSON, err := json.Marshal(sealed)
+			if err != nil {
+				return err
+			}
+			if _, err := target.recordHistory(tx, operator, remark); err != nil {
+				return err
+			}
+			if err := target.save(tx, string(configJSON), operator); err != nil {
+				return err
+			}
+		}
+	}
+
+	current, err := loadMenusByCode(tx, appID)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]uint, len(current))
+	for code, m := range current {
+		ids[code] = m.ID
+	}
+
+	ordered, _ := orderMenus(bundle.Menus)
+	for _, m := range ordered {
+		parentID := uint(0)
+		if m.ParentCode != "" {
+			parentID = ids[m.ParentCode]
+		}
+
+		existing, exists := current[m.Code]
+		if !exists {
+			menu := fromBundleMenu(m, appID, parentID)
+			if err := tx.Create(&menu).Error; err != nil {
+				return err
+			}
+			// 这几个字段带 default:1，Create 会忽略零值，需单独写回
+			if m.MenuType == 0 || m.Visible == 0 || m.Status == 0 {
+				if err := tx.Model(&menu).Updates(map[string]interface{}{
+					"menu_type": m.MenuType,
+					"visible":   m.Visible,
+					"status":    m.Status,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			ids[m.Code] = menu.ID
+			continue
+		}
+		if len(menuChanges(existing, m)) == 0 {
+			continue
+		}
+		if err := tx.Model(&existing.AppMenu).Updates(map[string]interface{}{
+			"parent_id":  parentID,
+			"name":       m.Name,
+			"icon":       m.Icon,
+			"path":       m.Path,
+			"component":  m.Component,
+			"menu_type":  m.MenuType,
+			"visible":    m.Visible,
+			"status":     m.Status,
+			"sort_order": m.SortOrder,
+			"permission": m.Permission,
+			"remark":     m.Remark,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// existingMenu 目标APP中的菜单及其父菜单Code
+type existingMenu struct {
+	model.AppMenu
+	ParentCode string
+}
+
+// loadMenusByCode 加载APP的菜单，按 Code 索引
+func loadMenusByCode(db *gorm.DB, appID uint) (map[string]existingMenu, error) {
+	var menus []model.AppMenu
+	if err := db.Where("app_id = ?", appID).Find(&menus).Error; err != nil {
+		return nil, err
+	}
+	codes := make(map[uint]string, len(menus))
+	for _, m := range menus {
+		codes[m.ID] = m.Code
+	}
+	result := make(map[string]existingMenu, len(menus))
+	for _, m := range menus {
+		result[m.Code] = existingMenu{AppMenu: m, ParentCode: codes[m.ParentID]}
+	}
+	return result, nil
+}
+
+func hasMenu(menus map[string]existingMenu, code string) bool {
+	_, ok := menus[code]
+	return ok
+}
+
+// orderMenus 按父菜单在前的顺序排列，返回排序结果和处于循环引用中的菜单Code
+// 父菜单不在配置包中的菜单视为根节点（父菜单已存在于目标APP或由冲突检查处理）
+func orderMenus(menus []BundleMenu) ([]BundleMenu, map[string]bool) {
+	inBundle := make(map[string]bool, len(menus))
+	for _, m := range menus {
+		inBundle[m.Code] = true
+	}
+
+	placed := make(map[string]bool, len(menus))
+	ordered := make([]BundleMenu, 0, len(menus))
+	remaining := menus
+	for len(remaining) > 0 {
+		var next []BundleMenu
+		for _, m := range remaining {
+			if m.ParentCode == "" || !inBundle[m.ParentCode] || placed[m.ParentCode] {
+				ordered = append(ordered, m)
+				placed[m.Code] = true
+			} else {
+				next = append(next, m)
+			}
+		}
+		if len(next) == len(remaining) {
+			break
+		}
+		remaining = next
+	}
+
+	cyclic := make(map[string]bool)
+	for _, m := range menus {
+		if !placed[m.Code] {
+			cyclic[m.Code] = true
+		}
+	}
+	return ordered, cyclic
+}
+
+// menuChanges 返回目标菜单与配置包中不一致的字段
+func menuChanges(existing existingMenu, m BundleMenu) []string {
+	current := toBundleMenu(existing.AppMenu)
+	current.ParentCode = existing.ParentCode
+
+	var fields []string
+	cv, nv := reflect.ValueOf(current), reflect.ValueOf(m)
+	for i := 0; i < cv.NumField(); i++ {
+		if cv.Field(i).Interface() != nv.Field(i).Interface() {
+			name := strings.Split(cv.Type().Field(i).Tag.Get("json"), ",")[0]
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func toBundleMenu(m model.AppMenu) BundleMenu {
+	return BundleMenu{
+		Code:       m.Code,
+		Name:       m.Name,
+		Icon:       m.Icon,
+		Path:       m.Path,
+		Component:  m.Component,
+		MenuType:   m.MenuType,
+		Visible:    m.Visible,
+		Status:     m.Status,
+		SortOrder:  m.SortOrder,
+		Permission: m.Permission,
+		Remark:     m.Remark,
+	}
+}
+
+func fromBundleMenu(m BundleMenu, appID, parentID uint) model.AppMenu {
+	return model.AppMenu{
+		AppID:      appID,
+		ParentID:   parentID,
+		Name:       m.Name,
+		Code:       m.Code,
+		Icon:       m.Icon,
+		Path:       m.Path,
+		Component:  m.Component,
+		MenuType:   m.MenuType,
+		Visible:    m.Visible,
+		Status:     m.Status,
+		SortOrder:  m.SortOrder,
+		Permission: m.Permission,
+		Remark:     m.Remark,
+	}
+}
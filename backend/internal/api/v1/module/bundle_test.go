@@ -0,0 +1,152 @@
+package module
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"app-platform-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openBundleDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "bundle.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&model.ModuleTemplate{}, &model.AppModule{}, &model.AppMenu{}, &model.ModuleConfigHistory{},
+		&model.AppModuleConfig{}, &model.ConfigEnvironment{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	return db
+}
+
+func TestParseBundle(t *testing.T) {
+	data := []byte(`
+format_version: 1
+source: {app_id: app_staging}
+modules:
+  - module_code: push_service
+    config: {batch_size: 500}
+`)
+	bundle, err := parseBundle(data, true)
+	if err != nil {
+		t.Fatalf("parseBundle() error = %v", err)
+	}
+	// YAML 整数需与数据库中解析出的 float64 一致
+	if got := bundle.Modules[0].Config["batch_size"]; got != float64(500) {
+		t.Errorf("batch_size = %#v, want float64(500)", got)
+	}
+
+	if _, err := parseBundle([]byte(`{"format_version": 2}`), false); err == nil {
+		t.Error("expected error for unsupported format_version")
+	}
+}
+
+func TestOrderMenus(t *testing.T) {
+	menus := []BundleMenu{
+		{Code: "child", ParentCode: "root"},
+		{Code: "root"},
+		{Code: "attached", ParentCode: "existing"},
+		{Code: "x", ParentCode: "y"},
+		{Code: "y", ParentCode: "x"},
+	}
+
+	ordered, cyclic := orderMenus(menus)
+	var codes []string
+	for _, m := range ordered {
+		codes = append(codes, m.Code)
+	}
+	if !reflect.DeepEqual(codes, []string{"root", "attached", "child"}) {
+		t.Errorf("ordered = %v", codes)
+	}
+	if !reflect.DeepEqual(cyclic, map[string]bool{"x": true, "y": true}) {
+		t.Errorf("cyclic = %v", cyclic)
+	}
+}
+
+func TestPlanAndApplyImport(t *testing.T) {
+	db := openBundleDB(t)
+	db.Create(&[]model.ModuleTemplate{
+		{ModuleCode: "user_management", Status: 1},
+		{ModuleCode: "push_service", Dependencies: `["user_management"]`, Status: 1},
+	})
+	db.Create(&model.AppModule{AppID: 2, ModuleCode: "user_management", Config: `{"max_users":10}`, Status: 1})
+	db.Create(&model.AppMenu{AppID: 2, Code: "home", Name: "Home", MenuType: 1, Visible: 1, Status: 1})
+
+	bundle := &ConfigBundle{
+		FormatVersion: BundleFormatVersion,
+		Source:        BundleSource{AppID: "app_staging"},
+		Modules: []BundleModule{
+			{ModuleCode: "user_management", Config: map[string]interface{}{"max_users": float64(20)}},
+			{ModuleCode: "push_service", Config: map[string]interface{}{}},
+			{ModuleCode: "ghost", Config: map[string]interface{}{}},
+		},
+		Menus: []BundleMenu{
+			{Code: "push", ParentCode: "home", Name: "Push", MenuType: 2, Visible: 0, Status: 1},
+			{Code: "home", Name: "Home", MenuType: 1, Visible: 1, Status: 1},
+		},
+	}
+
+	plan, err := planImport(db, 2, bundle)
+	if err != nil {
+		t.Fatalf("planImport() error = %v", err)
+	}
+	actions := map[string]string{}
+	for _, item := range plan.Items {
+		actions[item.Kind+":"+item.Key] = item.Action
+	}
+	want := map[string]string{
+		"module:user_management": ImportChange,
+		"module:push_service":    ImportAdd,
+		"module:ghost":           ImportConflict,
+		"menu:push":              ImportAdd,
+		"menu:home":              ImportUnchanged,
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	if plan.Summary != (ImportSummary{Add: 2, Change: 1, Unchanged: 1, Conflict: 1}) {
+		t.Errorf("summary = %+v", plan.Summary)
+	}
+
+	bundle.Modules = bundle.Modules[:2]
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return applyImport(tx, 2, bundle, "tester")
+	}); err != nil {
+		t.Fatalf("applyImport() error = %v", err)
+	}
+
+	var um model.AppModule
+	db.Where("app_id = 2 AND module_code = ?", "user_management").First(&um)
+	if parseConfig(um.Config)["max_users"] != float64(20) {
+		t.Errorf("user_management config = %s", um.Config)
+	}
+	var history []model.ModuleConfigHistory
+	db.Where("app_id = 2").Order("module_code ASC").Find(&history)
+	if len(history) != 2 || history[1].Config != `{"max_users":10}` || history[1].Remark != "import from app_staging" {
+		t.Errorf("history = %+v", history)
+	}
+
+	var home, push model.AppMenu
+	db.Where("app_id = 2 AND code = ?", "home").First(&home)
+	db.Where("app_id = 2 AND code = ?", "push").First(&push)
+	if push.ID == 0 || push.ParentID != home.ID || push.Visible != 0 {
+		t.Errorf("push menu = %+v, want parent %d", push, home.ID)
+	}
+
+	// 再次导入应全部不变
+	plan, err = planImport(db, 2, bundle)
+	if err != nil {
+		t.Fatalf("planImport() error = %v", err)
+	}
+	if plan.Summary != (ImportSummary{Unchanged: 4}) {
+		t.Errorf("summary after import = %+v", plan.Summary)
+	}
+}
@@ -0,0 +1,275 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	coremodule "app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// dependencyGraph 模块依赖图：模块Code -> 直接依赖的模块Code列表
+type dependencyGraph map[string][]string
+
+// loadDependencyGraph 根据 module_templates.dependencies 和模块注册表构建模块级依赖图
+// module_templates 中每条记录是一个功能，source_module 为其所属模块，dependencies 为功能依赖的功能Code；
+// 手工维护、没有 source_module 的模板记录中依赖项是模块Code。依赖项统一归并到所属模块
+// 已注册模块通过 Meta.Dependencies 声明的模块级依赖不写入模板表，从注册表读取
+func loadDependencyGraph(db *gorm.DB) (dependencyGraph, error) {
+	var templates []model.ModuleTemplate
+	if err := db.Where("status = 1").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]string, len(templates))
+	for _, t := range templates {
+		owners[t.ModuleCode] = templateOwner(t)
+	}
+
+	graph := make(dependencyGraph)
+	seen := make(map[string]map[string]bool)
+	for _, t := range templates {
+		owner := templateOwner(t)
+		if _, ok := graph[owner]; !ok {
+			graph[owner] = []string{}
+			seen[owner] = make(map[string]bool)
+		}
+
+		var deps []string
+		if t.Dependencies != "" {
+			if err := json.Unmarshal([]byte(t.Dependencies), &deps); err != nil {
+				continue
+			}
+		}
+		for _, dep := range deps {
+			target := dep
+			if o, ok := owners[dep]; ok {
+				target = o
+			}
+			if target == owner || seen[owner][target] {
+				continue
+			}
+			seen[owner][target] = true
+			graph[owner] = append(graph[owner], target)
+		}
+	}
+
+	for owner := range graph {
+		m, ok := coremodule.Get(owner)
+		if !ok {
+			continue
+		}
+		for _, dep := range m.Meta().Dependencies {
+			if dep == owner || seen[owner][dep] {
+				continue
+			}
+			seen[owner][dep] = true
+			graph[owner] = append(graph[owner], dep)
+		}
+	}
+	return graph, nil
+}
+
+// templateOwner 返回模板记录所属的模块Code
+func templateOwner(t model.ModuleTemplate) string {
+	if t.SourceModule != "" {
+		return t.SourceModule
+	}
+	return t.ModuleCode
+}
+
+// loadEnabledModules 返回APP已启用的模块Code集合
+func loadEnabledModules(db *gorm.DB, appID uint) (map[string]bool, error) {
+	var codes []string
+	if err := db.Model(&model.AppModule{}).
+		Where("app_id = ? AND status = 1", appID).
+		Pluck("module_code", &codes).Error; err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		enabled[code] = true
+	}
+	return enabled, nil
+}
+
+// dependencyConflict 模块状态变更违反依赖关系
+type dependencyConflict struct {
+	Missing    []string // 要启用的模块尚未启用的依赖
+	Dependents []string // 仍依赖要停用模块的已启用模块
+}
+
+func (e *dependencyConflict) Error() string {
+	return fmt.Sprintf("module dependency conflict: missing %v, dependents %v", e.Missing, e.Dependents)
+}
+
+// checkStatusChange 检查APP同时启用 enable、停用 disable 中的模块后依赖关系是否仍然满足
+// 同一批启用的模块可以互相满足依赖；违反时返回 *dependencyConflict
+func checkStatusChange(db *gorm.DB, appID uint, enable, disable []string) error {
+	graph, err := loadDependencyGraph(db)
+	if err != nil {
+		return err
+	}
+	enabled, err := loadEnabledModules(db, appID)
+	if err != nil {
+		return err
+	}
+	for _, code := range disable {
+		delete(enabled, code)
+	}
+	for _, code := range enable {
+		enabled[code] = true
+	}
+
+	missing, dependents := make(map[string]bool), make(map[string]bool)
+	for _, code := range enable {
+		for _, dep := range graph.missing(code, enabled) {
+			missing[dep] = true
+		}
+	}
+	for _, code := range disable {
+		for _, dep := range graph.dependents(code, enabled) {
+			dependents[dep] = true
+		}
+	}
+	if len(missing) == 0 && len(dependents) == 0 {
+		return nil
+	}
+	return &dependencyConflict{Missing: sortedKeys(missing), Dependents: sortedKeys(dependents)}
+}
+
+// respondStatusChangeError 返回 checkStatusChange 的错误：依赖冲突为 409，其他为 500
+func respondStatusChangeError(c *gin.Context, err error) {
+	var conflict *dependencyConflict
+	if !errors.As(err, &conflict) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load module dependencies"})
+		return
+	}
+	if len(conflict.Missing) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Module dependencies not satisfied",
+			"data":  gin.H{"missing": conflict.Missing},
+		})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error": "Module is required by other enabled modules",
+		"data":  gin.H{"dependents": conflict.Dependents},
+	})
+}
+
+// forceRequested 请求体或查询参数中 force=true 时跳过依赖检查
+func forceRequested(c *gin.Context, bodyForce bool) bool {
+	return bodyForce || c.Query("force") == "true"
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requiredBy 返回模块的传递依赖，按依赖顺序排列（被依赖的在前）
+// 存在循环依赖时跳过已访问节点，不会死循环
+func (g dependencyGraph) requiredBy(code string) []string {
+	visited := map[string]bool{code: true}
+	var order []string
+
+	var visit func(c string)
+	visit = func(c string) {
+		for _, dep := range g[c] {
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			visit(dep)
+			order = append(order, dep)
+		}
+	}
+	visit(code)
+	return order
+}
+
+// missing 返回模块尚未在APP中启用的传递依赖
+func (g dependencyGraph) missing(code string, enabled map[string]bool) []string {
+	result := []string{}
+	for _, dep := range g.requiredBy(code) {
+		if !enabled[dep] {
+			result = append(result, dep)
+		}
+	}
+	return result
+}
+
+// dependents 返回APP中已启用、且直接或间接依赖该模块的模块列表
+func (g dependencyGraph) dependents(code string, enabled map[string]bool) []string {
+	result := []string{}
+	for candidate := range enabled {
+		if candidate == code {
+			continue
+		}
+		for _, dep := range g.requiredBy(candidate) {
+			if dep == code {
+				result = append(result, candidate)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// unknown 返回依赖图中引用了、但没有任何模板定义的模块
+func (g dependencyGraph) unknown(codes []string) []string {
+	result := []string{}
+	for _, code := range codes {
+		if _, ok := g[code]; !ok {
+			result = append(result, code)
+		}
+	}
+	return result
+}
+
+// findCycle 从指定模块出发查找循环依赖，返回首尾相同的路径；无循环时返回 nil
+func (g dependencyGraph) findCycle(code string) []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(c string) []string
+	visit = func(c string) []string {
+		state[c] = visiting
+		path = append(path, c)
+		for _, dep := range g[c] {
+			switch state[dep] {
+			case visiting:
+				for i, p := range path {
+					if p == dep {
+						return append(append([]string{}, path[i:]...), dep)
+					}
+				}
+			case 0:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[c] = visited
+		return nil
+	}
+	return visit(code)
+}
@@ -0,0 +1,131 @@
+package module
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	coremodule "app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	graph := dependencyGraph{
+		"user_management": {},
+		"message_center":  {"user_management"},
+		"push_service":    {"message_center", "user_management"},
+		"file_storage":    {},
+	}
+
+	if got := graph.requiredBy("push_service"); !reflect.DeepEqual(got, []string{"user_management", "message_center"}) {
+		t.Errorf("requiredBy() = %v", got)
+	}
+
+	enabled := map[string]bool{"user_management": true}
+	if got := graph.missing("push_service", enabled); !reflect.DeepEqual(got, []string{"message_center"}) {
+		t.Errorf("missing() = %v", got)
+	}
+
+	enabled = map[string]bool{"user_management": true, "message_center": true, "push_service": true, "file_storage": true}
+	if got := graph.dependents("user_management", enabled); !reflect.DeepEqual(got, []string{"message_center", "push_service"}) {
+		t.Errorf("dependents() = %v", got)
+	}
+	if got := graph.dependents("file_storage", enabled); len(got) != 0 {
+		t.Errorf("dependents() = %v, want none", got)
+	}
+
+	if cycle := graph.findCycle("push_service"); cycle != nil {
+		t.Errorf("findCycle() = %v, want nil", cycle)
+	}
+}
+
+func TestDependencyGraph_Cycle(t *testing.T) {
+	graph := dependencyGraph{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"b"},
+	}
+
+	if got := graph.findCycle("a"); !reflect.DeepEqual(got, []string{"b", "c", "b"}) {
+		t.Errorf("findCycle() = %v", got)
+	}
+	// 存在循环时传递依赖计算不应死循环
+	if got := graph.requiredBy("a"); !reflect.DeepEqual(got, []string{"c", "b"}) {
+		t.Errorf("requiredBy() = %v", got)
+	}
+	if got := graph.unknown([]string{"b", "ghost"}); !reflect.DeepEqual(got, []string{"ghost"}) {
+		t.Errorf("unknown() = %v", got)
+	}
+}
+
+func TestStatusChange_ChecksDependencies(t *testing.T) {
+	db := openBundleDB(t)
+	if err := db.AutoMigrate(&model.App{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	database.SetDB(db)
+	t.Cleanup(func() { database.SetDB(nil) })
+
+	db.Create(&model.App{ID: 1, Name: "prod", AppID: "app_prod"})
+	db.Create(&model.ModuleTemplate{ModuleCode: "user_management", Status: 1})
+	db.Create(&model.ModuleTemplate{ModuleCode: "message_center", Dependencies: `["user_management"]`, Status: 1})
+	db.Create(&model.ModuleTemplate{ModuleCode: "push_service", Dependencies: `["message_center"]`, Status: 1})
+	db.Create(&model.AppModule{AppID: 1, ModuleCode: "user_management", Config: "{}", Status: 1})
+	// Status 的零值会被列默认值覆盖，创建后再改为停用
+	db.Create(&model.AppModule{AppID: 1, ModuleCode: "message_center", Config: "{}"})
+	db.Model(&model.AppModule{}).Where("module_code = ?", "message_center").Update("status", 0)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/apps/:id/modules/:module_code", UpdateModule)
+	r.DELETE("/apps/:id/modules/:module_code", DisableModule)
+	r.POST("/apps/:id/modules/batch", BatchEnableModules)
+
+	// 批量启用时同一批模块可以互相满足依赖，缺少的依赖返回 409
+	if code, _ := doApproval(r, "POST", "/apps/1/modules/batch", "", gin.H{"module_codes": []string{"push_service"}}); code != http.StatusConflict {
+		t.Errorf("batch enable without dependency = %d, want 409", code)
+	}
+
+	if code, _ := doApproval(r, "POST", "/apps/1/modules/batch", "", gin.H{"module_codes": []string{"push_service", "message_center"}}); code != http.StatusOK {
+		t.Fatalf("batch enable with dependency = %d", code)
+	}
+
+	// 通过 PUT 启用和停用与单独的启用、停用接口执行同样的检查
+	if code, _ := doApproval(r, "PUT", "/apps/1/modules/message_center", "", gin.H{"status": 0}); code != http.StatusConflict {
+		t.Errorf("disable required module via PUT = %d, want 409", code)
+	}
+	if code, _ := doApproval(r, "DELETE", "/apps/1/modules/user_management", "", nil); code != http.StatusConflict {
+		t.Errorf("disable required module = %d, want 409", code)
+	}
+	if code, _ := doApproval(r, "DELETE", "/apps/1/modules/user_management", "", gin.H{"force": true}); code != http.StatusOK {
+		t.Errorf("forced disable via body = %d, want 200", code)
+	}
+}
+
+func TestLoadDependencyGraph_ModuleDependencies(t *testing.T) {
+	db := openBundleDB(t)
+	coremodule.Clear()
+	t.Cleanup(coremodule.Clear)
+	coremodule.Register(coremodule.NewBaseModule(coremodule.Meta{Code: "push_service", Dependencies: []string{"user_management"}}, nil))
+
+	// 功能记录只含功能依赖，模块级依赖来自注册表
+	db.Create(&model.ModuleTemplate{ModuleCode: "user_list", SourceModule: "user_management", Dependencies: `[]`, Status: 1})
+	db.Create(&model.ModuleTemplate{ModuleCode: "message_send", SourceModule: "message_center", Dependencies: `["user_list"]`, Status: 1})
+	db.Create(&model.ModuleTemplate{ModuleCode: "push_send", SourceModule: "push_service", Dependencies: `["message_send"]`, Status: 1})
+
+	graph, err := loadDependencyGraph(db)
+	if err != nil {
+		t.Fatalf("loadDependencyGraph() error = %v", err)
+	}
+	want := dependencyGraph{
+		"user_management": {},
+		"message_center":  {"user_management"},
+		"push_service":    {"message_center", "user_management"},
+	}
+	if !reflect.DeepEqual(graph, want) {
+		t.Errorf("loadDependencyGraph() = %v, want %v", graph, want)
+	}
+}
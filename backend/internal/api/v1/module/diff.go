@@ -0,0 +1,250 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 配置变更类型
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// diffContext 统一格式输出中每个变更块前后保留的上下文行数
+const diffContext = 3
+
+// ConfigChange 单个路径上的配置变更
+type ConfigChange struct {
+	Path string      `json:"path"` // 例如 providers[0].timeout
+	Op   string      `json:"op"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ConfigDiffSummary 变更统计
+type ConfigDiffSummary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// diffConfig 逐层比较两份配置，返回按路径排序的变更列表
+// 对象按键比较，数组按下标比较，类型不同或标量不同视为 changed
+func diffConfig(old, new interface{}) []ConfigChange {
+	changes := []ConfigChange{}
+	diffValue("", old, new, &changes)
+	return changes
+}
+
+func diffValue(path string, old, new interface{}, changes *[]ConfigChange) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		if n, ok := new.(map[string]interface{}); ok {
+			diffObject(path, o, n, changes)
+			return
+		}
+	case []interface{}:
+		if n, ok := new.([]interface{}); ok {
+			diffArray(path, o, n, changes)
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, ConfigChange{Path: path, Op: DiffChanged, Old: old, New: new})
+	}
+}
+
+func diffObject(path string, old, new map[string]interface{}, changes *[]ConfigChange) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := k
+		if path != "" {
+			child = path + "." + k
+		}
+		o, inOld := old[k]
+		n, inNew := new[k]
+		switch {
+		case !inOld:
+			*changes = append(*changes, ConfigChange{Path: child, Op: DiffAdded, New: n})
+		case !inNew:
+			*changes = append(*changes, ConfigChange{Path: child, Op: DiffRemoved, Old: o})
+		default:
+			diffValue(child, o, n, changes)
+		}
+	}
+}
+
+func diffArray(path string, old, new []interface{}, changes *[]ConfigChange) {
+	for i := 0; i < len(old) || i < len(new); i++ {
+		child := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(old):
+			*changes = append(*changes, ConfigChange{Path: child, Op: DiffAdded, New: new[i]})
+		case i >= len(new):
+			*changes = append(*changes, ConfigChange{Path: child, Op: DiffRemoved, Old: old[i]})
+		default:
+			diffValue(child, old[i], new[i], changes)
+		}
+	}
+}
+
+// summarizeDiff 统计各类变更数量
+func summarizeDiff(changes []ConfigChange) ConfigDiffSummary {
+	var s ConfigDiffSummary
+	for _, c := range changes {
+		switch c.Op {
+		case DiffAdded:
+			s.Added++
+		case DiffRemoved:
+			s.Removed++
+		case DiffChanged:
+			s.Changed++
+		}
+	}
+	return s
+}
+
+// unifiedDiff 将两份配置格式化为缩进的 JSON 后，按行输出统一格式（unified）差异
+// 两份配置相同时返回空字符串
+func unifiedDiff(fromLabel, toLabel string, old, new interface{}) string {
+	a := jsonLines(old)
+	b := jsonLines(new)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	for _, h := range hunks(ops) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromLabel, toLabel)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aCount), hunkRange(h.bStart, h.bCount))
+		for _, op := range ops[h.from:h.to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+// jsonLines 将配置格式化为按键排序的缩进 JSON 并拆分为行
+func jsonLines(v interface{}) []string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return []string{fmt.Sprint(v)}
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// lineOp 行级编辑操作，kind 为 ' '、'-' 或 '+'；a、b 为操作前已消耗的行数
+type lineOp struct {
+	kind byte
+	text string
+	a, b int
+}
+
+// diffLines 基于最长公共子序列计算行级差异
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]lineOp, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			ops = append(ops, lineOp{kind: ' ', text: a[i], a: i, b: j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			// 删除优先于新增，使 - 行排在对应的 + 行之前
+			ops = append(ops, lineOp{kind: '-', text: a[i], a: i, b: j})
+			i++
+		default:
+			ops = append(ops, lineOp{kind: '+', text: b[j], a: i, b: j})
+			j++
+		}
+	}
+	return ops
+}
+
+// hunk ops[from:to] 构成的变更块
+type hunk struct {
+	from, to       int
+	aStart, aCount int
+	bStart, bCount int
+}
+
+// hunks 将变更行连同上下文分组，间隔不超过两倍上下文的变更合并为一个块
+func hunks(ops []lineOp) []hunk {
+	var result []hunk
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		from := i - diffContext
+		if from < 0 {
+			from = 0
+		}
+		// 向后扩展，直到连续的未变更行超过两倍上下文
+		last := i
+		for j := i + 1; j < len(ops) && j-last <= 2*diffContext; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+		to := last + diffContext + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		h := hunk{from: from, to: to, aStart: ops[from].a, bStart: ops[from].b}
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				h.aCount++
+			}
+			if op.kind != '-' {
+				h.bCount++
+			}
+		}
+		result = append(result, h)
+		i = to - 1
+	}
+	return result
+}
+
+// hunkRange 格式化变更块的行范围，行号从 1 开始；空范围使用前一行的行号
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,87 @@
+package module
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	old := map[string]interface{}{
+		"provider":   "mock",
+		"batch_size": float64(500),
+		"hosts":      []interface{}{"a", "b"},
+		"retry":      map[string]interface{}{"max": float64(3), "backoff": "1s"},
+	}
+	new := map[string]interface{}{
+		"provider": "fcm",
+		"hosts":    []interface{}{"a", "c", "d"},
+		"retry":    map[string]interface{}{"max": float64(3)},
+		"timeout":  float64(10),
+	}
+
+	got := diffConfig(old, new)
+	want := []ConfigChange{
+		{Path: "batch_size", Op: DiffRemoved, Old: float64(500)},
+		{Path: "hosts[1]", Op: DiffChanged, Old: "b", New: "c"},
+		{Path: "hosts[2]", Op: DiffAdded, New: "d"},
+		{Path: "provider", Op: DiffChanged, Old: "mock", New: "fcm"},
+		{Path: "retry.backoff", Op: DiffRemoved, Old: "1s"},
+		{Path: "timeout", Op: DiffAdded, New: float64(10)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffConfig() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	summary := summarizeDiff(got)
+	if summary != (ConfigDiffSummary{Added: 2, Removed: 2, Changed: 2}) {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	if changes := diffConfig(old, old); len(changes) != 0 {
+		t.Errorf("identical configs produced changes: %+v", changes)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := map[string]interface{}{"a": float64(1), "b": float64(2)}
+	new := map[string]interface{}{"a": float64(1), "b": float64(3), "c": true}
+
+	got := unifiedDiff("version 1", "current", old, new)
+	want := strings.Join([]string{
+		"--- version 1",
+		"+++ current",
+		"@@ -1,4 +1,5 @@",
+		" {",
+		`   "a": 1,`,
+		`-  "b": 2`,
+		`+  "b": 3,`,
+		`+  "c": true`,
+		" }",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := unifiedDiff("a", "b", old, old); got != "" {
+		t.Errorf("identical configs produced diff:\n%s", got)
+	}
+}
+
+func TestHunks_SeparateContext(t *testing.T) {
+	a := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	b := append([]string{"0"}, a...)
+	b[len(b)-1] = "twelve"
+
+	h := hunks(diffLines(a, b))
+	if len(h) != 2 {
+		t.Fatalf("hunks = %+v, want 2", h)
+	}
+	if hunkRange(h[0].aStart, h[0].aCount) != "1,3" || hunkRange(h[0].bStart, h[0].bCount) != "1,4" {
+		t.Errorf("first hunk = %+v", h[0])
+	}
+	if hunkRange(h[1].aStart, h[1].aCount) != "9,4" || hunkRange(h[1].bStart, h[1].bCount) != "10,4" {
+		t.Errorf("second hunk = %+v", h[1])
+	}
+}
@@ -0,0 +1,362 @@
+package module
+
+import (
+	"errors"
+	"net/http"
+
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 模块配置的环境和命名空间
+// 环境沿用配置中心的环境及其继承关系，接口通过查询参数 environment、namespace 指定，默认为 default 环境的 application 命名空间（基础范围）
+// 基础范围的配置保存在 AppModule.Config，其他范围自身的覆盖值保存在 AppModuleConfig
+// 生效配置沿继承链从 default 开始逐层合并顶层字段，越近的环境优先；命名空间之间互不继承
+
+// baseScope 保存在 AppModule.Config 上的基础范围
+var baseScope = configapi.Scope{Environment: model.DefaultConfigEnvironment, Namespace: model.DefaultConfigNamespace}
+
+// isBaseScope 是否为基础范围
+func isBaseScope(s configapi.Scope) bool {
+	return s == baseScope
+}
+
+// queryConfigScope 解析查询参数中的 environment 和 namespace
+func queryConfigScope(c *gin.Context) (configapi.Scope, bool) {
+	s, err := configapi.NormalizeScope(c.Query("environment"), c.Query("namespace"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return s, false
+	}
+	return s, true
+}
+
+// respondConfigScopeError 环境不存在时返回 404，其他错误返回 500 和 message
+func respondConfigScopeError(c *gin.Context, err error, message string) {
+	if errors.Is(err, configapi.ErrEnvironmentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Environment not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// scopedConfig 模块在某个环境、命名空间下自身保存的配置
+// 基础范围对应 Module.Config；其他范围对应 Override，还没有覆盖值时 Override 为 nil
+type scopedConfig struct {
+	Module   *model.AppModule
+	Scope    configapi.Scope
+	Override *model.AppModuleConfig
+}
+
+// loadScopedConfig 读取模块在范围内自身保存的配置
+// 覆盖值不单独加锁，写入方都先对模块行加锁（etag.ForUpdate）
+func loadScopedConfig(tx *gorm.DB, module *model.AppModule, s configapi.Scope) (*scopedConfig, error) {
+	target := &scopedConfig{Module: module, Scope: s}
+	if isBaseScope(s) {
+		return target, nil
+	}
+	var overrides []model.AppModuleConfig
+	if err := tx.Where("app_id = ? AND module_code = ? AND environment = ? AND namespace = ?",
+		module.AppID, module.ModuleCode, s.Environment, s.Namespace).Limit(1).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	if len(overrides) > 0 {
+		target.Override = &overrides[0]
+	}
+	return target, nil
+}
+
+// raw 范围内自身保存的配置（JSON），还没有覆盖值时为空对象
+func (t *scopedConfig) raw() string {
+	if isBaseScope(t.Scope) {
+		return t.Module.Config
+	}
+	if t.Override == nil {
+		return "{}"
+	}
+	return t.Override.Config
+}
+
+// resource 用于计算 ETag 的资源：基础范围为模块本身，其他范围为覆盖值
+func (t *scopedConfig) resource() interface{} {
+	if isBaseScope(t.Scope) {
+		return *t.Module
+	}
+	return t.override()
+}
+
+// override 覆盖值，还没有覆盖值时返回未保存的空覆盖值
+func (t *scopedConfig) override() model.AppModuleConfig {
+	if t.Override != nil {
+		return *t.Override
+	}
+	return model.AppModuleConfig{
+		AppID:       t.Module.AppID,
+		ModuleCode:  t.Module.ModuleCode,
+		Environment: t.Scope.Environment,
+		Namespace:   t.Scope.Namespace,
+		Config:      "{}",
+	}
+}
+
+// recordHistory 将范围内当前的配置记录为新的历史版本，调用方需在事务中先对模块行加锁
+func (t *scopedConfig) recordHistory(tx *gorm.DB, operator, remark string) (*model.ModuleConfigHistory, error) {
+	history := &model.ModuleConfigHistory{
+		AppID:       t.Module.AppID,
+		ModuleCode:  t.Module.ModuleCode,
+		Environment: t.Scope.Environment,
+		Namespace:   t.Scope.Namespace,
+		Config:      t.raw(),
+		Version:     nextHistoryVersion(tx, t.Module.AppID, t.Module.ModuleCode, t.Scope),
+		Operator:    operator,
+		Remark:      remark,
+	}
+	if err := tx.Create(history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// save 保存范围内自身的配置并重新读取，使 ETag 与后续 GET 一致；调用方需在事务中先对模块行加锁
+// 其他范围保存空配置时删除覆盖值，所有字段重新从基础环境继承
+func (t *scopedConfig) save(tx *gorm.DB, config, operator string) error {
+	if isBaseScope(t.Scope) {
+		if err := tx.Model(t.Module).Update("config", config).Error; err != nil {
+			return err
+		}
+		return tx.First(t.Module, t.Module.ID).Error
+	}
+
+	if len(parseConfig(config)) == 0 {
+		if t.Override == nil {
+			return nil
+		}
+		if err := tx.Delete(t.Override).Error; err != nil {
+			return err
+		}
+		t.Override = nil
+		return nil
+	}
+	if t.Override == nil {
+		override := t.override()
+		override.Config = config
+		override.UpdatedBy = operator
+		if err := tx.Create(&override).Error; err != nil {
+			return err
+		}
+		t.Override = &override
+	} else if err := tx.Model(t.Override).Updates(map[string]interface{}{
+		"config":     config,
+		"updated_by": operator,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.First(t.Override, t.Override.ID).Error
+}
+
+// configLayer 继承链上一个环境自身保存的配置（敏感字段为密文）
+type configLayer struct {
+	Environment string
+	Config      map[string]interface{}
+}
+
+// scopeLayers 返回继承链上各环境在命名空间下自身保存的配置，从 default 开始，最后一层是 s 所在的环境
+// 环境不存在时返回 configapi.ErrEnvironmentNotFound
+func scopeLayers(tx *gorm.DB, module *model.AppModule, s configapi.Scope) ([]configLayer, error) {
+	chain, err := configapi.EnvironmentChain(tx, module.AppID, s.Environment)
+	if err != nil {
+		return nil, err
+	}
+	var overrides []model.AppModuleConfig
+	if err := tx.Where("app_id = ? AND module_code = ? AND namespace = ? AND environment IN ?",
+		module.AppID, module.ModuleCode, s.Namespace, chain).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	byEnvironment := make(map[string]string, len(overrides))
+	for _, o := range overrides {
+		byEnvironment[o.Environment] = o.Config
+	}
+
+	layers := make([]configLayer, 0, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		env := chain[i]
+		raw := byEnvironment[env]
+		if isBaseScope(configapi.Scope{Environment: env, Namespace: s.Namespace}) {
+			raw = module.Config
+		}
+		layers = append(layers, configLayer{Environment: env, Config: parseConfig(raw)})
+	}
+	return layers, nil
+}
+
+// mergeLayers 逐层合并顶层字段，后面的层覆盖前面的层，返回合并后的配置和每个字段来自的环境
+func mergeLayers(layers []configLayer) (map[string]interface{}, map[string]string) {
+	merged := map[string]interface{}{}
+	sources := map[string]string{}
+	for _, layer := range layers {
+		for name, value := range layer.Config {
+			merged[name] = value
+			sources[name] = layer.Environment
+		}
+	}
+	return merged, sources
+}
+
+// withInherited 将范围自身的明文配置叠加到从基础环境继承的配置上，得到用于校验的明文生效配置
+func withInherited(layers []configLayer, own map[string]interface{}) (map[string]interface{}, error) {
+	inherited, _ := mergeLayers(layers[:len(layers)-1])
+	merged, err := openConfig(inherited)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range own {
+		merged[name] = value
+	}
+	return merged, nil
+}
+
+// respondConfigModified 返回 412 和范围内配置的当前状态，基础范围与 respondModuleModified 一致
+func respondConfigModified(c *gin.Context, target *scopedConfig) {
+	if isBaseScope(target.Scope) {
+		respondModuleModified(c, target.Module)
+		return
+	}
+	etag.Set(c, target.resource())
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "Config has been modified by someone else, reload and retry",
+		"data":  maskedOverride(target.override()),
+	})
+}
+
+// PromoteModuleConfig 将模块在源环境某个命名空间下的生效配置复制到目标环境
+// 目标环境中生效值已经相同的字段跳过，其余写入目标环境作为覆盖值；目标环境独有的字段保持不变
+// 与保存配置一样经过审批、If-Match 校验并记录历史；dry_run 为 true 时只返回目标环境自身配置的差异
+func PromoteModuleConfig(c *gin.Context) {
+	appID, err := getAppDatabaseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+	moduleCode := c.Param("module_code")
+
+	var req struct {
+		Namespace string `json:"namespace"`
+		From      string `json:"from" binding:"required"`
+		To        string `json:"to" binding:"required"`
+		DryRun    bool   `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, err := configapi.NormalizeScope(req.From, req.Namespace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := configapi.NormalizeScope(req.To, req.Namespace)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from.Environment == to.Environment {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be different environments"})
+		return
+	}
+
+	db := database.GetDB()
+	var module model.AppModule
+	if err := db.Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+	sourceLayers, err := scopeLayers(db, &module, from)
+	if err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+	targetLayers, err := scopeLayers(db, &module, to)
+	if err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+	target, err := loadScopedConfig(db, &module, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return
+	}
+	schema, err := loadConfigSchema(db, moduleCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config schema"})
+		return
+	}
+
+	// 按明文比较生效值，同一值的不同密文不算作差异；写入的仍是源环境中的密文
+	source, _ := mergeLayers(sourceLayers)
+	current, _ := mergeLayers(targetLayers)
+	sourcePlain, err := openConfig(source)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+	currentPlain, err := openConfig(current)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+	promoted := parseConfig(target.raw())
+	for name, value := range source {
+		if old, ok := currentPlain[name]; ok && len(diffConfig(old, sourcePlain[name])) == 0 {
+			continue
+		}
+		promoted[name] = value
+	}
+
+	own := openStoredConfig(target.raw())
+	promotedPlain, err := openConfig(promoted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+	changes := maskDiff(schema, own, promotedPlain, diffConfig(own, promotedPlain))
+	if req.DryRun || len(changes) == 0 {
+		message := "Promote preview"
+		if !req.DryRun {
+			message = "Nothing to promote"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"code":    0,
+			"message": message,
+			"data": gin.H{
+				"from":      from.Environment,
+				"to":        to.Environment,
+				"namespace": to.Namespace,
+				"dry_run":   req.DryRun,
+				"diff":      changes,
+			},
+		})
+		return
+	}
+
+	effective, err := withInherited(targetLayers, promotedPlain)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+	if err := validator.ValidateModuleConfig(moduleCode, schema, effective); err != nil {
+		respondConfigInvalid(c, err)
+		return
+	}
+	sealed, err := sealConfig(schema, promoted)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+	commitModuleConfig(c, target, sealed, "promote from "+from.Environment, "Config promoted successfully")
+}
@@ -0,0 +1,205 @@
+package module
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// environmentRouter 在审批路由的基础上注册读取和晋级接口，并创建 staging（继承 default）和 prod（继承 staging）两个环境
+func environmentRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	r := approvalRouter(t)
+	r.GET("/apps/:id/modules/:module_code/config", GetModuleConfig)
+	r.GET("/apps/:id/modules/:module_code/config/history", GetConfigHistory)
+	r.POST("/apps/:id/modules/:module_code/config/promote", PromoteModuleConfig)
+
+	db := database.GetDB()
+	db.Create(&model.ModuleTemplate{
+		ModuleCode:   "push_send",
+		Status:       1,
+		ConfigSchema: `{"type":"object","required":["batch_size"],"properties":{"batch_size":{"type":"integer"},"endpoint":{"type":"string"}}}`,
+	})
+	db.Create(&[]model.ConfigEnvironment{
+		{AppID: 1, Name: "staging", BaseEnvironment: "default"},
+		{AppID: 1, Name: "prod", BaseEnvironment: "staging"},
+	})
+	return r
+}
+
+// effectiveConfig 读取范围内的生效配置和字段来源
+func effectiveConfig(t *testing.T, r *gin.Engine, query string) (map[string]interface{}, map[string]interface{}) {
+	t.Helper()
+	code, resp := doApproval(r, http.MethodGet, "/apps/1/modules/push_send/config?"+query, "1:alice", nil)
+	if code != http.StatusOK {
+		t.Fatalf("get config %s status = %d, body %v", query, code, resp)
+	}
+	data := resp["data"].(map[string]interface{})
+	return data["effective_config"].(map[string]interface{}), data["sources"].(map[string]interface{})
+}
+
+func TestModuleConfigEnvironments(t *testing.T) {
+	r := environmentRouter(t)
+	db := database.GetDB()
+	const alice = "1:alice"
+
+	// prod 只覆盖 endpoint，必填的 batch_size 从 default 继承
+	if code, resp := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config?environment=prod", alice, gin.H{"config": gin.H{"endpoint": "https://prod"}}); code != http.StatusOK {
+		t.Fatalf("save prod status = %d, body %v", code, resp)
+	}
+	if code, _ := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config?environment=qa", alice, gin.H{"config": gin.H{}}); code != http.StatusNotFound {
+		t.Errorf("save to unknown environment status = %d, want 404", code)
+	}
+	if code, _ := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config?environment=prod", alice, gin.H{"config": gin.H{"batch_size": "many"}}); code != http.StatusBadRequest {
+		t.Errorf("save invalid override status = %d, want 400", code)
+	}
+	if code, _ := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config?environment=staging", alice, gin.H{"config": gin.H{"batch_size": 200}}); code != http.StatusOK {
+		t.Fatalf("save staging status = %d", code)
+	}
+
+	effective, sources := effectiveConfig(t, r, "environment=prod")
+	if effective["batch_size"] != float64(200) || effective["endpoint"] != "https://prod" {
+		t.Errorf("prod effective config = %v", effective)
+	}
+	if sources["batch_size"] != "staging" || sources["endpoint"] != "prod" {
+		t.Errorf("prod sources = %v", sources)
+	}
+	var module model.AppModule
+	db.Where("app_id = 1 AND module_code = ?", "push_send").First(&module)
+	if module.Config != `{"batch_size":100}` {
+		t.Errorf("base config changed: %s", module.Config)
+	}
+
+	// 晋级预览不修改配置；晋级后 staging 获得 prod 的 endpoint，相同的 batch_size 不写入
+	promote := gin.H{"from": "prod", "to": "staging", "dry_run": true}
+	code, resp := doApproval(r, http.MethodPost, "/apps/1/modules/push_send/config/promote", alice, promote)
+	if diff, _ := resp["data"].(map[string]interface{})["diff"].([]interface{}); code != http.StatusOK || len(diff) != 1 {
+		t.Fatalf("promote preview status = %d, body %v", code, resp)
+	}
+	promote["dry_run"] = false
+	if code, resp := doApproval(r, http.MethodPost, "/apps/1/modules/push_send/config/promote", alice, promote); code != http.StatusOK {
+		t.Fatalf("promote status = %d, body %v", code, resp)
+	}
+	var staging model.AppModuleConfig
+	db.Where("app_id = 1 AND environment = ?", "staging").First(&staging)
+	if staging.Config != `{"batch_size":200,"endpoint":"https://prod"}` {
+		t.Errorf("staging config after promote = %s", staging.Config)
+	}
+
+	// 重置 prod 删除覆盖值，全部从 staging 继承；回滚后恢复
+	if code, _ := doApproval(r, http.MethodPost, "/apps/1/modules/push_send/config/reset?environment=prod", alice, nil); code != http.StatusOK {
+		t.Fatalf("reset prod status = %d", code)
+	}
+	var overrides int64
+	db.Model(&model.AppModuleConfig{}).Where("app_id = 1 AND environment = ?", "prod").Count(&overrides)
+	if _, sources := effectiveConfig(t, r, "environment=prod"); overrides != 0 || sources["endpoint"] != "staging" {
+		t.Errorf("prod after reset: overrides = %d, sources = %v", overrides, sources)
+	}
+
+	code, resp = doApproval(r, http.MethodGet, "/apps/1/modules/push_send/config/history?environment=prod", alice, nil)
+	history, _ := resp["data"].([]interface{})
+	if code != http.StatusOK || len(history) != 2 {
+		t.Fatalf("prod history status = %d, body %v", code, resp)
+	}
+	latest := history[0].(map[string]interface{})
+	if latest["version"] != float64(2) || latest["config"] != `{"endpoint":"https://prod"}` {
+		t.Errorf("latest prod history = %v", latest)
+	}
+	if code, resp := doApproval(r, http.MethodPost, fmt.Sprintf("/apps/1/modules/push_send/config/rollback/%v", latest["id"]), alice, nil); code != http.StatusOK {
+		t.Fatalf("rollback prod status = %d, body %v", code, resp)
+	}
+	if _, sources := effectiveConfig(t, r, "environment=prod"); sources["endpoint"] != "prod" {
+		t.Errorf("prod sources after rollback = %v", sources)
+	}
+}
+
+func TestModuleConfigEnvironments_Approval(t *testing.T) {
+	r := environmentRouter(t)
+	db := database.GetDB()
+	db.Create(&model.ConfigApprovalPolicy{AppID: 1, RequireApproval: true})
+
+	code, resp := doApproval(r, http.MethodPut, "/apps/1/modules/push_send/config?environment=staging", "1:alice", gin.H{"config": gin.H{"batch_size": 300}})
+	if code != http.StatusAccepted {
+		t.Fatalf("save status = %d, body %v", code, resp)
+	}
+	requestID := uint(resp["data"].(map[string]interface{})["id"].(float64))
+	var request model.ConfigChangeRequest
+	db.First(&request, requestID)
+	if request.Environment != "staging" || request.Namespace != model.DefaultConfigNamespace || request.BaseConfig != "{}" {
+		t.Fatalf("request = %+v", request)
+	}
+
+	base := fmt.Sprintf("/apps/1/config-requests/%d", requestID)
+	doApproval(r, http.MethodPost, base+"/approve", "2:bob", nil)
+	if code, resp := doApproval(r, http.MethodPost, base+"/apply", "1:alice", nil); code != http.StatusOK {
+		t.Fatalf("apply status = %d, body %v", code, resp)
+	}
+
+	var staging model.AppModuleConfig
+	db.Where("app_id = 1 AND environment = ?", "staging").First(&staging)
+	var module model.AppModule
+	db.Where("app_id = 1 AND module_code = ?", "push_send").First(&module)
+	if staging.Config != `{"batch_size":300}` || module.Config != `{"batch_size":100}` {
+		t.Errorf("staging = %s, base = %s", staging.Config, module.Config)
+	}
+	var history model.ModuleConfigHistory
+	db.Where("app_id = 1 AND environment = ?", "staging").First(&history)
+	if history.Version != 1 || history.Config != "{}" {
+		t.Errorf("staging history = %+v", history)
+	}
+}
+
+func TestBundleEnvironmentOverrides(t *testing.T) {
+	db := openBundleDB(t)
+	if err := db.AutoMigrate(&model.App{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	db.Create(&model.App{ID: 1, Name: "staging", AppID: "app_staging"})
+	db.Create(&model.ModuleTemplate{ModuleCode: "push_send", Status: 1})
+	db.Create(&model.AppModule{AppID: 1, ModuleCode: "push_send", Config: `{"batch_size":100}`, Status: 1})
+	db.Create(&model.AppModuleConfig{AppID: 1, ModuleCode: "push_send", Environment: "prod", Namespace: "application", Config: `{"batch_size":500}`})
+
+	bundle, err := exportBundle(db, 1, nil, false)
+	if err != nil {
+		t.Fatalf("exportBundle() error = %v", err)
+	}
+	if len(bundle.Modules) != 1 || len(bundle.Modules[0].Overrides) != 1 || bundle.Modules[0].Overrides[0].Environment != "prod" {
+		t.Fatalf("exported modules = %+v", bundle.Modules)
+	}
+
+	// 目标APP没有 prod 环境时覆盖值视为冲突
+	plan, err := planImport(db, 2, bundle)
+	if err != nil {
+		t.Fatalf("planImport() error = %v", err)
+	}
+	if plan.Summary != (ImportSummary{Add: 1, Conflict: 1}) {
+		t.Fatalf("summary without environment = %+v, items %+v", plan.Summary, plan.Items)
+	}
+
+	db.Create(&model.ConfigEnvironment{AppID: 2, Name: "prod", BaseEnvironment: "default"})
+	if plan, _ = planImport(db, 2, bundle); plan.Summary != (ImportSummary{Add: 2}) {
+		t.Fatalf("summary = %+v, items %+v", plan.Summary, plan.Items)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return applyImport(tx, 2, bundle, "tester")
+	}); err != nil {
+		t.Fatalf("applyImport() error = %v", err)
+	}
+	var prod model.AppModuleConfig
+	db.Where("app_id = 2 AND environment = ?", "prod").First(&prod)
+	var history model.ModuleConfigHistory
+	db.Where("app_id = 2 AND environment = ?", "prod").First(&history)
+	if prod.Config != `{"batch_size":500}` || history.Config != "{}" || history.Remark != "import from app_staging" {
+		t.Errorf("prod override = %+v, history = %+v", prod, history)
+	}
+
+	if plan, _ = planImport(db, 2, bundle); plan.Summary != (ImportSummary{Unchanged: 2}) {
+		t.Errorf("summary after import = %+v", plan.Summary)
+	}
+}
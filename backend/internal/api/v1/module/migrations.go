@@ -0,0 +1,165 @@
+package module
+
+import (
+	"time"
+
+	coremodule "app-platform-backend/core/module"
+
+	"gorm.io/gorm"
+)
+
+// 配置变更审批相关表的迁移，之前由启动时的 InitConfigApproval 创建
+// 下面的结构体是建表时的快照，之后的表结构变更写成新的迁移
+func init() {
+	coremodule.RegisterMigrations("config_approval", coremodule.Migration{
+		Version:     202610170001,
+		Description: "create config_approval_policies, config_change_requests, config_change_activities",
+		Up: func(tx *gorm.DB) error {
+			return coremodule.CreateTableIfNotExists(tx, &approvalPolicyV1{}, &changeRequestV1{}, &changeActivityV1{})
+		},
+	}, coremodule.Migration{
+		Version:     202610170002,
+		Description: "add environment and namespace to config_change_requests",
+		Up: func(tx *gorm.DB) error {
+			// 已有申请通过列默认值归入 default 环境的 application 命名空间
+			return coremodule.AddColumnsIfNotExists(tx, &changeRequestScopeV2{}, "Environment", "Namespace")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropScopeColumns(tx, &changeRequestScopeV2{})
+		},
+	})
+
+	// 模块配置按环境、命名空间保存的覆盖值
+	coremodule.RegisterMigrations("module_config", coremodule.Migration{
+		Version:     202610170001,
+		Description: "create app_module_configs, add environment and namespace to module_config_histories",
+		Up: func(tx *gorm.DB) error {
+			if err := coremodule.CreateTableIfNotExists(tx, &appModuleConfigV1{}, &moduleConfigHistoryV1{}); err != nil {
+				return err
+			}
+			return coremodule.AddColumnsIfNotExists(tx, &moduleConfigHistoryScopeV1{}, "Environment", "Namespace")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropScopeColumns(tx, &moduleConfigHistoryScopeV1{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable("app_module_configs")
+		},
+	})
+}
+
+// dropScopeColumns 删除迁移中新增的环境和命名空间列
+func dropScopeColumns(tx *gorm.DB, model interface{}) error {
+	m := tx.Migrator()
+	for _, field := range []string{"Environment", "Namespace"} {
+		if !m.HasColumn(model, field) {
+			continue
+		}
+		if err := m.DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type approvalPolicyV1 struct {
+	AppID           uint   `gorm:"primaryKey;autoIncrement:false"`
+	RequireApproval bool   `gorm:"not null"`
+	UpdatedBy       string `gorm:"size:50"`
+	UpdatedAt       time.Time
+}
+
+func (approvalPolicyV1) TableName() string {
+	return "config_approval_policies"
+}
+
+type changeRequestV1 struct {
+	ID            uint   `gorm:"primaryKey"`
+	AppID         uint   `gorm:"not null;index:idx_config_change_app_status"`
+	ModuleCode    string `gorm:"size:50;not null"`
+	Config        string `gorm:"type:json"`
+	BaseConfig    string `gorm:"type:json"`
+	Status        string `gorm:"size:20;not null;index:idx_config_change_app_status"`
+	Remark        string `gorm:"size:255"`
+	SubmittedBy   string `gorm:"size:50"`
+	SubmittedByID uint
+	ReviewedBy    string `gorm:"size:50"`
+	ReviewedByID  uint
+	ReviewedAt    *time.Time
+	ReviewComment string `gorm:"size:500"`
+	AppliedBy     string `gorm:"size:50"`
+	AppliedAt     *time.Time
+	HistoryID     uint
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (changeRequestV1) TableName() string {
+	return "config_change_requests"
+}
+
+type changeActivityV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	RequestID  uint   `gorm:"not null;index"`
+	Action     string `gorm:"size:20;not null"`
+	Operator   string `gorm:"size:50"`
+	OperatorID uint
+	Content    string `gorm:"type:text"`
+	CreatedAt  time.Time
+}
+
+func (changeActivityV1) TableName() string {
+	return "config_change_activities"
+}
+
+// changeRequestScopeV2 config_change_requests 新增的环境和命名空间列
+type changeRequestScopeV2 struct {
+	Environment string `gorm:"size:50;default:default"`
+	Namespace   string `gorm:"size:50;default:application"`
+}
+
+func (changeRequestScopeV2) TableName() string {
+	return "config_change_requests"
+}
+
+type appModuleConfigV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	AppID       uint   `gorm:"uniqueIndex:idx_app_module_configs_scope,priority:1"`
+	ModuleCode  string `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:2"`
+	Environment string `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:3"`
+	Namespace   string `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:4"`
+	Config      string `gorm:"type:json"`
+	UpdatedBy   string `gorm:"size:50"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (appModuleConfigV1) TableName() string {
+	return "app_module_configs"
+}
+
+// moduleConfigHistoryV1 引入迁移前的 module_config_histories，已存在的表保持不变
+type moduleConfigHistoryV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	AppID      uint   `gorm:"index"`
+	ModuleCode string `gorm:"size:50"`
+	Config     string `gorm:"type:json"`
+	Version    int
+	Operator   string `gorm:"size:50"`
+	Remark     string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
+
+func (moduleConfigHistoryV1) TableName() string {
+	return "module_config_histories"
+}
+
+// moduleConfigHistoryScopeV1 module_config_histories 新增的环境和命名空间列
+type moduleConfigHistoryScopeV1 struct {
+	Environment string `gorm:"size:50;default:default"`
+	Namespace   string `gorm:"size:50;default:application"`
+}
+
+func (moduleConfigHistoryScopeV1) TableName() string {
+	return "module_config_histories"
+}
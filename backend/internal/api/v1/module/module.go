@@ -2,13 +2,22 @@ package module
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
+	coremodule "app-platform-backend/core/module"
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/middleware"
 	"app-platform-backend/internal/model"
 	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/etag"
+	"app-platform-backend/internal/validator"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func GetAllTemplates(c *gin.Context) {
@@ -109,9 +118,10 @@ func GetAppModule(c *gin.Context) {
 		return
 	}
 
+	etag.Set(c, module)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
-		"data": module,
+		"data": maskedModule(&module),
 	})
 }
 
@@ -127,6 +137,7 @@ func EnableModule(c *gin.Context) {
 
 	var req struct {
 		ModuleCode string `json:"module_code" binding:"required"`
+		Force      bool   `json:"force"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -141,6 +152,14 @@ func EnableModule(c *gin.Context) {
 		return
 	}
 
+	// 检查依赖是否满足，force=true 时跳过
+	if !forceRequested(c, req.Force) {
+		if err := checkStatusChange(database.GetDB(), appID, []string{req.ModuleCode}, nil); err != nil {
+			respondStatusChangeError(c, err)
+			return
+		}
+	}
+
 	module := model.AppModule{
 		AppID:      appID,
 		ModuleCode: req.ModuleCode,
@@ -152,6 +171,7 @@ func EnableModule(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable module"})
 		return
 	}
+	middleware.InvalidateModuleGate(appID, req.ModuleCode)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
@@ -178,6 +198,7 @@ func UpdateModule(c *gin.Context) {
 
 	var req struct {
 		Status *int `json:"status"`
+		Force  bool `json:"force"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -186,12 +207,55 @@ func UpdateModule(c *gin.Context) {
 	}
 
 	if req.Status != nil {
-		database.GetDB().Model(&module).Update("status", *req.Status)
+		err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := etag.ForUpdate(tx).First(&module, module.ID).Error; err != nil {
+				return err
+			}
+			if !etag.Match(c, module) {
+				return errModuleModified
+			}
+			// 启用和停用与 EnableModule / DisableModule 一样检查依赖，force=true 时跳过
+			if !forceRequested(c, req.Force) {
+				var enable, disable []string
+				if *req.Status == 1 && module.Status != 1 {
+					enable = []string{moduleCode}
+				} else if *req.Status != 1 && module.Status == 1 {
+					disable = []string{moduleCode}
+				}
+				if len(enable)+len(disable) > 0 {
+					if err := checkStatusChange(tx, appID, enable, disable); err != nil {
+						return err
+					}
+				}
+			}
+			if err := tx.Model(&module).Update("status", *req.Status).Error; err != nil {
+				return err
+			}
+			return tx.First(&module, module.ID).Error
+		})
+		if errors.Is(err, errModuleModified) {
+			respondModuleModified(c, &module)
+			return
+		}
+		var conflict *dependencyConflict
+		if errors.As(err, &conflict) {
+			respondStatusChangeError(c, err)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update module"})
+			return
+		}
+		middleware.InvalidateModuleGate(appID, moduleCode)
+	} else if !etag.Match(c, module) {
+		respondModuleModified(c, &module)
+		return
 	}
 
+	etag.Set(c, module)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
-		"data": module,
+		"data": maskedModule(&module),
 	})
 }
 
@@ -206,10 +270,28 @@ func DisableModule(c *gin.Context) {
 		return
 	}
 
+	// 与 EnableModule 一样，force 可以放在请求体或查询参数中
+	var req struct {
+		Force bool `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 检查是否有已启用的模块依赖该模块，force=true 时跳过
+	if !forceRequested(c, req.Force) {
+		if err := checkStatusChange(database.GetDB(), appID, nil, []string{moduleCode}); err != nil {
+			respondStatusChangeError(c, err)
+			return
+		}
+	}
+
 	if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).Delete(&model.AppModule{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable module"})
 		return
 	}
+	middleware.InvalidateModuleGate(appID, moduleCode)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
@@ -229,6 +311,7 @@ func BatchEnableModules(c *gin.Context) {
 
 	var req struct {
 		ModuleCodes []string `json:"module_codes" binding:"required"`
+		Force       bool     `json:"force"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -236,18 +319,46 @@ func BatchEnableModules(c *gin.Context) {
 		return
 	}
 
-	for _, code := range req.ModuleCodes {
-		var existing model.AppModule
-		if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, code).First(&existing).Error; err != nil {
+	// 同一批启用的模块可以互相满足依赖，force=true 时跳过检查
+	if !forceRequested(c, req.Force) {
+		if err := checkStatusChange(database.GetDB(), appID, req.ModuleCodes, nil); err != nil {
+			respondStatusChangeError(c, err)
+			return
+		}
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, code := range req.ModuleCodes {
+			var existing []model.AppModule
+			if err := tx.Where("app_id = ? AND module_code = ?", appID, code).Limit(1).Find(&existing).Error; err != nil {
+				return err
+			}
+			if len(existing) > 0 {
+				// 已停用的模块重新启用，依赖检查假定同一批模块都处于启用状态
+				if existing[0].Status != 1 {
+					if err := tx.Model(&existing[0]).Update("status", 1).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
 			module := model.AppModule{
 				AppID:      appID,
 				ModuleCode: code,
 				Config:     "{}",
 				Status:     1,
 			}
-			database.GetDB().Create(&module)
+			if err := tx.Create(&module).Error; err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable modules"})
+		return
 	}
+	middleware.InvalidateModuleGateApp(appID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
@@ -265,6 +376,10 @@ func SaveModuleConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
 		return
 	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
 
 	var module model.AppModule
 	if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
@@ -281,31 +396,115 @@ func SaveModuleConfig(c *gin.Context) {
 		return
 	}
 
-	// 保存配置历史
-	var maxVersion int
-	database.GetDB().Model(&model.ModuleConfigHistory{}).
-		Where("app_id = ? AND module_code = ?", appID, moduleCode).
-		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion)
+	// 按模块声明的 ConfigSchema 校验配置
+	schema, err := loadConfigSchema(database.GetDB(), moduleCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config schema"})
+		return
+	}
+	target, plain, sealed, ok := resolveScopedConfig(c, &module, s, schema, req.Config)
+	if !ok {
+		return
+	}
+	if err := validator.ValidateModuleConfig(moduleCode, schema, plain); err != nil {
+		respondConfigInvalid(c, err)
+		return
+	}
 
-	history := model.ModuleConfigHistory{
-		AppID:      appID,
-		ModuleCode: moduleCode,
-		Config:     module.Config,
-		Version:    maxVersion + 1,
-		Operator:   c.GetString("username"),
+	commitModuleConfig(c, target, sealed, "", "Config saved successfully")
+}
+
+// resolveScopedConfig 处理提交到某个范围的配置，返回该范围、用于校验的明文生效配置（叠加了继承的值）和用于保存的自身配置
+// 敏感字段提交 ****** 表示保持该范围原来的值；失败时已写入响应
+func resolveScopedConfig(c *gin.Context, module *model.AppModule, s configapi.Scope, schema, submitted map[string]interface{}) (*scopedConfig, map[string]interface{}, map[string]interface{}, bool) {
+	db := database.GetDB()
+	layers, err := scopeLayers(db, module, s)
+	if err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return nil, nil, nil, false
 	}
-	database.GetDB().Create(&history)
+	target, err := loadScopedConfig(db, module, s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return nil, nil, nil, false
+	}
+	plain, sealed, err := resolveConfig(schema, submitted, target.raw())
+	if err == nil {
+		plain, err = withInherited(layers, plain)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return nil, nil, nil, false
+	}
+	return target, plain, sealed, true
+}
 
-	// 更新配置
-	configJSON, _ := json.Marshal(req.Config)
-	database.GetDB().Model(&module).Update("config", string(configJSON))
+// commitModuleConfig 保存模块在某个范围的新配置，sealed 为敏感字段已加密的配置
+// 要求审批的APP只生成变更申请；否则加锁重新读取并校验 If-Match，记录配置历史后更新
+func commitModuleConfig(c *gin.Context, target *scopedConfig, sealed map[string]interface{}, remark, message string) {
+	module := target.Module
+	// 要求审批的APP只生成变更申请，审批通过并应用后才生效
+	if requiresApproval(database.GetDB(), module.AppID) {
+		if !etag.Match(c, target.resource()) {
+			respondConfigModified(c, target)
+			return
+		}
+		createConfigChange(c, target, sealed, remark)
+		return
+	}
 
+	// 加锁重新读取，携带 If-Match 时确认配置未被其他人修改
+	// 各个范围的写入都锁模块行，同一模块的历史版本号不会冲突
+	configJSON, _ := json.Marshal(sealed)
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(module, module.ID).Error; err != nil {
+			return err
+		}
+		current, err := loadScopedConfig(tx, module, target.Scope)
+		if err != nil {
+			return err
+		}
+		*target = *current
+		if !etag.Match(c, target.resource()) {
+			return errModuleModified
+		}
+
+		// 保存配置历史
+		if _, err := target.recordHistory(tx, c.GetString("username"), remark); err != nil {
+			return err
+		}
+		return target.save(tx, string(configJSON), c.GetString("username"))
+	})
+	if errors.Is(err, errModuleModified) {
+		respondConfigModified(c, target)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
+		return
+	}
+
+	etag.Set(c, target.resource())
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "Config saved successfully",
+		"message": message,
 	})
 }
 
+// errModuleModified 请求携带的 If-Match 与模块当前状态不一致
+var errModuleModified = errors.New("module has been modified")
+
+// respondModuleModified 返回 412 和模块的当前状态，客户端据此合并后携带新的 ETag 重试
+func respondModuleModified(c *gin.Context, module *model.AppModule) {
+	etag.Set(c, module)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "Module has been modified by someone else, reload and retry",
+		"data":  maskedModule(module),
+	})
+}
+
+// GetModuleConfig 模块在某个范围的配置：config 为该范围自身保存的配置，effective_config 为叠加继承和 schema 默认值后的生效配置
+// sources 为生效配置中每个已保存字段来自的环境
 func GetModuleConfig(c *gin.Context) {
 	idParam := c.Param("id")
 	moduleCode := c.Param("module_code")
@@ -316,21 +515,67 @@ func GetModuleConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
 		return
 	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
 
 	var module model.AppModule
 	if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
 		return
 	}
+	layers, err := scopeLayers(database.GetDB(), &module, s)
+	if err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+	target, err := loadScopedConfig(database.GetDB(), &module, s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return
+	}
 
+	schema, err := loadConfigSchema(database.GetDB(), moduleCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config schema"})
+		return
+	}
+
+	// 生效配置 = 继承链上合并后的配置 + schema 默认值，敏感字段均遮盖
+	effective, sources := mergeLayers(layers)
+	if schema != nil {
+		effective = validator.ApplySchemaDefaults(schema, effective)
+	}
+
+	etag.Set(c, target.resource())
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
-			"config": module.Config,
+			"environment":      s.Environment,
+			"namespace":        s.Namespace,
+			"config":           maskConfigJSON(schema, target.raw()),
+			"effective_config": maskConfig(schema, effective),
+			"sources":          sources,
+			"schema":           schema,
 		},
 	})
 }
 
+// respondConfigInvalid 返回配置校验失败的响应，包含每个字段的错误路径
+func respondConfigInvalid(c *gin.Context, err error) {
+	var errs validator.SchemaErrors
+	if !errors.As(err, &errs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "Config validation failed",
+		"data":  gin.H{"errors": errs},
+	})
+}
+
+// ResetModuleConfig 重置模块在某个范围的配置：基础范围恢复为 schema 默认值，其他范围删除覆盖值，全部从基础环境继承
 func ResetModuleConfig(c *gin.Context) {
 	idParam := c.Param("id")
 	moduleCode := c.Param("module_code")
@@ -341,27 +586,108 @@ func ResetModuleConfig(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
 		return
 	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
 
 	var module model.AppModule
 	if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
 		return
 	}
+	if _, err := configapi.EnvironmentChain(database.GetDB(), appID, s.Environment); err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+	target, err := loadScopedConfig(database.GetDB(), &module, s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return
+	}
 
-	database.GetDB().Model(&module).Update("config", "{}")
-
-	c.JSON(http.StatusOK, gin.H{
-		"code":    0,
-		"message": "Config reset successfully",
-	})
+	// 与保存配置一样经过审批、If-Match 校验并记录历史
+	commitModuleConfig(c, target, map[string]interface{}{}, "reset to defaults", "Config reset successfully")
 }
 
+// TestModuleConfig 用候选配置测试模块的外部依赖，不保存配置
+// 请求体未提供 config 时测试当前已保存的配置；候选配置叠加到从基础环境继承的配置上，先按 schema 校验，再交给模块的 ConfigTester 逐项检查
 func TestModuleConfig(c *gin.Context) {
+	idParam := c.Param("id")
+	moduleCode := c.Param("module_code")
+
+	// 获取数据库 ID
+	appID, err := getAppDatabaseID(idParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Config map[string]interface{} `json:"config"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	// 未启用的模块也可以测试，此时没有已保存的配置
+	module := model.AppModule{AppID: appID, ModuleCode: moduleCode, Config: "{}"}
+	database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module)
+	layers, err := scopeLayers(database.GetDB(), &module, s)
+	if err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+
+	// 候选配置中的 ****** 使用该范围已保存的值，未提供 config 时测试已保存的配置
+	stored := layers[len(layers)-1].Config
+	if req.Config == nil {
+		req.Config = stored
+	}
+	req.Config, err = openConfig(unmaskConfig(req.Config, stored))
+	if err == nil {
+		req.Config, err = withInherited(layers, req.Config)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process secret config: " + err.Error()})
+		return
+	}
+
+	schema, err := loadConfigSchema(database.GetDB(), moduleCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config schema"})
+		return
+	}
+	if err := validator.ValidateModuleConfig(moduleCode, schema, req.Config); err != nil {
+		respondConfigInvalid(c, err)
+		return
+	}
+
+	checks := coremodule.RunConfigChecks(c.Request.Context(), appID, moduleCode, req.Config)
+	if checks == nil {
+		checks = []coremodule.ConfigCheckResult{}
+	}
+	success := true
+	for _, check := range checks {
+		success = success && check.Passed
+	}
+
+	message := "Config test passed"
+	if !success {
+		message = "Config test failed"
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
-		"message": "Config test passed",
+		"message": message,
 		"data": gin.H{
-			"success": true,
+			"success": success,
+			"checks":  checks,
 		},
 	})
 }
@@ -376,17 +702,28 @@ func GetConfigHistory(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
 		return
 	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
 
 	var history []model.ModuleConfigHistory
-	database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).
+	database.GetDB().Where("app_id = ? AND module_code = ? AND environment = ? AND namespace = ?", appID, moduleCode, s.Environment, s.Namespace).
 		Order("version DESC").Limit(20).Find(&history)
 
+	schema := newSchemaCache(database.GetDB()).get(moduleCode)
+	for i := range history {
+		history[i].Config = maskConfigJSON(schema, history[i].Config)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": history,
 	})
 }
 
+// RollbackConfig 将配置回滚到某个历史版本，回滚到历史记录所在的环境和命名空间
+// 与保存配置一致，回滚前的配置会作为新的历史版本记录下来，备注中注明回滚的目标版本
 func RollbackConfig(c *gin.Context) {
 	idParam := c.Param("id")
 	moduleCode := c.Param("module_code")
@@ -399,68 +736,381 @@ func RollbackConfig(c *gin.Context) {
 		return
 	}
 
-	var history model.ModuleConfigHistory
-	if err := database.GetDB().First(&history, historyID).Error; err != nil {
+	var target model.ModuleConfigHistory
+	if err := database.GetDB().Where("id = ? AND app_id = ? AND module_code = ?", historyID, appID, moduleCode).
+		First(&target).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "History not found"})
 		return
 	}
 
-	database.GetDB().Model(&model.AppModule{}).
-		Where("app_id = ? AND module_code = ?", appID, moduleCode).
-		Update("config", history.Config)
+	var module model.AppModule
+	if err := database.GetDB().Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+	s := historyScope(&target)
+	if _, err := configapi.EnvironmentChain(database.GetDB(), appID, s.Environment); err != nil {
+		respondConfigScopeError(c, err, "Failed to load config")
+		return
+	}
+	scoped, err := loadScopedConfig(database.GetDB(), &module, s)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load config"})
+		return
+	}
+
+	// 要求审批的APP将回滚作为变更申请提交，审批通过并应用后才生效
+	if requiresApproval(database.GetDB(), appID) {
+		if !etag.Match(c, scoped.resource()) {
+			respondConfigModified(c, scoped)
+			return
+		}
+		createConfigChange(c, scoped, parseConfig(target.Config), fmt.Sprintf("rollback to version %d", target.Version))
+		return
+	}
+
+	// 与保存配置一样加锁重新读取，携带 If-Match 时确认配置未被其他人修改
+	var history *model.ModuleConfigHistory
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(&module, module.ID).Error; err != nil {
+			return err
+		}
+		current, err := loadScopedConfig(tx, &module, s)
+		if err != nil {
+			return err
+		}
+		scoped = current
+		if !etag.Match(c, scoped.resource()) {
+			return errModuleModified
+		}
+
+		if history, err = scoped.recordHistory(tx, c.GetString("username"), fmt.Sprintf("rollback to version %d", target.Version)); err != nil {
+			return err
+		}
+		return scoped.save(tx, target.Config, c.GetString("username"))
+	})
+	if errors.Is(err, errModuleModified) {
+		respondConfigModified(c, scoped)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Module not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rollback config: " + err.Error()})
+		return
+	}
 
+	etag.Set(c, scoped.resource())
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "Config rolled back successfully",
+		"data": gin.H{
+			"history":        maskedHistory(history),
+			"rolled_back_to": target.Version,
+		},
 	})
 }
 
+// historyScope 历史记录所属的环境和命名空间
+func historyScope(history *model.ModuleConfigHistory) configapi.Scope {
+	return configapi.Scope{Environment: history.Environment, Namespace: history.Namespace}
+}
+
+// CompareConfig 比较同一环境、命名空间下的两个配置版本
+// 查询参数 from、to 为历史版本号或 current（当前配置），to 默认为 current
+// 返回逐路径的结构化差异和统一格式的文本差异
 func CompareConfig(c *gin.Context) {
+	idParam := c.Param("id")
+	moduleCode := c.Param("module_code")
+
+	appID, err := getAppDatabaseID(idParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+	s, ok := queryConfigScope(c)
+	if !ok {
+		return
+	}
+
+	from := c.Query("from")
+	to := c.DefaultQuery("to", configVersionCurrent)
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+		return
+	}
+
+	fromConfig, err := loadConfigVersion(database.GetDB(), appID, moduleCode, s, from)
+	if err != nil {
+		respondConfigVersionError(c, from, err)
+		return
+	}
+	toConfig, err := loadConfigVersion(database.GetDB(), appID, moduleCode, s, to)
+	if err != nil {
+		respondConfigVersionError(c, to, err)
+		return
+	}
+
+	// 按明文比较，避免同一值的不同密文被识别为变更；返回前遮盖敏感字段
+	schema := newSchemaCache(database.GetDB()).get(moduleCode)
+	changes := maskDiff(schema, fromConfig, toConfig, diffConfig(fromConfig, toConfig))
+	maskedFrom, maskedTo := maskConfigPair(schema, fromConfig, toConfig)
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
-			"diff": []string{},
+			"from":    gin.H{"version": from, "config": maskedFrom},
+			"to":      gin.H{"version": to, "config": maskedTo},
+			"diff":    changes,
+			"summary": summarizeDiff(changes),
+			"unified": unifiedDiff(configVersionLabel(from), configVersionLabel(to), maskedFrom, maskedTo),
 		},
 	})
 }
 
+// configVersionCurrent 表示范围内当前保存的配置
+const configVersionCurrent = "current"
+
+// errInvalidConfigVersion 版本参数既不是数字也不是 current
+var errInvalidConfigVersion = errors.New("invalid config version")
+
+// loadConfigVersion 加载范围内指定版本的配置（敏感字段已解密），version 为历史版本号或 current
+func loadConfigVersion(db *gorm.DB, appID uint, moduleCode string, s configapi.Scope, version string) (map[string]interface{}, error) {
+	if version == configVersionCurrent {
+		var module model.AppModule
+		if err := db.Where("app_id = ? AND module_code = ?", appID, moduleCode).First(&module).Error; err != nil {
+			return nil, err
+		}
+		target, err := loadScopedConfig(db, &module, s)
+		if err != nil {
+			return nil, err
+		}
+		return openStoredConfig(target.raw()), nil
+	}
+
+	v, err := strconv.Atoi(version)
+	if err != nil || v <= 0 {
+		return nil, errInvalidConfigVersion
+	}
+	var history model.ModuleConfigHistory
+	if err := db.Where("app_id = ? AND module_code = ? AND environment = ? AND namespace = ? AND version = ?",
+		appID, moduleCode, s.Environment, s.Namespace, v).First(&history).Error; err != nil {
+		return nil, err
+	}
+	return openStoredConfig(history.Config), nil
+}
+
+// configVersionLabel 统一格式差异中的版本标签
+func configVersionLabel(version string) string {
+	if version == configVersionCurrent {
+		return version
+	}
+	return "version " + version
+}
+
+// respondConfigVersionError 返回加载配置版本失败的响应
+func respondConfigVersionError(c *gin.Context, version string, err error) {
+	switch {
+	case errors.Is(err, errInvalidConfigVersion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid version %q, expected a history version or current", version)})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Config version %s not found", version)})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// nextHistoryVersion 返回模块配置在范围内的下一个历史版本号
+// 调用方需在同一事务中先对模块行加锁（etag.ForUpdate），否则并发写入可能得到相同的版本号
+func nextHistoryVersion(db *gorm.DB, appID uint, moduleCode string, s configapi.Scope) int {
+	var maxVersion int
+	db.Model(&model.ModuleConfigHistory{}).
+		Where("app_id = ? AND module_code = ? AND environment = ? AND namespace = ?", appID, moduleCode, s.Environment, s.Namespace).
+		Select("COALESCE(MAX(version), 0)").Scan(&maxVersion)
+	return maxVersion + 1
+}
+
 func CheckModuleDependencies(c *gin.Context) {
+	idParam := c.Param("id")
+	moduleCode := c.Param("module_code")
+
+	// 获取数据库 ID
+	appID, err := getAppDatabaseID(idParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	graph, err := loadDependencyGraph(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load module dependencies"})
+		return
+	}
+	enabled, err := loadEnabledModules(database.GetDB(), appID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load app modules"})
+		return
+	}
+
+	required := graph.requiredBy(moduleCode)
+	missing := graph.missing(moduleCode, enabled)
+	suggestions := make([]string, 0, len(missing))
+	for _, code := range missing {
+		suggestions = append(suggestions, fmt.Sprintf("请先启用模块 %s", code))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
-			"satisfied":   true,
-			"missing":     []string{},
-			"suggestions": []string{},
+			"satisfied":    len(missing) == 0,
+			"dependencies": required,
+			"missing":      missing,
+			"unknown":      graph.unknown(required),
+			"suggestions":  suggestions,
 		},
 	})
 }
 
 func CheckModuleReverseDependencies(c *gin.Context) {
+	idParam := c.Param("id")
+	moduleCode := c.Param("module_code")
+
+	// 获取数据库 ID
+	appID, err := getAppDatabaseID(idParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	graph, err := loadDependencyGraph(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load module dependencies"})
+		return
+	}
+	enabled, err := loadEnabledModules(database.GetDB(), appID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load app modules"})
+		return
+	}
+
+	dependents := graph.dependents(moduleCode, enabled)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
-			"dependents": []string{},
+			"dependents":      dependents,
+			"safe_to_disable": len(dependents) == 0,
 		},
 	})
 }
 
 func AutoEnableModuleDependencies(c *gin.Context) {
+	idParam := c.Param("id")
+	moduleCode := c.Param("module_code")
+
+	// 获取数据库 ID
+	appID, err := getAppDatabaseID(idParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App not found"})
+		return
+	}
+
+	graph, err := loadDependencyGraph(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load module dependencies"})
+		return
+	}
+	if cycle := graph.findCycle(moduleCode); cycle != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Circular dependency detected",
+			"data":  gin.H{"path": cycle},
+		})
+		return
+	}
+	if unknown := graph.unknown(graph.requiredBy(moduleCode)); len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unknown dependency modules",
+			"data":  gin.H{"unknown": unknown},
+		})
+		return
+	}
+
+	var enabledCodes []string
+	err = database.WithTransaction(func(tx *gorm.DB) error {
+		enabled, err := loadEnabledModules(tx, appID)
+		if err != nil {
+			return err
+		}
+		enabledCodes = graph.missing(moduleCode, enabled)
+		return enableModules(tx, appID, enabledCodes)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable dependencies"})
+		return
+	}
+	middleware.InvalidateModuleGateApp(appID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    0,
 		"message": "Dependencies enabled successfully",
+		"data": gin.H{
+			"enabled": enabledCodes,
+		},
 	})
 }
 
 func DetectCircularDependency(c *gin.Context) {
+	moduleCode := c.Param("module_code")
+
+	graph, err := loadDependencyGraph(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load module dependencies"})
+		return
+	}
+
+	path := graph.findCycle(moduleCode)
+	if path == nil {
+		path = []string{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"data": gin.H{
-			"has_circular": false,
-			"path":         []string{},
+			"has_circular": len(path) > 0,
+			"path":         path,
 		},
 	})
 }
 
+// enableModules 在事务中启用一组模块：已存在但未启用的记录恢复状态，不存在的新建
+func enableModules(tx *gorm.DB, appID uint, codes []string) error {
+	for _, code := range codes {
+		var existing model.AppModule
+		err := tx.Where("app_id = ? AND module_code = ?", appID, code).First(&existing).Error
+		if err == nil {
+			if err := tx.Model(&existing).Update("status", 1).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		module := model.AppModule{
+			AppID:      appID,
+			ModuleCode: code,
+			Config:     "{}",
+			Status:     1,
+		}
+		if err := tx.Create(&module).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func parseUint(s string) uint {
 	var id uint
 	fmt.Sscanf(s, "%d", &id)
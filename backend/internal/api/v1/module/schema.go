@@ -0,0 +1,100 @@
+package module
+
+import (
+	"encoding/json"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/validator"
+
+	"gorm.io/gorm"
+)
+
+// loadConfigSchema 加载模块配置对应的 JSON Schema
+// module_code 直接对应某个功能时使用该功能的 schema；
+// 对应整个模块时，将模块下所有功能 schema 的 properties/required 合并为一个对象 schema
+// 没有声明任何 schema 时返回 nil
+func loadConfigSchema(db *gorm.DB, moduleCode string) (map[string]interface{}, error) {
+	var templates []model.ModuleTemplate
+	if err := db.Where("status = 1 AND (module_code = ? OR source_module = ?)", moduleCode, moduleCode).
+		Order("id ASC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	for _, t := range templates {
+		if t.ModuleCode == moduleCode {
+			if schema := parseSchema(t.ConfigSchema); len(schema) > 0 {
+				return schema, nil
+			}
+		}
+	}
+
+	properties := map[string]interface{}{}
+	required := []interface{}{}
+	for _, t := range templates {
+		schema := parseSchema(t.ConfigSchema)
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range props {
+			properties[name] = prop
+		}
+		if req, ok := schema["required"].([]interface{}); ok {
+			required = append(required, req...)
+		}
+	}
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	merged := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		merged["required"] = required
+	}
+	return merged, nil
+}
+
+// parseSchema 解析数据库中存储的 schema 字符串，无效或为空时返回 nil
+func parseSchema(raw string) map[string]interface{} {
+	if raw == "" {
+		return nil
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return nil
+	}
+	return schema
+}
+
+// parseConfig 解析 AppModule.Config，无效或为空时返回空对象
+func parseConfig(raw string) map[string]interface{} {
+	config := map[string]interface{}{}
+	if raw != "" {
+		json.Unmarshal([]byte(raw), &config)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return config
+}
+
+// LoadModuleConfig 读取APP已启用模块的配置供模块运行时使用：敏感字段已解密，并补全 schema 默认值
+// APP未启用该模块时返回 gorm.ErrRecordNotFound
+func LoadModuleConfig(db *gorm.DB, appID uint, moduleCode string) (map[string]interface{}, error) {
+	var module model.AppModule
+	if err := db.Where("app_id = ? AND module_code = ? AND status = 1", appID, moduleCode).First(&module).Error; err != nil {
+		return nil, err
+	}
+	config, err := openConfig(parseConfig(module.Config))
+	if err != nil {
+		return nil, err
+	}
+	schema, err := loadConfigSchema(db, moduleCode)
+	if err != nil {
+		return nil, err
+	}
+	if schema != nil {
+		config = validator.ApplySchemaDefaults(schema, config)
+	}
+	return config, nil
+}
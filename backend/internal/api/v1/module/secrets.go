@@ -0,0 +1,380 @@
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/secret"
+
+	"gorm.io/gorm"
+)
+
+// 模块配置中的敏感字段
+// ConfigSchema 顶层属性声明 "secret": true 的字段保存时加密，字段值（可以是对象，如推送凭证）整体编码为 JSON 后加密为
+// enc:v1:... 字符串；接口返回时替换为 ******，提交时传回 ****** 表示保持原值不变
+
+// secretChanged 比较差异时，新值相对旧值发生变化的敏感字段显示为该占位值
+const secretChanged = secret.Masked + " (changed)"
+
+// secretFields 返回 schema 顶层声明为 secret 的字段
+func secretFields(schema map[string]interface{}) map[string]bool {
+	fields := map[string]bool{}
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, prop := range props {
+		if p, ok := prop.(map[string]interface{}); ok && p["secret"] == true {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// isSecretValue 字段是否需要遮盖：schema 声明为 secret，或者值本身是密文（schema 后来取消了 secret 的历史数据）
+func isSecretValue(fields map[string]bool, name string, value interface{}) bool {
+	if fields[name] {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && secret.IsEncrypted(s)
+}
+
+// unmaskConfig 将提交的配置中为 ****** 的字段还原为已保存的值，已保存配置中没有该字段时删除
+func unmaskConfig(config, stored map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		if value != secret.Masked {
+			result[name] = value
+			continue
+		}
+		if old, ok := stored[name]; ok {
+			result[name] = old
+		}
+	}
+	return result
+}
+
+// openConfig 解密配置中的密文字段，返回新的配置
+func openConfig(config map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		s, ok := value.(string)
+		if !ok || !secret.IsEncrypted(s) {
+			result[name] = value
+			continue
+		}
+		plaintext, err := secret.Decrypt(s)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt config field %s: %w", name, err)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(plaintext), &decoded); err != nil {
+			return nil, fmt.Errorf("decode config field %s: %w", name, err)
+		}
+		result[name] = decoded
+	}
+	return result, nil
+}
+
+// openStoredConfig 解析并解密数据库中保存的配置；无法解密时记录日志并返回密文，用于只读的比较
+func openStoredConfig(raw string) map[string]interface{} {
+	config := parseConfig(raw)
+	opened, err := openConfig(config)
+	if err != nil {
+		log.Printf("[ModuleConfig] %v", err)
+		return config
+	}
+	return opened
+}
+
+// sealConfig 加密配置中的敏感字段，已是密文的值保持不变；未启用加密时原样返回
+func sealConfig(schema, config map[string]interface{}) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		result[name] = value
+	}
+	if !secret.Default().Enabled() {
+		return result, nil
+	}
+	for name := range secretFields(schema) {
+		value, ok := result[name]
+		if !ok || value == nil {
+			continue
+		}
+		if s, ok := value.(string); ok && secret.IsEncrypted(s) {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted, err := secret.Encrypt(string(data))
+		if err != nil {
+			return nil, err
+		}
+		result[name] = encrypted
+	}
+	return result, nil
+}
+
+// resolveConfig 处理提交的配置：****** 还原为 stored 中的值，解密得到用于校验的明文配置，加密得到用于保存的配置
+// 保持不变的敏感字段沿用原密文，不会因为每次保存而产生新的密文
+func resolveConfig(schema, submitted map[string]interface{}, stored string) (plain, sealed map[string]interface{}, err error) {
+	merged := unmaskConfig(submitted, parseConfig(stored))
+	plain, err = openConfig(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	sealed, err = sealConfig(schema, merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plain, sealed, nil
+}
+
+// maskConfig 返回遮盖了敏感字段的配置
+func maskConfig(schema, config map[string]interface{}) map[string]interface{} {
+	fields := secretFields(schema)
+	result := make(map[string]interface{}, len(config))
+	for name, value := range config {
+		if value != nil && isSecretValue(fields, name, value) {
+			value = secret.Masked
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// maskConfigJSON 遮盖 JSON 字符串形式的配置
+func maskConfigJSON(schema map[string]interface{}, raw string) string {
+	if raw == "" {
+		return raw
+	}
+	data, err := json.Marshal(maskConfig(schema, parseConfig(raw)))
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// maskConfigPair 遮盖用于比较的两份明文配置，敏感字段发生变化时新值显示为 ****** (changed)，使差异仍然可见
+func maskConfigPair(schema, from, to map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	maskedFrom, maskedTo := maskConfig(schema, from), maskConfig(schema, to)
+	for name, value := range maskedTo {
+		if value == secret.Masked && maskedFrom[name] == secret.Masked && len(diffConfig(from[name], to[name])) > 0 {
+			maskedTo[name] = secretChanged
+		}
+	}
+	return maskedFrom, maskedTo
+}
+
+// maskDiff 将敏感字段下的逐路径差异合并为字段级的一条，不暴露字段内部的路径和值
+func maskDiff(schema, from, to map[string]interface{}, changes []ConfigChange) []ConfigChange {
+	fields := secretFields(schema)
+	result := make([]ConfigChange, 0, len(changes))
+	seen := map[string]bool{}
+	for _, change := range changes {
+		name := change.Path
+		if i := strings.IndexAny(name, ".["); i >= 0 {
+			name = name[:i]
+		}
+		if !fields[name] && !isSecretValue(fields, name, from[name]) && !isSecretValue(fields, name, to[name]) {
+			result = append(result, change)
+			continue
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		masked := ConfigChange{Path: name, Op: DiffChanged, Old: secret.Masked, New: secretChanged}
+		if _, ok := from[name]; !ok {
+			masked.Op, masked.Old, masked.New = DiffAdded, nil, secret.Masked
+		} else if _, ok := to[name]; !ok {
+			masked.Op, masked.Old, masked.New = DiffRemoved, secret.Masked, nil
+		}
+		result = append(result, masked)
+	}
+	return result
+}
+
+// schemaCache 在一次请求内按模块缓存 schema，加载失败时按无 schema 处理（仍会遮盖密文）
+type schemaCache struct {
+	db      *gorm.DB
+	schemas map[string]map[string]interface{}
+}
+
+func newSchemaCache(db *gorm.DB) *schemaCache {
+	return &schemaCache{db: db, schemas: map[string]map[string]interface{}{}}
+}
+
+func (s *schemaCache) get(moduleCode string) map[string]interface{} {
+	if schema, ok := s.schemas[moduleCode]; ok {
+		return schema
+	}
+	schema, err := loadConfigSchema(s.db, moduleCode)
+	if err != nil {
+		log.Printf("[ModuleConfig] Failed to load config schema of %s: %v", moduleCode, err)
+	}
+	s.schemas[moduleCode] = schema
+	return schema
+}
+
+// maskedModule 返回遮盖了敏感配置的模块副本，ETag 仍按原始记录计算
+func maskedModule(module *model.AppModule) model.AppModule {
+	masked := *module
+	masked.Config = maskConfigJSON(newSchemaCache(database.GetDB()).get(module.ModuleCode), module.Config)
+	return masked
+}
+
+// maskedOverride 返回遮盖了敏感配置的覆盖值副本
+func maskedOverride(override model.AppModuleConfig) model.AppModuleConfig {
+	override.Config = maskConfigJSON(newSchemaCache(database.GetDB()).get(override.ModuleCode), override.Config)
+	return override
+}
+
+// maskedChangeRequest 返回遮盖了敏感配置的申请副本
+func maskedChangeRequest(schemas *schemaCache, request *model.ConfigChangeRequest) model.ConfigChangeRequest {
+	schema := schemas.get(request.ModuleCode)
+	masked := *request
+	masked.Config = maskConfigJSON(schema, request.Config)
+	masked.BaseConfig = maskConfigJSON(schema, request.BaseConfig)
+	return masked
+}
+
+// maskedHistory 返回遮盖了敏感配置的历史副本
+func maskedHistory(history *model.ModuleConfigHistory) model.ModuleConfigHistory {
+	masked := *history
+	masked.Config = maskConfigJSON(newSchemaCache(database.GetDB()).get(history.ModuleCode), history.Config)
+	return masked
+}
+
+// rekeyConfig 将配置中的密文改用当前主密钥加密，并加密 schema 中声明为 secret 但仍是明文的字段
+func rekeyConfig(schema map[string]interface{}, raw string) (string, bool, error) {
+	config := parseConfig(raw)
+	rotated := make(map[string]interface{}, len(config))
+	changed := false
+	for name, value := range config {
+		s, ok := value.(string)
+		if !ok || !secret.IsEncrypted(s) {
+			rotated[name] = value
+			continue
+		}
+		next, keyChanged, err := secret.Rotate(s)
+		if err != nil {
+			return raw, false, fmt.Errorf("field %s: %w", name, err)
+		}
+		rotated[name] = next
+		changed = changed || keyChanged
+	}
+
+	sealed, err := sealConfig(schema, rotated)
+	if err != nil {
+		return raw, false, err
+	}
+	for name, value := range sealed {
+		if s, ok := value.(string); ok && secret.IsEncrypted(s) && rotated[name] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return raw, false, nil
+	}
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		return raw, false, err
+	}
+	return string(data), true, nil
+}
+
+// ReencryptConfigs 轮换模块配置（包括各环境的覆盖值）、配置历史和变更申请中的敏感字段，返回更新的记录数
+func ReencryptConfigs(db *gorm.DB) (int, error) {
+	schemas := newSchemaCache(db)
+	updated := 0
+
+	var modules []model.AppModule
+	if err := db.Find(&modules).Error; err != nil {
+		return 0, err
+	}
+	for _, m := range modules {
+		config, changed, err := rekeyConfig(schemas.get(m.ModuleCode), m.Config)
+		if err != nil {
+			return updated, fmt.Errorf("app module %d: %w", m.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := db.Model(&model.AppModule{}).Where("id = ?", m.ID).UpdateColumn("config", config).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	var overrides []model.AppModuleConfig
+	if err := db.Find(&overrides).Error; err != nil {
+		return updated, err
+	}
+	for _, o := range overrides {
+		config, changed, err := rekeyConfig(schemas.get(o.ModuleCode), o.Config)
+		if err != nil {
+			return updated, fmt.Errorf("app module config %d: %w", o.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := db.Model(&model.AppModuleConfig{}).Where("id = ?", o.ID).UpdateColumn("config", config).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	var histories []model.ModuleConfigHistory
+	if err := db.Find(&histories).Error; err != nil {
+		return updated, err
+	}
+	for _, h := range histories {
+		config, changed, err := rekeyConfig(schemas.get(h.ModuleCode), h.Config)
+		if err != nil {
+			return updated, fmt.Errorf("config history %d: %w", h.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := db.Model(&model.ModuleConfigHistory{}).Where("id = ?", h.ID).UpdateColumn("config", config).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	// 审批表在启用审批功能后才存在
+	if !db.Migrator().HasTable(&model.ConfigChangeRequest{}) {
+		return updated, nil
+	}
+	var requests []model.ConfigChangeRequest
+	if err := db.Find(&requests).Error; err != nil {
+		return updated, err
+	}
+	for _, r := range requests {
+		schema := schemas.get(r.ModuleCode)
+		config, configChanged, err := rekeyConfig(schema, r.Config)
+		if err != nil {
+			return updated, fmt.Errorf("config change request %d: %w", r.ID, err)
+		}
+		base, baseChanged, err := rekeyConfig(schema, r.BaseConfig)
+		if err != nil {
+			return updated, fmt.Errorf("config change request %d: %w", r.ID, err)
+		}
+		if !configChanged && !baseChanged {
+			continue
+		}
+		if err := db.Model(&model.ConfigChangeRequest{}).Where("id = ?", r.ID).UpdateColumns(map[string]interface{}{
+			"config":      config,
+			"base_config": base,
+		}).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
@@ -0,0 +1,115 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"app-platform-backend/internal/pkg/secret"
+)
+
+func useKeyring(t *testing.T, keys map[string][]byte, active string) {
+	t.Helper()
+	k, err := secret.NewKeyring(keys, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := secret.Default()
+	secret.SetDefault(k)
+	t.Cleanup(func() { secret.SetDefault(previous) })
+}
+
+func TestSecretConfig_SealMaskResolve(t *testing.T) {
+	k1 := bytes.Repeat([]byte{1}, 32)
+	useKeyring(t, map[string][]byte{"k1": k1}, "k1")
+
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"provider":    map[string]interface{}{"type": "string"},
+			"credentials": map[string]interface{}{"type": "object", "secret": true},
+		},
+	}
+	creds := map[string]interface{}{"server_key": "s3cr3t"}
+	plain := map[string]interface{}{"provider": "fcm", "credentials": creds}
+
+	sealed, err := sealConfig(schema, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(sealed)
+	if strings.Contains(string(raw), "s3cr3t") || secret.KeyID(sealed["credentials"].(string)) != "k1" {
+		t.Fatalf("sealConfig() = %s", raw)
+	}
+	if opened, err := openConfig(sealed); err != nil || !reflect.DeepEqual(opened, plain) {
+		t.Fatalf("openConfig() = %v, %v", opened, err)
+	}
+
+	masked := maskConfig(schema, sealed)
+	if masked["credentials"] != secret.Masked || masked["provider"] != "fcm" {
+		t.Errorf("maskConfig() = %v", masked)
+	}
+
+	// 提交 ****** 保持已保存的值，其余字段按提交的值更新
+	submitted := map[string]interface{}{"provider": "hms", "credentials": secret.Masked}
+	gotPlain, gotSealed, err := resolveConfig(schema, submitted, string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotPlain["credentials"], creds) || gotPlain["provider"] != "hms" {
+		t.Errorf("resolveConfig() plain = %v", gotPlain)
+	}
+	if gotSealed["credentials"] != sealed["credentials"] {
+		t.Error("unchanged secret was re-encrypted")
+	}
+	// 没有已保存的值时，****** 视为未设置
+	if gotPlain, _, _ := resolveConfig(schema, submitted, "{}"); len(gotPlain) != 1 {
+		t.Errorf("resolveConfig() without stored value = %v", gotPlain)
+	}
+
+	// 差异中不暴露敏感字段内部的路径和值
+	changed := map[string]interface{}{"provider": "fcm", "credentials": map[string]interface{}{"server_key": "other"}}
+	diff := maskDiff(schema, plain, changed, diffConfig(plain, changed))
+	want := []ConfigChange{{Path: "credentials", Op: DiffChanged, Old: secret.Masked, New: secretChanged}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("maskDiff() = %+v", diff)
+	}
+	from, to := maskConfigPair(schema, plain, changed)
+	if from["credentials"] != secret.Masked || to["credentials"] != secretChanged {
+		t.Errorf("maskConfigPair() = %v, %v", from, to)
+	}
+}
+
+func TestSecretConfig_Rekey(t *testing.T) {
+	k1 := bytes.Repeat([]byte{1}, 32)
+	k2 := bytes.Repeat([]byte{2}, 32)
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"token": map[string]interface{}{"type": "string", "secret": true},
+		},
+	}
+
+	useKeyring(t, map[string][]byte{"k1": k1}, "k1")
+	sealed, _ := sealConfig(schema, map[string]interface{}{"token": "abc", "n": float64(1)})
+	raw, _ := json.Marshal(sealed)
+
+	// 切换到新主密钥后，旧密文改用新主密钥加密；加密前保存的明文也会被加密
+	useKeyring(t, map[string][]byte{"k1": k1, "k2": k2}, "k2")
+	for _, input := range []string{string(raw), `{"token":"abc","n":1}`} {
+		got, changed, err := rekeyConfig(schema, input)
+		if err != nil || !changed {
+			t.Fatalf("rekeyConfig(%s) = %s, %v, %v", input, got, changed, err)
+		}
+		config := parseConfig(got)
+		if secret.KeyID(config["token"].(string)) != "k2" {
+			t.Errorf("rekeyConfig(%s) = %s", input, got)
+		}
+		if opened, _ := openConfig(config); opened["token"] != "abc" || opened["n"] != float64(1) {
+			t.Errorf("rekeyed config opened to %v", opened)
+		}
+		if _, changed, _ := rekeyConfig(schema, got); changed {
+			t.Error("config already using the active key was changed")
+		}
+	}
+}
@@ -1,10 +1,15 @@
 package monitor
 
 import (
+	"app-platform-backend/core/module"
 	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/etag"
 	"app-platform-backend/internal/response"
 	"app-platform-backend/internal/validator"
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"strconv"
 	"time"
 
@@ -89,6 +94,19 @@ func checkAlerts(appID uint, metricName string, value float64) {
 				"status":        "alerting",
 				"last_alert_at": now,
 			})
+
+			if err := module.Publish(context.Background(), module.AlertTriggeredEvent{
+				AppID:       appID,
+				AlertID:     alert.ID,
+				AlertName:   alert.AlertName,
+				MetricName:  metricName,
+				Condition:   alert.Condition,
+				Threshold:   alert.Threshold,
+				Value:       value,
+				TriggeredAt: now,
+			}); err != nil {
+				log.Printf("[Monitor] Failed to publish alert %d: %v", alert.ID, err)
+			}
 		}
 	}
 }
@@ -330,12 +348,61 @@ func UpdateAlert(c *gin.Context) {
 		updates["is_active"] = *req.IsActive
 	}
 
-	if err := db.Model(&alert).Updates(updates).Error; err != nil {
+	// 加锁重新读取，携带 If-Match 时确认告警规则未被其他人修改
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(&alert, alert.ID).Error; err != nil {
+			return err
+		}
+		if !etag.Match(c, alert) {
+			return errAlertModified
+		}
+		if err := tx.Model(&alert).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&alert, alert.ID).Error
+	})
+	if errors.Is(err, errAlertModified) {
+		etag.Set(c, alert)
+		response.PreconditionFailed(c, "告警规则已被他人修改，请刷新后重试", alert)
+		return
+	}
+	if err != nil {
+		response.DBError(c, err)
+		return
+	}
+
+	etag.Set(c, alert)
+	response.SuccessWithMessage(c, alert, "告警规则更新成功")
+}
+
+// errAlertModified 请求携带的 If-Match 与告警规则当前状态不一致
+var errAlertModified = errors.New("alert has been modified")
+
+// AlertDetail 告警规则详情，响应头 ETag 用于更新时的 If-Match
+func AlertDetail(c *gin.Context) {
+	id, err := validator.ValidateID(c.Param("id"))
+	if err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+	appID, err := strconv.ParseUint(c.Query("app_id"), 10, 32)
+	if err != nil {
+		response.ParamError(c, "无效的 app_id")
+		return
+	}
+
+	var alert model.MonitorAlert
+	if err := db.Where("id = ? AND app_id = ?", id, appID).First(&alert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "告警规则不存在或无权限操作")
+			return
+		}
 		response.DBError(c, err)
 		return
 	}
 
-	response.SuccessWithMessage(c, nil, "告警规则更新成功")
+	etag.Set(c, alert)
+	response.Success(c, alert)
 }
 
 // DeleteAlert 删除告警规则
@@ -0,0 +1,143 @@
+package push
+
+import (
+	"strings"
+	"time"
+
+	"app-platform-backend/internal/middleware"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/response"
+	"app-platform-backend/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterDevice 客户端注册或更新推送设备（SDK 接口）
+// 同一APP下按令牌去重，重新注册会更新用户、标签、时区并恢复失效的设备
+// timezone 为 IANA 时区（例如 Asia/Shanghai），用于按设备本地时间发送计划推送，未上报时为 UTC
+func RegisterDevice(c *gin.Context) {
+	app := middleware.SDKApp(c)
+	var req struct {
+		Token    string   `json:"token" binding:"required,max=255"`
+		Platform string   `json:"platform" binding:"required,oneof=ios android"`
+		UserID   string   `json:"user_id" binding:"max=64"`
+		Tags     []string `json:"tags"`
+		Timezone string   `json:"timezone" binding:"max=64"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ParamError(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := loadTimezone(req.Timezone); err != nil {
+		response.ParamError(c, err.Error())
+		return
+	}
+	tags := joinTags(req.Tags)
+	if len(tags) > 500 {
+		response.ParamError(c, "标签总长度不能超过500个字符")
+		return
+	}
+
+	now := time.Now()
+	var device model.PushDevice
+	err := db.Where("app_id = ? AND token = ?", app.ID, req.Token).First(&device).Error
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		device = model.PushDevice{
+			AppID:      app.ID,
+			Token:      req.Token,
+			Platform:   req.Platform,
+			UserID:     req.UserID,
+			Tags:       tags,
+			Timezone:   req.Timezone,
+			Status:     1,
+			LastSeenAt: &now,
+		}
+		err = db.Create(&device).Error
+	case err == nil:
+		err = db.Model(&device).Updates(map[string]interface{}{
+			"platform":     req.Platform,
+			"user_id":      req.UserID,
+			"tags":         tags,
+			"timezone":     req.Timezone,
+			"status":       1,
+			"last_seen_at": now,
+		}).Error
+	}
+	if err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.Success(c, device)
+}
+
+// UnregisterDevice 客户端注销推送设备（SDK 接口），例如用户退出登录或关闭通知
+func UnregisterDevice(c *gin.Context) {
+	app := middleware.SDKApp(c)
+	result := db.Where("app_id = ? AND token = ?", app.ID, c.Param("token")).Delete(&model.PushDevice{})
+	if result.Error != nil {
+		response.DBError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.NotFound(c, "设备不存在")
+		return
+	}
+	response.SuccessWithMessage(c, nil, "设备已注销")
+}
+
+// Devices 推送设备列表，可按 user_id、platform、status 过滤
+func Devices(c *gin.Context) {
+	appID := c.Query("app_id")
+	if appID == "" {
+		response.ParamError(c, "app_id 不能为空")
+		return
+	}
+	page, size := validator.ParsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("size", "20"))
+	page, size = validator.ValidatePagination(page, size)
+
+	query := db.Model(&model.PushDevice{}).Where("app_id = ?", appID)
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if platform := c.Query("platform"); platform != "" {
+		query = query.Where("platform = ?", platform)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	var devices []model.PushDevice
+	if err := query.Offset((page - 1) * size).Limit(size).Order("id DESC").Find(&devices).Error; err != nil {
+		response.DBError(c, err)
+		return
+	}
+	response.PageSuccess(c, devices, total, page, size)
+}
+
+// joinTags 将标签保存为首尾带逗号的形式，空标签和重复标签会被忽略
+func joinTags(tags []string) string {
+	seen := make(map[string]bool, len(tags))
+	var kept []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", ""))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		kept = append(kept, tag)
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return "," + strings.Join(kept, ",") + ","
+}
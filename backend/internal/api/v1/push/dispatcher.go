@@ -0,0 +1,470 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"app-platform-backend/core/module"
+	moduleapi "app-platform-backend/internal/api/v1/module"
+	"app-platform-backend/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// 推送状态：pending -> sending -> sent / failed，pending 时可以取消
+const (
+	StatusPending   = "pending"
+	StatusSending   = "sending"
+	StatusSent      = "sent"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// moduleCode 推送服务的模块Code，发送时读取APP在该模块下的配置
+const moduleCode = "push_service"
+
+const (
+	// DefaultWorkers 同时处理的推送任务数
+	DefaultWorkers = 4
+	// queueSize 等待处理的推送任务上限，队列满时发送接口返回 503
+	queueSize = 256
+
+	defaultBatchSize  = 500
+	defaultMaxRetries = 3
+)
+
+// retryBackoff 第一次重试前的等待时间，之后每次翻倍
+var retryBackoff = time.Second
+
+// errQueueUnavailable 分发器未启动或队列已满
+var errQueueUnavailable = errors.New("push queue is unavailable")
+
+// dispatcher 推送分发器：发送接口将任务放入队列后立即返回，由固定数量的 worker 异步完成投递
+type dispatcher struct {
+	mu      sync.Mutex
+	jobs    chan uint
+	quit    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+var deliveries = &dispatcher{}
+
+// StartDispatcher 启动 worker，由推送模块的 Start 调用
+func StartDispatcher(workers int) {
+	deliveries.start(workers)
+}
+
+// StopDispatcher 停止接收新任务并等待进行中的任务完成，ctx 结束时中断发送
+// 尚未开始处理的任务按 restoreQueued 恢复，重启后可以重新发送
+func StopDispatcher(ctx context.Context) error {
+	return deliveries.stop(ctx)
+}
+
+func (d *dispatcher) start(workers int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		return
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.jobs = make(chan uint, queueSize)
+	d.quit = make(chan struct{})
+	d.cancel = cancel
+	d.running = true
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work(ctx)
+	}
+	log.Printf("[Push] Dispatcher started with %d workers", workers)
+}
+
+func (d *dispatcher) stop(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return nil
+	}
+	d.running = false
+	close(d.quit)
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		err = ctx.Err()
+	}
+	d.cancel()
+
+	now := time.Now()
+	for {
+		select {
+		case id := <-d.jobs:
+			if db != nil {
+				restoreQueued(id, now)
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// restoreQueued 恢复停止时尚未处理的推送
+// 计划推送和计划产生的单次推送恢复为 scheduled 并立即到期，由计划分发器重新发送；其余恢复为 pending，等待手动发送
+func restoreQueued(id uint, now time.Time) {
+	sending := db.Model(&model.PushRecord{}).Where("id = ? AND status = ?", id, StatusSending)
+	result := sending.Session(&gorm.Session{}).Where("scheduled_at IS NOT NULL OR parent_id <> 0").
+		Updates(map[string]interface{}{"status": StatusScheduled, "next_run_at": now})
+	if result.Error != nil {
+		log.Printf("[Push] Failed to restore queued push %d: %v", id, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		return
+	}
+	if err := sending.Session(&gorm.Session{}).Update("status", StatusPending).Error; err != nil {
+		log.Printf("[Push] Failed to restore queued push %d: %v", id, err)
+	}
+}
+
+// SendingTimeout sending 状态超过该时间没有进度的推送视为投递中断，例如实例崩溃时队列中的任务丢失
+// 投递过程中每批设备发送后都会更新进度，正常投递不会超过该时间
+const SendingTimeout = 15 * time.Minute
+
+// RecoverStuck 恢复投递中断的推送，由推送模块声明为定时任务
+// 尚未发送任何设备的推送重新放入队列；已发送部分设备的推送标记为失败，避免重复发送给同一设备
+func RecoverStuck(ctx context.Context) error {
+	_, err := recoverStuck(ctx, time.Now())
+	return err
+}
+
+// recoverStuck 返回重新放入队列的推送数
+func recoverStuck(ctx context.Context, now time.Time) (int, error) {
+	cutoff := now.Add(-SendingTimeout)
+	var records []model.PushRecord
+	if err := db.WithContext(ctx).Where("status = ? AND updated_at < ?", StatusSending, cutoff).
+		Order("updated_at ASC").Limit(scheduleBatch).Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+	for _, record := range records {
+		// 条件更新认领，多个实例同时恢复时只有一个实例处理
+		claim := db.Model(&model.PushRecord{}).Where("id = ? AND status = ? AND updated_at < ?", record.ID, StatusSending, cutoff)
+		if record.SentCount > 0 {
+			result := claim.Updates(map[string]interface{}{
+				"status":        StatusFailed,
+				"error_message": fmt.Sprintf("投递中断，已发送 %d 台设备", record.SentCount),
+			})
+			if result.Error != nil {
+				return requeued, result.Error
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("[Push] Push %d was interrupted after %d devices, marked as failed", record.ID, record.SentCount)
+			}
+			continue
+		}
+
+		result := claim.Update("updated_at", now)
+		if result.Error != nil {
+			return requeued, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := deliveries.enqueue(record.ID); err != nil {
+			// 队列繁忙，超时后再次恢复
+			log.Printf("[Push] Failed to requeue push %d: %v", record.ID, err)
+			continue
+		}
+		requeued++
+		log.Printf("[Push] Requeued interrupted push %d", record.ID)
+	}
+	return requeued, nil
+}
+
+// enqueue 将已改为 sending 的推送放入队列，分发器未启动或队列已满时返回错误
+func (d *dispatcher) enqueue(id uint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.running {
+		return errQueueUnavailable
+	}
+	select {
+	case d.jobs <- id:
+		return nil
+	default:
+		return errQueueUnavailable
+	}
+}
+
+func (d *dispatcher) work(ctx context.Context) {
+	defer d.wg.Done()
+	for {
+		// 优先响应停止，未处理的任务留在队列中由 stop 恢复
+		select {
+		case <-d.quit:
+			return
+		default:
+		}
+		select {
+		case <-d.quit:
+			return
+		case id := <-d.jobs:
+			deliver(ctx, id)
+		}
+	}
+}
+
+// deliveryStats 一次推送的投递统计
+type deliveryStats struct {
+	Sent    int
+	Success int
+	Failed  int
+}
+
+// deliver 投递一条推送并写回真实的统计，发送成功后发布 push.sent 事件
+func deliver(ctx context.Context, id uint) {
+	var record model.PushRecord
+	if err := db.First(&record, id).Error; err != nil {
+		log.Printf("[Push] Failed to load push %d: %v", id, err)
+		return
+	}
+	if record.Status != StatusSending {
+		return
+	}
+
+	stats, err := run(ctx, &record)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":        StatusSent,
+		"sent_at":       now,
+		"sent_count":    stats.Sent,
+		"success_count": stats.Success,
+		"failed_count":  stats.Failed,
+		"error_message": "",
+	}
+	if err != nil {
+		updates["status"] = StatusFailed
+		updates["error_message"] = truncate(err.Error(), 500)
+		log.Printf("[Push] Push %d failed: %v", record.ID, err)
+	}
+	if err := db.Model(&record).Updates(updates).Error; err != nil {
+		log.Printf("[Push] Failed to update push %d: %v", record.ID, err)
+		return
+	}
+	if updates["status"] != StatusSent {
+		return
+	}
+
+	if err := module.Publish(context.Background(), module.PushSentEvent{
+		AppID:        record.AppID,
+		PushID:       record.ID,
+		Title:        record.Title,
+		Content:      record.Content,
+		TargetType:   record.TargetType,
+		TargetIDs:    splitTargets(record.TargetIDs),
+		SentCount:    stats.Sent,
+		SuccessCount: stats.Success,
+		FailedCount:  stats.Failed,
+		SentAt:       now,
+	}); err != nil {
+		log.Printf("[Push] Failed to publish push.sent for push %d: %v", record.ID, err)
+	}
+}
+
+// run 读取APP的推送配置，按批次向目标设备发送，每批完成后更新进度
+// 没有匹配的设备、通道配置错误或所有批次整批失败时返回错误
+func run(ctx context.Context, record *model.PushRecord) (deliveryStats, error) {
+	var stats deliveryStats
+	config, err := moduleapi.LoadModuleConfig(db, record.AppID, moduleCode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return stats, fmt.Errorf("%s is not enabled for app %d", moduleCode, record.AppID)
+	}
+	if err != nil {
+		return stats, err
+	}
+	provider, err := NewProvider(config)
+	if err != nil {
+		return stats, err
+	}
+	batchSize := intConfig(config, "batch_size", 1, defaultBatchSize)
+	maxRetries := intConfig(config, "max_retries", 0, defaultMaxRetries)
+
+	query, err := targetQuery(record)
+	if err != nil {
+		return stats, err
+	}
+	msg := Message{PushID: record.ID, Title: record.Title, Content: record.Content}
+
+	var devices []model.PushDevice
+	var lastErr error
+	result := query.Select("id", "token").FindInBatches(&devices, batchSize, func(tx *gorm.DB, batch int) error {
+		tokens := make([]string, len(devices))
+		for i, d := range devices {
+			tokens[i] = d.Token
+		}
+		outcome := sendBatch(ctx, provider, msg, tokens, maxRetries)
+		stats.Sent += len(tokens)
+		stats.Success += outcome.success
+		stats.Failed += len(tokens) - outcome.success
+		if outcome.err != nil {
+			lastErr = outcome.err
+		}
+		if len(outcome.unregistered) > 0 {
+			if err := db.Model(&model.PushDevice{}).
+				Where("app_id = ? AND token IN ?", record.AppID, outcome.unregistered).
+				Update("status", 0).Error; err != nil {
+				log.Printf("[Push] Failed to deactivate devices for push %d: %v", record.ID, err)
+			}
+		}
+		if err := db.Model(record).Updates(map[string]interface{}{
+			"sent_count":    stats.Sent,
+			"success_count": stats.Success,
+			"failed_count":  stats.Failed,
+		}).Error; err != nil {
+			log.Printf("[Push] Failed to update progress of push %d: %v", record.ID, err)
+		}
+		return ctx.Err()
+	})
+	if result.Error != nil {
+		return stats, result.Error
+	}
+	if stats.Sent == 0 {
+		return stats, errors.New("no active devices matched the target")
+	}
+	if stats.Success == 0 && lastErr != nil {
+		return stats, lastErr
+	}
+	return stats, nil
+}
+
+// targetQuery 推送目标对应的可用设备
+// all 为APP下全部设备，user 按 user_id 匹配，tag 匹配带有任一标签的设备
+// 按设备时区发送的计划产生的单次推送只发送给该时区的设备
+func targetQuery(record *model.PushRecord) (*gorm.DB, error) {
+	query := db.Model(&model.PushDevice{}).Where("app_id = ? AND status = 1", record.AppID)
+	if record.LocalTime && record.ParentID != 0 {
+		query = query.Where("timezone = ?", record.Timezone)
+	}
+	ids := splitTargets(record.TargetIDs)
+	switch record.TargetType {
+	case "", "all":
+		return query, nil
+	case "user":
+		if len(ids) == 0 {
+			return nil, errors.New("target_ids is required for user target")
+		}
+		return query.Where("user_id IN ?", ids), nil
+	case "tag":
+		if len(ids) == 0 {
+			return nil, errors.New("target_ids is required for tag target")
+		}
+		conditions := db.Where("tags LIKE ?", "%,"+ids[0]+",%")
+		for _, tag := range ids[1:] {
+			conditions = conditions.Or("tags LIKE ?", "%,"+tag+",%")
+		}
+		return query.Where(conditions), nil
+	default:
+		return nil, fmt.Errorf("target type %s is not supported for delivery", record.TargetType)
+	}
+}
+
+// batchOutcome 一批设备的发送结果
+type batchOutcome struct {
+	success      int
+	unregistered []string
+	err          error
+}
+
+// sendBatch 发送一批设备，临时失败的设备按指数退避重试，最多重试 maxRetries 次
+func sendBatch(ctx context.Context, provider Provider, msg Message, tokens []string, maxRetries int) batchOutcome {
+	var outcome batchOutcome
+	pending := tokens
+	for attempt := 0; ; attempt++ {
+		results, err := provider.Send(ctx, msg, pending)
+		var retry []string
+		switch {
+		case err != nil && IsTransient(err):
+			retry = pending
+			outcome.err = err
+		case err != nil:
+			outcome.err = err
+			return outcome
+		default:
+			for _, r := range results {
+				switch {
+				case r.Err == nil:
+					outcome.success++
+				case errors.Is(r.Err, ErrUnregistered):
+					outcome.unregistered = append(outcome.unregistered, r.Token)
+				case IsTransient(r.Err):
+					retry = append(retry, r.Token)
+				}
+			}
+		}
+		if len(retry) == 0 || attempt >= maxRetries {
+			return outcome
+		}
+
+		timer := time.NewTimer(retryBackoff << attempt)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			outcome.err = ctx.Err()
+			return outcome
+		case <-timer.C:
+		}
+		pending = retry
+	}
+}
+
+// splitTargets 解析逗号分隔的目标ID
+func splitTargets(raw string) []string {
+	var ids []string
+	for _, id := range strings.Split(raw, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// intConfig 读取整数配置，缺失或小于 min 时使用默认值；JSON 解码后的数字为 float64
+func intConfig(config map[string]interface{}, key string, min, def int) int {
+	value := def
+	switch v := config[key].(type) {
+	case float64:
+		value = int(v)
+	case int:
+		value = v
+	}
+	if value < min {
+		return def
+	}
+	return value
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,85 @@
+package push
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"app-platform-backend/internal/model"
+)
+
+func TestRecoverStuck(t *testing.T) {
+	jobs := useScheduleDB(t)
+	now := time.Now()
+
+	lost := model.PushRecord{AppID: 1, Title: "lost", Content: "c", Status: StatusSending}
+	partial := model.PushRecord{AppID: 1, Title: "partial", Content: "c", Status: StatusSending, SentCount: 5}
+	active := model.PushRecord{AppID: 1, Title: "active", Content: "c", Status: StatusSending}
+	for _, record := range []*model.PushRecord{&lost, &partial, &active} {
+		db.Create(record)
+	}
+	stale := now.Add(-SendingTimeout - time.Minute)
+	db.Model(&model.PushRecord{}).Where("id IN ?", []uint{lost.ID, partial.ID}).UpdateColumn("updated_at", stale)
+
+	n, err := recoverStuck(context.Background(), now)
+	if err != nil || n != 1 || <-jobs != lost.ID {
+		t.Fatalf("recoverStuck() = %d, %v", n, err)
+	}
+	// 重新入队后刷新了进度时间，下一次检查不会重复入队
+	if n, _ := recoverStuck(context.Background(), now); n != 0 {
+		t.Errorf("requeued %d pushes twice", n)
+	}
+
+	var got model.PushRecord
+	db.First(&got, partial.ID)
+	if got.Status != StatusFailed || got.ErrorMessage == "" {
+		t.Errorf("partially sent push = %+v", got)
+	}
+	var untouched model.PushRecord
+	db.First(&untouched, active.ID)
+	if untouched.Status != StatusSending {
+		t.Errorf("active push status = %s, want sending", untouched.Status)
+	}
+}
+
+func TestDispatcherStop_RestoresQueued(t *testing.T) {
+	jobs := useScheduleDB(t)
+	deliveries.quit = make(chan struct{})
+	deliveries.cancel = func() {}
+	now := time.Now()
+
+	scheduled := model.PushRecord{AppID: 1, Title: "scheduled", Content: "c", Status: StatusScheduled, ScheduledAt: &now, NextRunAt: &now}
+	manual := model.PushRecord{AppID: 1, Title: "manual", Content: "c", Status: StatusSending}
+	db.Create(&scheduled)
+	db.Create(&manual)
+	child := model.PushRecord{AppID: 1, Title: "child", Content: "c", Status: StatusSending, ParentID: scheduled.ID}
+	db.Create(&child)
+	if n, err := dispatchOnce(&scheduled, now); err != nil || n != 1 {
+		t.Fatalf("dispatchOnce() = %d, %v", n, err)
+	}
+	jobs <- manual.ID
+	jobs <- child.ID
+
+	if err := deliveries.stop(context.Background()); err != nil {
+		t.Fatalf("stop() error = %v", err)
+	}
+
+	// 计划推送重新到期，由计划分发器重新发送；手动发送的推送恢复为 pending
+	for _, id := range []uint{scheduled.ID, child.ID} {
+		var got model.PushRecord
+		db.First(&got, id)
+		if got.Status != StatusScheduled || got.NextRunAt == nil || got.NextRunAt.After(time.Now()) {
+			t.Errorf("queued scheduled push after stop = %+v", got)
+		}
+	}
+	var got model.PushRecord
+	db.First(&got, manual.ID)
+	if got.Status != StatusPending {
+		t.Errorf("queued manual push status = %s, want pending", got.Status)
+	}
+
+	deliveries.running = true
+	if n := dispatchAt(t, time.Now()); n != 2 {
+		t.Errorf("redispatched %d pushes after restart, want 2", n)
+	}
+}
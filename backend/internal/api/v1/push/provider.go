@@ -0,0 +1,173 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Message 一次推送发送给每个设备的内容
+type Message struct {
+	PushID  uint
+	Title   string
+	Content string
+}
+
+// Result 单个设备的发送结果，Err 为 nil 表示成功
+type Result struct {
+	Token string
+	Err   error
+}
+
+// Provider 推送通道
+// Send 向一批设备发送同一条消息，返回每个设备的结果；返回 error 表示整批失败（例如鉴权失败、网络错误）
+// 可重试的失败用 Transient 包装，设备令牌失效返回 ErrUnregistered
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, msg Message, tokens []string) ([]Result, error)
+}
+
+// ProviderFactory 按模块配置创建推送通道，每次发送任务创建一次
+type ProviderFactory func(config map[string]interface{}) (Provider, error)
+
+// ErrUnregistered 设备令牌已失效，不再重试，设备会被标记为不可用
+var ErrUnregistered = errors.New("device token is no longer valid")
+
+// transientError 可重试的临时失败，例如限流、服务端 5xx、网络错误
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient 将错误标记为可重试
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient 错误是否可重试
+func IsTransient(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]ProviderFactory{}
+)
+
+// RegisterProvider 注册推送通道，同名时覆盖，测试可以借此替换 mock 通道
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	providers[name] = factory
+	providersMu.Unlock()
+}
+
+func lookupProvider(name string) (ProviderFactory, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	factory, ok := providers[name]
+	return factory, ok
+}
+
+func init() {
+	RegisterProvider("mock", func(map[string]interface{}) (Provider, error) { return NewMockProvider(), nil })
+	RegisterProvider("apns", newAPNsProvider)
+	RegisterProvider("fcm", newFCMProvider)
+	RegisterProvider("hms", newHMSProvider)
+	RegisterProvider("webhook", newWebhookProvider)
+}
+
+// NewProvider 按模块配置中的 provider 创建推送通道，未配置时使用 mock
+func NewProvider(config map[string]interface{}) (Provider, error) {
+	if err := VerifyProviderCredentials(context.Background(), config); err != nil {
+		return nil, err
+	}
+	factory, _ := lookupProvider(providerName(config))
+	return factory(config)
+}
+
+func providerName(config map[string]interface{}) string {
+	if provider, _ := config["provider"].(string); provider != "" {
+		return provider
+	}
+	return "mock"
+}
+
+// providerCredentialFields 各推送通道必需的凭证字段
+var providerCredentialFields = map[string][]string{
+	"apns": {"key_id", "team_id", "bundle_id", "private_key"},
+	"fcm":  {"service_account"},
+	"hms":  {"client_id", "client_secret"},
+}
+
+// VerifyProviderCredentials 校验模块配置中的推送通道凭证
+// 只检查凭证字段是否齐全、格式是否正确，不访问推送服务；通过 RegisterProvider 注册的其他通道不做检查
+func VerifyProviderCredentials(ctx context.Context, config map[string]interface{}) error {
+	provider := providerName(config)
+
+	switch provider {
+	case "mock":
+		return nil
+	case "webhook":
+		raw, _ := config["webhook_url"].(string)
+		if raw == "" {
+			return errors.New("webhook_url is required for webhook provider")
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook_url: %s", raw)
+		}
+		return nil
+	}
+
+	fields, ok := providerCredentialFields[provider]
+	if !ok {
+		if _, registered := lookupProvider(provider); registered {
+			return ctx.Err()
+		}
+		return fmt.Errorf("unsupported provider: %s", provider)
+	}
+	credentials := providerCredentials(config)
+	var missing []string
+	for _, field := range fields {
+		if strings.TrimSpace(credentials[field]) == "" {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s credentials missing: %s", provider, strings.Join(missing, ", "))
+	}
+	switch provider {
+	case "apns":
+		if !strings.Contains(credentials["private_key"], "BEGIN PRIVATE KEY") {
+			return errors.New("apns private_key must be a PEM encoded key")
+		}
+	case "fcm":
+		if _, _, err := parseFCMCredentials(credentials); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// providerCredentials 模块配置中的凭证，只保留字符串字段
+func providerCredentials(config map[string]interface{}) map[string]string {
+	raw, _ := config["credentials"].(map[string]interface{})
+	credentials := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			credentials[key] = s
+		}
+	}
+	return credentials
+}
@@ -0,0 +1,141 @@
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fcmServiceAccountJSON 生成测试用的服务账号密钥
+func fcmServiceAccountJSON(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "demo-project",
+		"private_key_id": "kid",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "push@demo-project.iam.gserviceaccount.com",
+	})
+	return string(data)
+}
+
+func TestVerifyProviderCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr string
+	}{
+		{"mock", map[string]interface{}{}, ""},
+		{"webhook missing url", map[string]interface{}{"provider": "webhook"}, "webhook_url is required for webhook provider"},
+		{"webhook", map[string]interface{}{"provider": "webhook", "webhook_url": "https://example.com/hook"}, ""},
+		{"fcm missing", map[string]interface{}{"provider": "fcm", "credentials": map[string]interface{}{"project_id": "p"}}, "fcm credentials missing: service_account"},
+		{"fcm bad key", map[string]interface{}{"provider": "fcm", "credentials": map[string]interface{}{"service_account": `{"project_id":"p","client_email":"e","private_key":"nope"}`}},
+			"fcm service_account private_key must be a PEM encoded key"},
+		{"hms", map[string]interface{}{"provider": "hms", "credentials": map[string]interface{}{"client_id": "1", "client_secret": "s"}}, ""},
+		{"apns bad key", map[string]interface{}{"provider": "apns", "credentials": map[string]interface{}{
+			"key_id": "k", "team_id": "t", "bundle_id": "b", "private_key": "nope"}}, "apns private_key must be a PEM encoded key"},
+	}
+	for _, tt := range tests {
+		err := VerifyProviderCredentials(context.Background(), tt.config)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.wantErr {
+			t.Errorf("%s: error = %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestFCMProvider_Results(t *testing.T) {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			r.ParseForm()
+			if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.PostForm.Get("assertion"), ".") != 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token":"at","expires_in":3600}`))
+			return
+		}
+		if r.URL.Path != "/v1/projects/demo-project/messages:send" || r.Header.Get("Authorization") != "Bearer at" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Message struct {
+				Token string `json:"token"`
+			} `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.Message.Token {
+		case "b":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+		case "c":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"status":"UNAVAILABLE"}}`))
+		default:
+			w.Write([]byte(`{"name":"projects/demo-project/messages/1"}`))
+		}
+	}))
+	defer server.Close()
+	defer func(token, send string) { fcmTokenURL, fcmSendURL = token, send }(fcmTokenURL, fcmSendURL)
+	fcmTokenURL = server.URL + "/token"
+	fcmSendURL = server.URL + "/v1/projects/%s/messages:send"
+
+	provider, err := NewProvider(map[string]interface{}{"provider": "fcm", "credentials": map[string]interface{}{"service_account": fcmServiceAccountJSON(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		results, err := provider.Send(context.Background(), Message{PushID: 1, Title: "t", Content: "c"}, []string{"a", "b", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[0].Err != nil || !errors.Is(results[1].Err, ErrUnregistered) || !IsTransient(results[2].Err) {
+			t.Errorf("results = %+v", results)
+		}
+	}
+	// 访问令牌在有效期内复用
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want 1", tokenRequests)
+	}
+}
+
+func TestSendBatch_RetriesTransient(t *testing.T) {
+	defer func(old time.Duration) { retryBackoff = old }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	provider := NewMockProvider()
+	tokens := []string{"ok", "transient-1", "invalid-1"}
+	outcome := sendBatch(context.Background(), provider, Message{PushID: 1}, tokens, 3)
+	if outcome.success != 2 || len(outcome.unregistered) != 1 || outcome.unregistered[0] != "invalid-1" {
+		t.Errorf("outcome = %+v", outcome)
+	}
+	if got := len(provider.Delivered()); got != 2 {
+		t.Errorf("delivered = %d, want 2", got)
+	}
+
+	// 不允许重试时临时失败的设备计为失败
+	outcome = sendBatch(context.Background(), NewMockProvider(), Message{PushID: 1}, tokens, 0)
+	if outcome.success != 1 {
+		t.Errorf("success without retry = %d, want 1", outcome.success)
+	}
+}
@@ -1,9 +1,12 @@
 package push
 
 import (
+	"app-platform-backend/core/module"
 	"app-platform-backend/internal/model"
 	"app-platform-backend/internal/response"
 	"app-platform-backend/internal/validator"
+	"context"
+	"errors"
 	"strings"
 	"time"
 
@@ -36,6 +39,10 @@ func List(c *gin.Context) {
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	// parent_id 查看周期推送或按时区发送的计划产生的单次推送
+	if parentID := c.Query("parent_id"); parentID != "" {
+		query = query.Where("parent_id = ?", parentID)
+	}
 
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
@@ -62,6 +69,9 @@ func Create(c *gin.Context) {
 		TargetType  string   `json:"target_type"`
 		TargetIDs   []string `json:"target_ids"`
 		ScheduledAt string   `json:"scheduled_at"`
+		Timezone    string   `json:"timezone"`
+		LocalTime   bool     `json:"local_time"`
+		Recurrence  string   `json:"recurrence"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -98,20 +108,18 @@ func Create(c *gin.Context) {
 		Content:    req.Content,
 		TargetType: req.TargetType,
 		TargetIDs:  strings.Join(req.TargetIDs, ","),
-		Status:     "pending",
+		Status:     StatusPending,
 	}
 
-	if req.ScheduledAt != "" {
-		scheduledTime, err := time.Parse("2006-01-02 15:04:05", req.ScheduledAt)
-		if err != nil {
-			response.ParamError(c, "计划发送时间格式错误，请使用: 2006-01-02 15:04:05")
-			return
-		}
-		if scheduledTime.Before(time.Now()) {
-			response.ParamError(c, "计划发送时间不能早于当前时间")
-			return
-		}
-		record.ScheduledAt = &scheduledTime
+	// 设置了计划时间或周期时由计划分发器到期发送
+	if err := ApplySchedule(&record, ScheduleSpec{
+		ScheduledAt: req.ScheduledAt,
+		Timezone:    req.Timezone,
+		LocalTime:   req.LocalTime,
+		Recurrence:  req.Recurrence,
+	}, time.Now()); err != nil {
+		response.ParamError(c, err.Error())
+		return
 	}
 
 	if err := db.Create(&record).Error; err != nil {
@@ -146,6 +154,7 @@ func Detail(c *gin.Context) {
 }
 
 // Send 立即发送推送
+// 推送改为 sending 并放入发送队列后立即返回，由分发器异步投递并写回真实的发送统计
 func Send(c *gin.Context) {
 	id := c.Param("id")
 
@@ -165,33 +174,26 @@ func Send(c *gin.Context) {
 		return
 	}
 
-	if record.Status != "pending" {
+	// 仅当仍为待发送时修改状态，避免重复点击或并发请求导致重复发送
+	result := db.Model(&model.PushRecord{}).
+		Where("id = ? AND status = ?", record.ID, StatusPending).
+		Update("status", StatusSending)
+	if result.Error != nil {
+		response.DBError(c, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
 		response.ParamError(c, "只有待发送状态的推送可以发送")
 		return
 	}
-
-	// 模拟发送推送
-	now := time.Now()
-	sentCount := 100
-	successCount := 95
-	failedCount := 5
-
-	if err := db.Model(&record).Updates(map[string]interface{}{
-		"status":        "sent",
-		"sent_at":       now,
-		"sent_count":    sentCount,
-		"success_count": successCount,
-		"failed_count":  failedCount,
-	}).Error; err != nil {
-		response.DBError(c, err)
+	if err := deliveries.enqueue(record.ID); err != nil {
+		db.Model(&model.PushRecord{}).Where("id = ? AND status = ?", record.ID, StatusSending).Update("status", StatusPending)
+		response.ServiceUnavailable(c, "推送队列繁忙，请稍后重试")
 		return
 	}
 
-	response.SuccessWithMessage(c, gin.H{
-		"sent_count":    sentCount,
-		"success_count": successCount,
-		"failed_count":  failedCount,
-	}, "推送发送成功")
+	record.Status = StatusSending
+	response.SuccessWithMessage(c, record, "推送任务已开始发送")
 }
 
 // Cancel 取消推送任务
@@ -214,15 +216,31 @@ func Cancel(c *gin.Context) {
 		return
 	}
 
-	if record.Status != "pending" {
-		response.ParamError(c, "只有待发送状态的推送可以取消")
+	if record.Status != StatusPending && record.Status != StatusScheduled {
+		response.ParamError(c, "只有待发送或计划中的推送可以取消")
 		return
 	}
 
-	if err := db.Model(&record).Update("status", "cancelled").Error; err != nil {
+	// 条件更新，避免与计划分发器同时认领
+	var cancelled bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.PushRecord{}).
+			Where("id = ? AND status IN ?", record.ID, []string{StatusPending, StatusScheduled}).
+			Updates(map[string]interface{}{"status": StatusCancelled, "next_run_at": nil})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		cancelled = true
+		return cancelPlan(tx, record.ID)
+	})
+	if err != nil {
 		response.DBError(c, err)
 		return
 	}
+	if !cancelled {
+		response.ParamError(c, "推送已开始发送，无法取消")
+		return
+	}
 
 	response.SuccessWithMessage(c, nil, "推送已取消")
 }
@@ -263,13 +281,16 @@ func Stats(c *gin.Context) {
 		return
 	}
 
-	var total, pending, sent, cancelled int64
+	var total, pending, scheduled, sending, sent, failed, cancelled int64
 	var totalSent, totalSuccess, totalFailed int64
 
 	db.Model(&model.PushRecord{}).Where("app_id = ?", appID).Count(&total)
-	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, "pending").Count(&pending)
-	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, "sent").Count(&sent)
-	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, "cancelled").Count(&cancelled)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusPending).Count(&pending)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusScheduled).Count(&scheduled)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusSending).Count(&sending)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusSent).Count(&sent)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusFailed).Count(&failed)
+	db.Model(&model.PushRecord{}).Where("app_id = ? AND status = ?", appID, StatusCancelled).Count(&cancelled)
 
 	db.Model(&model.PushRecord{}).Where("app_id = ?", appID).
 		Select("COALESCE(SUM(sent_count), 0)").Scan(&totalSent)
@@ -286,7 +307,10 @@ func Stats(c *gin.Context) {
 	response.Success(c, gin.H{
 		"total":         total,
 		"pending":       pending,
+		"scheduled":     scheduled,
+		"sending":       sending,
 		"sent":          sent,
+		"failed":        failed,
 		"cancelled":     cancelled,
 		"total_sent":    totalSent,
 		"total_success": totalSuccess,
@@ -308,3 +332,13 @@ func Templates(c *gin.Context) {
 		{"id": 3, "name": "订单通知", "title_template": "订单{{order_id}}状态更新", "content_template": "您的订单{{order_id}}{{status}}"},
 	})
 }
+
+// OnAppDeleted APP删除后取消其所有待发送和计划中的推送
+func OnAppDeleted(ctx context.Context, e module.AppDeletedEvent) error {
+	if db == nil {
+		return errors.New("push module not initialized")
+	}
+	return db.WithContext(ctx).Model(&model.PushRecord{}).
+		Where("app_id = ? AND status IN ?", e.AppID, []string{StatusPending, StatusScheduled}).
+		Updates(map[string]interface{}{"status": StatusCancelled, "next_run_at": nil}).Error
+}
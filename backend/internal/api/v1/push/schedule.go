@@ -0,0 +1,310 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"app-platform-backend/internal/model"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StatusScheduled 计划推送等待到期，由计划分发器改为 sending；周期推送在取消前一直保持该状态
+const StatusScheduled = "scheduled"
+
+const (
+	// ScheduleInterval 计划分发器的检查间隔，也是计划推送的最大延迟
+	ScheduleInterval = 30 * time.Second
+	// scheduleBatch 每次检查最多处理的到期推送数
+	scheduleBatch = 100
+	// 同一本地时间最早在 UTC+14 到达，最晚在 UTC-12 到达
+	localTimeEarliest = 14 * time.Hour
+	localTimeLatest   = 12 * time.Hour
+	// localTimeRecheck 按设备时区发送期间的最长检查间隔，使新注册设备的时区也能按时发送
+	localTimeRecheck = 15 * time.Minute
+)
+
+// ScheduleSpec 创建计划推送时的时间设置
+type ScheduleSpec struct {
+	ScheduledAt string // 2006-01-02 15:04:05，按 Timezone 解析
+	Timezone    string // IANA 时区，为空时为 UTC
+	LocalTime   bool   // 按每个设备的时区发送
+	Recurrence  string // cron 表达式（分 时 日 月 周）
+}
+
+// ApplySchedule 校验计划设置并写入推送记录，未设置计划时间和周期时记录保持 pending
+// 按设备时区发送时 ScheduledAt 保存为以 UTC 表示的本地时间
+func ApplySchedule(record *model.PushRecord, spec ScheduleSpec, now time.Time) error {
+	if spec.ScheduledAt == "" && spec.Recurrence == "" {
+		if spec.LocalTime {
+			return errors.New("按设备时区发送需要设置计划发送时间或周期")
+		}
+		return nil
+	}
+	if spec.Timezone == "" {
+		spec.Timezone = "UTC"
+	}
+	loc, err := loadTimezone(spec.Timezone)
+	if err != nil {
+		return err
+	}
+	if spec.LocalTime {
+		// 本地时间与具体时区无关，统一按 UTC 计算
+		loc = time.UTC
+	}
+
+	var schedule cron.Schedule
+	if spec.Recurrence != "" {
+		if schedule, err = cron.ParseStandard(spec.Recurrence); err != nil {
+			return fmt.Errorf("无效的周期表达式: %v", err)
+		}
+	}
+
+	var at time.Time
+	switch {
+	case spec.ScheduledAt != "":
+		if at, err = time.ParseInLocation("2006-01-02 15:04:05", spec.ScheduledAt, loc); err != nil {
+			return errors.New("计划发送时间格式错误，请使用: 2006-01-02 15:04:05")
+		}
+	case spec.LocalTime:
+		// 取仍有时区未到达的最近一次本地时间
+		at = schedule.Next(now.Add(-localTimeLatest).In(loc))
+	default:
+		at = schedule.Next(now.In(loc))
+	}
+	latest := at
+	if spec.LocalTime {
+		latest = at.Add(localTimeLatest)
+	}
+	if latest.Before(now) {
+		return errors.New("计划发送时间不能早于当前时间")
+	}
+
+	next := at
+	if spec.LocalTime {
+		next = at.Add(-localTimeEarliest)
+	}
+	at, next = at.UTC(), next.UTC()
+	record.Status = StatusScheduled
+	record.ScheduledAt = &at
+	record.NextRunAt = &next
+	record.Timezone = spec.Timezone
+	record.LocalTime = spec.LocalTime
+	record.Recurrence = spec.Recurrence
+	return nil
+}
+
+// DispatchScheduled 计划分发器任务，由推送模块声明为定时任务
+func DispatchScheduled(ctx context.Context) error {
+	_, err := dispatchDue(ctx, time.Now())
+	return err
+}
+
+// dispatchDue 处理所有到期的计划推送，返回放入发送队列的推送数
+// 多个实例同时执行时通过条件更新和 occurrence 唯一键认领，每次发送只会被一个实例执行
+func dispatchDue(ctx context.Context, now time.Time) (int, error) {
+	var records []model.PushRecord
+	if err := db.Where("status = ? AND next_run_at <= ?", StatusScheduled, now).
+		Order("next_run_at ASC").Limit(scheduleBatch).Find(&records).Error; err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for i := range records {
+		if err := ctx.Err(); err != nil {
+			return dispatched, err
+		}
+		record := &records[i]
+		var n int
+		var err error
+		if isPlan(record) {
+			n, err = dispatchPlan(record, now)
+		} else {
+			n, err = dispatchOnce(record, now)
+		}
+		dispatched += n
+		if err != nil {
+			log.Printf("[Push] Failed to dispatch scheduled push %d: %v", record.ID, err)
+		}
+	}
+	return dispatched, nil
+}
+
+// isPlan 周期推送和按设备时区发送的推送是计划，每次发送产生一条单次推送；其余推送直接发送自身
+func isPlan(record *model.PushRecord) bool {
+	return record.ParentID == 0 && (record.Recurrence != "" || record.LocalTime)
+}
+
+// dispatchOnce 认领到期的单次推送并放入发送队列
+func dispatchOnce(record *model.PushRecord, now time.Time) (int, error) {
+	result := db.Model(&model.PushRecord{}).
+		Where("id = ? AND status = ?", record.ID, StatusScheduled).
+		Updates(map[string]interface{}{"status": StatusSending, "next_run_at": nil})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 已被其他实例认领或已取消
+		return 0, nil
+	}
+	if err := deliveries.enqueue(record.ID); err != nil {
+		// 队列繁忙时恢复为计划状态，下次检查时重试
+		db.Model(&model.PushRecord{}).Where("id = ? AND status = ?", record.ID, StatusSending).
+			Updates(map[string]interface{}{"status": StatusScheduled, "next_run_at": now.Add(ScheduleInterval)})
+		return 0, err
+	}
+	return 1, nil
+}
+
+// dispatchPlan 为计划中已到期的发送产生单次推送，并推进到下一次发送
+// 固定时区时每次发送产生一条；按设备时区时每个时区到达计划的本地时间后各产生一条
+func dispatchPlan(plan *model.PushRecord, now time.Time) (int, error) {
+	at := plan.ScheduledAt.UTC()
+	if !plan.LocalTime {
+		n, err := spawn(plan, at, plan.Timezone)
+		if err != nil {
+			return n, err
+		}
+		loc, err := time.LoadLocation(plan.Timezone)
+		if err != nil {
+			return n, err
+		}
+		// 停机期间错过的多次发送只补发一次
+		return n, advance(plan, latestOf(at, now).In(loc))
+	}
+
+	query, err := targetQuery(plan)
+	if err != nil {
+		// 目标无法解析时计划不可能发送成功，标记为失败而不是每次检查都重试
+		return 0, failPlan(plan, err)
+	}
+	var zones []string
+	if err := query.Distinct().Pluck("timezone", &zones).Error; err != nil {
+		return 0, err
+	}
+	dispatched := 0
+	nextRun := at.Add(localTimeLatest)
+	for _, zone := range zones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			// 设备注册时已校验时区，这里只可能是时区数据缺失，跳过该时区
+			log.Printf("[Push] Skip timezone %s of push %d: %v", zone, plan.ID, err)
+			continue
+		}
+		local := localTime(at, loc)
+		if local.After(now) {
+			if local.Before(nextRun) {
+				nextRun = local
+			}
+			continue
+		}
+		n, err := spawn(plan, local, zone)
+		dispatched += n
+		if err != nil {
+			return dispatched, err
+		}
+	}
+	if now.Before(at.Add(localTimeLatest)) {
+		// 仍有时区未到达计划时间
+		if recheck := now.Add(localTimeRecheck); nextRun.After(recheck) {
+			nextRun = recheck
+		}
+		return dispatched, db.Model(plan).Update("next_run_at", nextRun.UTC()).Error
+	}
+	return dispatched, advance(plan, latestOf(at, now.Add(-localTimeLatest).UTC()))
+}
+
+// spawn 为计划的一次发送创建单次推送并放入发送队列，occurrence 唯一键保证同一次发送只创建一次
+func spawn(plan *model.PushRecord, at time.Time, zone string) (int, error) {
+	key := fmt.Sprintf("%d/%d/%s", plan.ID, plan.ScheduledAt.Unix(), zone)
+	at = at.UTC()
+	child := model.PushRecord{
+		AppID:       plan.AppID,
+		Title:       plan.Title,
+		Content:     plan.Content,
+		TargetType:  plan.TargetType,
+		TargetIDs:   plan.TargetIDs,
+		Status:      StatusSending,
+		ScheduledAt: &at,
+		Timezone:    zone,
+		LocalTime:   plan.LocalTime,
+		ParentID:    plan.ID,
+		Occurrence:  &key,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&child)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+	if err := deliveries.enqueue(child.ID); err != nil {
+		// 单次推送已创建，恢复为计划状态后按单次推送重新分发
+		log.Printf("[Push] Push %d of plan %d deferred: %v", child.ID, plan.ID, err)
+		return 0, db.Model(&child).Updates(map[string]interface{}{"status": StatusScheduled, "next_run_at": time.Now().Add(ScheduleInterval)}).Error
+	}
+	return 1, nil
+}
+
+// advance 推进到 after 之后的下一次发送；没有周期的计划发送完成后结束
+func advance(plan *model.PushRecord, after time.Time) error {
+	query := db.Model(&model.PushRecord{}).Where("id = ? AND status = ?", plan.ID, StatusScheduled)
+	if plan.Recurrence == "" {
+		return query.Updates(map[string]interface{}{"status": StatusSent, "sent_at": time.Now(), "next_run_at": nil}).Error
+	}
+	schedule, err := cron.ParseStandard(plan.Recurrence)
+	if err != nil {
+		return err
+	}
+	at := schedule.Next(after)
+	if at.IsZero() {
+		return query.Updates(map[string]interface{}{"status": StatusSent, "sent_at": time.Now(), "next_run_at": nil}).Error
+	}
+	next := at
+	if plan.LocalTime {
+		next = at.Add(-localTimeEarliest)
+	}
+	return query.Updates(map[string]interface{}{"scheduled_at": at.UTC(), "next_run_at": next.UTC()}).Error
+}
+
+// failPlan 将无法继续分发的计划标记为失败
+func failPlan(plan *model.PushRecord, cause error) error {
+	return db.Model(&model.PushRecord{}).Where("id = ? AND status = ?", plan.ID, StatusScheduled).
+		Updates(map[string]interface{}{"status": StatusFailed, "error_message": truncate(cause.Error(), 500), "next_run_at": nil}).Error
+}
+
+// loadTimezone 加载 IANA 时区；Local 取决于服务器设置，不允许使用
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, fmt.Errorf("无效的时区: %s", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区: %s", name)
+	}
+	return loc, nil
+}
+
+// localTime 将以 UTC 表示的本地时间换算为 loc 时区中的时刻
+func localTime(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+}
+
+func latestOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// cancelPlan 取消计划时一并取消尚未发送的单次推送
+func cancelPlan(tx *gorm.DB, id uint) error {
+	return tx.Model(&model.PushRecord{}).
+		Where("parent_id = ? AND status = ?", id, StatusScheduled).
+		Updates(map[string]interface{}{"status": StatusCancelled, "next_run_at": nil}).Error
+}
@@ -0,0 +1,188 @@
+package push
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"app-platform-backend/internal/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useScheduleDB 使用临时数据库，并以只入队不投递的分发器替换 deliveries，便于检查被认领的推送
+func useScheduleDB(t *testing.T) chan uint {
+	t.Helper()
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "schedule.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&model.PushRecord{}, &model.PushDevice{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	InitDB(database)
+
+	jobs := make(chan uint, 16)
+	old := deliveries
+	deliveries = &dispatcher{jobs: jobs, running: true}
+	t.Cleanup(func() { deliveries = old })
+	return jobs
+}
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func dispatchAt(t *testing.T, now time.Time) int {
+	t.Helper()
+	n, err := dispatchDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("dispatch at %s: %v", now, err)
+	}
+	return n
+}
+
+func TestApplySchedule_Validation(t *testing.T) {
+	now := mustTime(t, "2026-10-17T00:00:00Z")
+	tests := []struct {
+		name    string
+		spec    ScheduleSpec
+		wantErr string
+	}{
+		{"bad timezone", ScheduleSpec{ScheduledAt: "2026-10-18 09:00:00", Timezone: "Mars/Base"}, "无效的时区: Mars/Base"},
+		{"local timezone", ScheduleSpec{ScheduledAt: "2026-10-18 09:00:00", Timezone: "Local"}, "无效的时区: Local"},
+		{"past", ScheduleSpec{ScheduledAt: "2026-10-16 09:00:00"}, "计划发送时间不能早于当前时间"},
+		{"bad cron", ScheduleSpec{Recurrence: "every day"}, "无效的周期表达式"},
+		{"local without time", ScheduleSpec{LocalTime: true}, "按设备时区发送需要设置计划发送时间或周期"},
+		// 本地时间 10-16 20:00 在 UTC+14 已过，但在 UTC-12 仍未到达
+		{"local partially past", ScheduleSpec{ScheduledAt: "2026-10-16 20:00:00", LocalTime: true}, ""},
+	}
+	for _, tt := range tests {
+		var record model.PushRecord
+		err := ApplySchedule(&record, tt.spec, now)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if (got == "") != (tt.wantErr == "") || !strings.HasPrefix(got, tt.wantErr) {
+			t.Errorf("%s: error = %q, want %q", tt.name, got, tt.wantErr)
+		}
+	}
+}
+
+func TestDispatchScheduled_OnceAndRecurring(t *testing.T) {
+	jobs := useScheduleDB(t)
+	now := mustTime(t, "2026-10-17T00:00:00Z")
+
+	once := model.PushRecord{AppID: 1, Title: "once", Content: "c", TargetType: "all"}
+	if err := ApplySchedule(&once, ScheduleSpec{ScheduledAt: "2026-10-17 09:00:00", Timezone: "Asia/Shanghai"}, now); err != nil {
+		t.Fatal(err)
+	}
+	daily := model.PushRecord{AppID: 1, Title: "daily", Content: "c", TargetType: "all"}
+	if err := ApplySchedule(&daily, ScheduleSpec{Recurrence: "30 9 * * *", Timezone: "Asia/Shanghai"}, now); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&once)
+	db.Create(&daily)
+
+	// 上海 09:00 为 UTC 01:00
+	if n := dispatchAt(t, mustTime(t, "2026-10-17T00:59:00Z")); n != 0 {
+		t.Fatalf("dispatched %d before due", n)
+	}
+	if n := dispatchAt(t, mustTime(t, "2026-10-17T01:00:00Z")); n != 1 || <-jobs != once.ID {
+		t.Fatalf("dispatched %d at due time", n)
+	}
+	var claimed model.PushRecord
+	db.First(&claimed, once.ID)
+	if claimed.Status != StatusSending || claimed.NextRunAt != nil {
+		t.Errorf("one-off push after dispatch = %+v", claimed)
+	}
+
+	// 另一个实例持有旧数据时不会重复发送同一次周期推送
+	stale := daily
+	due := mustTime(t, "2026-10-17T01:30:00Z")
+	if n := dispatchAt(t, due); n != 1 {
+		t.Fatalf("dispatched %d recurring pushes", n)
+	}
+	if n, err := dispatchPlan(&stale, due); err != nil || n != 0 {
+		t.Fatalf("stale dispatch = %d, %v", n, err)
+	}
+	if n := dispatchAt(t, due); n != 0 {
+		t.Fatalf("dispatched %d pushes twice", n)
+	}
+
+	var child model.PushRecord
+	db.Where("parent_id = ?", daily.ID).First(&child)
+	if child.ID != <-jobs || child.Status != StatusSending || child.Title != "daily" {
+		t.Errorf("occurrence = %+v", child)
+	}
+	var advanced model.PushRecord
+	db.First(&advanced, daily.ID)
+	if advanced.Status != StatusScheduled || !advanced.ScheduledAt.Equal(mustTime(t, "2026-10-18T01:30:00Z")) ||
+		!advanced.NextRunAt.Equal(*advanced.ScheduledAt) {
+		t.Errorf("plan after dispatch = %+v", advanced)
+	}
+}
+
+func TestDispatchScheduled_LocalTime(t *testing.T) {
+	jobs := useScheduleDB(t)
+	now := mustTime(t, "2026-10-17T00:00:00Z")
+
+	for _, d := range []model.PushDevice{
+		{AppID: 1, Token: "sh", Timezone: "Asia/Shanghai", Status: 1},
+		{AppID: 1, Token: "utc", Timezone: "UTC", Status: 1},
+		{AppID: 1, Token: "ny", Timezone: "America/New_York", Status: 1},
+	} {
+		db.Create(&d)
+	}
+	plan := model.PushRecord{AppID: 1, Title: "morning", Content: "c", TargetType: "all"}
+	if err := ApplySchedule(&plan, ScheduleSpec{ScheduledAt: "2026-10-18 09:00:00", LocalTime: true}, now); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&plan)
+
+	// 每个时区到达本地 09:00 时各发送一次：上海 UTC 01:00，UTC 09:00，纽约（夏令时 UTC-4）13:00
+	waves := []struct {
+		at   string
+		zone string
+	}{
+		{"2026-10-18T01:00:00Z", "Asia/Shanghai"},
+		{"2026-10-18T09:00:00Z", "UTC"},
+		{"2026-10-18T13:00:00Z", "America/New_York"},
+	}
+	for _, wave := range waves {
+		if n := dispatchAt(t, mustTime(t, wave.at).Add(-time.Minute)); n != 0 {
+			t.Fatalf("dispatched %d before %s", n, wave.zone)
+		}
+		if n := dispatchAt(t, mustTime(t, wave.at)); n != 1 {
+			t.Fatalf("dispatched %d at %s", n, wave.zone)
+		}
+		var child model.PushRecord
+		db.First(&child, <-jobs)
+		query, _ := targetQuery(&child)
+		var count int64
+		query.Count(&count)
+		if child.Timezone != wave.zone || count != 1 {
+			t.Errorf("wave %s: timezone %s with %d devices", wave.zone, child.Timezone, count)
+		}
+	}
+
+	// 最晚的时区（UTC-12）到达后计划结束
+	dispatchAt(t, mustTime(t, "2026-10-18T21:00:00Z"))
+	var finished model.PushRecord
+	db.First(&finished, plan.ID)
+	if finished.Status != StatusSent || finished.NextRunAt != nil {
+		t.Errorf("plan after last timezone = %+v", finished)
+	}
+}
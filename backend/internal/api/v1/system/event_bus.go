@@ -0,0 +1,21 @@
+package system
+
+import (
+	"net/http"
+
+	"app-platform-backend/core/module"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventBusStats 查看事件总线各订阅者的投递统计
+func EventBusStats(c *gin.Context) {
+	stats := module.Bus().Stats()
+	if stats == nil {
+		stats = []module.SubscriberStats{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
@@ -0,0 +1,119 @@
+package system
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"app-platform-backend/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListJobs 获取所有定时任务
+func ListJobs(c *gin.Context) {
+	s := scheduler.Default()
+	if s == nil {
+		respondSchedulerUnavailable(c)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": s.Jobs(),
+	})
+}
+
+// GetJob 获取任务详情及最近的运行记录
+func GetJob(c *gin.Context) {
+	s := scheduler.Default()
+	if s == nil {
+		respondSchedulerUnavailable(c)
+		return
+	}
+
+	name := c.Param("name")
+	job, err := s.Job(name)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	runs, err := s.History(name, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取运行记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"job":  job,
+			"runs": runs,
+		},
+	})
+}
+
+// PauseJob 暂停任务的定时执行
+func PauseJob(c *gin.Context) {
+	jobAction(c, "任务已暂停", func(s *scheduler.Scheduler, name string) error { return s.Pause(name) })
+}
+
+// ResumeJob 恢复任务的定时执行
+func ResumeJob(c *gin.Context) {
+	jobAction(c, "任务已恢复", func(s *scheduler.Scheduler, name string) error { return s.Resume(name) })
+}
+
+// TriggerJob 立即执行一次任务
+func TriggerJob(c *gin.Context) {
+	jobAction(c, "任务已触发", func(s *scheduler.Scheduler, name string) error { return s.Trigger(name) })
+}
+
+// jobAction 执行任务操作并返回最新的任务信息
+func jobAction(c *gin.Context, message string, action func(s *scheduler.Scheduler, name string) error) {
+	s := scheduler.Default()
+	if s == nil {
+		respondSchedulerUnavailable(c)
+		return
+	}
+
+	name := c.Param("name")
+	if err := action(s, name); err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	job, _ := s.Job(name)
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data":    job,
+	})
+}
+
+// respondJobError 将调度器错误映射为HTTP响应
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "任务不存在"})
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "任务正在运行"})
+	case errors.Is(err, scheduler.ErrModuleDisabled):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "任务所属模块已停用"})
+	case errors.Is(err, scheduler.ErrNotLeader):
+		c.JSON(http.StatusConflict, gin.H{"code": 409, "message": "当前实例不是调度 leader，请在 leader 实例上触发"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+	}
+}
+
+// respondSchedulerUnavailable 调度器未初始化
+func respondSchedulerUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"code":    503,
+		"message": "任务调度器未初始化",
+	})
+}
@@ -0,0 +1,105 @@
+package system
+
+import (
+	"errors"
+	"net/http"
+
+	"app-platform-backend/core/module"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModuleStateRequest 启用/停用模块请求
+type ModuleStateRequest struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// ListModules 获取所有已注册模块及其全局启用状态
+func ListModules(c *gin.Context) {
+	modules := module.GetAllModules()
+	result := make([]gin.H, 0, len(modules))
+	for _, m := range modules {
+		meta := m.Meta()
+		functions := m.GetFunctions()
+		funcList := make([]gin.H, 0, len(functions))
+		for _, fn := range functions {
+			funcList = append(funcList, gin.H{
+				"code":        fn.Code,
+				"name":        fn.Name,
+				"type":        fn.Type,
+				"description": fn.Description,
+			})
+		}
+
+		state := module.GetState(meta.Code)
+		item := gin.H{
+			"code":         meta.Code,
+			"name":         meta.Name,
+			"description":  meta.Description,
+			"icon":         meta.Icon,
+			"dependencies": meta.Dependencies,
+			"functions":    funcList,
+			"enabled":      state.Enabled,
+			"reason":       state.Reason,
+			"changed_by":   state.ChangedBy,
+			"changed_at":   nil,
+		}
+		// 从未变更过状态的模块没有变更时间
+		if !state.ChangedAt.IsZero() {
+			item["changed_at"] = state.ChangedAt
+		}
+		result = append(result, item)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total":   len(modules),
+		"modules": result,
+	})
+}
+
+// EnableModule 全局启用模块
+func EnableModule(c *gin.Context) {
+	setModuleState(c, true, "模块已启用")
+}
+
+// DisableModule 全局停用模块，停用后其路由、定时任务和事件订阅立即失效
+func DisableModule(c *gin.Context) {
+	setModuleState(c, false, "模块已停用")
+}
+
+// setModuleState 修改模块全局状态
+func setModuleState(c *gin.Context, enabled bool, message string) {
+	var req ModuleStateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "参数错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	state, err := module.SetEnabled(c.Request.Context(), c.Param("code"), enabled, req.Reason, c.GetString("username"))
+	if err != nil {
+		var depErr *module.StateDependencyError
+		switch {
+		case errors.Is(err, module.ErrModuleNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"code": 404, "message": "模块不存在"})
+		case errors.As(err, &depErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    409,
+				"message": err.Error(),
+				"data":    gin.H{"blocking": depErr.Blocking},
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data":    state,
+	})
+}
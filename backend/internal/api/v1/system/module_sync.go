@@ -0,0 +1,55 @@
+package system
+
+import (
+	"log"
+	"net/http"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SyncModules 手动触发模块功能同步
+// 查询参数 dry_run=true 时只返回差异报告，不写入数据库
+func SyncModules(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	syncer := module.NewSyncer(database.GetDB())
+	result, err := syncer.Sync(module.SyncOptions{DryRun: dryRun})
+	if err != nil {
+		log.Printf("[System] Module sync failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "模块同步失败: " + err.Error(),
+		})
+		return
+	}
+
+	message := "模块同步完成"
+	if dryRun {
+		message = "模块同步预览（未写入数据库）"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": message,
+		"data":    result,
+	})
+}
+
+// LastModuleSync 返回本实例最近一次模块功能同步的结果（启动时或手动触发），预览不计入
+func LastModuleSync(c *gin.Context) {
+	result := module.LastSyncResult()
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "尚未同步模块功能",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data":    result,
+	})
+}
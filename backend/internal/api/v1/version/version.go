@@ -1,9 +1,13 @@
 package version
 
 import (
+	"app-platform-backend/core/module"
 	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/etag"
 	"app-platform-backend/internal/response"
 	"app-platform-backend/internal/validator"
+	"errors"
+	"log"
 	"strconv"
 	"time"
 
@@ -199,12 +203,61 @@ func Update(c *gin.Context) {
 		updates["is_force_update"] = 1
 	}
 
-	if err := db.Model(&model.Version{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+	// 加锁重新读取，携带 If-Match 时确认版本未被其他人修改
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := etag.ForUpdate(tx).First(&existingVersion, existingVersion.ID).Error; err != nil {
+			return err
+		}
+		if !etag.Match(c, existingVersion) {
+			return errVersionModified
+		}
+		if err := tx.Model(&existingVersion).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&existingVersion, existingVersion.ID).Error
+	})
+	if errors.Is(err, errVersionModified) {
+		etag.Set(c, existingVersion)
+		response.PreconditionFailed(c, "版本已被他人修改，请刷新后重试", existingVersion)
+		return
+	}
+	if err != nil {
 		response.DBError(c, err)
 		return
 	}
 
-	response.SuccessWithMessage(c, nil, "版本更新成功")
+	etag.Set(c, existingVersion)
+	response.SuccessWithMessage(c, existingVersion, "版本更新成功")
+}
+
+// errVersionModified 请求携带的 If-Match 与版本当前状态不一致
+var errVersionModified = errors.New("version has been modified")
+
+// Detail 版本详情，响应头 ETag 用于更新时的 If-Match
+func Detail(c *gin.Context) {
+	id, err := validator.ValidateID(c.Param("id"))
+	if err != nil {
+		response.ParamError(c, "无效的版本ID")
+		return
+	}
+	appID, err := strconv.ParseUint(c.Query("app_id"), 10, 32)
+	if err != nil {
+		response.ParamError(c, "无效的 app_id")
+		return
+	}
+
+	var version model.Version
+	if err := db.Where("id = ? AND app_id = ?", id, appID).First(&version).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.NotFound(c, "版本不存在或无权限操作")
+			return
+		}
+		response.DBError(c, err)
+		return
+	}
+
+	etag.Set(c, version)
+	response.Success(c, version)
 }
 
 // Publish 发布版本
@@ -255,6 +308,19 @@ func Publish(c *gin.Context) {
 		return
 	}
 
+	if err := module.Publish(c.Request.Context(), module.VersionPublishedEvent{
+		AppID:         existingVersion.AppID,
+		VersionID:     existingVersion.ID,
+		VersionName:   existingVersion.VersionName,
+		VersionCode:   existingVersion.VersionCode,
+		Description:   existingVersion.Description,
+		DownloadURL:   existingVersion.DownloadURL,
+		IsForceUpdate: existingVersion.IsForceUpdate == 1,
+		PublishedAt:   now,
+	}); err != nil {
+		log.Printf("[Version] Failed to publish version.published for version %d: %v", existingVersion.ID, err)
+	}
+
 	response.SuccessWithMessage(c, nil, "版本发布成功")
 }
 
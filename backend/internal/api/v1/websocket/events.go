@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+
+	"app-platform-backend/core/module"
+)
+
+// OnAlertTriggered 将触发的告警实时推送给APP的WebSocket客户端
+func OnAlertTriggered(ctx context.Context, e module.AlertTriggeredEvent) error {
+	BroadcastAlert(e.AppID, &AlertData{
+		ID:        e.AlertID,
+		Level:     "warning",
+		Title:     e.AlertName,
+		Message:   fmt.Sprintf("%s %s %v (当前值 %v)", e.MetricName, e.Condition, e.Threshold, e.Value),
+		Source:    "monitor",
+		Status:    "active",
+		CreatedAt: e.TriggeredAt.UnixMilli(),
+	})
+	return nil
+}
+
+// OnVersionPublished 将版本发布通知实时推送给APP的WebSocket客户端
+func OnVersionPublished(ctx context.Context, e module.VersionPublishedEvent) error {
+	BroadcastNotification(e.AppID, "版本更新", fmt.Sprintf("新版本 %s 已发布", e.VersionName))
+	return nil
+}
+
+// configReviewTitles 配置审批通知的标题
+var configReviewTitles = map[string]string{
+	"submit":  "配置变更待审批",
+	"comment": "配置变更有新评论",
+	"approve": "配置变更已通过",
+	"reject":  "配置变更被驳回",
+	"cancel":  "配置变更已撤回",
+	"apply":   "配置变更已生效",
+}
+
+// OnConfigReview 将模块配置审批的进展实时通知给管理后台
+func OnConfigReview(ctx context.Context, e module.ConfigReviewEvent) error {
+	title, ok := configReviewTitles[e.Action]
+	if !ok {
+		return nil
+	}
+	message := fmt.Sprintf("%s 的配置变更申请 #%d（%s）", e.SubmittedBy, e.RequestID, e.ModuleCode)
+	if e.Operator != "" && e.Operator != e.SubmittedBy {
+		message += "，操作人 " + e.Operator
+	}
+	if e.Comment != "" {
+		message += "：" + e.Comment
+	}
+	BroadcastNotification(e.AppID, title, message)
+	return nil
+}
@@ -1,7 +1,9 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -38,6 +40,9 @@ type Hub struct {
 	broadcast  chan *Message
 	register   chan *Client
 	unregister chan *Client
+	ping       chan chan struct{}
+	quit       chan struct{}
+	closeOnce  sync.Once
 	mu         sync.RWMutex
 }
 
@@ -86,6 +91,8 @@ func NewHub() *Hub {
 		broadcast:  make(chan *Message, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		ping:       make(chan chan struct{}),
+		quit:       make(chan struct{}),
 	}
 }
 
@@ -93,6 +100,12 @@ func NewHub() *Hub {
 func (h *Hub) Run() {
 	for {
 		select {
+		case <-h.quit:
+			return
+
+		case reply := <-h.ping:
+			close(reply)
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
@@ -148,6 +161,44 @@ func (h *Hub) Run() {
 	}
 }
 
+// Close 关闭Hub：停止消息分发并断开所有客户端连接
+// 可重复调用，只有第一次调用生效
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() {
+		close(h.quit)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for client := range h.clients {
+			client.Conn.WriteControl(ws.CloseMessage,
+				ws.FormatCloseMessage(ws.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			client.Conn.Close()
+		}
+		h.clients = make(map[*Client]bool)
+		h.appClients = make(map[uint]map[*Client]bool)
+		log.Printf("[WebSocket] Hub closed")
+	})
+}
+
+// Ping 检查Hub的分发循环是否仍在响应，用于健康检查
+func (h *Hub) Ping(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case h.ping <- reply:
+	case <-h.quit:
+		return errors.New("websocket hub is closed")
+	case <-ctx.Done():
+		return fmt.Errorf("websocket hub not responding: %w", ctx.Err())
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("websocket hub not responding: %w", ctx.Err())
+	}
+}
+
 // GetHub 获取全局Hub实例
 func GetHub() *Hub {
 	return hub
@@ -156,7 +207,10 @@ func GetHub() *Hub {
 // Broadcast 广播消息
 func (h *Hub) Broadcast(msg *Message) {
 	msg.Timestamp = time.Now().UnixMilli()
-	h.broadcast <- msg
+	select {
+	case h.broadcast <- msg:
+	case <-h.quit:
+	}
 }
 
 // BroadcastToApp 向指定APP广播消息
@@ -215,7 +269,12 @@ func HandleWebSocket(c *gin.Context) {
 		Hub:    hub,
 	}
 
-	hub.register <- client
+	select {
+	case hub.register <- client:
+	case <-hub.quit:
+		conn.Close()
+		return
+	}
 
 	// 启动读写协程
 	go client.writePump()
@@ -225,7 +284,10 @@ func HandleWebSocket(c *gin.Context) {
 // readPump 读取客户端消息
 func (c *Client) readPump() {
 	defer func() {
-		c.Hub.unregister <- c
+		select {
+		case c.Hub.unregister <- c:
+		case <-c.Hub.quit:
+		}
 		c.Conn.Close()
 	}()
 
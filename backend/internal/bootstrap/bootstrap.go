@@ -66,7 +66,11 @@ func (b *Bootstrap) RegisterModuleRoutes(authMiddleware gin.HandlerFunc) {
 	for _, m := range modules {
 		meta := m.Meta()
 		log.Printf("[Bootstrap] Registering routes for module: %s", meta.Code)
-		m.RegisterRoutes(authGroup)
+		group := authGroup.Group("")
+		if !meta.Global {
+			group.Use(middleware.ModuleGateMiddleware(meta.Code))
+		}
+		m.RegisterRoutes(group)
 	}
 
 	log.Printf("[Bootstrap] %d module routes registered", len(modules))
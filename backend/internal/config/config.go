@@ -11,11 +11,18 @@ type Config struct {
 	Database DatabaseConfig `yaml:"database"`
 	JWT      JWTConfig      `yaml:"jwt"`
 	CORS     CORSConfig     `yaml:"cors"`
+	// Encryption 敏感字段加密的主密钥，生产环境建议通过环境变量配置
+	Encryption EncryptionConfig `yaml:"encryption"`
+	// RemoteModules 进程外的远程模块，通过反向代理挂载到 /api/v1/<code>
+	RemoteModules []RemoteModuleConfig `yaml:"remote_modules"`
 }
 
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Mode string `yaml:"mode"`
+	Port            int    `yaml:"port"`
+	Mode            string `yaml:"mode"`
+	ReadTimeout     int    `yaml:"read_timeout"`     // 秒
+	WriteTimeout    int    `yaml:"write_timeout"`    // 秒
+	ShutdownTimeout int    `yaml:"shutdown_timeout"` // 秒，优雅关闭等待请求处理完成的最长时间
 }
 
 type DatabaseConfig struct {
@@ -24,6 +31,8 @@ type DatabaseConfig struct {
 	User     string `yaml:"username"`
 	Password string `yaml:"password"`
 	DBName   string `yaml:"database"`
+	// SkipMigrate 为 true 时启动时不自动执行迁移，需通过 -migrate up 手动执行
+	SkipMigrate bool `yaml:"skip_migrate"`
 }
 
 type JWTConfig struct {
@@ -35,9 +44,50 @@ type CORSConfig struct {
 	AllowOrigins     []string `yaml:"allow_origins"`
 	AllowMethods     []string `yaml:"allow_methods"`
 	AllowHeaders     []string `yaml:"allow_headers"`
+	ExposeHeaders    []string `yaml:"expose_headers"`
 	AllowCredentials bool     `yaml:"allow_credentials"`
 }
 
+// EncryptionConfig 敏感字段加密配置
+type EncryptionConfig struct {
+	ActiveKey string          `yaml:"active_key"` // 加密新值使用的主密钥ID，默认为第一个
+	Keys      []EncryptionKey `yaml:"keys"`
+}
+
+// EncryptionKey 主密钥，轮换时新增主密钥并设为 active_key，旧主密钥在重新加密完成前需要保留
+type EncryptionKey struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"` // base64 编码的 32 字节密钥，例如 openssl rand -base64 32
+}
+
+// RemoteModuleConfig 远程模块配置
+type RemoteModuleConfig struct {
+	Code         string   `yaml:"code"`
+	Name         string   `yaml:"name"`
+	Description  string   `yaml:"description"`
+	Icon         string   `yaml:"icon"`
+	SortOrder    int      `yaml:"sort_order"`
+	Global       bool     `yaml:"global"`
+	Dependencies []string `yaml:"dependencies"`
+	Upstream     string   `yaml:"upstream"`    // 上游服务基础地址，例如 http://coupon-svc:8080/api
+	Secret       string   `yaml:"secret"`      // 请求签名密钥，为空时不签名
+	Timeout      int      `yaml:"timeout"`     // 秒，等待上游响应头的最长时间
+	HealthPath   string   `yaml:"health_path"` // 健康检查路径，默认 /health
+
+	Functions []RemoteFunctionConfig `yaml:"functions"`
+}
+
+// RemoteFunctionConfig 远程模块声明的功能
+type RemoteFunctionConfig struct {
+	Code         string                 `yaml:"code"`
+	Name         string                 `yaml:"name"`
+	Description  string                 `yaml:"description"`
+	Type         string                 `yaml:"type"` // active / passive，默认 active
+	ConfigSchema map[string]interface{} `yaml:"config_schema"`
+	Dependencies []string               `yaml:"dependencies"`
+	SortOrder    int                    `yaml:"sort_order"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
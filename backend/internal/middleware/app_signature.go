@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/pkg/secret"
+	"app-platform-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// APP签名请求头
+const (
+	HeaderAppID        = "X-App-ID"
+	HeaderAppTimestamp = "X-Timestamp"
+	HeaderAppSignature = "X-Signature"
+	HeaderAppNonce     = "X-Nonce"
+)
+
+// AppSignatureMaxSkew 请求时间戳与服务器时间允许的最大偏差，超出视为重放
+const AppSignatureMaxSkew = 5 * time.Minute
+
+// AppSignatureMaxBody 签名请求体的最大字节数，签名校验前需要把请求体完整读入内存
+const AppSignatureMaxBody = 1 << 20
+
+// nonce 长度限制，客户端应为每个请求生成随机值
+const (
+	appNonceMinLen = 8
+	appNonceMaxLen = 64
+)
+
+// sdkAppKey 上下文中保存已认证APP的键
+const sdkAppKey = "sdk_app"
+
+// SignAppRequest 计算 SDK 请求签名，十六进制编码：
+// HMAC-SHA256(app_secret, method \n path \n canonical_query \n timestamp \n nonce \n hex(SHA256(body)))
+// path 为不含查询参数的请求路径，例如 /api/v1/sdk/configs；query 为原始查询字符串，按 CanonicalQuery 规范化；
+// timestamp 为秒级 Unix 时间戳；body 为请求体原文，没有请求体时为空
+func SignAppRequest(secret, method, path, query, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		method, path, CanonicalQuery(query), timestamp, nonce, hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CanonicalQuery 规范化查询字符串：按参数名排序，同名参数按值排序，再按 URL 编码拼接
+// 客户端与服务端对参数顺序、编码方式的差异不影响签名
+func CanonicalQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// 无法解析的查询字符串原样参与签名
+		return rawQuery
+	}
+	for _, v := range values {
+		sort.Strings(v)
+	}
+	return values.Encode()
+}
+
+// AppSignatureMiddleware SDK 签名认证中间件
+// 客户端通过 X-App-ID（apps.app_id）、X-Timestamp、X-Nonce、X-Signature 四个请求头证明持有 app_secret，
+// 签名覆盖查询参数和请求体；同一APP的 nonce 在时间戳有效期内只能使用一次，各实例共享记录
+// 只接受启用状态的APP，认证通过后可用 SDKApp 获取APP
+func AppSignatureMiddleware(db *gorm.DB) gin.HandlerFunc {
+	nonces := newNonceStore(db, 2*AppSignatureMaxSkew)
+	return func(c *gin.Context) {
+		appKey := c.GetHeader(HeaderAppID)
+		timestamp := c.GetHeader(HeaderAppTimestamp)
+		nonce := c.GetHeader(HeaderAppNonce)
+		signature := c.GetHeader(HeaderAppSignature)
+		if appKey == "" || timestamp == "" || nonce == "" || signature == "" {
+			response.Unauthorized(c, "缺少签名请求头")
+			c.Abort()
+			return
+		}
+		if len(nonce) < appNonceMinLen || len(nonce) > appNonceMaxLen {
+			response.Unauthorized(c, "无效的 nonce")
+			c.Abort()
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			response.Unauthorized(c, "无效的时间戳")
+			c.Abort()
+			return
+		}
+		skew := time.Since(time.Unix(ts, 0))
+		if skew > AppSignatureMaxSkew || skew < -AppSignatureMaxSkew {
+			response.Unauthorized(c, "请求已过期，请校准设备时间")
+			c.Abort()
+			return
+		}
+
+		var app model.App
+		if err := db.Where("app_id = ? AND status = 1", appKey).First(&app).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				response.Unauthorized(c, "签名校验失败")
+			} else {
+				response.DBError(c, err)
+			}
+			c.Abort()
+			return
+		}
+
+		appSecret, err := secret.Decrypt(app.AppSecret)
+		if err != nil {
+			log.Printf("[AppSignature] Failed to decrypt secret of app %s: %v", app.AppID, err)
+			response.ServerError(c, "APP密钥不可用")
+			c.Abort()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, AppSignatureMaxBody)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					response.PayloadTooLarge(c, "请求体过大")
+				} else {
+					response.ParamError(c, "读取请求体失败")
+				}
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		expected := SignAppRequest(appSecret, c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, timestamp, nonce, body)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			response.Unauthorized(c, "签名校验失败")
+			c.Abort()
+			return
+		}
+		// 签名通过后才记录 nonce，伪造的请求不能占用合法客户端的 nonce
+		fresh, err := nonces.add(app.AppID, nonce, time.Now())
+		if err != nil {
+			response.DBError(c, err)
+			c.Abort()
+			return
+		}
+		if !fresh {
+			response.Unauthorized(c, "请求重复")
+			c.Abort()
+			return
+		}
+
+		c.Set(sdkAppKey, &app)
+		c.Next()
+	}
+}
+
+// SDKApp 返回通过签名认证的APP，未经过 AppSignatureMiddleware 时返回 nil
+func SDKApp(c *gin.Context) *model.App {
+	if v, ok := c.Get(sdkAppKey); ok {
+		if app, ok := v.(*model.App); ok {
+			return app
+		}
+	}
+	return nil
+}
+
+// nonceStore 记录有效期内已使用的 nonce，用于拒绝重放的请求
+// 保存在数据库中由所有实例共享，(app_id, nonce) 主键保证同一 nonce 只能被一个请求使用；
+// 有效期覆盖时间戳允许的偏差范围，过期的记录在写入时顺带清理
+type nonceStore struct {
+	db        *gorm.DB
+	ttl       time.Duration
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func newNonceStore(db *gorm.DB, ttl time.Duration) *nonceStore {
+	return &nonceStore{db: db, ttl: ttl}
+}
+
+// add 记录 nonce，有效期内已使用过时返回 false
+func (n *nonceStore) add(appID, nonce string, now time.Time) (bool, error) {
+	n.sweep(now)
+
+	expiresAt := now.Add(n.ttl)
+	err := n.db.Create(&model.AppNonce{AppID: appID, Nonce: nonce, ExpiresAt: expiresAt}).Error
+	if err == nil {
+		return true, nil
+	}
+	if !database.IsDuplicateKey(err) {
+		return false, err
+	}
+	// 已过期但尚未清理的记录可以重新使用，条件更新保证并发时只有一个请求成功
+	result := n.db.Model(&model.AppNonce{}).
+		Where("app_id = ? AND nonce = ? AND expires_at <= ?", appID, nonce, now).
+		Update("expires_at", expiresAt)
+	return result.RowsAffected > 0, result.Error
+}
+
+// sweep 每半个有效期清理一次过期的记录
+func (n *nonceStore) sweep(now time.Time) {
+	n.mu.Lock()
+	if now.Sub(n.lastSweep) <= n.ttl/2 {
+		n.mu.Unlock()
+		return
+	}
+	n.lastSweep = now
+	n.mu.Unlock()
+
+	if err := n.db.Where("expires_at <= ?", now).Delete(&model.AppNonce{}).Error; err != nil {
+		log.Printf("[AppSignature] Failed to sweep expired nonces: %v", err)
+	}
+}
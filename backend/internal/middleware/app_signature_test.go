@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"app-platform-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"b=2&a=1":              "a=1&b=2",
+		"tag=y&tag=x":          "tag=x&tag=y",
+		"name=a b&env=prod%2F": "env=prod%2F&name=a+b",
+	}
+	for raw, want := range tests {
+		if got := CanonicalQuery(raw); got != want {
+			t.Errorf("CanonicalQuery(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestAppSignatureMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "sdk.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&model.App{}, &model.AppNonce{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	database.Create(&model.App{AppID: "app_demo", AppSecret: "s3cret", Status: 1})
+
+	newRouter := func() *gin.Engine {
+		router := gin.New()
+		router.Use(AppSignatureMiddleware(database))
+		router.POST("/api/v1/sdk/push/devices", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+	// replica 模拟另一个实例，与 router 共享数据库
+	router, replica := newRouter(), newRouter()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body := `{"token":"t1"}`
+	signature := SignAppRequest("s3cret", http.MethodPost, "/api/v1/sdk/push/devices", "b=2&a=1", timestamp, "nonce-0001", []byte(body))
+	sendTo := func(router *gin.Engine, query, nonce, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/sdk/push/devices?"+query, strings.NewReader(body))
+		req.Header.Set(HeaderAppID, "app_demo")
+		req.Header.Set(HeaderAppTimestamp, timestamp)
+		req.Header.Set(HeaderAppNonce, nonce)
+		req.Header.Set(HeaderAppSignature, signature)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	send := func(query, nonce, body string) int { return sendTo(router, query, nonce, body) }
+
+	// 篡改查询参数、请求体或 nonce 都会使签名失效
+	if code := send("a=1&b=3", "nonce-0001", body); code != http.StatusUnauthorized {
+		t.Errorf("tampered query: status = %d, want 401", code)
+	}
+	if code := send("a=1&b=2", "nonce-0001", `{"token":"t2"}`); code != http.StatusUnauthorized {
+		t.Errorf("tampered body: status = %d, want 401", code)
+	}
+	if code := send("a=1&b=2", "nonce-0002", body); code != http.StatusUnauthorized {
+		t.Errorf("tampered nonce: status = %d, want 401", code)
+	}
+	// 参数顺序不影响签名；同一请求重放时被拒绝
+	if code := send("a=1&b=2", "nonce-0001", body); code != http.StatusOK {
+		t.Errorf("signed request: status = %d, want 200", code)
+	}
+	if code := send("a=1&b=2", "nonce-0001", body); code != http.StatusUnauthorized {
+		t.Errorf("replayed request: status = %d, want 401", code)
+	}
+	if code := sendTo(replica, "a=1&b=2", "nonce-0001", body); code != http.StatusUnauthorized {
+		t.Errorf("replayed on another instance: status = %d, want 401", code)
+	}
+	// 超过上限的请求体在签名校验前被拒绝
+	if code := send("a=1&b=2", "nonce-0003", strings.Repeat("x", AppSignatureMaxBody+1)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want 413", code)
+	}
+}
+
+func TestNonceStore_Expiry(t *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "nonce.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&model.AppNonce{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	store := newNonceStore(database, time.Minute)
+	now := time.Now()
+
+	if ok, err := store.add("app_demo", "nonce-0001", now); !ok || err != nil {
+		t.Fatalf("first add() = %v, %v", ok, err)
+	}
+	if ok, _ := store.add("app_demo", "nonce-0001", now.Add(30*time.Second)); ok {
+		t.Error("nonce reused within ttl")
+	}
+	if ok, _ := store.add("app_other", "nonce-0001", now); !ok {
+		t.Error("nonce of another app rejected")
+	}
+	// 过期后可以重新使用，清理后记录被删除
+	if ok, err := store.add("app_demo", "nonce-0001", now.Add(2*time.Minute)); !ok || err != nil {
+		t.Errorf("add() after expiry = %v, %v", ok, err)
+	}
+	var count int64
+	database.Model(&model.AppNonce{}).Count(&count)
+	if count != 1 {
+		t.Errorf("nonces after sweep = %d, want 1", count)
+	}
+}
@@ -103,6 +103,10 @@ func CORSMiddleware(cfg *config.CORSConfig) gin.HandlerFunc {
 		
 		c.Header("Access-Control-Allow-Methods", strings.Join(cfg.AllowMethods, ", "))
 		c.Header("Access-Control-Allow-Headers", strings.Join(cfg.AllowHeaders, ", "))
+		// 跨域请求默认读不到 ETag 等响应头，需要显式暴露
+		if len(cfg.ExposeHeaders) > 0 {
+			c.Header("Access-Control-Expose-Headers", strings.Join(cfg.ExposeHeaders, ", "))
+		}
 		
 		if cfg.AllowCredentials {
 			c.Header("Access-Control-Allow-Credentials", "true")
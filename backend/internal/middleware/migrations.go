@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"time"
+
+	"app-platform-backend/core/module"
+
+	"gorm.io/gorm"
+)
+
+// SDK 签名认证使用的表结构迁移
+// 下面的结构体是建表时的快照，之后的表结构变更写成新的迁移
+func init() {
+	module.RegisterMigrations("app_signature", module.Migration{
+		Version:     202610170001,
+		Description: "create app_nonces",
+		Up: func(tx *gorm.DB) error {
+			return module.CreateTableIfNotExists(tx, &appNonceV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&appNonceV1{})
+		},
+	})
+}
+
+type appNonceV1 struct {
+	AppID     string    `gorm:"primaryKey;size:50"`
+	Nonce     string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (appNonceV1) TableName() string {
+	return "app_nonces"
+}
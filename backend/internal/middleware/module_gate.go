@@ -0,0 +1,321 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DefaultModuleGateTTL 模块启用状态缓存的默认有效期
+// 本实例内启用/禁用模块会立即失效缓存，TTL 用于兜底其他实例上的变更
+const DefaultModuleGateTTL = 60 * time.Second
+
+// moduleGateEntry 缓存的拦截决策
+type moduleGateEntry struct {
+	appID     uint // APP数据库ID，0 表示APP不存在，此时不缓存
+	enabled   bool
+	expiresAt time.Time
+}
+
+// ModuleGate 按APP校验模块启用状态的拦截器
+// 只缓存存在的APP，过期的记录在写入时按 TTL 周期清理，缓存大小不超过有效期内访问过的 APP×模块 数
+type ModuleGate struct {
+	db        *gorm.DB
+	ttl       time.Duration
+	mu        sync.RWMutex
+	entries   map[string]moduleGateEntry // key: APP数据库ID + ":" + 模块Code
+	lastSweep time.Time
+}
+
+var moduleGate *ModuleGate
+
+// InitModuleGate 初始化模块拦截器
+func InitModuleGate(db *gorm.DB, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultModuleGateTTL
+	}
+	moduleGate = &ModuleGate{
+		db:      db,
+		ttl:     ttl,
+		entries: make(map[string]moduleGateEntry),
+	}
+}
+
+// ModuleGateMiddleware 模块启用状态拦截中间件
+// 解析请求所属的APP，检查该APP是否启用了 moduleCode 对应的模块，未启用时拒绝请求
+// APP的来源依次为：SDK 签名认证的APP、模块通过 RouteScoper 声明的记录表、请求中的 app_id
+// 无法确定APP的请求一律拒绝，只有声明为 AppFree 或 Optional 的路由可以不带 app_id
+func ModuleGateMiddleware(moduleCode string) gin.HandlerFunc {
+	var scopes map[string]module.RouteScope
+	if m, ok := module.Get(moduleCode); ok {
+		if scoper, ok := m.(module.RouteScoper); ok {
+			scopes = scoper.RouteScopes()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if moduleGate == nil {
+			c.Next()
+			return
+		}
+
+		scope := scopes[c.Request.Method+" "+strings.TrimPrefix(c.FullPath(), "/api/v1")]
+		if scope.AppFree {
+			c.Next()
+			return
+		}
+
+		appID, err := moduleGate.resolveApp(c, scope)
+		if err != nil {
+			var gateErr *moduleGateError
+			switch {
+			case errors.As(err, &gateErr) && gateErr.notFound:
+				response.NotFound(c, gateErr.message)
+			case errors.As(err, &gateErr):
+				response.ParamError(c, gateErr.message)
+			default:
+				response.DBError(c, err)
+			}
+			c.Abort()
+			return
+		}
+		if appID == 0 {
+			if scope.Optional {
+				c.Next()
+				return
+			}
+			response.ParamError(c, "app_id 不能为空")
+			c.Abort()
+			return
+		}
+
+		entry, err := moduleGate.lookup(appID, moduleCode)
+		if err != nil {
+			response.DBError(c, err)
+			c.Abort()
+			return
+		}
+
+		if entry.appID == 0 {
+			response.NotFound(c, "APP不存在")
+			c.Abort()
+			return
+		}
+
+		if !entry.enabled {
+			response.ErrorWithData(c, response.CodeModuleDisabled,
+				fmt.Sprintf("模块 %s 未对该APP启用", moduleCode),
+				gin.H{"app_id": entry.appID, "module_code": moduleCode})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ModuleStateMiddleware 模块全局启用状态拦截中间件
+// 模块被全局停用后，其所有路由返回 503，与APP是否启用该模块无关
+func ModuleStateMiddleware(moduleCode string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if module.IsEnabled(moduleCode) {
+			c.Next()
+			return
+		}
+		response.ErrorWithData(c, response.CodeModuleOffline,
+			fmt.Sprintf("模块 %s 已停用", moduleCode),
+			gin.H{"module_code": moduleCode})
+		c.Abort()
+	}
+}
+
+// InvalidateModuleGate 使指定APP某个模块的缓存失效
+// 在启用、禁用、更新模块状态后调用
+func InvalidateModuleGate(appID uint, moduleCode string) {
+	if moduleGate == nil {
+		return
+	}
+	moduleGate.invalidate(func(key string, entry moduleGateEntry) bool {
+		return entry.appID == appID && strings.HasSuffix(key, ":"+moduleCode)
+	})
+}
+
+// InvalidateModuleGateApp 使指定APP所有模块的缓存失效
+func InvalidateModuleGateApp(appID uint) {
+	if moduleGate == nil {
+		return
+	}
+	moduleGate.invalidate(func(key string, entry moduleGateEntry) bool {
+		return entry.appID == appID
+	})
+}
+
+// lookup 查询缓存，未命中或过期时从数据库加载
+func (g *ModuleGate) lookup(appID uint, moduleCode string) (moduleGateEntry, error) {
+	key := fmt.Sprintf("%d:%s", appID, moduleCode)
+	now := time.Now()
+
+	g.mu.RLock()
+	entry, ok := g.entries[key]
+	g.mu.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry, nil
+	}
+
+	entry = moduleGateEntry{expiresAt: now.Add(g.ttl)}
+
+	var app model.App
+	err := g.db.Select("id").First(&app, appID).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return entry, err
+	}
+	if err != nil {
+		// 不存在的APP不缓存，避免任意 app_id 撑大缓存
+		return entry, nil
+	}
+	entry.appID = app.ID
+
+	var count int64
+	if err := g.db.Model(&model.AppModule{}).
+		Where("app_id = ? AND module_code = ? AND status = 1", app.ID, moduleCode).
+		Count(&count).Error; err != nil {
+		return entry, err
+	}
+	entry.enabled = count > 0
+
+	g.mu.Lock()
+	g.entries[key] = entry
+	if now.Sub(g.lastSweep) > g.ttl {
+		for k, e := range g.entries {
+			if !now.Before(e.expiresAt) {
+				delete(g.entries, k)
+			}
+		}
+		g.lastSweep = now
+	}
+	g.mu.Unlock()
+	return entry, nil
+}
+
+// moduleGateError 无法确定请求所属APP时返回给客户端的错误
+type moduleGateError struct {
+	notFound bool
+	message  string
+}
+
+func (e *moduleGateError) Error() string { return e.message }
+
+// resolveApp 返回请求所属APP的数据库ID，请求中没有APP时返回 0
+func (g *ModuleGate) resolveApp(c *gin.Context, scope module.RouteScope) (uint, error) {
+	if app := SDKApp(c); app != nil {
+		return app.ID, nil
+	}
+
+	if scope.Table != "" {
+		param := scope.Param
+		if param == "" {
+			param = "id"
+		}
+		id, err := strconv.ParseUint(c.Param(param), 10, 64)
+		if err != nil {
+			return 0, &moduleGateError{message: "无效的ID"}
+		}
+		var appIDs []uint
+		if err := g.db.Table(scope.Table).Where("id = ?", id).Limit(1).Pluck("app_id", &appIDs).Error; err != nil {
+			return 0, err
+		}
+		if len(appIDs) == 0 {
+			return 0, &moduleGateError{notFound: true, message: "记录不存在"}
+		}
+		return appIDs[0], nil
+	}
+
+	raw, err := requestAppID(c)
+	if err != nil || raw == "" {
+		return 0, err
+	}
+	// 与处理器一致，app_id 只接受APP的数据库ID
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil || id == 0 {
+		return 0, &moduleGateError{message: "无效的 app_id"}
+	}
+	return uint(id), nil
+}
+
+// invalidate 删除满足条件的缓存项
+func (g *ModuleGate) invalidate(match func(key string, entry moduleGateEntry) bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, entry := range g.entries {
+		if match(key, entry) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+// requestAppID 从请求中解析 app_id
+// 来源为路径参数、查询参数、JSON请求体和表单，与处理器读取 app_id 的位置一致；
+// 多个来源的值不一致时返回错误，避免拦截器校验的APP与处理器使用的APP不同
+func requestAppID(c *gin.Context) (string, error) {
+	var found string
+	for _, v := range []string{c.Param("app_id"), c.Query("app_id"), bodyAppID(c)} {
+		if v == "" {
+			continue
+		}
+		if found != "" && v != found {
+			return "", &moduleGateError{message: "app_id 不一致"}
+		}
+		found = v
+	}
+	return found, nil
+}
+
+// bodyAppID 读取JSON请求体或表单中的 app_id，读取后还原请求体
+func bodyAppID(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	switch c.ContentType() {
+	case "multipart/form-data", "application/x-www-form-urlencoded":
+		// 表单解析结果缓存在请求中，处理器仍可读取
+		return c.PostForm("app_id")
+	case "application/json":
+	default:
+		return ""
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	if err != nil || len(bodyBytes) == 0 {
+		return ""
+	}
+
+	var body struct {
+		AppID json.RawMessage `json:"app_id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil || len(body.AppID) == 0 {
+		return ""
+	}
+	// app_id 可能是数字，也可能是字符串
+	var s string
+	if err := json.Unmarshal(body.AppID, &s); err == nil {
+		return s
+	}
+	if string(body.AppID) == "null" {
+		return ""
+	}
+	return string(body.AppID)
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRequestAppID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		target  string
+		body    string
+		form    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "query", target: "/push?app_id=12", want: "12"},
+		{name: "json number", target: "/push", body: `{"app_id": 7, "title": "t"}`, want: "7"},
+		{name: "json string", target: "/push", body: `{"app_id": "app_xyz"}`, want: "app_xyz"},
+		{name: "json null", target: "/push", body: `{"app_id": null}`, want: ""},
+		{name: "form", target: "/files", body: "app_id=5&name=a", form: true, want: "5"},
+		{name: "query and body agree", target: "/push?app_id=7", body: `{"app_id": 7}`, want: "7"},
+		{name: "query and body conflict", target: "/push?app_id=1", body: `{"app_id": 2}`, wantErr: true},
+		{name: "none", target: "/push/3", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			method := http.MethodGet
+			var body io.Reader
+			if tt.body != "" {
+				method = http.MethodPost
+				body = strings.NewReader(tt.body)
+			}
+			c.Request = httptest.NewRequest(method, tt.target, body)
+			switch {
+			case tt.form:
+				c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			case tt.body != "":
+				c.Request.Header.Set("Content-Type", "application/json")
+			}
+			// 旧版本从该请求头读取APP，管理端请求不再接受
+			c.Request.Header.Set(HeaderAppID, "99")
+
+			got, err := requestAppID(c)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("requestAppID() = %q, %v, want %q", got, err, tt.want)
+			}
+
+			// 请求体读取后必须还原，供后续处理器使用
+			if tt.body != "" && !tt.form {
+				rest, _ := io.ReadAll(c.Request.Body)
+				if string(rest) != tt.body {
+					t.Errorf("body not restored: %q", rest)
+				}
+			}
+		})
+	}
+}
+
+// scopedModule 为测试路由声明APP来源的模块
+type scopedModule struct {
+	*module.BaseModule
+}
+
+func (m *scopedModule) RouteScopes() map[string]module.RouteScope {
+	return map[string]module.RouteScope{
+		"GET /push/templates": {AppFree: true},
+		"GET /push/:id":       {Table: "push_records"},
+		"GET /push/alerts":    {Optional: true},
+	}
+}
+
+func TestModuleGateMiddleware_ResolvesApp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gate.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&model.App{}, &model.AppModule{}, &model.PushRecord{}); err != nil {
+		t.Fatalf("auto migrate: %v", err)
+	}
+	enabled := model.App{AppID: "app_on", Name: "on"}
+	disabled := model.App{AppID: "app_off", Name: "off"}
+	database.Create(&enabled)
+	database.Create(&disabled)
+	database.Create(&model.AppModule{AppID: enabled.ID, ModuleCode: "push_service", Status: 1})
+	onRecord := model.PushRecord{AppID: enabled.ID, Title: "t", Content: "c"}
+	offRecord := model.PushRecord{AppID: disabled.ID, Title: "t", Content: "c"}
+	database.Create(&onRecord)
+	database.Create(&offRecord)
+
+	module.Clear()
+	defer module.Clear()
+	module.Register(&scopedModule{module.NewBaseModule(module.Meta{Code: "push_service"}, nil)})
+	InitModuleGate(database, time.Minute)
+	defer func() { moduleGate = nil }()
+
+	router := gin.New()
+	g := router.Group("/api/v1")
+	g.Use(ModuleGateMiddleware("push_service"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	g.GET("/push", ok)
+	g.POST("/push", ok)
+	g.GET("/push/templates", ok)
+	g.GET("/push/alerts", ok)
+	g.GET("/push/:id", ok)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"enabled app", http.MethodGet, fmt.Sprintf("/api/v1/push?app_id=%d", enabled.ID), "", http.StatusOK},
+		{"disabled app", http.MethodGet, fmt.Sprintf("/api/v1/push?app_id=%d", disabled.ID), "", http.StatusForbidden},
+		{"missing app_id", http.MethodGet, "/api/v1/push", "", http.StatusBadRequest},
+		// app_id 只接受数据库ID，不再按 apps.app_id 字符串匹配
+		{"app key", http.MethodGet, "/api/v1/push?app_id=app_on", "", http.StatusBadRequest},
+		{"unknown app", http.MethodGet, "/api/v1/push?app_id=999", "", http.StatusNotFound},
+		{"body", http.MethodPost, "/api/v1/push", fmt.Sprintf(`{"app_id": %d}`, disabled.ID), http.StatusForbidden},
+		{"body conflicts query", http.MethodPost, fmt.Sprintf("/api/v1/push?app_id=%d", enabled.ID), fmt.Sprintf(`{"app_id": %d}`, disabled.ID), http.StatusBadRequest},
+		{"app free", http.MethodGet, "/api/v1/push/templates", "", http.StatusOK},
+		{"optional", http.MethodGet, "/api/v1/push/alerts", "", http.StatusOK},
+		{"optional disabled", http.MethodGet, fmt.Sprintf("/api/v1/push/alerts?app_id=%d", disabled.ID), "", http.StatusForbidden},
+		{"record of enabled app", http.MethodGet, fmt.Sprintf("/api/v1/push/%d", onRecord.ID), "", http.StatusOK},
+		// 记录所属APP决定拦截结果，查询参数中的 app_id 不能绕过
+		{"record of disabled app", http.MethodGet, fmt.Sprintf("/api/v1/push/%d?app_id=%d", offRecord.ID, enabled.ID), "", http.StatusForbidden},
+		{"missing record", http.MethodGet, "/api/v1/push/999", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.target, body)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	// 不存在的APP不进入缓存
+	if _, cached := moduleGate.entries["999:push_service"]; cached {
+		t.Error("unknown app should not be cached")
+	}
+
+	// 过期的记录在下一次写入缓存时清理
+	for key, entry := range moduleGate.entries {
+		entry.expiresAt = time.Now().Add(-time.Second)
+		moduleGate.entries[key] = entry
+	}
+	moduleGate.lastSweep = time.Now().Add(-2 * time.Minute)
+	if _, err := moduleGate.lookup(enabled.ID, "push_service"); err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(moduleGate.entries) != 1 {
+		t.Errorf("cached entries after sweep = %d, want 1", len(moduleGate.entries))
+	}
+}
+
+func TestModuleGateMiddleware_PassWithoutGate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	moduleGate = nil
+
+	router := gin.New()
+	router.GET("/push", ModuleGateMiddleware("push_service"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/push?app_id=1", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 when gate is not initialized", w.Code)
+	}
+}
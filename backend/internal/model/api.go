@@ -54,7 +54,7 @@ type AppAPIKey struct {
 	AppID       uint           `gorm:"not null;index" json:"app_id"`
 	Name        string         `gorm:"size:100;not null" json:"name"`
 	APIKey      string         `gorm:"size:64;not null;uniqueIndex" json:"api_key"`
-	APISecret   string         `gorm:"size:128;not null" json:"-"` // 不返回给前端
+	APISecret   string         `gorm:"size:255;not null" json:"-"` // 加密保存，不返回给前端
 	Status      int8           `gorm:"default:1" json:"status"`
 	Permissions string         `gorm:"type:json" json:"permissions"`
 	IPWhitelist string         `gorm:"type:text" json:"ip_whitelist"`
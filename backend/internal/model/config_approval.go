@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+)
+
+// 配置变更申请状态
+const (
+	ConfigChangePending   = "pending"
+	ConfigChangeApproved  = "approved"
+	ConfigChangeRejected  = "rejected"
+	ConfigChangeApplied   = "applied"
+	ConfigChangeCancelled = "cancelled"
+)
+
+// ConfigApprovalPolicy APP的配置审批设置，没有记录的APP不需要审批
+type ConfigApprovalPolicy struct {
+	AppID           uint      `gorm:"primaryKey;autoIncrement:false" json:"app_id"`
+	RequireApproval bool      `gorm:"not null" json:"require_approval"`
+	UpdatedBy       string    `gorm:"size:50" json:"updated_by"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (ConfigApprovalPolicy) TableName() string {
+	return "config_approval_policies"
+}
+
+// ConfigChangeRequest 模块配置变更申请
+// 提交时记录所在环境、命名空间当时的配置作为基线，应用时若配置已被其他变更修改则拒绝应用
+type ConfigChangeRequest struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	AppID         uint       `gorm:"not null;index:idx_config_change_app_status" json:"app_id"`
+	ModuleCode    string     `gorm:"size:50;not null" json:"module_code"`
+	Environment   string     `gorm:"size:50;default:default" json:"environment"`
+	Namespace     string     `gorm:"size:50;default:application" json:"namespace"`
+	Config        string     `gorm:"type:json" json:"config"`
+	BaseConfig    string     `gorm:"type:json" json:"base_config"`
+	Status        string     `gorm:"size:20;not null;index:idx_config_change_app_status" json:"status"`
+	Remark        string     `gorm:"size:255" json:"remark"`
+	SubmittedBy   string     `gorm:"size:50" json:"submitted_by"`
+	SubmittedByID uint       `json:"submitted_by_id"`
+	ReviewedBy    string     `gorm:"size:50" json:"reviewed_by"`
+	ReviewedByID  uint       `json:"reviewed_by_id"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	ReviewComment string     `gorm:"size:500" json:"review_comment"`
+	AppliedBy     string     `gorm:"size:50" json:"applied_by"`
+	AppliedAt     *time.Time `json:"applied_at"`
+	HistoryID     uint       `json:"history_id"` // 应用时生成的配置历史记录
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (ConfigChangeRequest) TableName() string {
+	return "config_change_requests"
+}
+
+// ConfigChangeActivity 配置变更申请的操作记录和评论
+// Action: submit, comment, approve, reject, cancel, apply
+type ConfigChangeActivity struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	RequestID  uint      `gorm:"not null;index" json:"request_id"`
+	Action     string    `gorm:"size:20;not null" json:"action"`
+	Operator   string    `gorm:"size:50" json:"operator"`
+	OperatorID uint      `json:"operator_id"`
+	Content    string    `gorm:"type:text" json:"content"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (ConfigChangeActivity) TableName() string {
+	return "config_change_activities"
+}
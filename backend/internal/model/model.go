@@ -24,7 +24,7 @@ type App struct {
 	ID          uint           `gorm:"primarykey" json:"id"`
 	Name        string         `gorm:"size:100" json:"name" binding:"-"`
 	AppID       string         `gorm:"uniqueIndex;size:50" json:"app_id"`
-	AppSecret   string         `gorm:"size:100" json:"app_secret"`
+	AppSecret   string         `gorm:"size:255" json:"app_secret"` // 加密保存，接口返回时遮盖
 	PackageName string         `gorm:"size:100" json:"package_name"`
 	Description string         `gorm:"type:text" json:"description"`
 	Icon        string         `gorm:"size:255" json:"icon"`
@@ -34,6 +34,13 @@ type App struct {
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// AppNonce SDK 签名请求已使用的 nonce，多个实例共享，过期后可以清理
+type AppNonce struct {
+	AppID     string    `gorm:"primaryKey;size:50" json:"app_id"` // apps.app_id
+	Nonce     string    `gorm:"primaryKey;size:64" json:"nonce"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
 // ModuleTemplate 模块模板
 type ModuleTemplate struct {
 	ID           uint           `gorm:"primarykey" json:"id"`
@@ -65,18 +72,33 @@ type AppModule struct {
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// ModuleConfigHistory 模块配置历史
-type ModuleConfigHistory struct {
-	ID         uint      `gorm:"primarykey" json:"id"`
-	AppID      uint      `gorm:"index" json:"app_id"`
-	ModuleCode string    `gorm:"size:50" json:"module_code"`
-	Config     string    `gorm:"type:json" json:"config"`
-	Version    int       `json:"version"`
-	Operator   string    `gorm:"size:50" json:"operator"`
-	Remark     string    `gorm:"size:255" json:"remark"`
-	CreatedAt  time.Time `json:"created_at"`
+// AppModuleConfig 模块配置在某个环境、命名空间下的覆盖值
+// default 环境 application 命名空间的配置保存在 AppModule.Config，其他环境中没有覆盖的字段从基础环境继承
+type AppModuleConfig struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	AppID       uint      `gorm:"uniqueIndex:idx_app_module_configs_scope,priority:1" json:"app_id"`
+	ModuleCode  string    `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:2" json:"module_code"`
+	Environment string    `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:3" json:"environment"`
+	Namespace   string    `gorm:"size:50;uniqueIndex:idx_app_module_configs_scope,priority:4" json:"namespace"`
+	Config      string    `gorm:"type:json" json:"config"`
+	UpdatedBy   string    `gorm:"size:50" json:"updated_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// ModuleConfigHistory 模块配置历史，版本号在 APP + 模块 + 环境 + 命名空间内递增
+type ModuleConfigHistory struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	AppID       uint      `gorm:"index" json:"app_id"`
+	ModuleCode  string    `gorm:"size:50" json:"module_code"`
+	Environment string    `gorm:"size:50;default:default" json:"environment"`
+	Namespace   string    `gorm:"size:50;default:application" json:"namespace"`
+	Config      string    `gorm:"type:json" json:"config"`
+	Version     int       `json:"version"`
+	Operator    string    `gorm:"size:50" json:"operator"`
+	Remark      string    `gorm:"size:255" json:"remark"`
+	CreatedAt   time.Time `json:"created_at"`
+}
 
 // User 用户模型
 type User struct {
@@ -120,13 +142,35 @@ type PushRecord struct {
 	SentCount    int            `gorm:"default:0" json:"sent_count"`
 	SuccessCount int            `gorm:"default:0" json:"success_count"`
 	FailedCount  int            `gorm:"default:0" json:"failed_count"`
+	ErrorMessage string         `gorm:"size:500" json:"error_message"` // 发送失败的原因
 	ScheduledAt  *time.Time     `json:"scheduled_at"`
+	Timezone     string         `gorm:"size:64" json:"timezone"`         // 计划时间和周期所在的时区
+	LocalTime    bool           `gorm:"default:false" json:"local_time"` // 按每个设备所在时区的计划时间发送
+	Recurrence   string         `gorm:"size:100" json:"recurrence"`      // 周期发送的 cron 表达式，为空表示只发送一次
+	NextRunAt    *time.Time     `gorm:"index" json:"next_run_at"`        // 计划分发器下一次处理该推送的时间
+	ParentID     uint           `gorm:"index" json:"parent_id"`          // 由周期推送或按时区发送产生的单次推送所属的计划
+	Occurrence   *string        `gorm:"size:150;uniqueIndex" json:"-"`   // 单次推送的唯一键，防止多个实例重复产生
 	SentAt       *time.Time     `json:"sent_at"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// PushDevice 推送设备，由客户端通过 SDK 接口注册
+type PushDevice struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	AppID      uint       `gorm:"uniqueIndex:idx_push_devices_app_token,priority:1" json:"app_id"`
+	Token      string     `gorm:"size:255;uniqueIndex:idx_push_devices_app_token,priority:2" json:"token"`
+	Platform   string     `gorm:"size:20" json:"platform"` // ios / android
+	UserID     string     `gorm:"size:64;index" json:"user_id"`
+	Tags       string     `gorm:"size:500" json:"tags"` // 逗号分隔且首尾带逗号，例如 ,vip,beta,，便于按标签匹配
+	Timezone   string     `gorm:"size:64;default:UTC" json:"timezone"`
+	Status     int        `gorm:"default:1" json:"status"` // 1 可用，0 令牌已失效
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
 // Event 事件模型
 type Event struct {
 	ID         uint64    `gorm:"primarykey" json:"id"`
@@ -206,30 +250,98 @@ type File struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// Config 配置模型
+// 配置值类型
+const (
+	ConfigTypeString = "string"
+	ConfigTypeNumber = "number"
+	ConfigTypeBool   = "bool"
+	ConfigTypeJSON   = "json"
+)
+
+// 配置中心内置的环境和命名空间
+// default 环境是所有环境继承链的根，始终存在
+const (
+	DefaultConfigEnvironment = "default"
+	DefaultConfigNamespace   = "application"
+)
+
+// ConfigEnvironment 配置环境，例如 dev、staging、prod
+// 环境从 BaseEnvironment 继承配置，只需保存与基础环境不同的覆盖值
+type ConfigEnvironment struct {
+	ID              uint      `gorm:"primarykey" json:"id"`
+	AppID           uint      `gorm:"uniqueIndex:idx_config_environments_app_name" json:"app_id"`
+	Name            string    `gorm:"size:50;uniqueIndex:idx_config_environments_app_name" json:"name"`
+	BaseEnvironment string    `gorm:"size:50" json:"base_environment"`
+	Description     string    `gorm:"size:255" json:"description"`
+	CreatedBy       string    `gorm:"size:50" json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Config 配置模型（草稿），修改后需发布才会下发给客户端
+// 同一APP下按 环境 + 命名空间 + 键 唯一，环境中不存在的键从基础环境继承
 type Config struct {
 	ID          uint           `gorm:"primarykey" json:"id"`
-	AppID       uint           `gorm:"index" json:"app_id"`
-	ConfigKey   string         `gorm:"size:255" json:"config_key"`
+	AppID       uint           `gorm:"index;uniqueIndex:idx_configs_scope_key,priority:1" json:"app_id"`
+	Environment string         `gorm:"size:50;default:default;uniqueIndex:idx_configs_scope_key,priority:2" json:"environment"`
+	Namespace   string         `gorm:"size:50;default:application;uniqueIndex:idx_configs_scope_key,priority:3" json:"namespace"`
+	ConfigKey   string         `gorm:"size:255;uniqueIndex:idx_configs_scope_key,priority:4" json:"config_key"`
 	ConfigValue string         `gorm:"type:text" json:"config_value"`
+	ValueType   string         `gorm:"size:20" json:"value_type"`
 	Description string         `gorm:"type:text" json:"description"`
-	IsPublished int            `gorm:"default:0" json:"is_published"`
+	IsPublished int            `gorm:"default:0" json:"is_published"` // 1 表示当前值已包含在最新发布中
 	PublishedAt *time.Time     `json:"published_at"`
+	UpdatedBy   string         `gorm:"size:50" json:"updated_by"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// ConfigHistory 配置历史模型
+// ConfigHistory 配置历史模型，记录每次修改前后的值和操作人
+// Operation: create, update, delete, rollback, promote
 type ConfigHistory struct {
 	ID          uint      `gorm:"primarykey" json:"id"`
 	ConfigID    uint      `gorm:"index" json:"config_id"`
+	AppID       uint      `gorm:"index" json:"app_id"`
+	Environment string    `gorm:"size:50;default:default" json:"environment"`
+	Namespace   string    `gorm:"size:50;default:application" json:"namespace"`
+	ConfigKey   string    `gorm:"size:255" json:"config_key"`
+	OldValue    string    `gorm:"type:text" json:"old_value"`
 	ConfigValue string    `gorm:"type:text" json:"config_value"`
+	ValueType   string    `gorm:"size:20" json:"value_type"`
 	OperatorID  *uint     `json:"operator_id"`
+	Operator    string    `gorm:"size:50" json:"operator"`
 	Operation   string    `gorm:"size:50" json:"operation"`
+	ReleaseID   uint      `json:"release_id,omitempty"` // 回滚时对应的目标发布
 	CreatedAt   time.Time `gorm:"index" json:"created_at"`
 }
 
+// ConfigRelease 配置发布，发布时对某个环境、命名空间的生效配置做快照，创建后不再修改
+// 版本号在 APP + 环境 + 命名空间 内递增
+type ConfigRelease struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	AppID        uint      `gorm:"uniqueIndex:idx_config_releases_scope_version,priority:1" json:"app_id"`
+	Environment  string    `gorm:"size:50;default:default;uniqueIndex:idx_config_releases_scope_version,priority:2" json:"environment"`
+	Namespace    string    `gorm:"size:50;default:application;uniqueIndex:idx_config_releases_scope_version,priority:3" json:"namespace"`
+	Version      int       `gorm:"uniqueIndex:idx_config_releases_scope_version,priority:4" json:"version"`
+	Snapshot     string    `gorm:"type:json" json:"-"`
+	KeyCount     int       `json:"key_count"`
+	Remark       string    `gorm:"size:255" json:"remark"`
+	RollbackFrom int       `json:"rollback_from,omitempty"` // 由回滚产生时为回滚目标的版本号
+	PublishedBy  string    `gorm:"size:50" json:"published_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ConfigReleaseItem 发布快照中的单个配置
+// Source 为值所在的环境，与发布的环境不同时表示继承自基础环境
+type ConfigReleaseItem struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Source      string `json:"source,omitempty"`
+}
+
 // Version 版本模型
 type Version struct {
 	ID            uint           `gorm:"primarykey" json:"id"`
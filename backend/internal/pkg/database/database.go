@@ -2,6 +2,7 @@ package database
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -117,6 +118,26 @@ func GetDB() *gorm.DB {
 	return db
 }
 
+// SetDB 替换全局数据库连接（主要用于测试，例如 moduletest 使用 SQLite）
+func SetDB(conn *gorm.DB) {
+	db = conn
+}
+
+// IsDuplicateKey 判断错误是否由唯一索引冲突引起，兼容 MySQL 和测试使用的 SQLite
+func IsDuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
 func Close() {
 	if db != nil {
 		sqlDB, _ := db.DB()
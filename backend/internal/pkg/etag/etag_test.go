@@ -0,0 +1,45 @@
+package etag
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMatch(t *testing.T) {
+	type resource struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	current := resource{ID: 1, Name: "home"}
+	tag := Of(current)
+	if tag == "" || tag != Of(resource{ID: 1, Name: "home"}) {
+		t.Fatalf("Of() = %q, want stable non-empty tag", tag)
+	}
+	if tag == Of(resource{ID: 1, Name: "index"}) {
+		t.Fatal("different content produced the same tag")
+	}
+
+	tests := []struct {
+		ifMatch string
+		want    bool
+	}{
+		{"", true},
+		{"*", true},
+		{tag, true},
+		{`"stale", ` + tag, true},
+		{`"stale"`, false},
+		{"W/" + tag, false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("PUT", "/", nil)
+		if tt.ifMatch != "" {
+			c.Request.Header.Set("If-Match", tt.ifMatch)
+		}
+		if got := Match(c, current); got != tt.want {
+			t.Errorf("Match(If-Match: %s) = %v, want %v", tt.ifMatch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Package secret 对数据库中的敏感字段（APP密钥、API密钥、模块配置中的 secret 字段）做信封加密
+// 每个值使用随机生成的数据密钥（DEK）做 AES-256-GCM 加密，DEK 再由主密钥（KEK）加密后与密文一起保存
+// 密文格式为 enc:v1:<主密钥ID>:<base64>，主密钥ID用于轮换：新值总是使用当前主密钥，旧主密钥保留用于解密，
+// 通过 -reencrypt 命令将存量数据改用当前主密钥后即可移除旧主密钥
+// 未配置主密钥时不加密，按明文读写，兼容开发环境；没有前缀的值始终按明文处理，兼容加密前的存量数据
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"app-platform-backend/internal/config"
+)
+
+// Prefix 加密值的前缀
+const Prefix = "enc:v1:"
+
+// Masked 接口返回敏感字段时使用的占位值；写入时收到占位值表示保持原值不变
+const Masked = "******"
+
+// 通过环境变量配置主密钥，优先于配置文件
+// APP_ENCRYPTION_KEYS 格式为 id:base64key,id:base64key
+const (
+	EnvKeys      = "APP_ENCRYPTION_KEYS"
+	EnvActiveKey = "APP_ENCRYPTION_ACTIVE_KEY"
+)
+
+const (
+	keySize   = 32 // AES-256
+	nonceSize = 12
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ErrUnknownKey 密文使用的主密钥不在密钥环中
+var ErrUnknownKey = errors.New("secret: unknown encryption key")
+
+// Keyring 主密钥集合，active 为加密新值使用的主密钥
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// NewKeyring 创建密钥环，keys 为 主密钥ID -> 32 字节密钥；keys 为空时返回不加密的密钥环
+func NewKeyring(keys map[string][]byte, active string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD, len(keys)), active: active}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("secret: invalid key id %q", id)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("secret: key %s must be %d bytes, got %d", id, keySize, len(key))
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if len(k.keys) > 0 {
+		if _, ok := k.keys[active]; !ok {
+			return nil, fmt.Errorf("secret: active key %q is not configured", active)
+		}
+	}
+	return k, nil
+}
+
+// Enabled 是否配置了主密钥
+func (k *Keyring) Enabled() bool {
+	return k != nil && len(k.keys) > 0
+}
+
+// ActiveKeyID 当前主密钥ID，未启用加密时为空
+func (k *Keyring) ActiveKeyID() string {
+	if !k.Enabled() {
+		return ""
+	}
+	return k.active
+}
+
+// Encrypt 用当前主密钥加密；未启用加密或值为空时原样返回
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if !k.Enabled() || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dek := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dek)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	blob := append(wrapped, data...)
+	return Prefix + k.active + ":" + base64.RawURLEncoding.EncodeToString(blob), nil
+}
+
+// Decrypt 解密；没有前缀的值视为明文原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	if !ok {
+		return "", errors.New("secret: malformed ciphertext")
+	}
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+	kek, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	blob, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("secret: malformed ciphertext: %w", err)
+	}
+
+	wrappedLen := nonceSize + keySize + kek.Overhead()
+	if len(blob) < wrappedLen {
+		return "", errors.New("secret: malformed ciphertext")
+	}
+	dek, err := open(kek, blob[:wrappedLen])
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, blob[wrappedLen:])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rotate 将值改用当前主密钥加密，返回新值及是否发生了变化
+// 明文在启用加密后会被加密；已使用当前主密钥的值保持不变
+func (k *Keyring) Rotate(value string) (string, bool, error) {
+	if !k.Enabled() || value == "" || KeyID(value) == k.active {
+		return value, false, nil
+	}
+	plaintext, err := k.Decrypt(value)
+	if err != nil {
+		return value, false, err
+	}
+	rotated, err := k.Encrypt(plaintext)
+	if err != nil {
+		return value, false, err
+	}
+	return rotated, true, nil
+}
+
+// IsEncrypted 值是否为密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// KeyID 密文使用的主密钥ID，明文返回空
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, Prefix), ":")
+	return id
+}
+
+// Mask 返回敏感值的占位值，空值保持为空以便区分未设置
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Masked
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并在密文前附加随机 nonce
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < nonceSize {
+		return nil, errors.New("secret: malformed ciphertext")
+	}
+	plaintext, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, errors.New("secret: decryption failed")
+	}
+	return plaintext, nil
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// Init 按配置初始化全局密钥环，环境变量 APP_ENCRYPTION_KEYS / APP_ENCRYPTION_ACTIVE_KEY 优先于配置文件
+// 未指定当前主密钥时使用第一个主密钥
+func Init(cfg *config.EncryptionConfig) error {
+	var entries []config.EncryptionKey
+	active := ""
+	if cfg != nil {
+		entries = cfg.Keys
+		active = cfg.ActiveKey
+	}
+	if env := os.Getenv(EnvKeys); env != "" {
+		entries = nil
+		for _, item := range strings.Split(env, ",") {
+			id, key, ok := strings.Cut(strings.TrimSpace(item), ":")
+			if !ok {
+				return fmt.Errorf("secret: invalid %s entry %q, want id:base64key", EnvKeys, item)
+			}
+			entries = append(entries, config.EncryptionKey{ID: id, Key: key})
+		}
+		active = ""
+	}
+	if env := os.Getenv(EnvActiveKey); env != "" {
+		active = env
+	}
+
+	keys := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		key, err := base64.StdEncoding.DecodeString(entry.Key)
+		if err != nil {
+			return fmt.Errorf("secret: key %s is not valid base64: %w", entry.ID, err)
+		}
+		keys[entry.ID] = key
+	}
+	if active == "" && len(entries) > 0 {
+		active = entries[0].ID
+	}
+
+	keyring, err := NewKeyring(keys, active)
+	if err != nil {
+		return err
+	}
+	if !keyring.Enabled() {
+		log.Println("[Secret] WARNING: no encryption key configured, secrets are stored in plain text")
+	}
+	SetDefault(keyring)
+	return nil
+}
+
+// SetDefault 替换全局密钥环，主要用于测试
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	defaultKeyring = k
+	defaultMu.Unlock()
+}
+
+// Default 返回全局密钥环，未初始化时为 nil（不加密）
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
+
+// Encrypt 使用全局密钥环加密
+func Encrypt(plaintext string) (string, error) {
+	return Default().Encrypt(plaintext)
+}
+
+// Decrypt 使用全局密钥环解密
+func Decrypt(value string) (string, error) {
+	return Default().Decrypt(value)
+}
+
+// Rotate 使用全局密钥环轮换
+func Rotate(value string) (string, bool, error) {
+	return Default().Rotate(value)
+}
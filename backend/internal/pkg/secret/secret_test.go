@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestKeyring_EncryptRotate(t *testing.T) {
+	k1 := bytes.Repeat([]byte{1}, 32)
+	k2 := bytes.Repeat([]byte{2}, 32)
+	old, err := NewKeyring(map[string][]byte{"k1": k1}, "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := old.Encrypt("app-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, Prefix+"k1:") || strings.Contains(enc, "app-secret") {
+		t.Fatalf("Encrypt() = %q", enc)
+	}
+	if again, _ := old.Encrypt("app-secret"); again == enc {
+		t.Error("same plaintext produced the same ciphertext")
+	}
+	if got, err := old.Decrypt(enc); err != nil || got != "app-secret" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+	if got, err := old.Decrypt("legacy-plain"); err != nil || got != "legacy-plain" {
+		t.Errorf("Decrypt(plain) = %q, %v", got, err)
+	}
+
+	// 篡改密文
+	tampered := enc[:len(enc)-2] + "AA"
+	if _, err := old.Decrypt(tampered); err == nil {
+		t.Error("tampered ciphertext decrypted")
+	}
+
+	// 轮换：新主密钥加密，旧主密钥仍可解密
+	rotated, err := NewKeyring(map[string][]byte{"k1": k1, "k2": k2}, "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, changed, err := rotated.Rotate(enc)
+	if err != nil || !changed || KeyID(value) != "k2" {
+		t.Fatalf("Rotate() = %q, %v, %v", value, changed, err)
+	}
+	if _, changed, _ := rotated.Rotate(value); changed {
+		t.Error("value already using the active key was rotated again")
+	}
+	if value, changed, _ := rotated.Rotate("legacy-plain"); !changed || KeyID(value) != "k2" {
+		t.Errorf("plain value not encrypted on rotate: %q", value)
+	}
+
+	// 移除旧主密钥后无法解密旧密文
+	onlyNew, _ := NewKeyring(map[string][]byte{"k2": k2}, "k2")
+	if _, err := onlyNew.Decrypt(enc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() with removed key error = %v", err)
+	}
+
+	if _, err := NewKeyring(map[string][]byte{"k1": k1[:16]}, "k1"); err == nil {
+		t.Error("short key accepted")
+	}
+	if _, err := NewKeyring(map[string][]byte{"k1": k1}, "k3"); err == nil {
+		t.Error("missing active key accepted")
+	}
+}
+
+func TestKeyring_Disabled(t *testing.T) {
+	var k *Keyring
+	if got, err := k.Encrypt("plain"); err != nil || got != "plain" {
+		t.Errorf("Encrypt() without keys = %q, %v", got, err)
+	}
+	if _, changed, _ := k.Rotate("plain"); changed {
+		t.Error("Rotate() without keys changed the value")
+	}
+}
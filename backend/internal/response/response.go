@@ -29,9 +29,15 @@ const (
 	CodeForbidden        = 403
 	CodeNotFound         = 404
 	CodeConflict         = 409
+	CodePreconditionFailed = 412
+	CodePayloadTooLarge    = 413
 	CodeTooManyRequests  = 429
 	CodeInternalError    = 500
 	CodeServiceUnavailable = 503
+
+	// 业务错误码
+	CodeModuleDisabled = 4031 // 模块未对当前APP启用
+	CodeModuleOffline  = 5031 // 模块已全局停用
 )
 
 // 错误消息定义
@@ -42,9 +48,13 @@ var codeMessages = map[int]string{
 	CodeForbidden:        "Forbidden",
 	CodeNotFound:         "Not found",
 	CodeConflict:         "Conflict",
+	CodePreconditionFailed: "Precondition failed",
+	CodePayloadTooLarge:    "Payload too large",
 	CodeTooManyRequests:  "Too many requests",
 	CodeInternalError:    "Internal server error",
 	CodeServiceUnavailable: "Service unavailable",
+	CodeModuleDisabled:     "Module not enabled for this app",
+	CodeModuleOffline:      "Module is disabled",
 }
 
 // Success 成功响应
@@ -138,6 +148,22 @@ func Conflict(c *gin.Context, message string) {
 	Error(c, CodeConflict, message)
 }
 
+// PreconditionFailed 412错误，data 为资源的当前状态，便于客户端合并后重试
+func PreconditionFailed(c *gin.Context, message string, current interface{}) {
+	if message == "" {
+		message = codeMessages[CodePreconditionFailed]
+	}
+	ErrorWithData(c, CodePreconditionFailed, message, current)
+}
+
+// PayloadTooLarge 413错误
+func PayloadTooLarge(c *gin.Context, message string) {
+	if message == "" {
+		message = codeMessages[CodePayloadTooLarge]
+	}
+	Error(c, CodePayloadTooLarge, message)
+}
+
 // TooManyRequests 429错误
 func TooManyRequests(c *gin.Context, message string) {
 	if message == "" {
@@ -202,15 +228,19 @@ func getHTTPStatus(code int) int {
 		return http.StatusBadRequest
 	case CodeUnauthorized:
 		return http.StatusUnauthorized
-	case CodeForbidden:
+	case CodeForbidden, CodeModuleDisabled:
 		return http.StatusForbidden
 	case CodeNotFound:
 		return http.StatusNotFound
 	case CodeConflict:
 		return http.StatusConflict
+	case CodePreconditionFailed:
+		return http.StatusPreconditionFailed
+	case CodePayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
 	case CodeTooManyRequests:
 		return http.StatusTooManyRequests
-	case CodeServiceUnavailable:
+	case CodeServiceUnavailable, CodeModuleOffline:
 		return http.StatusServiceUnavailable
 	default:
 		return http.StatusInternalServerError
@@ -1,36 +1,38 @@
 package scheduler
 
 import (
+	"context"
 	"log"
 	"sync"
 	"time"
 
+	"app-platform-backend/core/module"
+
 	"gorm.io/gorm"
 )
 
 // AuditCleanupConfig 审计日志清理配置
 type AuditCleanupConfig struct {
-	RetentionDays int           // 日志保留天数，默认90天
-	CleanupHour   int           // 每天清理的小时（0-23），默认凌晨3点
-	BatchSize     int           // 每批删除的记录数，默认1000
-	Interval      time.Duration // 清理检查间隔，默认24小时
+	RetentionDays int    // 日志保留天数，默认90天
+	Schedule      string // 清理任务的 cron 表达式，默认每天凌晨3点
+	BatchSize     int    // 每批删除的记录数，默认1000
 }
 
 // DefaultAuditCleanupConfig 默认配置
 var DefaultAuditCleanupConfig = AuditCleanupConfig{
 	RetentionDays: 90,
-	CleanupHour:   3,
+	Schedule:      "0 3 * * *",
 	BatchSize:     1000,
-	Interval:      24 * time.Hour,
 }
 
-// AuditCleanupScheduler 审计日志清理调度器
-type AuditCleanupScheduler struct {
-	db       *gorm.DB
-	config   AuditCleanupConfig
-	stopChan chan struct{}
-	running  bool
-	mu       sync.Mutex
+// AuditCleanupJobName 审计日志清理任务在调度器中的名称
+const AuditCleanupJobName = "audit_log.cleanup"
+
+// AuditCleanup 审计日志清理服务，由通用调度器按 Schedule 定时执行
+type AuditCleanup struct {
+	db     *gorm.DB
+	config AuditCleanupConfig
+	mu     sync.Mutex
 }
 
 // CleanupRecord 清理记录
@@ -46,109 +48,62 @@ type CleanupRecord struct {
 }
 
 var (
-	scheduler *AuditCleanupScheduler
-	once      sync.Once
+	auditCleanup *AuditCleanup
+	once         sync.Once
 )
 
-// InitAuditCleanupScheduler 初始化审计日志清理调度器
-func InitAuditCleanupScheduler(db *gorm.DB, config ...AuditCleanupConfig) *AuditCleanupScheduler {
+// InitAuditCleanup 初始化审计日志清理服务
+func InitAuditCleanup(db *gorm.DB, config ...AuditCleanupConfig) *AuditCleanup {
 	once.Do(func() {
 		cfg := DefaultAuditCleanupConfig
 		if len(config) > 0 {
 			cfg = config[0]
 		}
-
-		scheduler = &AuditCleanupScheduler{
-			db:       db,
-			config:   cfg,
-			stopChan: make(chan struct{}),
+		if cfg.Schedule == "" {
+			cfg.Schedule = DefaultAuditCleanupConfig.Schedule
 		}
 
-		// 自动创建清理记录表
-		db.AutoMigrate(&CleanupRecord{})
+		auditCleanup = &AuditCleanup{
+			db:     db,
+			config: cfg,
+		}
 
-		log.Printf("[AuditCleanup] Scheduler initialized with config: RetentionDays=%d, CleanupHour=%d, BatchSize=%d",
-			cfg.RetentionDays, cfg.CleanupHour, cfg.BatchSize)
+		log.Printf("[AuditCleanup] Initialized with config: RetentionDays=%d, Schedule=%q, BatchSize=%d",
+			cfg.RetentionDays, cfg.Schedule, cfg.BatchSize)
 	})
 
-	return scheduler
+	return auditCleanup
 }
 
-// GetScheduler 获取调度器实例
-func GetScheduler() *AuditCleanupScheduler {
-	return scheduler
+// GetAuditCleanup 获取清理服务实例
+func GetAuditCleanup() *AuditCleanup {
+	return auditCleanup
 }
 
-// Start 启动定时清理任务
-func (s *AuditCleanupScheduler) Start() {
-	s.mu.Lock()
-	if s.running {
-		s.mu.Unlock()
-		return
+// Job 返回供调度器注册的清理任务
+func (s *AuditCleanup) Job() module.Job {
+	return module.Job{
+		Name:         AuditCleanupJobName,
+		Description:  "删除超过保留天数的审计日志",
+		Schedule:     s.GetConfig().Schedule,
+		Timeout:      time.Hour,
+		MaxRetries:   2,
+		RetryBackoff: 5 * time.Minute,
+		Run:          s.Run,
 	}
-	s.running = true
-	s.mu.Unlock()
-
-	go s.run()
-	log.Printf("[AuditCleanup] Scheduler started")
 }
 
-// Stop 停止定时清理任务
-func (s *AuditCleanupScheduler) Stop() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if !s.running {
-		return
-	}
-
-	close(s.stopChan)
-	s.running = false
-	log.Printf("[AuditCleanup] Scheduler stopped")
+// Run 按配置的保留天数执行一次清理
+func (s *AuditCleanup) Run(ctx context.Context) error {
+	_, err := s.cleanup(ctx, s.GetConfig().RetentionDays)
+	return err
 }
 
-// run 运行清理任务
-func (s *AuditCleanupScheduler) run() {
-	// 计算下一次清理时间
-	nextCleanup := s.calculateNextCleanupTime()
-	log.Printf("[AuditCleanup] Next cleanup scheduled at: %s", nextCleanup.Format("2006-01-02 15:04:05"))
-
-	timer := time.NewTimer(time.Until(nextCleanup))
-	defer timer.Stop()
-
-	for {
-		select {
-		case <-s.stopChan:
-			return
-		case <-timer.C:
-			// 执行清理
-			s.executeCleanup()
-
-			// 计算下一次清理时间
-			nextCleanup = s.calculateNextCleanupTime()
-			log.Printf("[AuditCleanup] Next cleanup scheduled at: %s", nextCleanup.Format("2006-01-02 15:04:05"))
-			timer.Reset(time.Until(nextCleanup))
-		}
-	}
-}
-
-// calculateNextCleanupTime 计算下一次清理时间
-func (s *AuditCleanupScheduler) calculateNextCleanupTime() time.Time {
-	now := time.Now()
-	next := time.Date(now.Year(), now.Month(), now.Day(), s.config.CleanupHour, 0, 0, 0, now.Location())
-
-	// 如果今天的清理时间已过，则安排到明天
-	if next.Before(now) {
-		next = next.Add(24 * time.Hour)
-	}
-
-	return next
-}
-
-// executeCleanup 执行清理操作
-func (s *AuditCleanupScheduler) executeCleanup() {
+// cleanup 分批删除早于保留天数的日志，并记录清理结果
+func (s *AuditCleanup) cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	batchSize := s.GetConfig().BatchSize
 	startTime := time.Now()
-	cutoffDate := startTime.AddDate(0, 0, -s.config.RetentionDays)
+	cutoffDate := startTime.AddDate(0, 0, -retentionDays)
 
 	log.Printf("[AuditCleanup] Starting cleanup, deleting logs before: %s", cutoffDate.Format("2006-01-02"))
 
@@ -157,9 +112,10 @@ func (s *AuditCleanupScheduler) executeCleanup() {
 
 	// 分批删除，避免长时间锁表
 	for {
-		result := s.db.Exec(
-			"DELETE FROM audit_logs WHERE created_at < ? LIMIT ?",
-			cutoffDate, s.config.BatchSize,
+		// 多实例部署时在删除语句中校验调度租约，已被接管的实例不会再删除任何记录
+		result := s.db.WithContext(ctx).Exec(
+			"DELETE FROM audit_logs WHERE created_at < ? AND ? LIMIT ?",
+			cutoffDate, FenceCondition(ctx), batchSize,
 		)
 
 		if result.Error != nil {
@@ -170,13 +126,21 @@ func (s *AuditCleanupScheduler) executeCleanup() {
 
 		totalDeleted += result.RowsAffected
 
-		// 如果删除的行数小于批次大小，说明已经删除完毕
-		if result.RowsAffected < int64(s.config.BatchSize) {
+		// 如果删除的行数小于批次大小，说明已经删除完毕，或租约已被接管
+		if result.RowsAffected < int64(batchSize) {
+			lastErr = VerifyFence(ctx)
 			break
 		}
 
 		// 短暂休眠，避免对数据库造成过大压力
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ctx.Done():
+			lastErr = ctx.Err()
+		}
+		if lastErr != nil {
+			break
+		}
 	}
 
 	duration := time.Since(startTime).Milliseconds()
@@ -202,61 +166,20 @@ func (s *AuditCleanupScheduler) executeCleanup() {
 
 	log.Printf("[AuditCleanup] Cleanup completed: deleted %d rows in %dms, status: %s",
 		totalDeleted, duration, record.Status)
+
+	return totalDeleted, lastErr
 }
 
 // ManualCleanup 手动执行清理
-func (s *AuditCleanupScheduler) ManualCleanup(retentionDays int) (int64, error) {
+func (s *AuditCleanup) ManualCleanup(retentionDays int) (int64, error) {
 	if retentionDays <= 0 {
-		retentionDays = s.config.RetentionDays
+		retentionDays = s.GetConfig().RetentionDays
 	}
-
-	startTime := time.Now()
-	cutoffDate := startTime.AddDate(0, 0, -retentionDays)
-
-	log.Printf("[AuditCleanup] Manual cleanup started, deleting logs before: %s", cutoffDate.Format("2006-01-02"))
-
-	var totalDeleted int64
-
-	// 分批删除
-	for {
-		result := s.db.Exec(
-			"DELETE FROM audit_logs WHERE created_at < ? LIMIT ?",
-			cutoffDate, s.config.BatchSize,
-		)
-
-		if result.Error != nil {
-			return totalDeleted, result.Error
-		}
-
-		totalDeleted += result.RowsAffected
-
-		if result.RowsAffected < int64(s.config.BatchSize) {
-			break
-		}
-
-		time.Sleep(100 * time.Millisecond)
-	}
-
-	duration := time.Since(startTime).Milliseconds()
-
-	// 记录清理结果
-	record := &CleanupRecord{
-		CleanupTime: startTime,
-		DeletedRows: totalDeleted,
-		CutoffDate:  cutoffDate,
-		Duration:    duration,
-		Status:      "success",
-		CreatedAt:   time.Now(),
-	}
-	s.db.Create(record)
-
-	log.Printf("[AuditCleanup] Manual cleanup completed: deleted %d rows in %dms", totalDeleted, duration)
-
-	return totalDeleted, nil
+	return s.cleanup(context.Background(), retentionDays)
 }
 
 // GetCleanupHistory 获取清理历史记录
-func (s *AuditCleanupScheduler) GetCleanupHistory(limit int) ([]CleanupRecord, error) {
+func (s *AuditCleanup) GetCleanupHistory(limit int) ([]CleanupRecord, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -267,15 +190,17 @@ func (s *AuditCleanupScheduler) GetCleanupHistory(limit int) ([]CleanupRecord, e
 }
 
 // GetConfig 获取当前配置
-func (s *AuditCleanupScheduler) GetConfig() AuditCleanupConfig {
+func (s *AuditCleanup) GetConfig() AuditCleanupConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.config
 }
 
-// UpdateConfig 更新配置
-func (s *AuditCleanupScheduler) UpdateConfig(config AuditCleanupConfig) {
+// UpdateConfig 更新配置，Schedule 的变更在下次注册任务（重启）后生效
+func (s *AuditCleanup) UpdateConfig(config AuditCleanupConfig) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.config = config
-	log.Printf("[AuditCleanup] Config updated: RetentionDays=%d, CleanupHour=%d, BatchSize=%d",
-		config.RetentionDays, config.CleanupHour, config.BatchSize)
+	log.Printf("[AuditCleanup] Config updated: RetentionDays=%d, Schedule=%q, BatchSize=%d",
+		config.RetentionDays, config.Schedule, config.BatchSize)
 }
@@ -0,0 +1,311 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultLeaseTTL 租约的默认有效期，续约间隔为其三分之一
+const DefaultLeaseTTL = 30 * time.Second
+
+var (
+	ErrNotLeader = errors.New("this instance is not the scheduler leader")
+	// ErrFenced 表示任务开始时持有的租约已被其他实例接管，不应再继续写入
+	ErrFenced = errors.New("scheduler lease lost, run fenced")
+)
+
+// Lease 调度租约，对应 scheduler_leases 表
+// Token 每次易主时递增，作为 fencing token 识别过期的持有者
+type Lease struct {
+	Name       string    `gorm:"primaryKey;size:100"`
+	Holder     string    `gorm:"size:255"`
+	Token      int64     `gorm:"not null;default:0"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+}
+
+// TableName 指定表名
+func (Lease) TableName() string {
+	return "scheduler_leases"
+}
+
+// LeaderInfo 当前租约持有者信息
+type LeaderInfo struct {
+	Holder    string    `json:"holder"`
+	Token     int64     `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Self      string    `json:"self"`
+	IsSelf    bool      `json:"is_self"`
+}
+
+// LeaderElector 基于数据库租约的选主器
+// 持有者每 TTL/3 续约一次；续约失败或本地判断租约已过期时立即放弃领导权
+type LeaderElector struct {
+	db     *gorm.DB
+	name   string
+	holder string
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	token      int64
+	validUntil time.Time // 本地认为租约有效的截止时间，进程暂停后恢复时据此判定已失去领导权
+	termCtx    context.Context
+	termCancel context.CancelFunc
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLeaderElector 创建选主器，name 为租约名称，ttl 为 0 时使用默认值
+func NewLeaderElector(db *gorm.DB, name string, ttl time.Duration) (*LeaderElector, error) {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	// 确保租约行存在，之后的抢占和续约都是对这一行的条件更新
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Lease{Name: name, ExpiresAt: time.Unix(0, 0)}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create lease %s: %w", name, err)
+	}
+	return &LeaderElector{db: db, name: name, holder: instanceID(), ttl: ttl}, nil
+}
+
+// instanceID 生成实例标识：主机名-进程号-随机后缀
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// ID 返回本实例的持有者标识
+func (e *LeaderElector) ID() string {
+	return e.holder
+}
+
+// Start 立即尝试获取租约，并启动续约循环
+func (e *LeaderElector) Start() {
+	e.mu.Lock()
+	if e.stop != nil {
+		e.mu.Unlock()
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	e.stop, e.done = stop, done
+	e.mu.Unlock()
+
+	e.tick()
+	go e.loop(stop, done)
+}
+
+// Stop 停止续约并主动释放租约，使其他实例无需等待过期即可接管
+func (e *LeaderElector) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if e.stop == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	close(e.stop)
+	done := e.done
+	e.stop = nil
+	e.mu.Unlock()
+	<-done
+
+	e.mu.Lock()
+	token, leader := e.token, e.leaderLocked()
+	e.resign()
+	e.mu.Unlock()
+
+	if !leader {
+		return nil
+	}
+	return e.db.WithContext(ctx).Model(&Lease{}).
+		Where("name = ? AND holder = ? AND token = ?", e.name, e.holder, token).
+		Update("expires_at", dbNow(e.db, 0)).Error
+}
+
+// IsLeader 返回本实例当前是否持有租约
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leaderLocked()
+}
+
+// Term 返回当前任期的 fencing token 和上下文，上下文在失去领导权时取消
+func (e *LeaderElector) Term() (context.Context, int64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.leaderLocked() {
+		return nil, 0, false
+	}
+	return e.termCtx, e.token, true
+}
+
+// Validate 到数据库确认 token 对应的任期仍然有效
+func (e *LeaderElector) Validate(ctx context.Context, token int64) error {
+	var count int64
+	err := e.db.WithContext(ctx).Model(&Lease{}).
+		Where("name = ? AND holder = ? AND token = ? AND expires_at > ?", e.name, e.holder, token, dbNow(e.db, 0)).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to verify lease: %w", err)
+	}
+	if count == 0 {
+		return ErrFenced
+	}
+	return nil
+}
+
+// Leader 读取当前租约持有者，租约已过期时返回的 Holder 为空
+func (e *LeaderElector) Leader(ctx context.Context) (LeaderInfo, error) {
+	var leases []Lease
+	if err := e.db.WithContext(ctx).Where("name = ? AND expires_at > ?", e.name, dbNow(e.db, 0)).
+		Limit(1).Find(&leases).Error; err != nil {
+		return LeaderInfo{}, err
+	}
+	info := LeaderInfo{Self: e.holder}
+	if len(leases) > 0 {
+		lease := leases[0]
+		info.Holder = lease.Holder
+		info.Token = lease.Token
+		info.ExpiresAt = lease.ExpiresAt
+		info.IsSelf = lease.Holder == e.holder
+	}
+	return info, nil
+}
+
+// loop 续约循环
+func (e *LeaderElector) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.tick()
+		}
+	}
+}
+
+// tick 持有租约时续约，否则尝试抢占已过期的租约
+func (e *LeaderElector) tick() {
+	e.mu.Lock()
+	leader, token := e.leaderLocked(), e.token
+	if !leader && e.termCancel != nil {
+		// 本地判断租约已过期（例如进程被暂停过），先放弃当前任期
+		log.Printf("[Leader] Lease %s expired locally, stepping down", e.name)
+		e.resign()
+	}
+	e.mu.Unlock()
+
+	// 本地有效期从发起请求前计时，数据库中的过期时间以数据库时钟为准
+	expiresAt := time.Now().Add(e.ttl)
+	if leader {
+		result := e.db.Model(&Lease{}).
+			Where("name = ? AND holder = ? AND token = ? AND expires_at > ?", e.name, e.holder, token, dbNow(e.db, 0)).
+			Updates(map[string]interface{}{"expires_at": dbNow(e.db, e.ttl), "renewed_at": dbNow(e.db, 0)})
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if result.Error != nil || result.RowsAffected == 0 {
+			log.Printf("[Leader] Failed to renew lease %s (token %d): %v", e.name, token, result.Error)
+			e.resign()
+			return
+		}
+		e.validUntil = expiresAt
+		return
+	}
+
+	result := e.db.Model(&Lease{}).
+		Where("name = ? AND expires_at <= ?", e.name, dbNow(e.db, 0)).
+		Updates(map[string]interface{}{
+			"holder":      e.holder,
+			"token":       gorm.Expr("token + 1"),
+			"expires_at":  dbNow(e.db, e.ttl),
+			"acquired_at": dbNow(e.db, 0),
+			"renewed_at":  dbNow(e.db, 0),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+
+	var lease Lease
+	if err := e.db.Where("name = ? AND holder = ?", e.name, e.holder).First(&lease).Error; err != nil {
+		return
+	}
+	e.mu.Lock()
+	e.token = lease.Token
+	e.validUntil = expiresAt
+	e.termCtx, e.termCancel = context.WithCancel(context.Background())
+	e.mu.Unlock()
+	log.Printf("[Leader] Acquired lease %s as %s (token %d)", e.name, e.holder, lease.Token)
+}
+
+// dbNow 返回数据库当前时间加 offset 的 SQL 表达式
+// 租约的写入和过期判断都使用数据库时钟，实例之间的时钟偏差不影响选主；
+// SQLite 是嵌入式数据库，与进程共用时钟
+func dbNow(db *gorm.DB, offset time.Duration) clause.Expr {
+	if db.Dialector.Name() == "mysql" {
+		return gorm.Expr("NOW(6) + INTERVAL ? MICROSECOND", offset.Microseconds())
+	}
+	return gorm.Expr("?", time.Now().Add(offset))
+}
+
+// leaderLocked 调用方需持有锁
+func (e *LeaderElector) leaderLocked() bool {
+	return e.termCancel != nil && time.Now().Before(e.validUntil)
+}
+
+// resign 结束当前任期并取消任期内运行的任务，调用方需持有锁
+func (e *LeaderElector) resign() {
+	if e.termCancel != nil {
+		e.termCancel()
+		e.termCancel = nil
+		e.termCtx = nil
+	}
+	e.validUntil = time.Time{}
+}
+
+// fenceKey 用于在任务上下文中保存 fencing 信息
+type fenceKey struct{}
+
+type fence struct {
+	elector *LeaderElector
+	token   int64
+}
+
+// VerifyFence 确认任务启动时的任期仍然有效
+// 校验与之后的写操作之间租约仍可能易主，不可重复的写操作应在语句中使用 FenceCondition
+// 未启用选主（单实例）时总是返回 nil
+func VerifyFence(ctx context.Context) error {
+	f, ok := ctx.Value(fenceKey{}).(fence)
+	if !ok {
+		return nil
+	}
+	return f.elector.Validate(ctx, f.token)
+}
+
+// FenceCondition 返回任务启动时的任期仍然有效的 SQL 条件，用于 UPDATE/DELETE 的 WHERE 子句，
+// 使 fencing token 的校验与写入在同一条语句中原子完成
+// 未启用选主（单实例）时条件恒为真
+func FenceCondition(ctx context.Context) clause.Expr {
+	f, ok := ctx.Value(fenceKey{}).(fence)
+	if !ok {
+		return gorm.Expr("1 = 1")
+	}
+	e := f.elector
+	return gorm.Expr("EXISTS (SELECT 1 FROM scheduler_leases WHERE name = ? AND holder = ? AND token = ? AND expires_at > ?)",
+		e.name, e.holder, f.token, dbNow(e.db, 0))
+}
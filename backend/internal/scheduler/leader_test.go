@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if _, err := module.NewMigrationRunner(db).Up(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestLeaderElector_Failover(t *testing.T) {
+	db := openTestDB(t)
+	a, err := NewLeaderElector(db, "scheduler", time.Second)
+	if err != nil {
+		t.Fatalf("NewLeaderElector() error = %v", err)
+	}
+	b, _ := NewLeaderElector(db, "scheduler", time.Second)
+
+	a.Start()
+	b.Start()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("a.IsLeader() = %v, b.IsLeader() = %v; want only a", a.IsLeader(), b.IsLeader())
+	}
+	termA, tokenA, _ := a.Term()
+
+	leader, err := b.Leader(context.Background())
+	if err != nil || leader.Holder != a.ID() || leader.IsSelf {
+		t.Errorf("b.Leader() = %+v, %v; want holder %s", leader, err, a.ID())
+	}
+
+	// a 主动释放后 b 在下一次心跳接管，token 递增
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatalf("a.Stop() error = %v", err)
+	}
+	if termA.Err() == nil {
+		t.Error("a's term context should be cancelled after stepping down")
+	}
+	b.tick()
+	_, tokenB, ok := b.Term()
+	if !ok || tokenB != tokenA+1 {
+		t.Fatalf("b term = (%d, %v), want token %d", tokenB, ok, tokenA+1)
+	}
+	defer b.Stop(context.Background())
+
+	// a 的旧任期被 fencing 拒绝，b 的任期有效
+	if err := a.Validate(context.Background(), tokenA); !errors.Is(err, ErrFenced) {
+		t.Errorf("stale token Validate() error = %v, want ErrFenced", err)
+	}
+	if err := b.Validate(context.Background(), tokenB); err != nil {
+		t.Errorf("current token Validate() error = %v", err)
+	}
+}
+
+func TestLeaderElector_LocalExpiry(t *testing.T) {
+	db := openTestDB(t)
+	e, _ := NewLeaderElector(db, "scheduler", time.Second)
+	e.tick()
+	if !e.IsLeader() {
+		t.Fatal("elector should acquire a free lease")
+	}
+
+	// 模拟进程暂停超过租约有效期：本地立即判定失去领导权，且不能再续约
+	e.mu.Lock()
+	e.validUntil = time.Now().Add(-time.Millisecond)
+	e.mu.Unlock()
+	if e.IsLeader() {
+		t.Error("IsLeader() should be false after the lease expired locally")
+	}
+	if _, _, ok := e.Term(); ok {
+		t.Error("Term() should not be available after local expiry")
+	}
+}
+
+func TestScheduler_FollowerDoesNotRun(t *testing.T) {
+	db := openTestDB(t)
+	leader, _ := NewLeaderElector(db, "scheduler", time.Second)
+	leader.tick()
+	follower, _ := NewLeaderElector(db, "scheduler", time.Second)
+
+	s, err := New(db)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	s.UseLeaderElection(follower)
+	s.Register(testJob("noop"))
+	if err := s.Trigger("noop"); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Trigger() on follower error = %v, want ErrNotLeader", err)
+	}
+}
+
+func TestScheduler_OnceWaitsForLeadership(t *testing.T) {
+	db := openTestDB(t)
+	leader, _ := NewLeaderElector(db, "scheduler", time.Second)
+	leader.Start()
+	follower, _ := NewLeaderElector(db, "scheduler", time.Second)
+
+	s, _ := New(db)
+	s.UseLeaderElection(follower)
+	var runs atomic.Int32
+	s.ScheduleOnce(module.Job{Name: "once", Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}, 0)
+
+	// 非 leader 时一次性任务保留并稍后重试，而不是被丢弃
+	s.runDue(time.Now())
+	if job, err := s.Job("once"); err != nil || job.NextRunAt == nil {
+		t.Fatalf("one-off job on follower = %+v, %v; want pending", job, err)
+	}
+
+	leader.Stop(context.Background())
+	follower.tick()
+	defer follower.Stop(context.Background())
+	s.runDue(time.Now().Add(onceRetryInterval))
+	waitFor(t, func() bool { _, err := s.Job("once"); return errors.Is(err, ErrJobNotFound) })
+	if runs.Load() != 1 {
+		t.Errorf("runs = %d, want 1", runs.Load())
+	}
+}
+
+func TestScheduler_PauseFromOtherInstance(t *testing.T) {
+	db := openTestDB(t)
+	leader, _ := New(db)
+	other, _ := New(db)
+	leader.Register(testJob("digest"))
+	other.Register(testJob("digest"))
+
+	// 暂停请求由另一个实例处理，执行任务的实例同步后生效
+	if err := other.Pause("digest"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	leader.syncStates()
+	if job, _ := leader.Job("digest"); !job.Paused {
+		t.Error("pause from another instance not picked up")
+	}
+
+	if err := other.Resume("digest"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	leader.syncStates()
+	if job, _ := leader.Job("digest"); job.Paused || job.NextRunAt == nil {
+		t.Errorf("resumed job = %+v", job)
+	}
+}
+
+func TestFenceCondition(t *testing.T) {
+	db := openTestDB(t)
+	db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	db.Exec("INSERT INTO items (id) VALUES (1), (2)")
+	a, _ := NewLeaderElector(db, "scheduler", time.Second)
+	b, _ := NewLeaderElector(db, "scheduler", time.Second)
+	a.tick()
+	_, tokenA, _ := a.Term()
+	ctxA := context.WithValue(context.Background(), fenceKey{}, fence{elector: a, token: tokenA})
+
+	if n := db.Exec("DELETE FROM items WHERE id = 1 AND ?", FenceCondition(ctxA)).RowsAffected; n != 1 {
+		t.Fatalf("delete in current term affected %d rows, want 1", n)
+	}
+	if n := db.Exec("DELETE FROM items WHERE ?", FenceCondition(context.Background())).RowsAffected; n != 1 {
+		t.Fatalf("delete without fence affected %d rows, want 1", n)
+	}
+
+	// a 的租约被 b 接管后，使用旧任期的写语句不会生效
+	db.Exec("INSERT INTO items (id) VALUES (3)")
+	db.Model(&Lease{}).Where("name = ?", "scheduler").Update("expires_at", time.Now().Add(-time.Second))
+	b.tick()
+	if !b.IsLeader() {
+		t.Fatal("b should take over the expired lease")
+	}
+	if n := db.Exec("DELETE FROM items WHERE ?", FenceCondition(ctxA)).RowsAffected; n != 0 {
+		t.Errorf("delete in stale term affected %d rows, want 0", n)
+	}
+}
@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"time"
+
+	"app-platform-backend/core/module"
+
+	"gorm.io/gorm"
+)
+
+// 调度器表结构的迁移，之前由 New 和 NewLeaderElector 在启动时 AutoMigrate
+// 下面的结构体是建表时的快照，之后的表结构变更写成新的迁移
+func init() {
+	module.RegisterMigrations("scheduler",
+		module.Migration{
+			Version:     202610170001,
+			Description: "create job_runs, job_states",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateTableIfNotExists(tx, &jobRunV1{}, &jobStateV1{})
+			},
+		},
+		module.Migration{
+			Version:     202610170002,
+			Description: "create scheduler_leases",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateTableIfNotExists(tx, &leaseV1{})
+			},
+		},
+	)
+}
+
+type jobRunV1 struct {
+	ID         uint   `gorm:"primaryKey"`
+	JobName    string `gorm:"size:100;index"`
+	Trigger    string `gorm:"size:20"`
+	Attempt    int
+	Token      int64
+	Status     string    `gorm:"size:20"`
+	Error      string    `gorm:"type:text"`
+	StartedAt  time.Time `gorm:"index"`
+	FinishedAt *time.Time
+	Duration   int64
+}
+
+func (jobRunV1) TableName() string {
+	return "job_runs"
+}
+
+type jobStateV1 struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	Paused    bool   `gorm:"default:false"`
+	UpdatedAt time.Time
+}
+
+func (jobStateV1) TableName() string {
+	return "job_states"
+}
+
+type leaseV1 struct {
+	Name       string    `gorm:"primaryKey;size:100"`
+	Holder     string    `gorm:"size:255"`
+	Token      int64     `gorm:"not null;default:0"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+}
+
+func (leaseV1) TableName() string {
+	return "scheduler_leases"
+}
@@ -0,0 +1,680 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"app-platform-backend/core/module"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// 默认值
+const (
+	DefaultJobTimeout   = 10 * time.Minute
+	DefaultRetryBackoff = 30 * time.Second
+)
+
+// stateSyncInterval 从 job_states 同步暂停状态的间隔
+// 暂停和恢复可能由任意实例处理，执行任务的实例需要定期读取持久化的状态
+const stateSyncInterval = 10 * time.Second
+
+// onceRetryInterval 一次性任务未能启动（如非 leader 实例）时再次尝试的间隔
+const onceRetryInterval = 10 * time.Second
+
+// 触发方式
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+	TriggerOnce     = "once"
+	TriggerRetry    = "retry"
+)
+
+// 运行状态
+const (
+	RunStatusRunning = "running"
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+	RunStatusTimeout = "timeout"
+	RunStatusFenced  = "fenced" // 执行期间失去领导权，结果不可信
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobExists   = errors.New("job already registered")
+	ErrJobRunning  = errors.New("job is already running")
+	// ErrModuleDisabled 任务所属模块已全局停用
+	ErrModuleDisabled = errors.New("job module is disabled")
+)
+
+// JobRun 任务运行记录，对应 job_runs 表
+type JobRun struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	JobName    string     `json:"job_name" gorm:"size:100;index"`
+	Trigger    string     `json:"trigger" gorm:"size:20"`
+	Attempt    int        `json:"attempt"`
+	Token      int64      `json:"token"` // 执行时的 fencing token，未启用选主时为 0
+	Status     string     `json:"status" gorm:"size:20"`
+	Error      string     `json:"error" gorm:"type:text"`
+	StartedAt  time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Duration   int64      `json:"duration"` // 毫秒
+}
+
+// JobState 任务的持久化状态，对应 job_states 表，保证暂停状态在重启后保留
+type JobState struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	Paused    bool   `gorm:"default:false"`
+	UpdatedAt time.Time
+}
+
+// JobInfo 任务信息
+type JobInfo struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Schedule    string     `json:"schedule,omitempty"`
+	OneOff      bool       `json:"one_off"`
+	Paused      bool       `json:"paused"`
+	Module      string     `json:"module,omitempty"`
+	Disabled    bool       `json:"module_disabled"` // 所属模块已全局停用
+	Running     bool       `json:"running"`
+	Timeout     string     `json:"timeout"`
+	MaxRetries  int        `json:"max_retries"`
+	NextRunAt   *time.Time `json:"next_run_at,omitempty"`
+	LastRun     *JobRun    `json:"last_run,omitempty"`
+}
+
+// entry 调度器内部的任务条目
+type entry struct {
+	job      module.Job
+	schedule cron.Schedule // 一次性任务为 nil
+	next     time.Time
+	paused   bool
+	running  bool
+	lastRun  *JobRun
+}
+
+// Scheduler 通用任务调度器
+// 支持 cron 表达式和一次性延迟任务，每次执行都会记录到 job_runs 表
+type Scheduler struct {
+	db      *gorm.DB
+	mu      sync.Mutex
+	entries map[string]*entry
+	wake    chan struct{}
+	stop    chan struct{}
+	running bool
+	wg      sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+	elector *LeaderElector
+	// stateMu 串行化暂停状态的修改和同步，避免同步读到的旧状态覆盖刚保存的新状态
+	stateMu sync.Mutex
+}
+
+var defaultScheduler *Scheduler
+
+// Init 初始化全局调度器，db 为 nil 时不持久化运行记录
+func Init(db *gorm.DB) (*Scheduler, error) {
+	s, err := New(db)
+	if err != nil {
+		return nil, err
+	}
+	defaultScheduler = s
+	return s, nil
+}
+
+// Default 获取全局调度器
+func Default() *Scheduler {
+	return defaultScheduler
+}
+
+// New 创建调度器，运行记录表由迁移创建
+func New(db *gorm.DB) (*Scheduler, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:      db,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Register 注册一个 cron 任务
+func (s *Scheduler) Register(job module.Job) error {
+	if job.Schedule == "" {
+		return fmt.Errorf("job %s: schedule is required", job.Name)
+	}
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+	}
+	return s.add(job, &entry{job: job, schedule: schedule, next: schedule.Next(time.Now())})
+}
+
+// ScheduleOnce 注册一个延迟执行一次的任务，执行结束后自动移除
+// 一次性任务只保存在内存中，进程重启后不会恢复
+func (s *Scheduler) ScheduleOnce(job module.Job, delay time.Duration) error {
+	return s.add(job, &entry{job: job, next: time.Now().Add(delay)})
+}
+
+// add 校验并加入任务
+func (s *Scheduler) add(job module.Job, e *entry) error {
+	if job.Name == "" {
+		return errors.New("job name is required")
+	}
+	if job.Run == nil {
+		return fmt.Errorf("job %s: Run is required", job.Name)
+	}
+
+	if s.db != nil {
+		var state JobState
+		if err := s.db.Where("name = ?", job.Name).Limit(1).Find(&state).Error; err != nil {
+			return fmt.Errorf("job %s: failed to load state: %w", job.Name, err)
+		}
+		e.paused = state.Paused
+	}
+
+	s.mu.Lock()
+	if _, ok := s.entries[job.Name]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobExists, job.Name)
+	}
+	s.entries[job.Name] = e
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// UseLeaderElection 启用选主，多实例部署时只有持有租约的实例执行任务
+// 需在 Start 之前调用
+func (s *Scheduler) UseLeaderElection(elector *LeaderElector) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.elector = elector
+}
+
+// Leader 返回当前租约持有者，未启用选主时返回 nil
+func (s *Scheduler) Leader(ctx context.Context) (*LeaderInfo, error) {
+	if s.elector == nil {
+		return nil, nil
+	}
+	info, err := s.elector.Leader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Start 启动调度循环
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	elector := s.elector
+	s.mu.Unlock()
+
+	if elector != nil {
+		elector.Start()
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	log.Printf("[Scheduler] Started with %d jobs", len(s.Jobs()))
+}
+
+// Stop 停止调度并等待运行中的任务结束；ctx 超时后取消运行中的任务
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	s.running = false
+	close(s.stop)
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		err = fmt.Errorf("scheduler stop: %w", ctx.Err())
+	}
+
+	// 任务全部结束后再释放租约，避免其他实例在本实例仍在执行时接管
+	if s.elector != nil {
+		if releaseErr := s.elector.Stop(context.Background()); releaseErr != nil {
+			log.Printf("[Scheduler] Failed to release lease: %v", releaseErr)
+		}
+	}
+	log.Printf("[Scheduler] Stopped")
+	return err
+}
+
+// IsRunning 返回调度器是否正在运行
+func (s *Scheduler) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Pause 暂停任务的定时执行，手动触发不受影响
+func (s *Scheduler) Pause(name string) error {
+	return s.setPaused(name, true)
+}
+
+// Resume 恢复任务的定时执行，从当前时间重新计算下次执行时间
+func (s *Scheduler) Resume(name string) error {
+	return s.setPaused(name, false)
+}
+
+// setPaused 持久化并修改任务的暂停状态，其他实例在下一次同步时生效
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	s.mu.Lock()
+	_, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	if s.db != nil {
+		state := JobState{Name: name, Paused: paused, UpdatedAt: time.Now()}
+		if err := s.db.Save(&state).Error; err != nil {
+			return fmt.Errorf("failed to save job state: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	if e, ok := s.entries[name]; ok {
+		e.setPaused(paused, time.Now())
+	}
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// syncStates 从 job_states 读取各任务的暂停状态，读取失败时保留当前状态
+func (s *Scheduler) syncStates() {
+	if s.db == nil {
+		return
+	}
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+
+	var states []JobState
+	if err := s.db.Find(&states).Error; err != nil {
+		log.Printf("[Scheduler] Failed to sync job states: %v", err)
+		return
+	}
+	paused := make(map[string]bool, len(states))
+	for _, state := range states {
+		paused[state.Name] = state.Paused
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for name, e := range s.entries {
+		e.setPaused(paused[name], now)
+	}
+	s.mu.Unlock()
+}
+
+// Trigger 立即异步执行一次任务，任务正在运行时返回 ErrJobRunning
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if e.running {
+		return ErrJobRunning
+	}
+	if !e.enabled() {
+		return ErrModuleDisabled
+	}
+	return s.launch(e, TriggerManual)
+}
+
+// Job 返回单个任务的信息
+func (s *Scheduler) Job(name string) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	return e.info(), nil
+}
+
+// Jobs 返回所有任务的信息，按名称排序
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	jobs := make([]JobInfo, 0, len(s.entries))
+	for _, e := range s.entries {
+		jobs = append(jobs, e.info())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// History 返回任务最近的运行记录
+func (s *Scheduler) History(name string, limit int) ([]JobRun, error) {
+	if s.db == nil {
+		return []JobRun{}, nil
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	var runs []JobRun
+	err := s.db.Where("job_name = ?", name).Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// notify 唤醒调度循环重新计算下次触发时间
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop 调度循环：睡眠到最近一个任务的触发时间，然后执行所有到期任务
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mu.Lock()
+		next := s.nextWake()
+		s.mu.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		// 使用数据库时定期醒来同步暂停状态，其他实例恢复的任务才能按时执行
+		if s.db != nil && wait > stateSyncInterval {
+			wait = stateSyncInterval
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.syncStates()
+			s.runDue(time.Now())
+		}
+	}
+}
+
+// nextWake 返回最近一个未暂停任务的触发时间，调用方需持有锁
+func (s *Scheduler) nextWake() time.Time {
+	var next time.Time
+	for _, e := range s.entries {
+		if e.paused || e.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || e.next.Before(next) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// runDue 执行所有到期的任务；上一次执行尚未结束的任务跳过本次触发
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, e := range s.entries {
+		if e.paused || e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+
+		trigger := TriggerSchedule
+		if e.schedule != nil {
+			e.next = e.schedule.Next(now)
+		} else {
+			// 一次性任务成功启动后才清除触发时间，跳过时稍后重试
+			e.next = now.Add(onceRetryInterval)
+			trigger = TriggerOnce
+		}
+
+		if e.running {
+			log.Printf("[Scheduler] Job %s is still running, skipping this run", name)
+			continue
+		}
+		if !e.enabled() {
+			log.Printf("[Scheduler] Module %s is disabled, skipping job %s", e.job.Module, name)
+			continue
+		}
+		if err := s.launch(e, trigger); err != nil {
+			// 非 leader 实例跳过本次触发，定时任务由持有租约的实例执行
+			continue
+		}
+		if e.schedule == nil {
+			e.next = time.Time{}
+		}
+	}
+}
+
+// launch 在新的 goroutine 中执行任务，调用方需持有锁
+// 启用选主时只有 leader 可以执行任务，任务在当前任期内运行
+func (s *Scheduler) launch(e *entry, trigger string) error {
+	var t *term
+	if s.elector != nil {
+		ctx, token, ok := s.elector.Term()
+		if !ok {
+			return ErrNotLeader
+		}
+		t = &term{ctx: ctx, token: token, elector: s.elector}
+	}
+
+	e.running = true
+	stop := s.stop
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		lastRun := s.execute(e.job, trigger, stop, t)
+
+		s.mu.Lock()
+		e.running = false
+		e.lastRun = lastRun
+		if e.schedule == nil && e.next.IsZero() {
+			delete(s.entries, e.job.Name)
+		}
+		s.mu.Unlock()
+	}()
+	return nil
+}
+
+// term 任务启动时的领导任期
+type term struct {
+	ctx     context.Context
+	token   int64
+	elector *LeaderElector
+}
+
+// execute 执行任务，失败时按指数退避重试，返回最后一次运行记录
+// 调度器停止或失去领导权时不再等待重试
+func (s *Scheduler) execute(job module.Job, trigger string, stop <-chan struct{}, t *term) *JobRun {
+	timeout := job.Timeout
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+	backoff := job.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	var lost <-chan struct{}
+	if t != nil {
+		lost = t.ctx.Done()
+	}
+
+	var run *JobRun
+	for attempt := 1; ; attempt++ {
+		run = s.runAttempt(job, trigger, attempt, timeout, t)
+		if run.Status == RunStatusSuccess || run.Status == RunStatusFenced || attempt > job.MaxRetries {
+			return run
+		}
+
+		wait := backoff << (attempt - 1)
+		log.Printf("[Scheduler] Job %s failed (attempt %d): %s, retrying in %s", job.Name, attempt, run.Error, wait)
+		select {
+		case <-time.After(wait):
+		case <-stop:
+			return run
+		case <-lost:
+			return run
+		case <-s.ctx.Done():
+			return run
+		}
+		trigger = TriggerRetry
+	}
+}
+
+// runAttempt 执行一次任务并记录运行结果
+// 任务上下文在失去领导权时取消；结束时再次校验任期，过期任期内的执行标记为 fenced
+func (s *Scheduler) runAttempt(job module.Job, trigger string, attempt int, timeout time.Duration, t *term) *JobRun {
+	run := &JobRun{
+		JobName:   job.Name,
+		Trigger:   trigger,
+		Attempt:   attempt,
+		Status:    RunStatusRunning,
+		StartedAt: time.Now(),
+	}
+	if t != nil {
+		run.Token = t.token
+	}
+	s.saveRun(run)
+
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
+	if t != nil {
+		stopAfter := context.AfterFunc(t.ctx, cancel)
+		defer stopAfter()
+		ctx = context.WithValue(ctx, fenceKey{}, fence{elector: t.elector, token: t.token})
+	}
+	err := safeRun(ctx, job.Run)
+	cancel()
+
+	if t != nil {
+		checkCtx, checkCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if fenceErr := t.elector.Validate(checkCtx, t.token); fenceErr != nil {
+			err = fenceErr
+		}
+		checkCancel()
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Duration = finishedAt.Sub(run.StartedAt).Milliseconds()
+	switch {
+	case err == nil:
+		run.Status = RunStatusSuccess
+	case errors.Is(err, ErrFenced):
+		run.Status = RunStatusFenced
+		run.Error = err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		run.Status = RunStatusTimeout
+		run.Error = err.Error()
+	default:
+		run.Status = RunStatusFailed
+		run.Error = err.Error()
+	}
+	s.saveRun(run)
+	return run
+}
+
+// saveRun 持久化运行记录，失败只记录日志
+func (s *Scheduler) saveRun(run *JobRun) {
+	if s.db == nil {
+		return
+	}
+	if err := s.db.Save(run).Error; err != nil {
+		log.Printf("[Scheduler] Failed to save run of job %s: %v", run.JobName, err)
+	}
+}
+
+// safeRun 执行任务函数，panic 视为失败
+func safeRun(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// setPaused 修改条目的暂停状态，恢复时从 now 重新计算下次执行时间，调用方需持有锁
+func (e *entry) setPaused(paused bool, now time.Time) {
+	if e.paused && !paused && e.schedule != nil {
+		e.next = e.schedule.Next(now)
+	}
+	e.paused = paused
+}
+
+// info 返回任务信息，调用方需持有锁
+func (e *entry) info() JobInfo {
+	timeout := e.job.Timeout
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+	info := JobInfo{
+		Name:        e.job.Name,
+		Description: e.job.Description,
+		Schedule:    e.job.Schedule,
+		OneOff:      e.schedule == nil,
+		Paused:      e.paused,
+		Module:      e.job.Module,
+		Disabled:    !e.enabled(),
+		Running:     e.running,
+		Timeout:     timeout.String(),
+		MaxRetries:  e.job.MaxRetries,
+		LastRun:     e.lastRun,
+	}
+	if !e.paused && !e.next.IsZero() {
+		next := e.next
+		info.NextRunAt = &next
+	}
+	return info
+}
+
+// enabled 返回任务所属模块是否处于启用状态，不属于任何模块的任务总是启用
+func (e *entry) enabled() bool {
+	return e.job.Module == "" || module.IsEnabled(e.job.Module)
+}
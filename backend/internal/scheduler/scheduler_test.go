@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+)
+
+// waitFor 等待条件成立，超时则测试失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before timeout")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScheduler_OnceWithRetry(t *testing.T) {
+	s, _ := New(nil)
+	s.Start()
+	defer s.Stop(context.Background())
+
+	var attempts atomic.Int32
+	err := s.ScheduleOnce(module.Job{
+		Name:         "flaky",
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("temporary")
+			}
+			return nil
+		},
+	}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("ScheduleOnce() error = %v", err)
+	}
+
+	// 一次性任务执行结束后会被移除
+	waitFor(t, func() bool { _, err := s.Job("flaky"); return errors.Is(err, ErrJobNotFound) })
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestScheduler_TimeoutAndPanic(t *testing.T) {
+	s, _ := New(nil)
+
+	run := s.execute(module.Job{
+		Name:    "slow",
+		Timeout: 10 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}, TriggerManual, nil, nil)
+	if run.Status != RunStatusTimeout {
+		t.Errorf("slow job status = %s, want %s", run.Status, RunStatusTimeout)
+	}
+
+	run = s.execute(module.Job{
+		Name: "panics",
+		Run:  func(ctx context.Context) error { panic("boom") },
+	}, TriggerManual, nil, nil)
+	if run.Status != RunStatusFailed || run.Error == "" {
+		t.Errorf("panicking job run = %+v, want failed with error", run)
+	}
+}
+
+func TestScheduler_PauseAndTrigger(t *testing.T) {
+	s, _ := New(nil)
+
+	release := make(chan struct{})
+	var runs atomic.Int32
+	err := s.Register(module.Job{
+		Name:     "daily",
+		Schedule: "@daily",
+		Run: func(ctx context.Context) error {
+			runs.Add(1)
+			<-release
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := s.Register(module.Job{Name: "daily", Schedule: "@daily", Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrJobExists) {
+		t.Errorf("duplicate Register() error = %v, want ErrJobExists", err)
+	}
+	if err := s.Register(module.Job{Name: "bad", Schedule: "every day", Run: func(ctx context.Context) error { return nil }}); err == nil {
+		t.Error("Register() with invalid schedule should fail")
+	}
+
+	if err := s.Pause("daily"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+	if job, _ := s.Job("daily"); !job.Paused || job.NextRunAt != nil {
+		t.Errorf("paused job = %+v", job)
+	}
+
+	// 暂停的任务仍可手动触发，运行中再次触发返回 ErrJobRunning
+	if err := s.Trigger("daily"); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	waitFor(t, func() bool { return runs.Load() == 1 })
+	if err := s.Trigger("daily"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Trigger() while running error = %v, want ErrJobRunning", err)
+	}
+	close(release)
+	waitFor(t, func() bool { job, _ := s.Job("daily"); return !job.Running && job.LastRun != nil })
+
+	if err := s.Resume("daily"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if job, _ := s.Job("daily"); job.NextRunAt == nil {
+		t.Error("resumed job should have a next run time")
+	}
+	if err := s.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Trigger(missing) error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func testJob(name string) module.Job {
+	return module.Job{Name: name, Schedule: "@daily", Run: func(ctx context.Context) error { return nil }}
+}
+
+// jobModule 声明定时任务的测试模块
+type jobModule struct {
+	*module.BaseModule
+	jobs []module.Job
+}
+
+func (m *jobModule) Jobs() []module.Job { return m.jobs }
+
+func TestScheduler_SkipsDisabledModule(t *testing.T) {
+	module.Clear()
+	defer module.Clear()
+
+	var runs atomic.Int32
+	module.Register(&jobModule{
+		BaseModule: module.NewBaseModule(module.Meta{Code: "reports"}, nil),
+		jobs: []module.Job{{Name: "digest", Schedule: "@daily", Run: func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}}},
+	})
+	if err := module.InitStates(openTestDB(t), 0); err != nil {
+		t.Fatalf("InitStates() error = %v", err)
+	}
+	ctx := context.Background()
+	if _, err := module.SetEnabled(ctx, "reports", false, "maintenance", "admin"); err != nil {
+		t.Fatalf("disable reports: %v", err)
+	}
+	defer module.SetEnabled(ctx, "reports", true, "", "admin")
+
+	s, _ := New(nil)
+	for _, job := range module.GetAllJobs() {
+		if job.Module != "reports" {
+			t.Fatalf("job %s module = %q, want reports", job.Name, job.Module)
+		}
+		if err := s.Register(job); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	if job, _ := s.Job("reports.digest"); !job.Disabled {
+		t.Errorf("job of disabled module = %+v", job)
+	}
+	if err := s.Trigger("reports.digest"); !errors.Is(err, ErrModuleDisabled) {
+		t.Errorf("Trigger() error = %v, want ErrModuleDisabled", err)
+	}
+	s.runDue(time.Now().Add(48 * time.Hour))
+	s.wg.Wait()
+	if runs.Load() != 0 {
+		t.Errorf("job of disabled module ran %d times", runs.Load())
+	}
+}
@@ -0,0 +1,565 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 本文件实现了 JSON Schema (draft 2020-12) 的一个常用子集，用于校验模块配置
+//
+// 支持的关键字：
+//   - 通用：type, enum, const, default, allOf, anyOf, oneOf, not, $ref（仅本地 #/$defs/...）
+//   - 对象：properties, required, additionalProperties, minProperties, maxProperties
+//   - 数组：items, minItems, maxItems, uniqueItems
+//   - 数值：minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//   - 字符串：minLength, maxLength, pattern, format（email, uri, date-time, date, hostname, ipv4, ipv6）
+//
+// 不认识的关键字会被忽略，与规范中 annotation 的处理方式一致
+
+// SchemaError 单个字段的校验错误
+type SchemaError struct {
+	Path    string `json:"path"`    // JSON Pointer 格式的字段路径，根节点为 ""
+	Keyword string `json:"keyword"` // 触发错误的关键字
+	Message string `json:"message"`
+}
+
+// SchemaErrors 校验错误列表
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		path := err.Path
+		if path == "" {
+			path = "/"
+		}
+		msgs = append(msgs, fmt.Sprintf("%s: %s", path, err.Message))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateJSONSchema 使用 schema 校验 value
+// value 应为 encoding/json 解码得到的值（map[string]interface{}、[]interface{}、float64 等）
+// 校验通过返回 nil，否则返回 SchemaErrors
+func ValidateJSONSchema(schema map[string]interface{}, value interface{}) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	schema, _ = normalizeJSON(schema).(map[string]interface{})
+	v := &schemaValidator{root: schema}
+	v.validate(schema, normalizeJSON(value), "")
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// ApplySchemaDefaults 返回填充了 schema 默认值的配置副本，不修改原配置
+// 仅对对象的 properties 递归填充，缺失的字段使用 default，已存在的字段保持不变
+func ApplySchemaDefaults(schema map[string]interface{}, config map[string]interface{}) map[string]interface{} {
+	schema, _ = normalizeJSON(schema).(map[string]interface{})
+	v := &schemaValidator{root: schema}
+	result, _ := v.applyDefaults(schema, normalizeJSON(config)).(map[string]interface{})
+	if result == nil {
+		result = map[string]interface{}{}
+	}
+	return result
+}
+
+type schemaValidator struct {
+	root   map[string]interface{}
+	errors SchemaErrors
+	depth  int
+}
+
+func (v *schemaValidator) addError(path, keyword, format string, args ...interface{}) {
+	v.errors = append(v.errors, SchemaError{Path: path, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolve 处理 $ref，仅支持指向根 schema 内部的 JSON Pointer
+func (v *schemaValidator) resolve(schema map[string]interface{}) map[string]interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema
+		}
+		target, ok := lookupPointer(v.root, strings.TrimPrefix(ref, "#"))
+		if !ok {
+			return schema
+		}
+		schema = target
+	}
+	return schema
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, value interface{}, path string) {
+	// 防止 $ref 自引用导致无限递归
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > 64 {
+		v.addError(path, "$ref", "schema 嵌套层级过深")
+		return
+	}
+
+	schema = v.resolve(schema)
+	if ref, ok := schema["$ref"].(string); ok {
+		v.addError(path, "$ref", "无法解析引用 %s", ref)
+		return
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		v.addError(path, "type", "类型应为 %s，实际为 %s", typeNames(t), jsonTypeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(normalizeJSON(e), value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addError(path, "enum", "取值必须为以下之一: %s", compactJSON(enum))
+		}
+	}
+
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(normalizeJSON(c), value) {
+		v.addError(path, "const", "取值必须为 %s", compactJSON(c))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, val, path)
+	case []interface{}:
+		v.validateArray(schema, val, path)
+	case float64:
+		v.validateNumber(schema, val, path)
+	case string:
+		v.validateString(schema, val, path)
+	}
+
+	v.validateComposition(schema, value, path)
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, obj map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, exists := obj[name]; !exists {
+				v.addError(joinPointer(path, name), "required", "缺少必填字段 %s", name)
+			}
+		}
+	}
+
+	if n, ok := toFloat(schema["minProperties"]); ok && float64(len(obj)) < n {
+		v.addError(path, "minProperties", "字段数量不能少于 %v", n)
+	}
+	if n, ok := toFloat(schema["maxProperties"]); ok && float64(len(obj)) > n {
+		v.addError(path, "maxProperties", "字段数量不能超过 %v", n)
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := joinPointer(path, key)
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			v.validate(propSchema, obj[key], childPath)
+			continue
+		}
+		if _, ok := properties[key]; ok {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.addError(childPath, "additionalProperties", "不允许的字段 %s", key)
+			}
+		case map[string]interface{}:
+			v.validate(additional, obj[key], childPath)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, arr []interface{}, path string) {
+	if n, ok := toFloat(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.addError(path, "minItems", "元素数量不能少于 %v", n)
+	}
+	if n, ok := toFloat(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.addError(path, "maxItems", "元素数量不能超过 %v", n)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					v.addError(joinPointer(path, strconv.Itoa(j)), "uniqueItems", "与第 %d 个元素重复", i)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			v.validate(items, item, joinPointer(path, strconv.Itoa(i)))
+		}
+	}
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, n float64, path string) {
+	if min, ok := toFloat(schema["minimum"]); ok && n < min {
+		v.addError(path, "minimum", "不能小于 %v", min)
+	}
+	if max, ok := toFloat(schema["maximum"]); ok && n > max {
+		v.addError(path, "maximum", "不能大于 %v", max)
+	}
+	if min, ok := toFloat(schema["exclusiveMinimum"]); ok && n <= min {
+		v.addError(path, "exclusiveMinimum", "必须大于 %v", min)
+	}
+	if max, ok := toFloat(schema["exclusiveMaximum"]); ok && n >= max {
+		v.addError(path, "exclusiveMaximum", "必须小于 %v", max)
+	}
+	if m, ok := toFloat(schema["multipleOf"]); ok && m > 0 {
+		q := n / m
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			v.addError(path, "multipleOf", "必须是 %v 的倍数", m)
+		}
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, s string, path string) {
+	length := float64(utf8.RuneCountInString(s))
+	if min, ok := toFloat(schema["minLength"]); ok && length < min {
+		v.addError(path, "minLength", "长度不能少于 %v 个字符", min)
+	}
+	if max, ok := toFloat(schema["maxLength"]); ok && length > max {
+		v.addError(path, "maxLength", "长度不能超过 %v 个字符", max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.addError(path, "pattern", "schema 中的正则表达式无效: %s", pattern)
+		} else if !re.MatchString(s) {
+			v.addError(path, "pattern", "格式不匹配 %s", pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !matchesFormat(format, s) {
+		v.addError(path, "format", "不是有效的 %s 格式", format)
+	}
+}
+
+func (v *schemaValidator) validateComposition(schema map[string]interface{}, value interface{}, path string) {
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				v.validate(subSchema, value, path)
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok && v.matches(subSchema, value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.addError(path, "anyOf", "不满足 anyOf 中的任何一个 schema")
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok && v.matches(subSchema, value, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.addError(path, "oneOf", "必须恰好满足 oneOf 中的一个 schema，实际满足 %d 个", count)
+		}
+	}
+
+	if not, ok := schema["not"].(map[string]interface{}); ok && v.matches(not, value, path) {
+		v.addError(path, "not", "不能满足 not 中的 schema")
+	}
+}
+
+// matches 判断 value 是否满足子 schema，不记录错误
+func (v *schemaValidator) matches(schema map[string]interface{}, value interface{}, path string) bool {
+	sub := &schemaValidator{root: v.root, depth: v.depth}
+	sub.validate(schema, value, path)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) applyDefaults(schema map[string]interface{}, value interface{}) interface{} {
+	v.depth++
+	defer func() { v.depth-- }()
+	if v.depth > 64 {
+		return value
+	}
+
+	schema = v.resolve(schema)
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	result := make(map[string]interface{}, len(obj))
+	for k, val := range obj {
+		result[k] = val
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	for key, raw := range properties {
+		propSchema, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		propSchema = v.resolve(propSchema)
+
+		current, exists := result[key]
+		if !exists {
+			if def, ok := propSchema["default"]; ok {
+				result[key] = normalizeJSON(def)
+				current, exists = result[key], true
+			} else if isObjectSchema(propSchema) {
+				// 没有默认值的对象，如果子字段有默认值则创建空对象来承载
+				filled, _ := v.applyDefaults(propSchema, map[string]interface{}{}).(map[string]interface{})
+				if len(filled) > 0 {
+					result[key] = filled
+				}
+				continue
+			}
+		}
+		if exists {
+			result[key] = v.applyDefaults(propSchema, current)
+		}
+	}
+	return result
+}
+
+func isObjectSchema(schema map[string]interface{}) bool {
+	if t, ok := schema["type"].(string); ok {
+		return t == "object"
+	}
+	_, ok := schema["properties"]
+	return ok
+}
+
+// matchesType 判断值是否符合 type 关键字（字符串或字符串数组）
+func matchesType(t interface{}, value interface{}) bool {
+	switch tt := t.(type) {
+	case string:
+		return matchesSingleType(tt, value)
+	case []interface{}:
+		for _, item := range tt {
+			if s, ok := item.(string); ok && matchesSingleType(s, value) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, s := range tt {
+			if matchesSingleType(s, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(t string, value interface{}) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeOf(value) == t
+	}
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+func typeNames(t interface{}) string {
+	switch tt := t.(type) {
+	case string:
+		return tt
+	case []interface{}:
+		names := make([]string, 0, len(tt))
+		for _, item := range tt {
+			names = append(names, fmt.Sprint(item))
+		}
+		return strings.Join(names, "|")
+	case []string:
+		return strings.Join(tt, "|")
+	}
+	return fmt.Sprint(t)
+}
+
+var hostnamePattern = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+func matchesFormat(format, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "hostname":
+		return len(s) <= 253 && hostnamePattern.MatchString(s)
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && strings.Contains(s, ":")
+	}
+	// 未知格式按规范视为注解，不做校验
+	return true
+}
+
+// normalizeJSON 将任意值转换为 encoding/json 解码后的标准形式
+// 例如 Go 代码中声明的 int、[]string 会被转换为 float64、[]interface{}
+func normalizeJSON(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, bool, float64, string, map[string]interface{}, []interface{}:
+		if !containsNonJSON(value) {
+			return value
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
+
+func containsNonJSON(value interface{}) bool {
+	switch val := value.(type) {
+	case nil, bool, float64, string:
+		return false
+	case map[string]interface{}:
+		for _, item := range val {
+			if containsNonJSON(item) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, item := range val {
+			if containsNonJSON(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// joinPointer 拼接 JSON Pointer 路径，按 RFC 6901 转义 ~ 和 /
+func joinPointer(base, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return base + "/" + token
+}
+
+// lookupPointer 在 schema 中按 JSON Pointer 查找子 schema
+func lookupPointer(root map[string]interface{}, pointer string) (map[string]interface{}, bool) {
+	if pointer == "" {
+		return root, true
+	}
+	var current interface{} = root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[token]
+		if !ok {
+			return nil, false
+		}
+	}
+	result, ok := current.(map[string]interface{})
+	return result, ok
+}
@@ -0,0 +1,151 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func mustJSON(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid test JSON: %v", err)
+	}
+	return v
+}
+
+const testSchema = `{
+	"type": "object",
+	"required": ["provider"],
+	"additionalProperties": false,
+	"properties": {
+		"provider": {"type": "string", "enum": ["mock", "fcm"]},
+		"batch_size": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 500},
+		"webhook_url": {"type": "string", "format": "uri"},
+		"tags": {"type": "array", "items": {"type": "string", "minLength": 2}, "uniqueItems": true},
+		"retry": {
+			"type": "object",
+			"properties": {
+				"max": {"type": "integer", "default": 3},
+				"backoff": {"$ref": "#/$defs/duration"}
+			}
+		}
+	},
+	"$defs": {
+		"duration": {"type": "string", "pattern": "^[0-9]+(ms|s|m)$", "default": "1s"}
+	}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := mustJSON(t, testSchema)
+
+	tests := []struct {
+		name      string
+		config    string
+		wantPaths []string
+	}{
+		{
+			name:   "valid",
+			config: `{"provider": "fcm", "batch_size": 100, "tags": ["ab", "cd"], "retry": {"backoff": "500ms"}}`,
+		},
+		{
+			name:      "missing required and wrong type",
+			config:    `{"batch_size": "100"}`,
+			wantPaths: []string{"/batch_size", "/provider"},
+		},
+		{
+			name:      "enum, range and format",
+			config:    `{"provider": "apns", "batch_size": 1.5, "webhook_url": "not a url"}`,
+			wantPaths: []string{"/batch_size", "/provider", "/webhook_url"},
+		},
+		{
+			name:      "nested array and ref",
+			config:    `{"provider": "mock", "tags": ["a", "bb", "bb"], "retry": {"backoff": "soon"}}`,
+			wantPaths: []string{"/retry/backoff", "/tags/0", "/tags/2"},
+		},
+		{
+			name:      "additional property",
+			config:    `{"provider": "mock", "extra": true}`,
+			wantPaths: []string{"/extra"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateJSONSchema(schema, mustJSON(t, tt.config))
+			if len(tt.wantPaths) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateJSONSchema() error = %v", err)
+				}
+				return
+			}
+
+			var errs SchemaErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("ValidateJSONSchema() error = %v, want SchemaErrors", err)
+			}
+			var paths []string
+			for _, e := range errs {
+				paths = append(paths, e.Path)
+			}
+			sort.Strings(paths)
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("error paths = %v, want %v (%v)", paths, tt.wantPaths, err)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_Composition(t *testing.T) {
+	schema := mustJSON(t, `{
+		"oneOf": [
+			{"type": "object", "required": ["token"]},
+			{"type": "object", "required": ["key_id", "team_id"]}
+		],
+		"not": {"required": ["forbidden"]}
+	}`)
+
+	if err := ValidateJSONSchema(schema, mustJSON(t, `{"token": "x"}`)); err != nil {
+		t.Errorf("oneOf match error = %v", err)
+	}
+	if err := ValidateJSONSchema(schema, mustJSON(t, `{"token": "x", "key_id": "k", "team_id": "t"}`)); err == nil {
+		t.Error("matching both oneOf branches should fail")
+	}
+	if err := ValidateJSONSchema(schema, mustJSON(t, `{"token": "x", "forbidden": 1}`)); err == nil {
+		t.Error("not should fail")
+	}
+}
+
+func TestValidateJSONSchema_GoDeclaredSchema(t *testing.T) {
+	// 模块在代码中声明的 schema 使用 int、[]string 等 Go 类型
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"max_size"},
+		"properties": map[string]interface{}{
+			"max_size": map[string]interface{}{"type": "integer", "minimum": 1},
+		},
+	}
+	if err := ValidateJSONSchema(schema, map[string]interface{}{"max_size": float64(0)}); err == nil {
+		t.Error("minimum declared as int should be enforced")
+	}
+	if err := ValidateJSONSchema(schema, map[string]interface{}{}); err == nil {
+		t.Error("required declared as []string should be enforced")
+	}
+}
+
+func TestApplySchemaDefaults(t *testing.T) {
+	schema := mustJSON(t, testSchema)
+	config := mustJSON(t, `{"provider": "fcm", "batch_size": 10}`)
+
+	got := ApplySchemaDefaults(schema, config)
+	want := mustJSON(t, `{"provider": "fcm", "batch_size": 10, "retry": {"max": 3, "backoff": "1s"}}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplySchemaDefaults() = %v, want %v", got, want)
+	}
+	if _, ok := config["retry"]; ok {
+		t.Error("ApplySchemaDefaults() must not modify the input config")
+	}
+}
@@ -5,10 +5,14 @@ import (
 )
 
 // ValidateModuleConfig 验证模块配置
-func ValidateModuleConfig(moduleCode string, config map[string]interface{}) error {
+// schema 为模块功能声明的 ConfigSchema（JSON Schema），为空时不做结构校验
+// 校验失败时返回 SchemaErrors，包含每个字段的错误路径
+func ValidateModuleConfig(moduleCode string, schema map[string]interface{}, config map[string]interface{}) error {
 	if moduleCode == "" {
 		return fmt.Errorf("module code is required")
 	}
-	// 简单验证，实际可以根据模块类型做更复杂的验证
-	return nil
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	return ValidateJSONSchema(schema, config)
 }
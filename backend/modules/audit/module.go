@@ -1,11 +1,18 @@
 package audit
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"app-platform-backend/core/module"
 	auditapi "app-platform-backend/internal/api/v1/audit"
 	"app-platform-backend/internal/pkg/database"
+	"app-platform-backend/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func init() { module.Register(&AuditModule{}) }
@@ -13,7 +20,7 @@ func init() { module.Register(&AuditModule{}) }
 type AuditModule struct{}
 
 func (m *AuditModule) Meta() module.Meta {
-	return module.Meta{Code: "audit_log", Name: "审计日志", Description: "操作审计日志模块", Icon: "shield", SortOrder: 11}
+	return module.Meta{Code: "audit_log", Name: "审计日志", Description: "操作审计日志模块", Icon: "shield", SortOrder: 11, Global: true}
 }
 
 func (m *AuditModule) GetFunctions() []module.Function {
@@ -40,3 +47,102 @@ func (m *AuditModule) RegisterRoutes(group *gin.RouterGroup) {
 }
 
 func (m *AuditModule) Init() error { return nil }
+
+// HealthChecks 清理任务停止调度后日志会持续堆积，视为功能降级
+func (m *AuditModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{
+		{
+			Name: "cleanup_job",
+			Check: func(ctx context.Context) error {
+				s := scheduler.Default()
+				if s == nil || !s.IsRunning() {
+					return errors.New("job scheduler is not running")
+				}
+				job, err := s.Job(scheduler.AuditCleanupJobName)
+				if err != nil {
+					return fmt.Errorf("audit cleanup job: %w", err)
+				}
+				if job.Paused {
+					return errors.New("audit cleanup job is paused")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Jobs 审计日志定期清理任务
+func (m *AuditModule) Jobs() []module.Job {
+	cleanup := scheduler.GetAuditCleanup()
+	if cleanup == nil {
+		return nil
+	}
+	return []module.Job{cleanup.Job()}
+}
+
+// Migrations 审计日志及清理记录表结构
+func (m *AuditModule) Migrations() []module.Migration {
+	return []module.Migration{
+		{
+			Version:     202610170001,
+			Description: "create audit_logs",
+			Up: func(tx *gorm.DB) error {
+				// 之前由 audit.InitDB 的 AutoMigrate 创建，已存在的表保持不变
+				return module.CreateTableIfNotExists(tx, &auditLogV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("audit_logs")
+			},
+		},
+		{
+			Version:     202610170002,
+			Description: "create cleanup_records",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateTableIfNotExists(tx, &cleanupRecordV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("cleanup_records")
+			},
+		},
+	}
+}
+
+// 以下是建表时的表结构快照，不随模型变更而改变，之后的表结构变更写成新的迁移
+
+type auditLogV1 struct {
+	ID            uint   `gorm:"primaryKey"`
+	AppID         uint   `gorm:"index"`
+	UserID        string `gorm:"index"`
+	UserName      string
+	Action        string `gorm:"index"`
+	Resource      string `gorm:"index"`
+	ResourceID    string
+	Description   string
+	IPAddress     string
+	UserAgent     string
+	RequestPath   string
+	RequestMethod string
+	StatusCode    int
+	Duration      int64
+	Extra         string    `gorm:"type:text"`
+	CreatedAt     time.Time `gorm:"index"`
+}
+
+func (auditLogV1) TableName() string {
+	return "audit_logs"
+}
+
+type cleanupRecordV1 struct {
+	ID          uint `gorm:"primaryKey"`
+	CleanupTime time.Time
+	DeletedRows int64
+	CutoffDate  time.Time
+	Duration    int64
+	Status      string
+	ErrorMsg    string
+	CreatedAt   time.Time
+}
+
+func (cleanupRecordV1) TableName() string {
+	return "cleanup_records"
+}
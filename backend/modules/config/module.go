@@ -1,30 +1,288 @@
 package config
 
 import (
-"app-platform-backend/core/module"
-configapi "app-platform-backend/internal/api/v1/config"
-"github.com/gin-gonic/gin"
+	"fmt"
+	"time"
+
+	"app-platform-backend/core/module"
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func init() { module.Register(&ConfigModule{}) }
+
 type ConfigModule struct{}
+
 func (m *ConfigModule) Meta() module.Meta {
-return module.Meta{Code: "config_management", Name: "配置管理", Description: "配置管理模块", Icon: "settings", SortOrder: 8}
+	return module.Meta{Code: "config_management", Name: "配置管理", Description: "配置管理模块", Icon: "settings", SortOrder: 8}
 }
+
 func (m *ConfigModule) GetFunctions() []module.Function {
-return []module.Function{
-{Code: "config_list", Name: "配置列表", Type: "passive", Description: "获取配置列表"},
-{Code: "config_create", Name: "创建配置", Type: "active", Description: "创建新配置"},
-{Code: "config_update", Name: "更新配置", Type: "active", Description: "更新配置"},
-{Code: "config_publish", Name: "发布配置", Type: "active", Description: "发布配置"},
-{Code: "config_history", Name: "配置历史", Type: "passive", Description: "查看配置历史"},
-}
+	return []module.Function{
+		{Code: "config_list", Name: "配置列表", Type: "passive", Description: "获取配置列表"},
+		{Code: "config_create", Name: "创建配置", Type: "active", Description: "创建新配置"},
+		{Code: "config_update", Name: "更新配置", Type: "active", Description: "更新配置"},
+		{Code: "config_delete", Name: "删除配置", Type: "active", Description: "删除配置"},
+		{Code: "config_publish", Name: "发布配置", Type: "active", Description: "将全部配置快照发布"},
+		{Code: "config_rollback", Name: "回滚配置", Type: "active", Description: "回滚到历史发布"},
+		{Code: "config_environment", Name: "配置环境", Type: "active", Description: "管理环境及其继承关系"},
+		{Code: "config_promote", Name: "配置晋级", Type: "active", Description: "将命名空间的配置从一个环境复制到另一个环境"},
+		{Code: "config_history", Name: "配置历史", Type: "passive", Description: "查看配置历史"},
+	}
 }
+
 func (m *ConfigModule) RegisterRoutes(group *gin.RouterGroup) {
-group.GET("/configs", configapi.List)
-group.POST("/configs", configapi.Create)
-group.PUT("/configs/:id", configapi.Update)
-group.POST("/configs/:id/publish", configapi.Publish)
-group.GET("/configs/:id/history", configapi.History)
+	group.GET("/configs", configapi.List)
+	group.POST("/configs", configapi.Create)
+	group.GET("/configs/history", configapi.History)
+	group.GET("/configs/environments", configapi.Environments)
+	group.POST("/configs/environments", configapi.CreateEnvironment)
+	group.DELETE("/configs/environments/:env_id", configapi.DeleteEnvironment)
+	group.GET("/configs/namespaces", configapi.Namespaces)
+	group.GET("/configs/effective", configapi.Effective)
+	group.POST("/configs/promote", configapi.Promote)
+	group.POST("/configs/publish", configapi.Publish)
+	group.GET("/configs/releases", configapi.Releases)
+	group.GET("/configs/releases/latest", configapi.LatestRelease)
+	group.GET("/configs/releases/:release_id", configapi.Release)
+	group.POST("/configs/releases/:release_id/rollback", configapi.Rollback)
+	group.GET("/configs/:id", configapi.Detail)
+	group.PUT("/configs/:id", configapi.Update)
+	group.DELETE("/configs/:id", configapi.Delete)
+	group.GET("/configs/:id/history", configapi.History)
+}
+
+// RegisterSDKRoutes 客户端获取已发布配置，支持长轮询
+func (m *ConfigModule) RegisterSDKRoutes(group *gin.RouterGroup) {
+	group.GET("/configs", configapi.SDKFetch)
+}
+
+func (m *ConfigModule) Init() error {
+	configapi.InitDB(database.GetDB())
+	return nil
+}
+
+// Migrations 配置中心表结构
+// 迁移使用建表或加列时的表结构快照，不随 model 包中的模型变更而改变
+func (m *ConfigModule) Migrations() []module.Migration {
+	return []module.Migration{
+		{
+			Version:     202610170001,
+			Description: "create configs and config_histories",
+			Up: func(tx *gorm.DB) error {
+				if err := module.CreateTableIfNotExists(tx, &configV1{}, &configHistoryV1{}); err != nil {
+					return err
+				}
+				// 旧版本按当时的模型建过这两张表，补上配置中心新增的列
+				if err := module.AddColumnsIfNotExists(tx, &configV1{}, "ValueType", "UpdatedBy"); err != nil {
+					return err
+				}
+				return module.AddColumnsIfNotExists(tx, &configHistoryV1{},
+					"AppID", "ConfigKey", "OldValue", "ValueType", "Operator", "ReleaseID")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("config_histories", "configs")
+			},
+		},
+		{
+			Version:     202610170002,
+			Description: "create config_releases",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateTableIfNotExists(tx, &configReleaseV1{})
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("config_releases")
+			},
+		},
+		{
+			Version:     202610170003,
+			Description: "add config environments and namespaces",
+			Up: func(tx *gorm.DB) error {
+				if err := module.CreateTableIfNotExists(tx, &configEnvironmentV1{}); err != nil {
+					return err
+				}
+				// 已有配置和发布通过列默认值归入 default 环境的 application 命名空间
+				for _, scoped := range []interface{}{&configScopeV3{}, &configHistoryScopeV3{}, &configReleaseScopeV3{}} {
+					if err := module.AddColumnsIfNotExists(tx, scoped, "Environment", "Namespace"); err != nil {
+						return err
+					}
+				}
+				if err := module.CreateIndexIfNotExists(tx, "configs", "idx_configs_scope", "app_id, environment, namespace"); err != nil {
+					return err
+				}
+				if err := module.CreateIndexIfNotExists(tx, "config_releases", "idx_config_releases_scope", "app_id, environment, namespace, version"); err != nil {
+					return err
+				}
+				if tx.Migrator().HasIndex("config_releases", "idx_config_releases_app_version") {
+					return tx.Migrator().DropIndex("config_releases", "idx_config_releases_app_version")
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				m := tx.Migrator()
+				if err := m.DropIndex("configs", "idx_configs_scope"); err != nil {
+					return err
+				}
+				if err := m.DropIndex("config_releases", "idx_config_releases_scope"); err != nil {
+					return err
+				}
+				for _, scoped := range []interface{}{&configScopeV3{}, &configHistoryScopeV3{}, &configReleaseScopeV3{}} {
+					if err := m.DropColumn(scoped, "Environment"); err != nil {
+						return err
+					}
+					if err := m.DropColumn(scoped, "Namespace"); err != nil {
+						return err
+					}
+				}
+				if err := module.CreateIndexIfNotExists(tx, "config_releases", "idx_config_releases_app_version", "app_id, version"); err != nil {
+					return err
+				}
+				return m.DropTable("config_environments")
+			},
+		},
+		{
+			Version:     202610170004,
+			Description: "make config keys and release versions unique per scope",
+			Up: func(tx *gorm.DB) error {
+				// 删除的配置改为物理删除，之前软删除的记录已不可见，清理后才能建立唯一索引
+				if err := tx.Exec("DELETE FROM configs WHERE deleted_at IS NOT NULL").Error; err != nil {
+					return err
+				}
+				if err := module.CreateUniqueIndexIfNotExists(tx, "configs", "idx_configs_scope_key", "app_id, environment, namespace, config_key"); err != nil {
+					return fmt.Errorf("duplicate config keys must be resolved before migrating: %w", err)
+				}
+				if err := module.CreateUniqueIndexIfNotExists(tx, "config_releases", "idx_config_releases_scope_version", "app_id, environment, namespace, version"); err != nil {
+					return fmt.Errorf("duplicate release versions must be resolved before migrating: %w", err)
+				}
+				// 唯一索引的前缀可以代替原来的范围索引
+				m := tx.Migrator()
+				if m.HasIndex("configs", "idx_configs_scope") {
+					if err := m.DropIndex("configs", "idx_configs_scope"); err != nil {
+						return err
+					}
+				}
+				if m.HasIndex("config_releases", "idx_config_releases_scope") {
+					return m.DropIndex("config_releases", "idx_config_releases_scope")
+				}
+				return nil
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := module.CreateIndexIfNotExists(tx, "configs", "idx_configs_scope", "app_id, environment, namespace"); err != nil {
+					return err
+				}
+				if err := module.CreateIndexIfNotExists(tx, "config_releases", "idx_config_releases_scope", "app_id, environment, namespace, version"); err != nil {
+					return err
+				}
+				m := tx.Migrator()
+				if err := m.DropIndex("configs", "idx_configs_scope_key"); err != nil {
+					return err
+				}
+				return m.DropIndex("config_releases", "idx_config_releases_scope_version")
+			},
+		},
+	}
+}
+
+// 以下是建表或加列时的表结构快照，之后的表结构变更写成新的迁移
+
+type configV1 struct {
+	ID          uint   `gorm:"primarykey"`
+	AppID       uint   `gorm:"index"`
+	ConfigKey   string `gorm:"size:255"`
+	ConfigValue string `gorm:"type:text"`
+	ValueType   string `gorm:"size:20"`
+	Description string `gorm:"type:text"`
+	IsPublished int    `gorm:"default:0"`
+	PublishedAt *time.Time
+	UpdatedBy   string `gorm:"size:50"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (configV1) TableName() string {
+	return "configs"
+}
+
+type configHistoryV1 struct {
+	ID          uint   `gorm:"primarykey"`
+	ConfigID    uint   `gorm:"index"`
+	AppID       uint   `gorm:"index"`
+	ConfigKey   string `gorm:"size:255"`
+	OldValue    string `gorm:"type:text"`
+	ConfigValue string `gorm:"type:text"`
+	ValueType   string `gorm:"size:20"`
+	OperatorID  *uint
+	Operator    string `gorm:"size:50"`
+	Operation   string `gorm:"size:50"`
+	ReleaseID   uint
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+func (configHistoryV1) TableName() string {
+	return "config_histories"
+}
+
+type configReleaseV1 struct {
+	ID           uint   `gorm:"primarykey"`
+	AppID        uint   `gorm:"index:idx_config_releases_app_version"`
+	Version      int    `gorm:"index:idx_config_releases_app_version"`
+	Snapshot     string `gorm:"type:json"`
+	KeyCount     int
+	Remark       string `gorm:"size:255"`
+	RollbackFrom int
+	PublishedBy  string `gorm:"size:50"`
+	CreatedAt    time.Time
+}
+
+func (configReleaseV1) TableName() string {
+	return "config_releases"
+}
+
+type configEnvironmentV1 struct {
+	ID              uint   `gorm:"primarykey"`
+	AppID           uint   `gorm:"uniqueIndex:idx_config_environments_app_name"`
+	Name            string `gorm:"size:50;uniqueIndex:idx_config_environments_app_name"`
+	BaseEnvironment string `gorm:"size:50"`
+	Description     string `gorm:"size:255"`
+	CreatedBy       string `gorm:"size:50"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (configEnvironmentV1) TableName() string {
+	return "config_environments"
+}
+
+// configScopeV3 configs 新增的环境和命名空间列
+type configScopeV3 struct {
+	Environment string `gorm:"size:50;default:default"`
+	Namespace   string `gorm:"size:50;default:application"`
+}
+
+func (configScopeV3) TableName() string {
+	return "configs"
+}
+
+// configHistoryScopeV3 config_histories 新增的环境和命名空间列
+type configHistoryScopeV3 struct {
+	Environment string `gorm:"size:50;default:default"`
+	Namespace   string `gorm:"size:50;default:application"`
+}
+
+func (configHistoryScopeV3) TableName() string {
+	return "config_histories"
+}
+
+// configReleaseScopeV3 config_releases 新增的环境和命名空间列
+type configReleaseScopeV3 struct {
+	Environment string `gorm:"size:50;default:default"`
+	Namespace   string `gorm:"size:50;default:application"`
+}
+
+func (configReleaseScopeV3) TableName() string {
+	return "config_releases"
 }
-func (m *ConfigModule) Init() error { return nil }
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/core/module/moduletest"
+	configapi "app-platform-backend/internal/api/v1/config"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfigModule_PublishAndRollback(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"config_management"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "config_management")
+
+	// 值需要与声明的类型一致
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "timeout", "config_value": "abc", "value_type": "number"}).
+		AssertStatus(http.StatusBadRequest)
+
+	var timeout, banner model.Config
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "timeout", "config_value": "30", "value_type": "number"}).AssertOK().Data(&timeout)
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "banner", "config_value": `{"show": true}`, "value_type": "json"}).AssertOK().Data(&banner)
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "timeout", "config_value": "10", "value_type": "number"}).
+		AssertStatus(http.StatusConflict)
+
+	var v1 model.ConfigRelease
+	h.Post("/configs/publish", gin.H{"app_id": app.ID, "remark": "first"}).AssertOK().Data(&v1)
+	if v1.Version != 1 || v1.KeyCount != 2 {
+		t.Fatalf("first release = %+v", v1)
+	}
+	h.Post("/configs/publish", gin.H{"app_id": app.ID}).AssertStatus(http.StatusConflict)
+
+	// 修改、删除后再次发布
+	h.Put(fmt.Sprintf("/configs/%d?app_id=%d", timeout.ID, app.ID), gin.H{"config_value": "60"}).AssertOK()
+	h.Delete(fmt.Sprintf("/configs/%d?app_id=%d", banner.ID, app.ID)).AssertOK()
+	var v2 model.ConfigRelease
+	h.Post("/configs/publish", gin.H{"app_id": app.ID}).AssertOK().Data(&v2)
+
+	var latest configapi.ReleaseDetail
+	h.Get(fmt.Sprintf("/configs/releases/latest?app_id=%d", app.ID)).AssertOK().Data(&latest)
+	if latest.Version != 2 || len(latest.Values) != 1 || latest.Values["timeout"] != float64(60) {
+		t.Fatalf("latest release = %+v", latest)
+	}
+
+	// 回滚到 v1：恢复被删除的配置和旧值，并生成新的发布
+	var v3 model.ConfigRelease
+	h.Post(fmt.Sprintf("/configs/releases/%d/rollback?app_id=%d", v1.ID, app.ID), nil).AssertOK().Data(&v3)
+	if v3.Version != 3 || v3.RollbackFrom != 1 {
+		t.Fatalf("rollback release = %+v", v3)
+	}
+	h.Get(fmt.Sprintf("/configs/releases/latest?app_id=%d", app.ID)).AssertOK().Data(&latest)
+	if latest.Values["timeout"] != float64(30) || latest.Values["banner"] == nil {
+		t.Errorf("values after rollback = %v", latest.Values)
+	}
+
+	var page struct {
+		List  []model.ConfigHistory `json:"list"`
+		Total int64                 `json:"total"`
+	}
+	h.Get(fmt.Sprintf("/configs/history?app_id=%d&config_key=timeout", app.ID)).AssertOK().Data(&page)
+	var operations []string
+	for _, item := range page.List {
+		operations = append(operations, item.Operation)
+		if item.Operator == "" {
+			t.Errorf("history %d has no operator", item.ID)
+		}
+	}
+	if fmt.Sprint(operations) != "[rollback update create]" {
+		t.Errorf("timeout history = %v", operations)
+	}
+}
+
+func TestConfigModule_SDKFetch(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"config_management"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "config_management")
+
+	// 未签名或签名错误
+	h.Get("/sdk/configs").AssertStatus(http.StatusUnauthorized)
+	forged := *app
+	forged.AppSecret = "wrong"
+	h.SDKGet(&forged, "/configs", nil).AssertStatus(http.StatusUnauthorized)
+
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "dark_mode", "config_value": "true", "value_type": "bool"}).AssertOK()
+	h.Post("/configs/publish", gin.H{"app_id": app.ID}).AssertOK()
+
+	var cfg configapi.SDKConfig
+	resp := h.SDKGet(app, "/configs", nil).AssertOK()
+	resp.Data(&cfg)
+	tag := resp.Header.Get("ETag")
+	if cfg.Version != 1 || cfg.Values["dark_mode"] != true || tag == "" {
+		t.Fatalf("sdk config = %+v, etag %q", cfg, tag)
+	}
+	h.SDKGet(app, "/configs", http.Header{"If-None-Match": {tag}}).AssertStatus(http.StatusNotModified)
+
+	// 长轮询：新的发布立即唤醒挂起的请求
+	result := make(chan *moduletest.Response, 1)
+	go func() {
+		result <- h.SDKGet(app, "/configs?wait=10", http.Header{"If-None-Match": {tag}})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "theme", "config_value": "blue"}).AssertOK()
+	h.Post("/configs/publish", gin.H{"app_id": app.ID}).AssertOK()
+
+	select {
+	case resp := <-result:
+		resp.AssertOK().Data(&cfg)
+		if cfg.Version != 2 || cfg.Values["theme"] != "blue" || resp.Header.Get("ETag") == tag {
+			t.Errorf("long poll result = %+v", cfg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long poll was not woken by publish")
+	}
+}
+
+func TestConfigModule_Environments(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"config_management"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "config_management")
+
+	h.Post("/configs/environments", gin.H{"app_id": app.ID, "name": "prod", "base_environment": "staging"}).
+		AssertStatus(http.StatusBadRequest)
+	h.Post("/configs/environments", gin.H{"app_id": app.ID, "name": "staging"}).AssertOK()
+	h.Post("/configs/environments", gin.H{"app_id": app.ID, "name": "prod", "base_environment": "staging"}).AssertOK()
+	h.Post("/configs/environments", gin.H{"app_id": app.ID, "name": "prod"}).AssertStatus(http.StatusConflict)
+
+	// default 中的值被 staging、prod 继承，staging 覆盖 api_host
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "api_host", "config_value": "api.example.com"}).AssertOK()
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "retries", "config_value": "3", "value_type": "number"}).AssertOK()
+	h.Post("/configs", gin.H{"app_id": app.ID, "environment": "staging", "config_key": "api_host", "config_value": "staging.example.com"}).AssertOK()
+	// 不同命名空间中的同名键互不影响
+	h.Post("/configs", gin.H{"app_id": app.ID, "namespace": "ui", "config_key": "api_host", "config_value": "x"}).AssertOK()
+
+	var effective struct {
+		Items []configapi.EffectiveItem `json:"items"`
+	}
+	h.Get(fmt.Sprintf("/configs/effective?app_id=%d&environment=prod", app.ID)).AssertOK().Data(&effective)
+	if len(effective.Items) != 2 || effective.Items[0].Value != "staging.example.com" || effective.Items[0].Source != "staging" ||
+		!effective.Items[1].Inherited || effective.Items[1].TypedValue != float64(3) {
+		t.Fatalf("prod effective = %+v", effective.Items)
+	}
+	h.Get(fmt.Sprintf("/configs/effective?app_id=%d&environment=qa", app.ID)).AssertStatus(http.StatusNotFound)
+
+	// 各环境独立发布，SDK 按环境读取
+	h.Post("/configs/publish", gin.H{"app_id": app.ID, "environment": "prod"}).AssertOK()
+	var cfg configapi.SDKConfig
+	h.SDKGet(app, "/configs?environment=prod", nil).AssertOK().Data(&cfg)
+	if cfg.Version != 1 || cfg.Values["api_host"] != "staging.example.com" {
+		t.Fatalf("prod sdk config = %+v", cfg)
+	}
+	var unpublished configapi.SDKConfig
+	h.SDKGet(app, "/configs", nil).AssertOK().Data(&unpublished)
+	if unpublished.Version != 0 || len(unpublished.Values) != 0 {
+		t.Fatalf("unpublished default sdk config = %+v", unpublished)
+	}
+
+	// 晋级：staging 的生效配置复制到 default，预览不修改数据
+	var plan struct {
+		Changes []configapi.PromoteChange `json:"changes"`
+	}
+	h.Post("/configs/promote", gin.H{"app_id": app.ID, "from": "staging", "to": "default", "dry_run": true}).AssertOK().Data(&plan)
+	if len(plan.Changes) != 1 || plan.Changes[0].Key != "api_host" || plan.Changes[0].Action != "update" {
+		t.Fatalf("promote plan = %+v", plan.Changes)
+	}
+	var page struct {
+		List []model.Config `json:"list"`
+	}
+	h.Get(fmt.Sprintf("/configs?app_id=%d&keyword=api_host", app.ID)).AssertOK().Data(&page)
+	if page.List[0].ConfigValue != "api.example.com" {
+		t.Fatalf("dry run changed config: %+v", page.List[0])
+	}
+	h.Post("/configs/promote", gin.H{"app_id": app.ID, "from": "staging", "to": "default"}).AssertOK()
+	h.Get(fmt.Sprintf("/configs?app_id=%d&keyword=api_host", app.ID)).AssertOK().Data(&page)
+	if page.List[0].ConfigValue != "staging.example.com" {
+		t.Errorf("promoted config = %+v", page.List[0])
+	}
+
+	// 环境中仍有配置时不能删除
+	var envs []model.ConfigEnvironment
+	h.Get(fmt.Sprintf("/configs/environments?app_id=%d", app.ID)).AssertOK().Data(&envs)
+	if len(envs) != 3 || envs[0].Name != "default" {
+		t.Fatalf("environments = %+v", envs)
+	}
+	for _, env := range envs {
+		if env.Name == "staging" {
+			h.Delete(fmt.Sprintf("/configs/environments/%d?app_id=%d", env.ID, app.ID)).AssertStatus(http.StatusConflict)
+		}
+	}
+}
+
+func TestConfigModule_EnvironmentMigrationRoundTrip(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"config_management"}})
+	runner := module.NewMigrationRunner(h.DB)
+
+	// 回滚唯一索引和环境、命名空间迁移后，表结构应回到 002 的状态
+	if _, err := runner.Down("config_management", 2); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	m := h.DB.Migrator()
+	if m.HasColumn("configs", "environment") || m.HasTable("config_environments") {
+		t.Fatal("environment schema still present after down")
+	}
+	if !m.HasIndex("config_releases", "idx_config_releases_app_version") {
+		t.Error("idx_config_releases_app_version not restored")
+	}
+
+	if _, err := runner.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	for _, table := range []string{"configs", "config_histories", "config_releases"} {
+		if !m.HasColumn(table, "environment") || !m.HasColumn(table, "namespace") {
+			t.Errorf("%s missing environment or namespace after up", table)
+		}
+	}
+	if !m.HasIndex("configs", "idx_configs_scope_key") || !m.HasIndex("config_releases", "idx_config_releases_scope_version") {
+		t.Error("scope indexes missing after up")
+	}
+}
+
+func TestConfigModule_UniqueKeys(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"config_management"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "config_management")
+
+	// 删除后可以重新创建同名配置键
+	var cfg model.Config
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "timeout", "config_value": "30", "value_type": "number"}).AssertOK().Data(&cfg)
+	h.Delete(fmt.Sprintf("/configs/%d?app_id=%d", cfg.ID, app.ID)).AssertOK()
+	h.Post("/configs", gin.H{"app_id": app.ID, "config_key": "timeout", "config_value": "60", "value_type": "number"}).AssertOK()
+
+	// 绕过存在性检查的并发写入由唯一索引拦截
+	err := h.DB.Create(&model.Config{AppID: app.ID, Environment: "default", Namespace: "application", ConfigKey: "timeout"}).Error
+	if !database.IsDuplicateKey(err) {
+		t.Errorf("duplicate config key error = %v", err)
+	}
+	var release model.ConfigRelease
+	h.Post("/configs/publish", gin.H{"app_id": app.ID}).AssertOK().Data(&release)
+	err = h.DB.Create(&model.ConfigRelease{AppID: app.ID, Environment: "default", Namespace: "application", Version: release.Version}).Error
+	if !database.IsDuplicateKey(err) {
+		t.Errorf("duplicate release version error = %v", err)
+	}
+}
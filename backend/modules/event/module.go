@@ -45,4 +45,14 @@ func (m *EventModule) RegisterRoutes(group *gin.RouterGroup) {
 	}
 }
 
+// RouteScopes 事件定义按ID修改时从定义记录确定APP；定义列表的 app_id 为可选过滤条件
+func (m *EventModule) RouteScopes() map[string]module.RouteScope {
+	definition := module.RouteScope{Table: "event_definitions"}
+	return map[string]module.RouteScope{
+		"GET /events/definitions":        {Optional: true},
+		"PUT /events/definitions/:id":    definition,
+		"DELETE /events/definitions/:id": definition,
+	}
+}
+
 func (m *EventModule) Init() error { return nil }
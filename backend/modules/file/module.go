@@ -1,6 +1,8 @@
 package file
 
 import (
+	"context"
+
 	"app-platform-backend/core/module"
 	fileapi "app-platform-backend/internal/api/v1/file"
 	"app-platform-backend/internal/middleware"
@@ -12,6 +14,32 @@ import (
 
 func init() { module.Register(&FileModule{}) }
 
+// configSchema 文件存储的模块配置
+var configSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"storage_path": map[string]interface{}{
+			"type":        "string",
+			"minLength":   1,
+			"default":     "./uploads",
+			"description": "文件存储目录",
+		},
+		"max_size": map[string]interface{}{
+			"type":        "integer",
+			"minimum":     1,
+			"default":     10485760,
+			"description": "单个文件最大字节数",
+		},
+		"allowed_types": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string", "minLength": 1},
+			"uniqueItems": true,
+			"default":     []string{"jpg", "jpeg", "png", "gif", "pdf", "doc", "docx", "xls", "xlsx"},
+			"description": "允许上传的文件扩展名",
+		},
+	},
+}
+
 type FileModule struct{}
 
 func (m *FileModule) Meta() module.Meta {
@@ -20,7 +48,7 @@ func (m *FileModule) Meta() module.Meta {
 
 func (m *FileModule) GetFunctions() []module.Function {
 	return []module.Function{
-		{Code: "file_upload", Name: "文件上传", Type: "active", Description: "上传文件"},
+		{Code: "file_upload", Name: "文件上传", Type: "active", Description: "上传文件", ConfigSchema: configSchema},
 		{Code: "file_download", Name: "文件下载", Type: "active", Description: "下载文件"},
 		{Code: "file_list", Name: "文件列表", Type: "passive", Description: "获取文件列表"},
 		{Code: "file_delete", Name: "文件删除", Type: "active", Description: "删除文件"},
@@ -45,3 +73,30 @@ func (m *FileModule) RegisterRoutes(group *gin.RouterGroup) {
 }
 
 func (m *FileModule) Init() error { return nil }
+
+// HealthChecks 上传目录不可写时文件上传不可用，但不影响其他功能
+func (m *FileModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{
+		{
+			Name: "storage_writable",
+			Check: func(ctx context.Context) error {
+				return fileapi.CheckStorageWritable()
+			},
+		},
+	}
+}
+
+// ConfigChecks 检查候选配置中的存储目录可写
+func (m *FileModule) ConfigChecks(appID uint, moduleCode string, config map[string]interface{}) []module.ConfigCheck {
+	dir, _ := config["storage_path"].(string)
+	return []module.ConfigCheck{
+		{
+			Name:        "storage_writable",
+			Description: "存储目录可创建并写入文件",
+			Timeout:     5 * time.Second,
+			Check: func(ctx context.Context) error {
+				return fileapi.CheckDirWritable(dir)
+			},
+		},
+	}
+}
@@ -13,7 +13,8 @@ func init() { module.Register(&MessageModule{}) }
 type MessageModule struct{}
 
 func (m *MessageModule) Meta() module.Meta {
-	return module.Meta{Code: "message_center", Name: "消息中心", Description: "消息中心模块", Icon: "message", SortOrder: 2}
+	return module.Meta{Code: "message_center", Name: "消息中心", Description: "消息中心模块", Icon: "message", SortOrder: 2,
+		Dependencies: []string{"user_management"}}
 }
 
 func (m *MessageModule) GetFunctions() []module.Function {
@@ -28,8 +29,6 @@ func (m *MessageModule) GetFunctions() []module.Function {
 }
 
 func (m *MessageModule) RegisterRoutes(group *gin.RouterGroup) {
-	messageapi.InitDB(database.GetDB())
-
 	g := group.Group("/messages")
 	{
 		g.GET("", messageapi.List)
@@ -46,4 +45,17 @@ func (m *MessageModule) RegisterRoutes(group *gin.RouterGroup) {
 	}
 }
 
-func (m *MessageModule) Init() error { return nil }
+// RouteScopes 消息模板与APP无关
+func (m *MessageModule) RouteScopes() map[string]module.RouteScope {
+	return map[string]module.RouteScope{
+		"GET /messages/templates": {AppFree: true},
+	}
+}
+
+func (m *MessageModule) Init() error {
+	messageapi.InitDB(database.GetDB())
+	// 推送发送和版本发布后写入站内信
+	module.Subscribe("message_center.push_inbox", messageapi.OnPushSent, module.Async())
+	module.Subscribe("message_center.version_notice", messageapi.OnVersionPublished, module.Async())
+	return nil
+}
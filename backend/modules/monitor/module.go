@@ -39,6 +39,7 @@ func (m *MonitorModule) RegisterRoutes(group *gin.RouterGroup) {
 		// 告警管理
 		g.GET("/alerts", monitorapi.Alerts)
 		g.POST("/alerts", monitorapi.CreateAlert)
+		g.GET("/alerts/:id", monitorapi.AlertDetail)
 		g.PUT("/alerts/:id", monitorapi.UpdateAlert)
 		g.DELETE("/alerts/:id", monitorapi.DeleteAlert)
 		g.POST("/alerts/:id/resolve", monitorapi.ResolveAlert)
@@ -47,4 +48,13 @@ func (m *MonitorModule) RegisterRoutes(group *gin.RouterGroup) {
 	}
 }
 
+// RouteScopes 健康检查与APP无关；告警列表的 app_id 为可选过滤条件
+func (m *MonitorModule) RouteScopes() map[string]module.RouteScope {
+	return map[string]module.RouteScope{
+		"GET /monitor/health": {AppFree: true},
+		"GET /monitor/alerts": {Optional: true},
+		"GET /monitor/rules":  {Optional: true},
+	}
+}
+
 func (m *MonitorModule) Init() error { return nil }
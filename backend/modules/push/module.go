@@ -1,25 +1,67 @@
 package push
 
 import (
+	"context"
+	"time"
+
 	"app-platform-backend/core/module"
 	pushapi "app-platform-backend/internal/api/v1/push"
 	"app-platform-backend/internal/pkg/database"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func init() { module.Register(&PushModule{}) }
 
+// configSchema 推送服务的模块配置
+var configSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"provider": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"mock", "apns", "fcm", "hms", "webhook"},
+			"default":     "mock",
+			"description": "推送通道",
+		},
+		"batch_size": map[string]interface{}{
+			"type":        "integer",
+			"minimum":     1,
+			"maximum":     1000,
+			"default":     500,
+			"description": "每批发送的设备数",
+		},
+		"max_retries": map[string]interface{}{
+			"type":        "integer",
+			"minimum":     0,
+			"maximum":     10,
+			"default":     3,
+			"description": "临时失败的最大重试次数",
+		},
+		"webhook_url": map[string]interface{}{
+			"type":        "string",
+			"format":      "uri",
+			"description": "webhook 通道的回调地址",
+		},
+		"credentials": map[string]interface{}{
+			"type":        "object",
+			"secret":      true,
+			"description": "推送通道凭证，apns: key_id/team_id/bundle_id/private_key，fcm: service_account（服务账号密钥 JSON），hms: client_id/client_secret",
+		},
+	},
+}
+
 type PushModule struct{}
 
 func (m *PushModule) Meta() module.Meta {
-	return module.Meta{Code: "push_service", Name: "推送服务", Description: "推送服务模块", Icon: "bell", SortOrder: 3}
+	return module.Meta{Code: "push_service", Name: "推送服务", Description: "推送服务模块", Icon: "bell", SortOrder: 3,
+		Dependencies: []string{"user_management"}}
 }
 
 func (m *PushModule) GetFunctions() []module.Function {
 	return []module.Function{
 		{Code: "push_create", Name: "创建推送", Type: "active", Description: "创建推送任务"},
-		{Code: "push_send", Name: "发送推送", Type: "active", Description: "发送推送通知"},
+		{Code: "push_send", Name: "发送推送", Type: "active", Description: "发送推送通知", ConfigSchema: configSchema},
 		{Code: "push_list", Name: "推送列表", Type: "passive", Description: "推送任务列表"},
 		{Code: "push_stats", Name: "推送统计", Type: "passive", Description: "推送数据统计"},
 		{Code: "push_template", Name: "推送模板", Type: "passive", Description: "管理推送模板"},
@@ -28,14 +70,13 @@ func (m *PushModule) GetFunctions() []module.Function {
 }
 
 func (m *PushModule) RegisterRoutes(group *gin.RouterGroup) {
-	pushapi.InitDB(database.GetDB())
-
 	g := group.Group("/push")
 	{
 		g.GET("", pushapi.List)
 		g.POST("", pushapi.Create)
 		g.GET("/stats", pushapi.Stats)
 		g.GET("/templates", pushapi.Templates)
+		g.GET("/devices", pushapi.Devices)
 		g.GET("/:id", pushapi.Detail)
 		g.POST("/:id/send", pushapi.Send)
 		g.POST("/:id/cancel", pushapi.Cancel)
@@ -45,4 +86,276 @@ func (m *PushModule) RegisterRoutes(group *gin.RouterGroup) {
 	}
 }
 
-func (m *PushModule) Init() error { return nil }
+// RouteScopes 按推送ID访问的接口从推送记录确定APP
+func (m *PushModule) RouteScopes() map[string]module.RouteScope {
+	record := module.RouteScope{Table: "push_records"}
+	return map[string]module.RouteScope{
+		"GET /push/templates":   {AppFree: true},
+		"GET /push/:id":         record,
+		"DELETE /push/:id":      record,
+		"POST /push/:id/send":   record,
+		"POST /push/:id/cancel": record,
+	}
+}
+
+// RegisterSDKRoutes 客户端注册、注销推送设备
+func (m *PushModule) RegisterSDKRoutes(group *gin.RouterGroup) {
+	group.POST("/push/devices", pushapi.RegisterDevice)
+	group.DELETE("/push/devices/:token", pushapi.UnregisterDevice)
+}
+
+func (m *PushModule) Init() error {
+	pushapi.InitDB(database.GetDB())
+	module.Subscribe("push_service.cancel_pending", pushapi.OnAppDeleted, module.Async())
+	return nil
+}
+
+// Start 启动推送分发器，发送接口提交的任务由分发器异步投递
+func (m *PushModule) Start(ctx context.Context) error {
+	pushapi.StartDispatcher(pushapi.DefaultWorkers)
+	return nil
+}
+
+// Stop 等待进行中的推送完成，未开始的推送恢复为待发送
+func (m *PushModule) Stop(ctx context.Context) error {
+	return pushapi.StopDispatcher(ctx)
+}
+
+// Jobs 计划推送分发任务，启用选主时只在一个实例上执行，认领推送时另有数据库条件保证不重复发送
+func (m *PushModule) Jobs() []module.Job {
+	return []module.Job{
+		{
+			Name:        "dispatch_scheduled",
+			Description: "发送到期的计划推送和周期推送",
+			Schedule:    "@every " + pushapi.ScheduleInterval.String(),
+			Timeout:     pushapi.ScheduleInterval,
+			Run:         pushapi.DispatchScheduled,
+		},
+		{
+			Name:        "recover_stuck",
+			Description: "恢复实例崩溃或重启后停留在 sending 状态的推送",
+			Schedule:    "@every 1m",
+			Timeout:     time.Minute,
+			Run:         pushapi.RecoverStuck,
+		},
+	}
+}
+
+// ConfigChecks 校验候选配置中推送通道的凭证
+func (m *PushModule) ConfigChecks(appID uint, moduleCode string, config map[string]interface{}) []module.ConfigCheck {
+	return []module.ConfigCheck{
+		{
+			Name:        "provider_credentials",
+			Description: "推送通道凭证有效",
+			Timeout:     5 * time.Second,
+			Check: func(ctx context.Context) error {
+				return pushapi.VerifyProviderCredentials(ctx, config)
+			},
+		},
+	}
+}
+
+// Migrations 推送记录表结构，原先由 scripts/create_tables.go 手工创建
+func (m *PushModule) Migrations() []module.Migration {
+	return []module.Migration{
+		{
+			Version:     202610170001,
+			Description: "create push_records",
+			Up: func(tx *gorm.DB) error {
+				// 非 MySQL（如测试用的 SQLite）不支持下面的建表语句，按模型建表
+				if tx.Dialector.Name() != "mysql" {
+					return module.CreateTableIfNotExists(tx, &pushRecordV1{})
+				}
+				return tx.Exec(`CREATE TABLE IF NOT EXISTS push_records (
+					id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+					app_id BIGINT UNSIGNED NOT NULL,
+					title VARCHAR(255),
+					content TEXT,
+					target_type VARCHAR(50) DEFAULT 'all',
+					target_ids TEXT,
+					status VARCHAR(50) DEFAULT 'pending',
+					sent_count INT DEFAULT 0,
+					success_count INT DEFAULT 0,
+					failed_count INT DEFAULT 0,
+					scheduled_at DATETIME,
+					sent_at DATETIME,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+					deleted_at DATETIME,
+					INDEX idx_push_records_app_id (app_id),
+					INDEX idx_push_records_status (status),
+					INDEX idx_push_records_created_at (created_at)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("push_records")
+			},
+		},
+		{
+			Version:     202610170002,
+			Description: "add idx_push_records_app_status",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateIndexIfNotExists(tx, "push_records", "idx_push_records_app_status", "app_id, status")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropIndex("push_records", "idx_push_records_app_status")
+			},
+		},
+		{
+			Version:     202610170003,
+			Description: "create push_devices, add push_records.error_message",
+			Up: func(tx *gorm.DB) error {
+				if err := module.CreateTableIfNotExists(tx, &pushDeviceV3{}); err != nil {
+					return err
+				}
+				return module.AddColumnsIfNotExists(tx, &pushRecordV3{}, "ErrorMessage")
+			},
+			Down: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("push_devices"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&pushRecordV3{}, "ErrorMessage")
+			},
+		},
+		{
+			Version:     202610170004,
+			Description: "add push schedule columns, push_devices.timezone",
+			Up: func(tx *gorm.DB) error {
+				m := tx.Migrator()
+				if err := module.AddColumnsIfNotExists(tx, &pushRecordV4{}, pushScheduleFields...); err != nil {
+					return err
+				}
+				for _, field := range []string{"NextRunAt", "ParentID", "Occurrence"} {
+					if !m.HasIndex(&pushRecordV4{}, field) {
+						if err := m.CreateIndex(&pushRecordV4{}, field); err != nil {
+							return err
+						}
+					}
+				}
+				if err := module.AddColumnsIfNotExists(tx, &pushDeviceV4{}, "Timezone"); err != nil {
+					return err
+				}
+				// 之前带计划时间的推送一直停留在 pending：尚未到期或刚过期的交给计划分发器处理，
+				// 过期已久的不再补发，避免升级后一次性推送大量过时内容
+				cutoff := time.Now().Add(-legacyScheduleGrace)
+				if err := tx.Model(&pushRecordV4{}).
+					Where("status = ? AND scheduled_at IS NOT NULL AND scheduled_at >= ?", pushapi.StatusPending, cutoff).
+					Updates(map[string]interface{}{
+						"status":      pushapi.StatusScheduled,
+						"timezone":    "UTC",
+						"next_run_at": gorm.Expr("scheduled_at"),
+					}).Error; err != nil {
+					return err
+				}
+				return tx.Model(&pushRecordV4{}).
+					Where("status = ? AND scheduled_at IS NOT NULL AND scheduled_at < ?", pushapi.StatusPending, cutoff).
+					Updates(map[string]interface{}{
+						"status":        pushapi.StatusFailed,
+						"error_message": "计划时间已过期，升级时未补发",
+					}).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				m := tx.Migrator()
+				if err := m.DropColumn(&pushDeviceV4{}, "Timezone"); err != nil {
+					return err
+				}
+				for _, field := range []string{"NextRunAt", "ParentID", "Occurrence"} {
+					if m.HasIndex(&pushRecordV4{}, field) {
+						if err := m.DropIndex(&pushRecordV4{}, field); err != nil {
+							return err
+						}
+					}
+				}
+				for _, field := range pushScheduleFields {
+					if err := m.DropColumn(&pushRecordV4{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// legacyScheduleGrace 升级时计划时间已过但仍会补发的宽限时间
+const legacyScheduleGrace = 10 * time.Minute
+
+// pushScheduleFields 计划推送新增的 push_records 字段
+var pushScheduleFields = []string{"Timezone", "LocalTime", "Recurrence", "NextRunAt", "ParentID", "Occurrence"}
+
+// pushRecordV1 建表时的 push_records 表结构快照，仅用于非 MySQL 数据库
+type pushRecordV1 struct {
+	ID           uint   `gorm:"primarykey"`
+	AppID        uint   `gorm:"index"`
+	Title        string `gorm:"size:255"`
+	Content      string `gorm:"type:text"`
+	TargetType   string `gorm:"size:50;default:all"`
+	TargetIDs    string `gorm:"type:text"`
+	Status       string `gorm:"size:50;default:pending"`
+	SentCount    int    `gorm:"default:0"`
+	SuccessCount int    `gorm:"default:0"`
+	FailedCount  int    `gorm:"default:0"`
+	ScheduledAt  *time.Time
+	SentAt       *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (pushRecordV1) TableName() string {
+	return "push_records"
+}
+
+// pushDeviceV3 建表时的 push_devices 表结构快照
+type pushDeviceV3 struct {
+	ID         uint   `gorm:"primarykey"`
+	AppID      uint   `gorm:"uniqueIndex:idx_push_devices_app_token,priority:1"`
+	Token      string `gorm:"size:255;uniqueIndex:idx_push_devices_app_token,priority:2"`
+	Platform   string `gorm:"size:20"`
+	UserID     string `gorm:"size:64;index"`
+	Tags       string `gorm:"size:500"`
+	Status     int    `gorm:"default:1"`
+	LastSeenAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (pushDeviceV3) TableName() string {
+	return "push_devices"
+}
+
+// pushRecordV3 push_records 新增的失败原因列
+type pushRecordV3 struct {
+	ErrorMessage string `gorm:"size:500"`
+}
+
+func (pushRecordV3) TableName() string {
+	return "push_records"
+}
+
+// pushRecordV4 计划推送新增的 push_records 列，迁移中的数据更新也按这里的表结构进行
+type pushRecordV4 struct {
+	Status       string `gorm:"size:50;default:pending"`
+	ErrorMessage string `gorm:"size:500"`
+	ScheduledAt  *time.Time
+	Timezone     string     `gorm:"size:64"`
+	LocalTime    bool       `gorm:"default:false"`
+	Recurrence   string     `gorm:"size:100"`
+	NextRunAt    *time.Time `gorm:"index"`
+	ParentID     uint       `gorm:"index"`
+	Occurrence   *string    `gorm:"size:150;uniqueIndex"`
+	DeletedAt    gorm.DeletedAt
+}
+
+func (pushRecordV4) TableName() string {
+	return "push_records"
+}
+
+// pushDeviceV4 push_devices 新增的时区列
+type pushDeviceV4 struct {
+	Timezone string `gorm:"size:64;default:UTC"`
+}
+
+func (pushDeviceV4) TableName() string {
+	return "push_devices"
+}
@@ -0,0 +1,175 @@
+package push
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/core/module/moduletest"
+	pushapi "app-platform-backend/internal/api/v1/push"
+	"app-platform-backend/internal/model"
+	// push_service 依赖 user_management
+	_ "app-platform-backend/modules/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPushModule_SendDelivers(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{
+		Modules: []string{"push_service"},
+		// 发送时会读取模块配置 schema
+		Models: []interface{}{&model.ModuleTemplate{}},
+		Start:  true,
+	})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "push_service")
+
+	devices := []gin.H{
+		{"token": "tok-a", "platform": "ios", "user_id": "u1", "tags": []string{"vip", "beta"}},
+		{"token": "tok-b", "platform": "android", "user_id": "u2"},
+		{"token": "invalid-c", "platform": "android", "user_id": "u3", "tags": []string{"vip"}},
+	}
+	for _, d := range devices {
+		h.SDKDo(app, http.MethodPost, "/push/devices", d, nil).AssertOK()
+	}
+	// 重复注册只更新设备
+	h.SDKDo(app, http.MethodPost, "/push/devices", gin.H{"token": "tok-b", "platform": "android", "user_id": "u2", "tags": []string{"beta"}}, nil).AssertOK()
+	h.SDKDo(app, http.MethodPost, "/push/devices", gin.H{"token": "tok-x", "platform": "web"}, nil).AssertStatus(http.StatusBadRequest)
+
+	var page struct {
+		Total int64 `json:"total"`
+	}
+	h.Get(fmt.Sprintf("/push/devices?app_id=%d", app.ID)).AssertOK().Data(&page)
+	if page.Total != 3 {
+		t.Fatalf("device total = %d, want 3", page.Total)
+	}
+
+	var record model.PushRecord
+	h.Post("/push", gin.H{"app_id": app.ID, "title": "hi", "content": "hello"}).AssertOK().Data(&record)
+
+	var sending model.PushRecord
+	h.Post(fmt.Sprintf("/push/%d/send?app_id=%d", record.ID, app.ID), nil).AssertOK().Data(&sending)
+	if sending.Status != pushapi.StatusSending {
+		t.Fatalf("status after send = %s, want sending", sending.Status)
+	}
+
+	sent := waitForPush(t, h, app, record.ID)
+	if sent.Status != pushapi.StatusSent || sent.SentCount != 3 || sent.SuccessCount != 2 || sent.FailedCount != 1 {
+		t.Fatalf("delivered push = %+v", sent)
+	}
+	// 令牌失效的设备被标记为不可用，不再参与之后的推送
+	var invalid model.PushDevice
+	h.DB.Where("token = ?", "invalid-c").First(&invalid)
+	if invalid.Status != 0 {
+		t.Errorf("invalid device status = %d, want 0", invalid.Status)
+	}
+	h.Post(fmt.Sprintf("/push/%d/send?app_id=%d", record.ID, app.ID), nil).AssertStatus(http.StatusBadRequest)
+
+	// 按标签推送：invalid-c 已失效，只剩 tok-a
+	var tagged model.PushRecord
+	h.Post("/push", gin.H{"app_id": app.ID, "title": "vip", "content": "hello", "target_type": "tag", "target_ids": []string{"vip"}}).AssertOK().Data(&tagged)
+	h.Post(fmt.Sprintf("/push/%d/send?app_id=%d", tagged.ID, app.ID), nil).AssertOK()
+	if got := waitForPush(t, h, app, tagged.ID); got.Status != pushapi.StatusSent || got.SentCount != 1 || got.SuccessCount != 1 {
+		t.Fatalf("tag push = %+v", got)
+	}
+
+	// 注销后没有匹配的设备，推送失败并记录原因
+	h.SDKDo(app, http.MethodDelete, "/push/devices/tok-a", nil, nil).AssertOK()
+	h.SDKDo(app, http.MethodDelete, "/push/devices/tok-a", nil, nil).AssertStatus(http.StatusNotFound)
+	var user model.PushRecord
+	h.Post("/push", gin.H{"app_id": app.ID, "title": "u1", "content": "hello", "target_type": "user", "target_ids": []string{"u1"}}).AssertOK().Data(&user)
+	h.Post(fmt.Sprintf("/push/%d/send?app_id=%d", user.ID, app.ID), nil).AssertOK()
+	if got := waitForPush(t, h, app, user.ID); got.Status != pushapi.StatusFailed || got.ErrorMessage == "" {
+		t.Fatalf("user push = %+v", got)
+	}
+}
+
+// waitForPush 等待推送离开 sending 状态
+func waitForPush(t *testing.T, h *moduletest.Harness, app *model.App, id uint) model.PushRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var record model.PushRecord
+		h.Get(fmt.Sprintf("/push/%d?app_id=%d", id, app.ID)).AssertOK().Data(&record)
+		if record.Status != pushapi.StatusSending {
+			return record
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("push %d is still sending", id)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestPushModule_ScheduledPush(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"push_service"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "push_service")
+
+	h.Post("/push", gin.H{"app_id": app.ID, "title": "hi", "content": "hello", "recurrence": "0 9 * * *", "timezone": "Nowhere/City"}).
+		AssertStatus(http.StatusBadRequest)
+
+	var daily model.PushRecord
+	h.Post("/push", gin.H{"app_id": app.ID, "title": "hi", "content": "hello", "recurrence": "0 9 * * *", "local_time": true}).
+		AssertOK().Data(&daily)
+	if daily.Status != pushapi.StatusScheduled || daily.NextRunAt == nil || !daily.LocalTime {
+		t.Fatalf("created push = %+v", daily)
+	}
+	// 计划推送由分发器发送，不能手动发送
+	h.Post(fmt.Sprintf("/push/%d/send?app_id=%d", daily.ID, app.ID), nil).AssertStatus(http.StatusBadRequest)
+
+	var stats map[string]interface{}
+	h.Get(fmt.Sprintf("/push/stats?app_id=%d", app.ID)).AssertOK().Data(&stats)
+	if stats["scheduled"] != float64(1) {
+		t.Errorf("stats = %v", stats)
+	}
+
+	h.Post(fmt.Sprintf("/push/%d/cancel?app_id=%d", daily.ID, app.ID), nil).AssertOK()
+	var cancelled model.PushRecord
+	h.Get(fmt.Sprintf("/push/%d?app_id=%d", daily.ID, app.ID)).AssertOK().Data(&cancelled)
+	if cancelled.Status != pushapi.StatusCancelled || cancelled.NextRunAt != nil {
+		t.Errorf("cancelled push = %+v", cancelled)
+	}
+}
+
+func TestPushModule_LegacyScheduledMigration(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"push_service"}})
+	app := h.CreateApp("demo")
+
+	future := time.Now().Add(time.Hour)
+	recent := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-24 * time.Hour)
+	records := []*model.PushRecord{
+		{AppID: app.ID, Title: "future", Content: "c", Status: pushapi.StatusPending, ScheduledAt: &future},
+		{AppID: app.ID, Title: "recent", Content: "c", Status: pushapi.StatusPending, ScheduledAt: &recent},
+		{AppID: app.ID, Title: "stale", Content: "c", Status: pushapi.StatusPending, ScheduledAt: &stale},
+	}
+	for _, record := range records {
+		h.Create(record)
+	}
+
+	// 迁移可重复执行，模拟升级前遗留的 pending 记录
+	var upgrade module.Migration
+	for _, m := range (&PushModule{}).Migrations() {
+		if m.Version == 202610170004 {
+			upgrade = m
+		}
+	}
+	if err := upgrade.Up(h.DB); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+
+	want := []string{pushapi.StatusScheduled, pushapi.StatusScheduled, pushapi.StatusFailed}
+	for i, record := range records {
+		var got model.PushRecord
+		h.DB.First(&got, record.ID)
+		if got.Status != want[i] {
+			t.Errorf("%s: status = %s, want %s", got.Title, got.Status, want[i])
+		}
+		if got.Status == pushapi.StatusFailed && got.ErrorMessage == "" {
+			t.Errorf("%s: missing error message", got.Title)
+		}
+	}
+}
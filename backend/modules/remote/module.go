@@ -0,0 +1,270 @@
+// Package remote 提供远程模块支持
+// 远程模块由配置文件声明，不需要编译进本仓库：平台负责认证、挂载路由、同步功能和健康检查，
+// 请求通过反向代理转发给独立部署的上游服务
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/internal/config"
+	"app-platform-backend/internal/middleware"
+	"app-platform-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 转发给上游的身份请求头，客户端传入的同名请求头会被覆盖
+const (
+	HeaderUserID    = "X-Platform-User-ID"
+	HeaderUsername  = "X-Platform-Username"
+	HeaderModule    = "X-Platform-Module"
+	HeaderTimestamp = "X-Platform-Timestamp"
+	HeaderSignature = "X-Platform-Signature"
+)
+
+// 默认值
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultHealthPath = "/health"
+)
+
+// MaxSignedBody 配置了 secret 时请求体需要读入内存计算签名，超出该大小返回 413
+const MaxSignedBody = 32 << 20
+
+var codePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// reservedCodes 与平台自身路由前缀冲突的Code
+// 编译进来的模块的路由前缀不在此列，由 CheckRoutes 按实际注册的路由检查
+var reservedCodes = map[string]bool{
+	"admin": true, "apps": true, "system": true, "system-apis": true,
+	"modules": true, "stats": true, "ws": true, "sdk": true,
+}
+
+// RemoteModule 通过反向代理提供服务的远程模块
+type RemoteModule struct {
+	cfg      config.RemoteModuleConfig
+	upstream *url.URL
+	proxy    *httputil.ReverseProxy
+	client   *http.Client
+}
+
+// RegisterAll 根据配置注册所有远程模块，需在 module.InitAllModules 之前调用
+func RegisterAll(cfgs []config.RemoteModuleConfig) error {
+	for _, cfg := range cfgs {
+		if _, exists := module.Get(cfg.Code); exists {
+			return fmt.Errorf("remote module %s conflicts with a registered module", cfg.Code)
+		}
+		m, err := New(cfg)
+		if err != nil {
+			return err
+		}
+		module.Register(m)
+		log.Printf("[RemoteModule] Registered %s -> %s", cfg.Code, m.upstream)
+	}
+	return nil
+}
+
+// New 校验配置并创建远程模块
+func New(cfg config.RemoteModuleConfig) (*RemoteModule, error) {
+	if !codePattern.MatchString(cfg.Code) || reservedCodes[cfg.Code] {
+		return nil, fmt.Errorf("remote module: invalid code %q", cfg.Code)
+	}
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("remote module %s: name is required", cfg.Code)
+	}
+	upstream, err := url.Parse(cfg.Upstream)
+	if err != nil || (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return nil, fmt.Errorf("remote module %s: invalid upstream %q", cfg.Code, cfg.Upstream)
+	}
+	for _, fn := range cfg.Functions {
+		if fn.Code == "" || fn.Name == "" {
+			return nil, fmt.Errorf("remote module %s: function code and name are required", cfg.Code)
+		}
+	}
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if cfg.HealthPath == "" {
+		cfg.HealthPath = DefaultHealthPath
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+
+	m := &RemoteModule{
+		cfg:      cfg,
+		upstream: upstream,
+		client:   &http.Client{Transport: transport, Timeout: timeout},
+	}
+	m.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.SetXForwarded()
+		},
+		Transport:    transport,
+		ErrorHandler: m.proxyError,
+	}
+	return m, nil
+}
+
+// Meta 返回模块元数据
+func (m *RemoteModule) Meta() module.Meta {
+	return module.Meta{
+		Code:         m.cfg.Code,
+		Name:         m.cfg.Name,
+		Description:  m.cfg.Description,
+		Icon:         m.cfg.Icon,
+		SortOrder:    m.cfg.SortOrder,
+		Dependencies: m.cfg.Dependencies,
+		Global:       m.cfg.Global,
+	}
+}
+
+// GetFunctions 返回配置中声明的功能
+func (m *RemoteModule) GetFunctions() []module.Function {
+	functions := make([]module.Function, 0, len(m.cfg.Functions))
+	for _, fn := range m.cfg.Functions {
+		fnType := fn.Type
+		if fnType == "" {
+			fnType = "active"
+		}
+		functions = append(functions, module.Function{
+			Code:         fn.Code,
+			Name:         fn.Name,
+			Description:  fn.Description,
+			Type:         fnType,
+			ConfigSchema: fn.ConfigSchema,
+			Dependencies: fn.Dependencies,
+			SortOrder:    fn.SortOrder,
+		})
+	}
+	return functions
+}
+
+// RegisterRoutes 将 /<code>/* 转发给上游
+func (m *RemoteModule) RegisterRoutes(group *gin.RouterGroup) {
+	group.Any("/"+m.cfg.Code+"/*path", m.serve)
+}
+
+// CheckRoutes 检查 <basePath>/<code> 前缀下是否已注册了其他路由
+// gin 不允许 /<code>/*path 与同前缀的路由共存，注册时会直接 panic；需在其余路由注册完成后、RegisterRoutes 之前调用
+func (m *RemoteModule) CheckRoutes(routes gin.RoutesInfo, basePath string) error {
+	prefix := strings.TrimSuffix(basePath, "/") + "/" + m.cfg.Code
+	for _, route := range routes {
+		if route.Path == prefix || strings.HasPrefix(route.Path, prefix+"/") {
+			return fmt.Errorf("remote module %s conflicts with route %s %s", m.cfg.Code, route.Method, route.Path)
+		}
+	}
+	return nil
+}
+
+// Init 远程模块无需初始化
+func (m *RemoteModule) Init() error {
+	return nil
+}
+
+// HealthChecks 上游不可用只影响该模块，视为降级
+func (m *RemoteModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{
+		{Name: "upstream", Check: m.ping},
+	}
+}
+
+// serve 去掉 /api/v1/<code> 前缀，替换认证信息后转发
+// 平台的 Authorization 不会透传给上游，上游通过身份请求头和签名确认请求来自平台
+func (m *RemoteModule) serve(c *gin.Context) {
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.Path = c.Param("path")
+	req.URL.RawPath = ""
+
+	userID := strconv.FormatUint(uint64(c.GetUint("user_id")), 10)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Del("Authorization")
+	req.Header.Set(HeaderUserID, userID)
+	req.Header.Set(HeaderUsername, c.GetString("username"))
+	req.Header.Set(HeaderModule, m.cfg.Code)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Del(HeaderSignature)
+	if m.cfg.Secret != "" {
+		var body []byte
+		if req.Body != nil && req.Body != http.NoBody {
+			var err error
+			if body, err = io.ReadAll(http.MaxBytesReader(c.Writer, req.Body, MaxSignedBody)); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					response.PayloadTooLarge(c, "请求体过大")
+				} else {
+					response.ParamError(c, "读取请求体失败")
+				}
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		req.Header.Set(HeaderSignature, Sign(m.cfg.Secret, req.Method, req.URL.Path, req.URL.RawQuery, timestamp, userID, body))
+	}
+
+	m.proxy.ServeHTTP(c.Writer, req)
+}
+
+// proxyError 上游不可达或超时时返回 502
+func (m *RemoteModule) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("[RemoteModule] %s %s%s failed: %v", r.Method, m.cfg.Code, r.URL.Path, err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	json.NewEncoder(w).Encode(gin.H{
+		"code":    502,
+		"message": fmt.Sprintf("远程模块 %s 暂不可用", m.cfg.Code),
+	})
+}
+
+// ping 请求上游健康检查地址，2xx 视为健康
+func (m *RemoteModule) ping(ctx context.Context) error {
+	target := m.upstream.JoinPath(m.cfg.HealthPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("upstream health returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign 计算请求签名，十六进制编码：
+// HMAC-SHA256(secret, method \n path \n canonical_query \n timestamp \n userID \n hex(SHA256(body)))
+// path 为去掉 /api/v1/<code> 前缀、尚未拼接上游基础路径的相对路径；query 为原始查询字符串，
+// 按 middleware.CanonicalQuery 规范化；body 为请求体原文，没有请求体时为空
+// 上游使用相同算法校验，并应拒绝时间戳偏差过大的请求
+func Sign(secret, method, path, query, timestamp, userID string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		method, path, middleware.CanonicalQuery(query), timestamp, userID, hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
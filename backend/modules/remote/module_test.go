@@ -0,0 +1,146 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app-platform-backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recorder gin 的 ResponseWriter 在反向代理时需要 http.CloseNotifier
+type recorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (recorder) CloseNotify() <-chan bool { return make(chan bool) }
+
+func newRecorder() recorder { return recorder{httptest.NewRecorder()} }
+
+func TestNew_Validation(t *testing.T) {
+	invalid := map[string]config.RemoteModuleConfig{
+		"bad code":     {Code: "Coupon", Name: "优惠券", Upstream: "http://svc"},
+		"reserved":     {Code: "system", Name: "系统", Upstream: "http://svc"},
+		"reserved sdk": {Code: "sdk", Name: "SDK", Upstream: "http://svc"},
+		"no name":      {Code: "coupon", Upstream: "http://svc"},
+		"bad upstream": {Code: "coupon", Name: "优惠券", Upstream: "svc:8080"},
+		"bad function": {Code: "coupon", Name: "优惠券", Upstream: "http://svc", Functions: []config.RemoteFunctionConfig{{Code: "coupon_issue"}}},
+	}
+	for name, cfg := range invalid {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRemoteModule_CheckRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1")
+	v1.GET("/files/:id", func(c *gin.Context) {})
+	v1.GET("/files_export", func(c *gin.Context) {})
+
+	// 与编译模块的路由前缀相同时报错，前缀只是字符串相同的不算冲突
+	files, _ := New(config.RemoteModuleConfig{Code: "files", Name: "文件", Upstream: "http://svc"})
+	if err := files.CheckRoutes(router.Routes(), v1.BasePath()); err == nil {
+		t.Error("expected conflict with /api/v1/files/:id")
+	}
+	file, _ := New(config.RemoteModuleConfig{Code: "file", Name: "文件", Upstream: "http://svc"})
+	if err := file.CheckRoutes(router.Routes(), v1.BasePath()); err != nil {
+		t.Errorf("CheckRoutes() error = %v", err)
+	}
+	file.RegisterRoutes(v1)
+}
+
+func TestRemoteModule_Proxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got *http.Request
+	var gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	m, err := New(config.RemoteModuleConfig{
+		Code:     "coupon",
+		Name:     "优惠券",
+		Upstream: upstream.URL + "/api",
+		Secret:   "s3cret",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	r := gin.New()
+	auth := r.Group("/api/v1", func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		c.Set("username", "admin")
+	})
+	m.RegisterRoutes(auth)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/coupon/issue?app_id=1", strings.NewReader(`{"amount":10}`))
+	req.Header.Set("Authorization", "Bearer platform-token")
+	req.Header.Set(HeaderUserID, "1") // 客户端伪造的身份应被覆盖
+	w := newRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || got == nil {
+		t.Fatalf("status = %d, upstream called = %v", w.Code, got != nil)
+	}
+	if got.URL.Path != "/api/issue" || got.URL.RawQuery != "app_id=1" {
+		t.Errorf("upstream url = %s?%s, want /api/issue?app_id=1", got.URL.Path, got.URL.RawQuery)
+	}
+	if got.Header.Get("Authorization") != "" {
+		t.Error("Authorization header leaked to upstream")
+	}
+	if got.Header.Get(HeaderUserID) != "7" || got.Header.Get(HeaderUsername) != "admin" {
+		t.Errorf("identity headers = %s/%s", got.Header.Get(HeaderUserID), got.Header.Get(HeaderUsername))
+	}
+	// 签名覆盖查询参数和请求体，请求体原样转发给上游
+	if gotBody != `{"amount":10}` {
+		t.Errorf("upstream body = %q", gotBody)
+	}
+	want := Sign("s3cret", http.MethodPost, "/issue", "app_id=1", got.Header.Get(HeaderTimestamp), "7", []byte(gotBody))
+	if got.Header.Get(HeaderSignature) != want {
+		t.Errorf("signature = %s, want %s", got.Header.Get(HeaderSignature), want)
+	}
+	if tampered := Sign("s3cret", http.MethodPost, "/issue", "app_id=2", got.Header.Get(HeaderTimestamp), "7", []byte(gotBody)); tampered == want {
+		t.Error("signature should cover the query string")
+	}
+
+	if err := m.ping(context.Background()); err != nil {
+		t.Errorf("ping() error = %v", err)
+	}
+}
+
+func TestRemoteModule_UpstreamDown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close()
+
+	m, err := New(config.RemoteModuleConfig{Code: "coupon", Name: "优惠券", Upstream: upstream.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	r := gin.New()
+	m.RegisterRoutes(r.Group("/api/v1"))
+
+	w := newRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/coupon/list", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", w.Code)
+	}
+	if err := m.ping(context.Background()); err == nil {
+		t.Error("ping() should fail when upstream is down")
+	}
+}
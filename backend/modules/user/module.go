@@ -37,6 +37,16 @@ func (m *UserModule) RegisterRoutes(group *gin.RouterGroup) {
 	group.GET("/users/stats", userapi.Stats)
 }
 
+// RouteScopes 平台用户不属于APP，用户列表的 app_id 为可选参数
+func (m *UserModule) RouteScopes() map[string]module.RouteScope {
+	return map[string]module.RouteScope{
+		"GET /users":            {Optional: true},
+		"GET /users/:id":        {AppFree: true},
+		"PUT /users/:id/status": {AppFree: true},
+		"GET /users/stats":      {AppFree: true},
+	}
+}
+
 func (m *UserModule) Init() error {
 	// 初始化用户API的数据库连接
 	userapi.InitDB(database.GetDB())
@@ -1,11 +1,14 @@
 package version
 
 import (
+	"time"
+
 	"app-platform-backend/core/module"
 	versionapi "app-platform-backend/internal/api/v1/version"
 	"app-platform-backend/internal/pkg/database"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func init() { module.Register(&VersionModule{}) }
@@ -36,6 +39,7 @@ func (m *VersionModule) GetFunctions() []module.Function {
 func (m *VersionModule) RegisterRoutes(group *gin.RouterGroup) {
 	group.GET("/versions", versionapi.List)
 	group.POST("/versions", versionapi.Create)
+	group.GET("/versions/:id", versionapi.Detail)
 	group.PUT("/versions/:id", versionapi.Update)
 	group.DELETE("/versions/:id", versionapi.Delete)
 	group.POST("/versions/:id/publish", versionapi.Publish)
@@ -48,3 +52,69 @@ func (m *VersionModule) Init() error {
 	versionapi.InitDB(database.GetDB())
 	return nil
 }
+
+// Migrations 版本表结构，原先由 scripts/create_tables.go 手工创建
+func (m *VersionModule) Migrations() []module.Migration {
+	return []module.Migration{
+		{
+			Version:     202610170001,
+			Description: "create versions",
+			Up: func(tx *gorm.DB) error {
+				// 非 MySQL（如测试用的 SQLite）不支持下面的建表语句，按模型建表
+				if tx.Dialector.Name() != "mysql" {
+					return module.CreateTableIfNotExists(tx, &versionV1{})
+				}
+				return tx.Exec(`CREATE TABLE IF NOT EXISTS versions (
+					id BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+					app_id BIGINT UNSIGNED NOT NULL,
+					version_name VARCHAR(50) NOT NULL,
+					version_code INT NOT NULL,
+					description TEXT,
+					download_url VARCHAR(500),
+					is_force_update TINYINT DEFAULT 0,
+					status VARCHAR(50) DEFAULT 'draft',
+					published_at DATETIME,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+					deleted_at DATETIME,
+					INDEX idx_versions_app_id (app_id),
+					INDEX idx_versions_version_code (version_code),
+					INDEX idx_versions_created_at (created_at)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci`).Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("versions")
+			},
+		},
+		{
+			Version:     202610170002,
+			Description: "add idx_versions_app_status_code",
+			Up: func(tx *gorm.DB) error {
+				return module.CreateIndexIfNotExists(tx, "versions", "idx_versions_app_status_code", "app_id, status, version_code")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropIndex("versions", "idx_versions_app_status_code")
+			},
+		},
+	}
+}
+
+// versionV1 建表时的 versions 表结构快照，仅用于非 MySQL 数据库
+type versionV1 struct {
+	ID            uint   `gorm:"primarykey"`
+	AppID         uint   `gorm:"index"`
+	VersionName   string `gorm:"size:50"`
+	VersionCode   int    `gorm:"index"`
+	Description   string `gorm:"type:text"`
+	DownloadURL   string `gorm:"size:500"`
+	IsForceUpdate int    `gorm:"default:0"`
+	Status        string `gorm:"size:50;default:draft"`
+	PublishedAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (versionV1) TableName() string {
+	return "versions"
+}
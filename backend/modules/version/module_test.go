@@ -0,0 +1,79 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"app-platform-backend/core/module"
+	"app-platform-backend/core/module/moduletest"
+	"app-platform-backend/internal/model"
+	"app-platform-backend/internal/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVersionModule_CreateAndPublish(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"version_management"}})
+	app := h.CreateApp("demo")
+
+	// 未对APP启用模块时被拦截
+	resp := h.Post("/versions", gin.H{"app_id": app.ID, "version": "1.0.0"})
+	resp.AssertStatus(http.StatusForbidden)
+	if resp.Code() != response.CodeModuleDisabled {
+		t.Fatalf("code = %d, want %d", resp.Code(), response.CodeModuleDisabled)
+	}
+
+	h.EnableModule(app, "version_management")
+	var published []module.VersionPublishedEvent
+	module.Subscribe("test.version_published", func(ctx context.Context, e module.VersionPublishedEvent) error {
+		published = append(published, e)
+		return nil
+	})
+
+	var version model.Version
+	h.Post("/versions", gin.H{"app_id": app.ID, "version": "1.0.0"}).AssertOK().Data(&version)
+	if version.VersionCode != 1 || version.Status != "draft" {
+		t.Fatalf("created version = %+v", version)
+	}
+
+	h.Post(fmt.Sprintf("/versions/%d/publish?app_id=%d", version.ID, app.ID), nil).AssertOK()
+	if len(published) != 1 || published[0].VersionName != "1.0.0" {
+		t.Errorf("published events = %+v", published)
+	}
+
+	h.Token = ""
+	h.Get(fmt.Sprintf("/versions?app_id=%d", app.ID)).AssertStatus(http.StatusUnauthorized)
+}
+
+func TestVersionModule_IfMatch(t *testing.T) {
+	h := moduletest.New(t, moduletest.Options{Modules: []string{"version_management"}})
+	app := h.CreateApp("demo")
+	h.EnableModule(app, "version_management")
+
+	var version model.Version
+	h.Post("/versions", gin.H{"app_id": app.ID, "version": "1.0.0"}).AssertOK().Data(&version)
+	path := fmt.Sprintf("/versions/%d?app_id=%d", version.ID, app.ID)
+
+	tag := h.Get(path).AssertOK().Header.Get("ETag")
+	if tag == "" {
+		t.Fatal("GET did not return ETag")
+	}
+
+	// 另一位管理员先完成了修改
+	updated := h.Put(path, gin.H{"description": "first"}).AssertOK()
+	if updated.Header.Get("ETag") != h.Get(path).Header.Get("ETag") {
+		t.Error("ETag returned by PUT differs from subsequent GET")
+	}
+
+	resp := h.DoWithHeader(http.MethodPut, path, gin.H{"description": "second"}, http.Header{"If-Match": {tag}})
+	resp.AssertStatus(http.StatusPreconditionFailed)
+	var current model.Version
+	resp.Data(&current)
+	if current.Description != "first" {
+		t.Errorf("412 body = %+v, want current state", current)
+	}
+
+	h.DoWithHeader(http.MethodPut, path, gin.H{"description": "second"}, http.Header{"If-Match": {resp.Header.Get("ETag")}}).AssertOK()
+}
@@ -1,7 +1,10 @@
 package websocket
 
 import (
+	"context"
+
 	"app-platform-backend/core/module"
+	wsapi "app-platform-backend/internal/api/v1/websocket"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,7 +14,7 @@ func init() { module.Register(&WebSocketModule{}) }
 type WebSocketModule struct{}
 
 func (m *WebSocketModule) Meta() module.Meta {
-	return module.Meta{Code: "websocket", Name: "WebSocket服务", Description: "实时推送服务模块", Icon: "broadcast", SortOrder: 10}
+	return module.Meta{Code: "websocket", Name: "WebSocket服务", Description: "实时推送服务模块", Icon: "broadcast", SortOrder: 10, Global: true}
 }
 
 func (m *WebSocketModule) GetFunctions() []module.Function {
@@ -27,4 +30,23 @@ func (m *WebSocketModule) RegisterRoutes(group *gin.RouterGroup) {
 	// 原因：WebSocket不支持在连接时发送Authorization头，需要通过URL参数传递token
 }
 
-func (m *WebSocketModule) Init() error { return nil }
+func (m *WebSocketModule) Init() error {
+	module.Subscribe("websocket.alert", wsapi.OnAlertTriggered, module.Async())
+	module.Subscribe("websocket.version_notice", wsapi.OnVersionPublished, module.Async())
+	module.Subscribe("websocket.config_review", wsapi.OnConfigReview, module.Async())
+	return nil
+}
+
+// Stop 关闭WebSocket Hub，断开所有客户端连接
+// WebSocket连接已被劫持，http.Server.Shutdown 不会等待它们，需要在此主动关闭
+func (m *WebSocketModule) Stop(ctx context.Context) error {
+	wsapi.GetHub().Close()
+	return nil
+}
+
+// HealthChecks Hub 分发循环卡死时所有实时推送都会阻塞，只能通过重启恢复
+func (m *WebSocketModule) HealthChecks() []module.HealthCheck {
+	return []module.HealthCheck{
+		{Name: "hub", Liveness: true, Check: wsapi.GetHub().Ping},
+	}
+}